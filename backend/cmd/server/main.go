@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
+	_ "time/tzdata" // facility time zones must resolve even on hosts without a zoneinfo database
 
 	"github.com/Radi03825/PlaySpot/internal/handler"
 	http2 "github.com/Radi03825/PlaySpot/internal/http"
+	"github.com/Radi03825/PlaySpot/internal/jobs"
+	"github.com/Radi03825/PlaySpot/internal/model"
 
 	"github.com/Radi03825/PlaySpot/internal/repository"
 	"github.com/Radi03825/PlaySpot/internal/service"
+	"github.com/Radi03825/PlaySpot/internal/service/calendar"
+	"github.com/Radi03825/PlaySpot/internal/service/gateway"
 	"github.com/Radi03825/PlaySpot/internal/service/storage"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
@@ -41,8 +52,16 @@ func main() {
 	reservationRepo := repository.NewReservationRepository(db)
 	imageRepo := repository.NewImageRepository(db)
 	paymentRepo := repository.NewPaymentRepository(db)
+	paymentWebhookRepo := repository.NewPaymentWebhookRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
 	eventRepo := repository.NewEventRepository(db)
 	reviewRepo := repository.NewReviewRepository(db)
+	scheduleExceptionRepo := repository.NewScheduleExceptionRepository(db)
+	cancellationPolicyRepo := repository.NewCancellationPolicyRepository(db)
+	waitlistRepo := repository.NewWaitlistRepository(db)
+	calendarSyncRepo := repository.NewCalendarSyncRepository(db)
+	refundRepo := repository.NewRefundRepository(db)
+	paymentShareRepo := repository.NewPaymentShareRepository(db)
 
 	// Create email service
 	emailService := service.NewEmailService()
@@ -53,13 +72,12 @@ func main() {
 	// Create Image service
 	imageService := service.NewImageService(imageStorage, imageRepo)
 
-	// Create Google Calendar service
-	googleClientID := os.Getenv("GOOGLE_CLIENT_ID")
+	// Create the Google provider, which also verifies Google sign-ins and connects users' calendars
 	googleClientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
 	if googleClientSecret == "" {
 		fmt.Println("Warning: GOOGLE_CLIENT_SECRET not set, Google Calendar integration will be limited")
 	}
-	googleCalendarService := service.NewGoogleCalendarService(googleClientID, googleClientSecret)
+	googleOAuth := calendar.NewGoogleProvider(os.Getenv("GOOGLE_CLIENT_ID"), googleClientSecret)
 
 	// Create token service
 	tokenService := service.NewTokenService(tokenRepo, userRepo)
@@ -76,24 +94,153 @@ func main() {
 	// Set the sport complex service on facility service
 	facilityService.SetSportComplexService(sportComplexService)
 
-	// Create reservation service (needs userService, facilityService, and googleCalendarService)
-	reservationService := service.NewReservationService(reservationRepo, userService, facilityService, googleCalendarService)
+	// Create calendar providers. CALENDAR_PROVIDER=memory replaces Google Calendar with an offline stand-in
+	// that keeps events in memory, or in the JSON file CALENDAR_MEMORY_FILE when set.
+	var googleProvider calendar.CalendarProvider = googleOAuth
+	if os.Getenv("CALENDAR_PROVIDER") == "memory" {
+		memoryProvider, err := calendar.NewMemoryProvider(os.Getenv("CALENDAR_MEMORY_FILE"))
+		if err != nil {
+			panic("Failed to create memory calendar: " + err.Error())
+		}
+		googleProvider = memoryProvider
+	}
+	calendarProviders := map[string]calendar.CalendarProvider{
+		model.CalendarProviderGoogle: googleProvider,
+		model.CalendarProviderCalDAV: calendar.NewCalDAVProvider(),
+	}
+
+	// CalDAV passwords are stored encrypted with CALDAV_ENCRYPTION_KEY; without it CalDAV calendars are disabled
+	var calendarSecrets *calendar.SecretBox
+	if key := os.Getenv("CALDAV_ENCRYPTION_KEY"); key != "" {
+		var err error
+		calendarSecrets, err = calendar.NewSecretBox(key)
+		if err != nil {
+			panic("Failed to create CalDAV password encryption: " + err.Error())
+		}
+	} else {
+		fmt.Println("Warning: CALDAV_ENCRYPTION_KEY not set, CalDAV calendar sync is disabled")
+	}
+
+	// Create calendar sync service (queues calendar changes and applies them with retries)
+	calendarSyncService := service.NewCalendarSyncService(calendarSyncRepo, reservationRepo, userService, facilityService, calendarProviders, calendarSecrets)
+
+	// Create payment gateway. PAYMENT_GATEWAY=stripe charges cards through Stripe; otherwise a simulated
+	// gateway approves, declines or defers payments according to PAYMENT_SIMULATOR_MODE.
+	var paymentGateway gateway.PaymentGateway
+	if os.Getenv("PAYMENT_GATEWAY") == "stripe" {
+		paymentGateway = gateway.NewStripeGateway(os.Getenv("STRIPE_SECRET_KEY"), os.Getenv("STRIPE_WEBHOOK_SECRET"))
+	} else {
+		simulatedGateway, err := gateway.NewSimulatedGateway(os.Getenv("PAYMENT_SIMULATOR_MODE"), os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+		if err != nil {
+			panic("Failed to create simulated payment gateway: " + err.Error())
+		}
+		paymentGateway = simulatedGateway
+	}
+
+	// Create refund service (returns money through the payment gateway)
+	refundService := service.NewRefundService(refundRepo, paymentRepo, paymentShareRepo, reservationRepo, facilityService, userService, emailService, paymentGateway)
+
+	// Create reservation service (needs userService, facilityService, calendarSyncService, and refundService)
+	reservationService := service.NewReservationService(reservationRepo, userService, facilityService, calendarSyncService, emailService, refundService)
 
 	// Create payment service
-	paymentService := service.NewPaymentService(paymentRepo, reservationRepo, facilityService, emailService, googleCalendarService, userService)
+	paymentService := service.NewPaymentService(paymentRepo, paymentWebhookRepo, reservationRepo, facilityService, emailService, calendarSyncService, userService, refundService, paymentGateway)
 
 	// Create event service
-	eventService := service.NewEventService(eventRepo)
+	eventService := service.NewEventService(eventRepo, paymentShareRepo)
+
+	// Create split payment service (event participants paying shares of the event's booking)
+	splitPaymentService := service.NewSplitPaymentService(paymentShareRepo, eventRepo, paymentRepo, reservationRepo, paymentService, userService, facilityService, emailService, paymentGateway)
+	paymentService.SetSplitPaymentService(splitPaymentService)
 
 	// Create review service
 	reviewService := service.NewReviewService(reviewRepo)
 
-	//// Create and start reminder service
-	//reminderService := service.NewReminderService(reservationRepo, userService, facilityService, sportComplexService, emailService)
-	//reminderService.Start()
+	// Create schedule exception service (closures, special hours and special prices)
+	scheduleExceptionService := service.NewScheduleExceptionService(scheduleExceptionRepo, facilityService, sportComplexService)
+
+	// Create cancellation policy service (refund tiers per facility or sport complex)
+	cancellationPolicyService := service.NewCancellationPolicyService(cancellationPolicyRepo, facilityService, sportComplexService)
+
+	// Create waitlist service; cancellations offer freed slots to the next user in line
+	waitlistService := service.NewWaitlistService(waitlistRepo, reservationRepo, reservationService, userService, facilityService, emailService)
+	reservationService.SetWaitlistService(waitlistService)
+
+	// Create calendar feed service (secret-URL iCalendar feeds of bookings and events)
+	calendarFeedService := service.NewCalendarFeedService(userRepo, reservationRepo, eventRepo, facilityService)
+
+	// Create background job runner
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	jobRunner := jobs.NewRunner(logger)
+	jobRunner.Register(jobs.Job{
+		Name:     "expire-pending-reservations",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) (int64, error) {
+			return reservationService.ExpirePendingReservations()
+		},
+	})
+	jobRunner.Register(jobs.Job{
+		Name:     "process-waitlist",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) (int64, error) {
+			return waitlistService.ProcessWaitlist()
+		},
+	})
+	jobRunner.Register(jobs.Job{
+		Name:     "sync-google-calendar",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) (int64, error) {
+			return calendarSyncService.ProcessQueue()
+		},
+	})
+	jobRunner.Register(jobs.Job{
+		Name:     "delete-expired-idempotency-keys",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (int64, error) {
+			return idempotencyRepo.DeleteExpiredKeys()
+		},
+	})
+	jobRunner.Register(jobs.Job{
+		Name:     "complete-finished-reservations",
+		Interval: 5 * time.Minute,
+		Run: func(ctx context.Context) (int64, error) {
+			return reservationService.CompleteFinishedReservations()
+		},
+	})
+	jobRunner.Register(jobs.Job{
+		Name:     "complete-finished-events",
+		Interval: 5 * time.Minute,
+		Run: func(ctx context.Context) (int64, error) {
+			return eventService.CompleteFinishedEvents()
+		},
+	})
+
+	// Create reminder service and send due reminders every minute
+	reminderService := service.NewReminderService(reservationRepo, userService, facilityService, sportComplexService, emailService)
+	jobRunner.Register(jobs.Job{
+		Name:     "send-reservation-reminders",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) (int64, error) {
+			return reminderService.SendDueReminders()
+		},
+	})
+
+	jobRunner.Register(jobs.Job{
+		Name:     "send-split-payment-reminders",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (int64, error) {
+			return splitPaymentService.SendShareReminders()
+		},
+	})
+
+	// Stop background jobs and the HTTP server on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobRunner.Start(ctx)
 
 	// Create handlers
-	userHandler := handler.NewUserHandler(userService, tokenService, googleCalendarService)
+	userHandler := handler.NewUserHandler(userService, tokenService, googleOAuth)
 	sportComplexHandler := handler.NewSportComplexHandler(sportComplexService)
 	facilityHandler := handler.NewFacilityHandler(facilityService, metadataRepo)
 	reservationHandler := handler.NewReservationHandler(reservationService)
@@ -101,8 +248,14 @@ func main() {
 	paymentHandler := handler.NewPaymentHandler(paymentService)
 	eventHandler := handler.NewEventHandler(eventService)
 	reviewHandler := handler.NewReviewHandler(reviewService)
+	scheduleExceptionHandler := handler.NewScheduleExceptionHandler(scheduleExceptionService)
+	cancellationPolicyHandler := handler.NewCancellationPolicyHandler(cancellationPolicyService)
+	waitlistHandler := handler.NewWaitlistHandler(waitlistService)
+	calendarHandler := handler.NewCalendarHandler(calendarFeedService, calendarSyncService)
+	refundHandler := handler.NewRefundHandler(refundService)
+	splitPaymentHandler := handler.NewSplitPaymentHandler(splitPaymentService)
 
-	router := http2.NewRouter(userHandler, facilityHandler, sportComplexHandler, reservationHandler, imageHandler, paymentHandler, eventHandler, reviewHandler)
+	router := http2.NewRouter(userHandler, facilityHandler, sportComplexHandler, reservationHandler, imageHandler, paymentHandler, eventHandler, reviewHandler, scheduleExceptionHandler, cancellationPolicyHandler, waitlistHandler, calendarHandler, refundHandler, splitPaymentHandler, idempotencyRepo)
 
 	frontendURL := os.Getenv("FRONTEND_URL")
 	if frontendURL == "" {
@@ -112,7 +265,7 @@ func main() {
 	handlerr := cors.New(cors.Options{
 		AllowedOrigins:   []string{frontendURL},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "Idempotency-Key"},
 		AllowCredentials: true,
 	}).Handler(router)
 
@@ -121,12 +274,37 @@ func main() {
 		port = "8081"
 	}
 
-	fmt.Printf("Server starting on :%s\n", port)
-	fmt.Printf("Allowing CORS from: %s\n", frontendURL)
-	err = http.ListenAndServe(":"+port, handlerr)
-	if err != nil {
-		fmt.Printf("Server error: %v\n", err)
-		panic(err)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: handlerr,
+	}
+
+	go func() {
+		fmt.Printf("Server starting on :%s\n", port)
+		fmt.Printf("Allowing CORS from: %s\n", frontendURL)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Server error: %v\n", err)
+			panic(err)
+		}
+	}()
+
+	<-ctx.Done()
+	fmt.Println("Shutting down...")
+
+	// Give in-flight requests and running jobs time to finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("Server shutdown error: %v\n", err)
+	}
+
+	jobRunner.Stop()
+
+	if err := db.Close(); err != nil {
+		fmt.Printf("Failed to close database: %v\n", err)
 	}
 
+	fmt.Println("Server stopped")
 }
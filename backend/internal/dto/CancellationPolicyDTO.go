@@ -0,0 +1,11 @@
+package dto
+
+type CancellationRuleDTO struct {
+	HoursBefore   int     `json:"hours_before"`   // Minimum notice before the booking starts
+	RefundPercent float64 `json:"refund_percent"` // 0-100
+}
+
+// CancellationPolicyDTO replaces all refund tiers of a facility or sport complex; an empty list removes the policy
+type CancellationPolicyDTO struct {
+	Rules []CancellationRuleDTO `json:"rules"`
+}
@@ -0,0 +1,20 @@
+package dto
+
+import "github.com/Radi03825/PlaySpot/internal/model"
+
+type CheckoutItemDTO struct {
+	FacilityID int64  `json:"facility_id"`
+	StartTime  string `json:"start_time"`      // RFC3339 format
+	EndTime    string `json:"end_time"`        // RFC3339 format
+	Units      int    `json:"units,omitempty"` // Units of a shared facility to book, defaults to 1
+}
+
+type CheckoutDTO struct {
+	Items []CheckoutItemDTO `json:"items"`
+}
+
+type CheckoutResultDTO struct {
+	Reservations []model.FacilityReservation `json:"reservations"`
+	Payment      *model.Payment              `json:"payment"` // One pending payment covering every reservation
+	TotalPrice   float64                     `json:"total_price"`
+}
@@ -0,0 +1,7 @@
+package dto
+
+type ConnectCalDAVDTO struct {
+	CalendarURL string `json:"calendar_url"` // Calendar collection, e.g. https://cloud.example.com/remote.php/dav/calendars/alice/personal/
+	Username    string `json:"username"`
+	Password    string `json:"password"` // Preferably an app password
+}
@@ -1,20 +1,33 @@
 package dto
 
-// WorkingHoursDTO represents the working hours for a day type
+// WorkingHoursDTO represents the working hours for a day of the week
+// ("weekday" and "weekend" are accepted as shorthands for Mon-Fri and Sat-Sun)
 type WorkingHoursDTO struct {
-	DayType   string `json:"day_type" binding:"required,oneof=weekday weekend"`
+	DayType   string `json:"day_type" binding:"required,oneof=monday tuesday wednesday thursday friday saturday sunday weekday weekend"`
 	OpenTime  string `json:"open_time" binding:"required"`  // HH:MM format
 	CloseTime string `json:"close_time" binding:"required"` // HH:MM format
 }
 
 // PricingSlotDTO represents a pricing interval
 type PricingSlotDTO struct {
-	DayType      string  `json:"day_type" binding:"required,oneof=weekday weekend"`
+	DayType      string  `json:"day_type" binding:"required,oneof=monday tuesday wednesday thursday friday saturday sunday weekday weekend"`
 	StartHour    string  `json:"start_hour" binding:"required"` // HH:MM format
 	EndHour      string  `json:"end_hour" binding:"required"`   // HH:MM format
 	PricePerHour float64 `json:"price_per_hour" binding:"required,gt=0"`
 }
 
+// BookingPolicyDTO represents slot granularity, duration limits, reschedule rules and availability mode for a facility (all durations in minutes)
+type BookingPolicyDTO struct {
+	SlotMinutes             int    `json:"slot_minutes"`
+	MinDurationMinutes      int    `json:"min_duration_minutes"`
+	MaxDurationMinutes      *int   `json:"max_duration_minutes,omitempty"`
+	BufferMinutes           int    `json:"buffer_minutes"`
+	AllowReschedule         *bool  `json:"allow_reschedule,omitempty"` // Defaults to true
+	RescheduleCutoffMinutes int    `json:"reschedule_cutoff_minutes"`
+	MaxReschedules          *int   `json:"max_reschedules,omitempty"`
+	AvailabilityMode        string `json:"availability_mode,omitempty"` // "exclusive" (default) or "shared"
+}
+
 type CreateFacilityDTO struct {
 	Name           string            `json:"name"`
 	SportComplexID *int64            `json:"sport_complex_id"`
@@ -25,7 +38,9 @@ type CreateFacilityDTO struct {
 	Address        string            `json:"address"`
 	Description    string            `json:"description"`
 	Capacity       int               `json:"capacity"`
+	TimeZone       string            `json:"time_zone,omitempty"` // IANA zone, e.g. "Europe/Sofia"; defaults to the sport complex's zone
 	ImageURLs      []string          `json:"image_urls,omitempty"`
 	WorkingHours   []WorkingHoursDTO `json:"working_hours,omitempty"`
 	Pricing        []PricingSlotDTO  `json:"pricing,omitempty"`
+	BookingPolicy  *BookingPolicyDTO `json:"booking_policy,omitempty"`
 }
@@ -0,0 +1,32 @@
+package dto
+
+import "github.com/Radi03825/PlaySpot/internal/model"
+
+type CreateRecurringReservationDTO struct {
+	FacilityID    int64   `json:"facility_id"`
+	StartTime     string  `json:"start_time"`           // First occurrence, RFC3339 format
+	EndTime       string  `json:"end_time"`             // First occurrence, RFC3339 format
+	Frequency     string  `json:"frequency"`            // 'weekly' or 'biweekly'
+	UntilDate     *string `json:"until_date,omitempty"` // YYYY-MM-DD, last date an occurrence may fall on
+	Count         *int    `json:"count,omitempty"`      // Number of occurrences, used instead of until_date
+	SkipConflicts bool    `json:"skip_conflicts"`       // Book the available dates instead of rejecting the whole series
+	Units         int     `json:"units,omitempty"`      // Units of a shared facility to book, defaults to 1
+}
+
+type RecurringReservationResultDTO struct {
+	Series       *model.ReservationSeries `json:"series,omitempty"`
+	Created      bool                     `json:"created"`
+	CreatedCount int                      `json:"created_count"`
+	SkippedCount int                      `json:"skipped_count"`
+	TotalPrice   float64                  `json:"total_price"`
+	Occurrences  []model.SeriesOccurrence `json:"occurrences"`
+}
+
+type CancelSeriesDTO struct {
+	From *string `json:"from,omitempty"` // RFC3339, cancel occurrences starting at or after this time; defaults to now
+}
+
+type ReservationSeriesDetailsDTO struct {
+	Series       *model.ReservationSeries    `json:"series"`
+	Reservations []model.FacilityReservation `json:"reservations"`
+}
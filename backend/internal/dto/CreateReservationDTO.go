@@ -4,4 +4,5 @@ type CreateReservationDTO struct {
 	FacilityID int64  `json:"facility_id"`
 	StartTime  string `json:"start_time"`
 	EndTime    string `json:"end_time"`
+	Units      int    `json:"units,omitempty"` // Units of a shared facility to book, defaults to 1
 }
@@ -0,0 +1,11 @@
+package dto
+
+type CreateScheduleExceptionDTO struct {
+	Date         string   `json:"date"`                 // YYYY-MM-DD format
+	EndDate      *string  `json:"end_date,omitempty"`   // Optional, repeats the exception for every day through this date
+	Type         string   `json:"type"`                 // 'closed', 'special_hours', 'special_price'
+	StartTime    *string  `json:"start_time,omitempty"` // HH:MM format
+	EndTime      *string  `json:"end_time,omitempty"`   // HH:MM format
+	PricePerHour *float64 `json:"price_per_hour,omitempty"`
+	Reason       *string  `json:"reason,omitempty"`
+}
@@ -5,6 +5,7 @@ type CreateSportComplexDTO struct {
 	Address     string                       `json:"address"`
 	City        string                       `json:"city"`
 	Description string                       `json:"description"`
+	TimeZone    string                       `json:"time_zone,omitempty"` // IANA zone, e.g. "Europe/Sofia"
 	Facilities  []CreateFacilityInComplexDTO `json:"facilities,omitempty"`
 	ImageURLs   []string                     `json:"image_urls,omitempty"`
 }
@@ -19,4 +20,5 @@ type CreateFacilityInComplexDTO struct {
 	ImageURLs     []string          `json:"image_urls,omitempty"`
 	WorkingHours  []WorkingHoursDTO `json:"working_hours,omitempty"`
 	Pricing       []PricingSlotDTO  `json:"pricing,omitempty"`
+	BookingPolicy *BookingPolicyDTO `json:"booking_policy,omitempty"`
 }
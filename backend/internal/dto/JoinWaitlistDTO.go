@@ -0,0 +1,8 @@
+package dto
+
+type JoinWaitlistDTO struct {
+	FacilityID int64  `json:"facility_id"`
+	StartTime  string `json:"start_time"`      // RFC3339 format
+	EndTime    string `json:"end_time"`        // RFC3339 format
+	Units      int    `json:"units,omitempty"` // Units of a shared facility wanted, defaults to 1
+}
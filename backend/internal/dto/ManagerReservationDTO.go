@@ -0,0 +1,31 @@
+package dto
+
+import "github.com/Radi03825/PlaySpot/internal/model"
+
+// ManagerCancelReservationDTO is the payload for a facility manager cancelling a customer's booking
+type ManagerCancelReservationDTO struct {
+	Reason string `json:"reason"` // Shown to the customer in the cancellation email
+}
+
+type NoShowResultDTO struct {
+	Reservation     *model.FacilityReservation `json:"reservation"`
+	UserNoShowCount int                        `json:"user_no_show_count"`
+}
+
+// CreateManagerBookingDTO is the payload for staff booking a walk-in or phone customer.
+// The customer is either an existing account, by email, or a guest with a name and phone number.
+type CreateManagerBookingDTO struct {
+	FacilityID    int64  `json:"facility_id"`
+	StartTime     string `json:"start_time"`      // RFC3339 format
+	EndTime       string `json:"end_time"`        // RFC3339 format
+	Units         int    `json:"units,omitempty"` // Units of a shared facility to book, defaults to 1
+	CustomerEmail string `json:"customer_email,omitempty"`
+	GuestName     string `json:"guest_name,omitempty"`
+	GuestPhone    string `json:"guest_phone,omitempty"`
+	Paid          bool   `json:"paid"` // Paid on site when booking
+}
+
+type ManagerBookingResultDTO struct {
+	Reservation *model.FacilityReservation `json:"reservation"`
+	Payment     *model.Payment             `json:"payment"`
+}
@@ -1,5 +1,11 @@
 package dto
 
 type ProcessPaymentDTO struct {
-	PaymentMethod string `json:"payment_method"` // 'on_place' or 'card'
+	PaymentMethod string `json:"payment_method"`          // 'on_place' or 'card'
+	PaymentToken  string `json:"payment_token,omitempty"` // Card payment method from the gateway's client library, e.g. a Stripe PaymentMethod ID
+}
+
+// PayBalanceDTO is the payload for paying by card the balance left after a booking moved to a dearer time
+type PayBalanceDTO struct {
+	PaymentToken string `json:"payment_token"`
 }
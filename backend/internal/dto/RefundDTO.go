@@ -0,0 +1,16 @@
+package dto
+
+import "github.com/Radi03825/PlaySpot/internal/model"
+
+// CreateRefundDTO is the payload for staff refunding a paid reservation
+type CreateRefundDTO struct {
+	Amount float64 `json:"amount,omitempty"` // Omitted or 0 refunds everything left
+	Reason string  `json:"reason"`           // Shown to the customer in the refund email
+}
+
+// PaymentDetailsDTO is a payment with its refund history
+type PaymentDetailsDTO struct {
+	*model.Payment
+	Refunds   []model.Refund `json:"refunds"`
+	NetAmount float64        `json:"net_amount"` // Paid minus refunded; 0 while unpaid
+}
@@ -0,0 +1,16 @@
+package dto
+
+import "github.com/Radi03825/PlaySpot/internal/model"
+
+type RescheduleReservationDTO struct {
+	StartTime string `json:"start_time"` // RFC3339 format
+	EndTime   string `json:"end_time"`   // RFC3339 format
+}
+
+type RescheduleReservationResultDTO struct {
+	Reservation     *model.FacilityReservation `json:"reservation"`
+	PreviousPrice   float64                    `json:"previous_price"`
+	NewPrice        float64                    `json:"new_price"`
+	PriceDifference float64                    `json:"price_difference"`  // Positive when the new time costs more
+	Payment         *model.Payment             `json:"payment,omitempty"` // Its balance_due is left to pay; a cheaper time's difference is refunded
+}
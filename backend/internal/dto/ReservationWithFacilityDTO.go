@@ -10,11 +10,20 @@ type ReservationWithFacilityDTO struct {
 	EndTime    time.Time `json:"end_time"`
 	Status     string    `json:"status"`
 	TotalPrice float64   `json:"total_price"`
+	Units      int       `json:"units"`
 	CreatedAt  time.Time `json:"created_at"`
 
+	CancellationReason *string `json:"cancellation_reason,omitempty"`
+	RescheduleCount    int     `json:"reschedule_count"`
+
 	// User details
-	UserName  string `json:"user_name,omitempty"`
-	UserEmail string `json:"user_email,omitempty"`
+	UserName        string `json:"user_name,omitempty"`
+	UserEmail       string `json:"user_email,omitempty"`
+	UserNoShowCount int    `json:"user_no_show_count,omitempty"` // Bookings the user missed across all facilities
+
+	// Set for bookings made by staff; guest bookings have no user account
+	GuestPhone *string `json:"guest_phone,omitempty"`
+	CreatedBy  *int64  `json:"created_by,omitempty"`
 
 	// Facility details
 	FacilityName    string  `json:"facility_name"`
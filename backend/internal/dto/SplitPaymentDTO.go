@@ -0,0 +1,23 @@
+package dto
+
+import "github.com/Radi03825/PlaySpot/internal/model"
+
+// SplitPaymentRequestDTO is the payload for an event organizer splitting the cost of the event's booking
+type SplitPaymentRequestDTO struct {
+	IncludeOrganizer *bool `json:"include_organizer,omitempty"` // Whether the organizer pays a share too; defaults to true
+}
+
+// PayShareDTO is the payload for a participant paying their share by card
+type PayShareDTO struct {
+	PaymentToken string `json:"payment_token"`
+}
+
+// SplitPaymentDTO is the state of a booking's cost split among event participants
+type SplitPaymentDTO struct {
+	EventID       int64                `json:"event_id"`
+	ReservationID int64                `json:"reservation_id"`
+	Payment       *model.Payment       `json:"payment"`
+	Shares        []model.PaymentShare `json:"shares"`
+	Collected     float64              `json:"collected"` // Paid through shares
+	Remaining     float64              `json:"remaining"` // Still to be paid; 0 once the booking is paid
+}
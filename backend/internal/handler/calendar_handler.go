@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/middleware"
+	"github.com/Radi03825/PlaySpot/internal/service"
+	"github.com/gorilla/mux"
+)
+
+type CalendarHandler struct {
+	service     *service.CalendarFeedService
+	syncService *service.CalendarSyncService
+}
+
+func NewCalendarHandler(service *service.CalendarFeedService, syncService *service.CalendarSyncService) *CalendarHandler {
+	return &CalendarHandler{service: service, syncService: syncService}
+}
+
+// GetFeed handles GET /api/calendar-feed, returning the user's secret feed URL
+func (h *CalendarHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeCalendarError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	token, err := h.service.GetFeedToken(claims.UserID)
+	if err != nil {
+		writeCalendarError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedURLs(r, token))
+}
+
+// RotateFeed handles POST /api/calendar-feed/rotate, replacing the feed URL so old links stop working
+func (h *CalendarHandler) RotateFeed(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeCalendarError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	token, err := h.service.RotateFeedToken(claims.UserID)
+	if err != nil {
+		writeCalendarError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedURLs(r, token))
+}
+
+// UserFeed handles GET /api/calendar/{token}.ics; the token in the URL is the only credential
+func (h *CalendarHandler) UserFeed(w http.ResponseWriter, r *http.Request) {
+	feed, err := h.service.UserFeed(mux.Vars(r)["token"])
+	if err != nil {
+		writeCalendarFeedError(w, err)
+		return
+	}
+
+	writeCalendar(w, "playspot.ics", feed)
+}
+
+// FacilityFeed handles GET /api/calendar/{token}/facilities/{id}.ics for the facility's manager
+func (h *CalendarHandler) FacilityFeed(w http.ResponseWriter, r *http.Request) {
+	facilityID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeCalendarError(w, http.StatusBadRequest, "Invalid facility ID")
+		return
+	}
+
+	feed, err := h.service.FacilityFeed(mux.Vars(r)["token"], facilityID)
+	if err != nil {
+		writeCalendarFeedError(w, err)
+		return
+	}
+
+	writeCalendar(w, fmt.Sprintf("facility-%d.ics", facilityID), feed)
+}
+
+// GetSyncStatus handles GET /api/reservations/{id}/calendar-sync, reporting the calendar sync state
+func (h *CalendarHandler) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeCalendarError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	reservationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeCalendarError(w, http.StatusBadRequest, "Invalid reservation ID")
+		return
+	}
+
+	status, err := h.syncService.GetSyncStatus(reservationID, claims.UserID)
+	if err != nil {
+		writeCalendarError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetCalDAV handles GET /api/calendar-connections/caldav
+func (h *CalendarHandler) GetCalDAV(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeCalendarError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	account, err := h.syncService.GetCalDAVAccount(claims.UserID)
+	if err != nil {
+		writeCalendarError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if account == nil {
+		writeCalendarError(w, http.StatusNotFound, "No CalDAV calendar connected")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// ConnectCalDAV handles PUT /api/calendar-connections/caldav
+func (h *CalendarHandler) ConnectCalDAV(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeCalendarError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dto.ConnectCalDAVDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeCalendarError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	account, err := h.syncService.ConnectCalDAV(claims.UserID, req)
+	if err != nil {
+		writeCalendarError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// DisconnectCalDAV handles DELETE /api/calendar-connections/caldav
+func (h *CalendarHandler) DisconnectCalDAV(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeCalendarError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.syncService.DisconnectCalDAV(claims.UserID); err != nil {
+		writeCalendarError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// feedURLs builds the feed URLs served by this API for a feed token
+func feedURLs(r *http.Request, token string) map[string]string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	base := fmt.Sprintf("%s://%s/api/calendar/%s", scheme, r.Host, token)
+
+	return map[string]string{
+		"url":                   base + ".ics",
+		"facility_url_template": base + "/facilities/{facility_id}.ics",
+	}
+}
+
+func writeCalendar(w http.ResponseWriter, filename string, feed []byte) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write(feed)
+}
+
+func writeCalendarFeedError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCalendarFeedNotFound):
+		writeCalendarError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrNotManager):
+		writeCalendarError(w, http.StatusForbidden, err.Error())
+	default:
+		writeCalendarError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeCalendarError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/middleware"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/service"
+	"github.com/gorilla/mux"
+)
+
+type CancellationPolicyHandler struct {
+	service *service.CancellationPolicyService
+}
+
+func NewCancellationPolicyHandler(service *service.CancellationPolicyService) *CancellationPolicyHandler {
+	return &CancellationPolicyHandler{service: service}
+}
+
+// GetFacilityPolicy handles GET /api/facilities/{id}/cancellation-policy
+func (h *CancellationPolicyHandler) GetFacilityPolicy(w http.ResponseWriter, r *http.Request) {
+	h.getPolicy(w, r, "Invalid facility ID", h.service.GetFacilityPolicy)
+}
+
+// GetComplexPolicy handles GET /api/sport-complexes/{id}/cancellation-policy
+func (h *CancellationPolicyHandler) GetComplexPolicy(w http.ResponseWriter, r *http.Request) {
+	h.getPolicy(w, r, "Invalid sport complex ID", h.service.GetComplexPolicy)
+}
+
+// UpdateFacilityPolicy handles PUT /api/facilities/{id}/cancellation-policy
+func (h *CancellationPolicyHandler) UpdateFacilityPolicy(w http.ResponseWriter, r *http.Request) {
+	h.updatePolicy(w, r, "Invalid facility ID", h.service.UpdateFacilityPolicy)
+}
+
+// UpdateComplexPolicy handles PUT /api/sport-complexes/{id}/cancellation-policy
+func (h *CancellationPolicyHandler) UpdateComplexPolicy(w http.ResponseWriter, r *http.Request) {
+	h.updatePolicy(w, r, "Invalid sport complex ID", h.service.UpdateComplexPolicy)
+}
+
+func (h *CancellationPolicyHandler) getPolicy(w http.ResponseWriter, r *http.Request, invalidIDMessage string,
+	get func(id int64) (*model.CancellationPolicy, error)) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeCancellationPolicyError(w, http.StatusBadRequest, invalidIDMessage)
+		return
+	}
+
+	policy, err := get(id)
+	if err != nil {
+		writeCancellationPolicyError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (h *CancellationPolicyHandler) updatePolicy(w http.ResponseWriter, r *http.Request, invalidIDMessage string,
+	update func(id, userID int64, req dto.CancellationPolicyDTO) (*model.CancellationPolicy, error)) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeCancellationPolicyError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeCancellationPolicyError(w, http.StatusBadRequest, invalidIDMessage)
+		return
+	}
+
+	var req dto.CancellationPolicyDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeCancellationPolicyError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	policy, err := update(id, claims.UserID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrNotManager) {
+			status = http.StatusForbidden
+		}
+		writeCancellationPolicyError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+func writeCancellationPolicyError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
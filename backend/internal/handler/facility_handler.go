@@ -144,10 +144,12 @@ func (h *FacilityHandler) CreateFacility(w http.ResponseWriter, r *http.Request)
 		req.Address,
 		req.Description,
 		req.Capacity,
+		req.TimeZone,
 		claims.UserID,
 		req.ImageURLs,
 		req.WorkingHours,
 		req.Pricing,
+		req.BookingPolicy,
 	)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -284,7 +286,9 @@ func (h *FacilityHandler) UpdateFacility(w http.ResponseWriter, r *http.Request)
 		req.Address,
 		req.Description,
 		req.Capacity,
+		req.TimeZone,
 		claims.UserID,
+		req.BookingPolicy,
 	)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
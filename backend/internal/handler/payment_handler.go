@@ -2,6 +2,9 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -19,7 +22,7 @@ func NewPaymentHandler(service *service.PaymentService) *PaymentHandler {
 	return &PaymentHandler{service: service}
 }
 
-// GetPaymentByReservation retrieves payment information for a reservation
+// GetPaymentByReservation retrieves payment information for a reservation, with its refund history
 func (h *PaymentHandler) GetPaymentByReservation(w http.ResponseWriter, r *http.Request) {
 	claims, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
@@ -38,7 +41,7 @@ func (h *PaymentHandler) GetPaymentByReservation(w http.ResponseWriter, r *http.
 		return
 	}
 
-	payment, err := h.service.GetPaymentByReservationID(reservationID)
+	payment, err := h.service.GetPaymentDetails(reservationID)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -95,8 +98,15 @@ func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request)
 
 	payment, err := h.service.ProcessPayment(reservationID, claims.UserID, req)
 	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrPaymentDeclined):
+			status = http.StatusPaymentRequired
+		case errors.Is(err, service.ErrPaymentInProgress), errors.Is(err, service.ErrPaymentLapsed):
+			status = http.StatusConflict
+		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(status)
 		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -105,3 +115,121 @@ func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(payment)
 }
+
+// PayBalance pays by card what is left to pay after a reservation moved to a dearer time
+func (h *PaymentHandler) PayBalance(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	reservationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid reservation ID"})
+		return
+	}
+
+	var req dto.PayBalanceDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	charge, err := h.service.PayBalance(reservationID, claims.UserID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrPaymentDeclined):
+			status = http.StatusPaymentRequired
+		case errors.Is(err, service.ErrPaymentInProgress), errors.Is(err, service.ErrNoBalanceDue):
+			status = http.StatusConflict
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(charge)
+}
+
+// maxWebhookBodySize limits the size of a payment gateway webhook
+const maxWebhookBodySize = 1 << 20
+
+// HandleWebhook receives a webhook from the payment gateway named in the URL; its signature authenticates it
+func (h *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
+	if err != nil {
+		writePaymentError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	err = h.service.HandleWebhook(mux.Vars(r)["provider"], payload, r.Header)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownGateway):
+			writePaymentError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrInvalidWebhookSignature):
+			writePaymentError(w, http.StatusBadRequest, err.Error())
+		default:
+			log.Printf("[PAYMENT] Failed to process webhook: %v", err)
+			writePaymentError(w, http.StatusInternalServerError, "Failed to process webhook")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"received": true})
+}
+
+// ListWebhookEvents lists stored payment gateway webhooks for audit, optionally filtered by ?status= (admin)
+func (h *PaymentHandler) ListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	events, err := h.service.ListWebhookEvents(r.URL.Query().Get("status"))
+	if err != nil {
+		writePaymentError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// ReplayWebhookEvent applies a stored payment gateway webhook again (admin)
+func (h *PaymentHandler) ReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writePaymentError(w, http.StatusBadRequest, "Invalid webhook event ID")
+		return
+	}
+
+	event, err := h.service.ReplayWebhookEvent(eventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWebhookEventNotFound):
+			writePaymentError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrUnknownGateway):
+			writePaymentError(w, http.StatusConflict, "The event's payment gateway is not in use")
+		default:
+			writePaymentError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+func writePaymentError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/middleware"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/service"
+	"github.com/gorilla/mux"
+)
+
+type RefundHandler struct {
+	service *service.RefundService
+}
+
+func NewRefundHandler(service *service.RefundService) *RefundHandler {
+	return &RefundHandler{service: service}
+}
+
+// RefundBooking lets a facility manager refund part or all of a booking's payment
+func (h *RefundHandler) RefundBooking(w http.ResponseWriter, r *http.Request) {
+	h.refund(w, r, model.RefundInitiatorManager)
+}
+
+// AdminRefundReservation lets an admin refund part or all of any reservation's payment
+func (h *RefundHandler) AdminRefundReservation(w http.ResponseWriter, r *http.Request) {
+	h.refund(w, r, model.RefundInitiatorAdmin)
+}
+
+func (h *RefundHandler) refund(w http.ResponseWriter, r *http.Request, initiator string) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	reservationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid reservation ID"})
+		return
+	}
+
+	var req dto.CreateRefundDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	refund, err := h.service.RefundReservation(reservationID, claims.UserID, initiator, req)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, service.ErrNotManager):
+			w.WriteHeader(http.StatusForbidden)
+		case errors.Is(err, service.ErrNotRefundable), errors.Is(err, service.ErrRefundTooLarge):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// The refund is kept in the history even when the payment gateway refused it
+	status, message := http.StatusCreated, "Refund issued successfully"
+	if refund.Status == model.RefundFailed {
+		status, message = http.StatusBadGateway, "The payment gateway refused the refund"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"refund":  refund,
+	})
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/middleware"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/service"
+	"github.com/gorilla/mux"
+)
+
+type ScheduleExceptionHandler struct {
+	service *service.ScheduleExceptionService
+}
+
+func NewScheduleExceptionHandler(service *service.ScheduleExceptionService) *ScheduleExceptionHandler {
+	return &ScheduleExceptionHandler{service: service}
+}
+
+// GetFacilityExceptions handles GET /api/facilities/{id}/exceptions
+func (h *ScheduleExceptionHandler) GetFacilityExceptions(w http.ResponseWriter, r *http.Request) {
+	h.listExceptions(w, r, "Invalid facility ID", h.service.GetFacilityExceptions)
+}
+
+// GetComplexExceptions handles GET /api/sport-complexes/{id}/exceptions
+func (h *ScheduleExceptionHandler) GetComplexExceptions(w http.ResponseWriter, r *http.Request) {
+	h.listExceptions(w, r, "Invalid sport complex ID", h.service.GetComplexExceptions)
+}
+
+// CreateFacilityException handles POST /api/facilities/{id}/exceptions
+func (h *ScheduleExceptionHandler) CreateFacilityException(w http.ResponseWriter, r *http.Request) {
+	h.createExceptions(w, r, "Invalid facility ID", h.service.CreateFacilityExceptions)
+}
+
+// CreateComplexException handles POST /api/sport-complexes/{id}/exceptions
+func (h *ScheduleExceptionHandler) CreateComplexException(w http.ResponseWriter, r *http.Request) {
+	h.createExceptions(w, r, "Invalid sport complex ID", h.service.CreateComplexExceptions)
+}
+
+// DeleteException handles DELETE /api/schedule-exceptions/{id}
+func (h *ScheduleExceptionHandler) DeleteException(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeScheduleExceptionError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	exceptionID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeScheduleExceptionError(w, http.StatusBadRequest, "Invalid schedule exception ID")
+		return
+	}
+
+	if err := h.service.DeleteException(exceptionID, claims.UserID); err != nil {
+		writeScheduleExceptionError(w, scheduleExceptionErrorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Schedule exception deleted successfully"})
+}
+
+func (h *ScheduleExceptionHandler) listExceptions(w http.ResponseWriter, r *http.Request, invalidIDMessage string,
+	list func(id, userID int64, startDate, endDate time.Time) ([]model.ScheduleException, error)) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeScheduleExceptionError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeScheduleExceptionError(w, http.StatusBadRequest, invalidIDMessage)
+		return
+	}
+
+	// Default to the next 90 days
+	now := time.Now()
+	startDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	endDate := startDate.AddDate(0, 0, 90)
+
+	if startDateStr := r.URL.Query().Get("start_date"); startDateStr != "" {
+		startDate, err = time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			writeScheduleExceptionError(w, http.StatusBadRequest, "Invalid start_date format. Use YYYY-MM-DD")
+			return
+		}
+	}
+
+	if endDateStr := r.URL.Query().Get("end_date"); endDateStr != "" {
+		endDate, err = time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			writeScheduleExceptionError(w, http.StatusBadRequest, "Invalid end_date format. Use YYYY-MM-DD")
+			return
+		}
+	}
+
+	exceptions, err := list(id, claims.UserID, startDate, endDate)
+	if err != nil {
+		writeScheduleExceptionError(w, scheduleExceptionErrorStatus(err), err.Error())
+		return
+	}
+
+	if exceptions == nil {
+		exceptions = []model.ScheduleException{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exceptions)
+}
+
+func (h *ScheduleExceptionHandler) createExceptions(w http.ResponseWriter, r *http.Request, invalidIDMessage string,
+	create func(id, userID int64, req dto.CreateScheduleExceptionDTO) ([]model.ScheduleException, error)) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeScheduleExceptionError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeScheduleExceptionError(w, http.StatusBadRequest, invalidIDMessage)
+		return
+	}
+
+	var req dto.CreateScheduleExceptionDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeScheduleExceptionError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	exceptions, err := create(id, claims.UserID, req)
+	if err != nil {
+		writeScheduleExceptionError(w, scheduleExceptionErrorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(exceptions)
+}
+
+func scheduleExceptionErrorStatus(err error) int {
+	if errors.Is(err, service.ErrNotManager) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+func writeScheduleExceptionError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
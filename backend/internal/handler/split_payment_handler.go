@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/middleware"
+	"github.com/Radi03825/PlaySpot/internal/service"
+	"github.com/gorilla/mux"
+)
+
+type SplitPaymentHandler struct {
+	service *service.SplitPaymentService
+}
+
+func NewSplitPaymentHandler(service *service.SplitPaymentService) *SplitPaymentHandler {
+	return &SplitPaymentHandler{service: service}
+}
+
+// RequestSplit lets an event's organizer split the cost of its booking among the participants
+func (h *SplitPaymentHandler) RequestSplit(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeSplitPaymentError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	eventID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeSplitPaymentError(w, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	// The body is optional
+	var req dto.SplitPaymentRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeSplitPaymentError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	split, err := h.service.RequestSplit(eventID, claims.UserID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrNotEventOrganizer):
+			status = http.StatusForbidden
+		case errors.Is(err, service.ErrNothingToSplit), errors.Is(err, service.ErrPaymentInProgress):
+			status = http.StatusConflict
+		}
+		writeSplitPaymentError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(split)
+}
+
+// GetSplit returns how the cost of an event's booking is split and who has paid
+func (h *SplitPaymentHandler) GetSplit(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeSplitPaymentError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	eventID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeSplitPaymentError(w, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	split, err := h.service.GetSplit(eventID, claims.UserID)
+	if err != nil {
+		writeSplitPaymentError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(split)
+}
+
+// PayShare charges the current user's share of an event's booking to their card
+func (h *SplitPaymentHandler) PayShare(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeSplitPaymentError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	eventID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeSplitPaymentError(w, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	var req dto.PayShareDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSplitPaymentError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	share, err := h.service.PayShare(eventID, claims.UserID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrPaymentDeclined):
+			status = http.StatusPaymentRequired
+		case errors.Is(err, service.ErrShareNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrPaymentInProgress):
+			status = http.StatusConflict
+		}
+		writeSplitPaymentError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(share)
+}
+
+func writeSplitPaymentError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -12,13 +11,14 @@ import (
 	"github.com/Radi03825/PlaySpot/internal/dto"
 	"github.com/Radi03825/PlaySpot/internal/middleware"
 	"github.com/Radi03825/PlaySpot/internal/service"
+	"github.com/Radi03825/PlaySpot/internal/service/calendar"
 	"google.golang.org/api/idtoken"
 )
 
 type UserHandler struct {
-	service               *service.UserService
-	tokenService          *service.TokenService
-	googleCalendarService *service.GoogleCalendarService
+	service        *service.UserService
+	tokenService   *service.TokenService
+	googleProvider *calendar.GoogleProvider
 }
 
 type ErrorResponse struct {
@@ -32,11 +32,11 @@ type GoogleIDTokenPayload struct {
 	Name    string
 }
 
-func NewUserHandler(service *service.UserService, tokenService *service.TokenService, googleCalendarService *service.GoogleCalendarService) *UserHandler {
+func NewUserHandler(service *service.UserService, tokenService *service.TokenService, googleProvider *calendar.GoogleProvider) *UserHandler {
 	return &UserHandler{
-		service:               service,
-		tokenService:          tokenService,
-		googleCalendarService: googleCalendarService,
+		service:        service,
+		tokenService:   tokenService,
+		googleProvider: googleProvider,
 	}
 }
 
@@ -147,12 +147,12 @@ func (u *UserHandler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
 	if req.Code != "" {
 		// Only process calendar tokens if the scope includes calendar
 		if strings.Contains(req.Scope, "calendar") {
-			token, err := u.googleCalendarService.ExchangeCodeForTokens(req.Code)
+			creds, idToken, err := u.googleProvider.ExchangeCode(req.Code)
 			if err != nil {
 				// If calendar exchange fails, fall back to regular login without calendar
 				// This prevents login failure if calendar verification is pending
 				if req.IDToken != "" {
-					payload, err = verifyGoogleIDToken(req.IDToken)
+					payload, err = u.verifyGoogleIDToken(req.IDToken)
 					if err != nil {
 						w.Header().Set("Content-Type", "application/json")
 						w.WriteHeader(http.StatusUnauthorized)
@@ -166,13 +166,12 @@ func (u *UserHandler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 			} else {
-				googleAccessToken = token.AccessToken
-				googleRefreshToken = token.RefreshToken
-				tokenExpiry = token.Expiry
+				googleAccessToken = creds.AccessToken
+				googleRefreshToken = creds.RefreshToken
+				tokenExpiry = creds.Expiry
 
-				if token.Extra("id_token") != nil {
-					idToken := token.Extra("id_token").(string)
-					payload, err = verifyGoogleIDToken(idToken)
+				if idToken != "" {
+					payload, err = u.verifyGoogleIDToken(idToken)
 					if err != nil {
 						w.Header().Set("Content-Type", "application/json")
 						w.WriteHeader(http.StatusUnauthorized)
@@ -189,7 +188,7 @@ func (u *UserHandler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
 		} else {
 			// Code provided but no calendar scope - just use the ID token
 			if req.IDToken != "" {
-				payload, err = verifyGoogleIDToken(req.IDToken)
+				payload, err = u.verifyGoogleIDToken(req.IDToken)
 				if err != nil {
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusUnauthorized)
@@ -205,7 +204,7 @@ func (u *UserHandler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
 		}
 	} else if req.IDToken != "" {
 		// Handle ID token flow (standard login without calendar)
-		payload, err = verifyGoogleIDToken(req.IDToken)
+		payload, err = u.verifyGoogleIDToken(req.IDToken)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
@@ -602,7 +601,7 @@ func (u *UserHandler) ConnectGoogleCalendar(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	token, err := u.googleCalendarService.ExchangeCodeForTokens(req.Code)
+	creds, _, err := u.googleProvider.ExchangeCode(req.Code)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -615,9 +614,9 @@ func (u *UserHandler) ConnectGoogleCalendar(w http.ResponseWriter, r *http.Reque
 	// Update user's Google Calendar tokens
 	err = u.service.UpdateGoogleCalendarTokens(
 		claims.UserID,
-		token.AccessToken,
-		token.RefreshToken,
-		token.Expiry,
+		creds.AccessToken,
+		creds.RefreshToken,
+		creds.Expiry,
 	)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -635,10 +634,9 @@ func (u *UserHandler) ConnectGoogleCalendar(w http.ResponseWriter, r *http.Reque
 	})
 }
 
-func verifyGoogleIDToken(idToken string) (*GoogleIDTokenPayload, error) {
-	clientID := os.Getenv("GOOGLE_CLIENT_ID")
-
-	payload, err := idtoken.Validate(context.Background(), idToken, clientID)
+// verifyGoogleIDToken validates a Google ID token issued to the app's OAuth client
+func (u *UserHandler) verifyGoogleIDToken(idToken string) (*GoogleIDTokenPayload, error) {
+	payload, err := idtoken.Validate(context.Background(), idToken, u.googleProvider.ClientID())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/middleware"
+	"github.com/Radi03825/PlaySpot/internal/service"
+	"github.com/gorilla/mux"
+)
+
+type WaitlistHandler struct {
+	service *service.WaitlistService
+}
+
+func NewWaitlistHandler(service *service.WaitlistService) *WaitlistHandler {
+	return &WaitlistHandler{service: service}
+}
+
+// JoinWaitlist handles POST /api/waitlist
+func (h *WaitlistHandler) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeWaitlistError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dto.JoinWaitlistDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeWaitlistError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	entry, err := h.service.JoinWaitlist(claims.UserID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrAlreadyWaitlisted) {
+			status = http.StatusConflict
+		}
+		writeWaitlistError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// GetUserWaitlist handles GET /api/waitlist
+func (h *WaitlistHandler) GetUserWaitlist(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeWaitlistError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	entries, err := h.service.GetUserWaitlist(claims.UserID)
+	if err != nil {
+		writeWaitlistError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// ClaimOffer handles POST /api/waitlist/{id}/claim
+func (h *WaitlistHandler) ClaimOffer(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeWaitlistError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	entryID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeWaitlistError(w, http.StatusBadRequest, "Invalid waitlist entry ID")
+		return
+	}
+
+	reservation, err := h.service.ClaimOffer(entryID, claims.UserID)
+	if err != nil {
+		writeWaitlistError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reservation)
+}
+
+// LeaveWaitlist handles DELETE /api/waitlist/{id}
+func (h *WaitlistHandler) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		writeWaitlistError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	entryID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeWaitlistError(w, http.StatusBadRequest, "Invalid waitlist entry ID")
+		return
+	}
+
+	if err := h.service.LeaveWaitlist(entryID, claims.UserID); err != nil {
+		writeWaitlistError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Left the waitlist successfully"})
+}
+
+func writeWaitlistError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
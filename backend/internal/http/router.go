@@ -1,12 +1,14 @@
 package http
 
 import (
+	"net/http"
+
 	"github.com/Radi03825/PlaySpot/internal/handler"
 	"github.com/Radi03825/PlaySpot/internal/middleware"
 	"github.com/gorilla/mux"
 )
 
-func NewRouter(userHandler *handler.UserHandler, facilityHandler *handler.FacilityHandler, sportComplexHandler *handler.SportComplexHandler, reservationHandler *handler.ReservationHandler, imageHandler *handler.ImageHandler, paymentHandler *handler.PaymentHandler, eventHandler *handler.EventHandler, reviewHandler *handler.ReviewHandler) *mux.Router {
+func NewRouter(userHandler *handler.UserHandler, facilityHandler *handler.FacilityHandler, sportComplexHandler *handler.SportComplexHandler, reservationHandler *handler.ReservationHandler, imageHandler *handler.ImageHandler, paymentHandler *handler.PaymentHandler, eventHandler *handler.EventHandler, reviewHandler *handler.ReviewHandler, scheduleExceptionHandler *handler.ScheduleExceptionHandler, cancellationPolicyHandler *handler.CancellationPolicyHandler, waitlistHandler *handler.WaitlistHandler, calendarHandler *handler.CalendarHandler, refundHandler *handler.RefundHandler, splitPaymentHandler *handler.SplitPaymentHandler, idempotencyStore middleware.IdempotencyStore) *mux.Router {
 	router := mux.NewRouter()
 	api := router.PathPrefix("/api").Subrouter()
 
@@ -33,9 +35,11 @@ func NewRouter(userHandler *handler.UserHandler, facilityHandler *handler.Facili
 	api.HandleFunc("/facilities/search", facilityHandler.SearchFacilities).Methods("GET")
 	api.HandleFunc("/facilities/{id:[0-9]+}", facilityHandler.GetFacilityByID).Methods("GET")
 	api.HandleFunc("/facilities/{id:[0-9]+}/availability", reservationHandler.GetFacilityAvailability).Methods("GET")
+	api.HandleFunc("/facilities/{id:[0-9]+}/cancellation-policy", cancellationPolicyHandler.GetFacilityPolicy).Methods("GET")
 	api.HandleFunc("/sport-complexes", sportComplexHandler.GetAllSportComplexes).Methods("GET")
 	api.HandleFunc("/sport-complexes/{id:[0-9]+}", sportComplexHandler.GetSportComplexByID).Methods("GET")
 	api.HandleFunc("/sport-complexes/{id:[0-9]+}/facilities", facilityHandler.GetFacilitiesByComplexID).Methods("GET")
+	api.HandleFunc("/sport-complexes/{id:[0-9]+}/cancellation-policy", cancellationPolicyHandler.GetComplexPolicy).Methods("GET")
 
 	// Public image routes (allow viewing images without authentication)
 	api.HandleFunc("/images/{entityType}/{entityId:[0-9]+}", imageHandler.GetEntityImages).Methods("GET")
@@ -49,10 +53,20 @@ func NewRouter(userHandler *handler.UserHandler, facilityHandler *handler.Facili
 	api.HandleFunc("/facilities/{id:[0-9]+}/reviews", reviewHandler.GetReviewsByFacility).Methods("GET")
 	api.HandleFunc("/facilities/{id:[0-9]+}/reviews/stats", reviewHandler.GetFacilityReviewStats).Methods("GET")
 
+	// Public calendar feeds (the secret token in the URL authorizes calendar apps)
+	api.HandleFunc("/calendar/{token:[0-9a-f]+}.ics", calendarHandler.UserFeed).Methods("GET")
+	api.HandleFunc("/calendar/{token:[0-9a-f]+}/facilities/{id:[0-9]+}.ics", calendarHandler.FacilityFeed).Methods("GET")
+
+	// Payment gateway webhooks (the gateway's signature authenticates them)
+	api.HandleFunc("/payments/webhook/{provider:[a-z]+}", paymentHandler.HandleWebhook).Methods("POST")
+
 	// Protected routes (require authentication)
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(middleware.JWTAuthMiddleware)
 
+	// Booking and payment routes accept an Idempotency-Key header so clients can safely retry them
+	idempotent := middleware.Idempotency(idempotencyStore)
+
 	// Image upload routes (authenticated users can upload images)
 	protected.HandleFunc("/upload/image", imageHandler.UploadImage).Methods("POST")
 	protected.HandleFunc("/upload/images", imageHandler.UploadMultipleImages).Methods("POST")
@@ -71,16 +85,48 @@ func NewRouter(userHandler *handler.UserHandler, facilityHandler *handler.Facili
 	protected.HandleFunc("/facilities", facilityHandler.CreateFacility).Methods("POST")
 	protected.HandleFunc("/facilities/{id:[0-9]+}", facilityHandler.UpdateFacility).Methods("PUT")
 	protected.HandleFunc("/facilities/{id:[0-9]+}/bookings", reservationHandler.GetFacilityBookings).Methods("GET")
+	protected.Handle("/bookings", idempotent(http.HandlerFunc(reservationHandler.CreateManagerBooking))).Methods("POST")
+	protected.HandleFunc("/bookings/{id:[0-9]+}/cancel", reservationHandler.ManagerCancelReservation).Methods("PUT", "POST")
+	protected.HandleFunc("/bookings/{id:[0-9]+}/no-show", reservationHandler.MarkNoShow).Methods("PUT", "POST")
+	protected.HandleFunc("/bookings/{id:[0-9]+}/mark-paid", reservationHandler.MarkBookingPaid).Methods("PUT", "POST")
+	protected.Handle("/bookings/{id:[0-9]+}/refunds", idempotent(http.HandlerFunc(refundHandler.RefundBooking))).Methods("POST")
+	protected.HandleFunc("/facilities/{id:[0-9]+}/exceptions", scheduleExceptionHandler.GetFacilityExceptions).Methods("GET")
+	protected.HandleFunc("/facilities/{id:[0-9]+}/exceptions", scheduleExceptionHandler.CreateFacilityException).Methods("POST")
+	protected.HandleFunc("/sport-complexes/{id:[0-9]+}/exceptions", scheduleExceptionHandler.GetComplexExceptions).Methods("GET")
+	protected.HandleFunc("/sport-complexes/{id:[0-9]+}/exceptions", scheduleExceptionHandler.CreateComplexException).Methods("POST")
+	protected.HandleFunc("/schedule-exceptions/{id:[0-9]+}", scheduleExceptionHandler.DeleteException).Methods("DELETE")
+	protected.HandleFunc("/facilities/{id:[0-9]+}/cancellation-policy", cancellationPolicyHandler.UpdateFacilityPolicy).Methods("PUT")
+	protected.HandleFunc("/sport-complexes/{id:[0-9]+}/cancellation-policy", cancellationPolicyHandler.UpdateComplexPolicy).Methods("PUT")
 	
 	// Reservation routes (authenticated users)
-	protected.HandleFunc("/reservations", reservationHandler.CreateReservation).Methods("POST")
+	protected.Handle("/reservations", idempotent(http.HandlerFunc(reservationHandler.CreateReservation))).Methods("POST")
 	protected.HandleFunc("/reservations/user", reservationHandler.GetUserReservations).Methods("GET")
 	protected.HandleFunc("/reservations/upcoming", reservationHandler.GetUpcomingConfirmedReservations).Methods("GET")
 	protected.HandleFunc("/reservations/{id:[0-9]+}/cancel", reservationHandler.CancelReservation).Methods("PUT", "POST")
+	protected.HandleFunc("/reservations/{id:[0-9]+}/reschedule", reservationHandler.RescheduleReservation).Methods("PUT")
+	protected.Handle("/reservations/recurring", idempotent(http.HandlerFunc(reservationHandler.CreateRecurringReservation))).Methods("POST")
+	protected.Handle("/reservations/checkout", idempotent(http.HandlerFunc(reservationHandler.Checkout))).Methods("POST")
+	protected.HandleFunc("/reservations/series/{id:[0-9]+}", reservationHandler.GetReservationSeries).Methods("GET")
+	protected.HandleFunc("/reservations/series/{id:[0-9]+}/cancel", reservationHandler.CancelReservationSeries).Methods("PUT", "POST")
+
+	// Waitlist routes
+	protected.HandleFunc("/waitlist", waitlistHandler.JoinWaitlist).Methods("POST")
+	protected.HandleFunc("/waitlist", waitlistHandler.GetUserWaitlist).Methods("GET")
+	protected.HandleFunc("/waitlist/{id:[0-9]+}/claim", waitlistHandler.ClaimOffer).Methods("POST")
+	protected.HandleFunc("/waitlist/{id:[0-9]+}", waitlistHandler.LeaveWaitlist).Methods("DELETE")
+
+	// Calendar feed routes
+	protected.HandleFunc("/calendar-feed", calendarHandler.GetFeed).Methods("GET")
+	protected.HandleFunc("/calendar-feed/rotate", calendarHandler.RotateFeed).Methods("POST")
+	protected.HandleFunc("/reservations/{id:[0-9]+}/calendar-sync", calendarHandler.GetSyncStatus).Methods("GET")
+	protected.HandleFunc("/calendar-connections/caldav", calendarHandler.GetCalDAV).Methods("GET")
+	protected.HandleFunc("/calendar-connections/caldav", calendarHandler.ConnectCalDAV).Methods("PUT")
+	protected.HandleFunc("/calendar-connections/caldav", calendarHandler.DisconnectCalDAV).Methods("DELETE")
 
 	// Payment routes (authenticated users)
 	protected.HandleFunc("/reservations/{id:[0-9]+}/payment", paymentHandler.GetPaymentByReservation).Methods("GET")
-	protected.HandleFunc("/reservations/{id:[0-9]+}/pay", paymentHandler.ProcessPayment).Methods("POST")
+	protected.Handle("/reservations/{id:[0-9]+}/pay", idempotent(http.HandlerFunc(paymentHandler.ProcessPayment))).Methods("POST")
+	protected.Handle("/reservations/{id:[0-9]+}/balance/pay", idempotent(http.HandlerFunc(paymentHandler.PayBalance))).Methods("POST")
 
 	// Event routes (authenticated users)
 	protected.HandleFunc("/events", eventHandler.CreateEvent).Methods("POST")
@@ -88,6 +134,9 @@ func NewRouter(userHandler *handler.UserHandler, facilityHandler *handler.Facili
 	protected.HandleFunc("/events/{id:[0-9]+}", eventHandler.DeleteEvent).Methods("DELETE")
 	protected.HandleFunc("/events/{id:[0-9]+}/join", eventHandler.JoinEvent).Methods("POST")
 	protected.HandleFunc("/events/{id:[0-9]+}/leave", eventHandler.LeaveEvent).Methods("POST")
+	protected.HandleFunc("/events/{id:[0-9]+}/split-payment", splitPaymentHandler.GetSplit).Methods("GET")
+	protected.HandleFunc("/events/{id:[0-9]+}/split-payment", splitPaymentHandler.RequestSplit).Methods("POST")
+	protected.Handle("/events/{id:[0-9]+}/split-payment/pay", idempotent(http.HandlerFunc(splitPaymentHandler.PayShare))).Methods("POST")
 	protected.HandleFunc("/users/me/events", eventHandler.GetUserEvents).Methods("GET")
 	protected.HandleFunc("/users/me/events/joined", eventHandler.GetUserJoinedEvents).Methods("GET")
 
@@ -110,6 +159,9 @@ func NewRouter(userHandler *handler.UserHandler, facilityHandler *handler.Facili
 	adminRoutes.HandleFunc("/users", userHandler.GetAllUsers).Methods("GET")
 	adminRoutes.HandleFunc("/users/activate", userHandler.ActivateUser).Methods("POST")
 	adminRoutes.HandleFunc("/users/deactivate", userHandler.DeactivateUser).Methods("POST")
+	adminRoutes.HandleFunc("/payment-webhooks", paymentHandler.ListWebhookEvents).Methods("GET")
+	adminRoutes.HandleFunc("/payment-webhooks/{id:[0-9]+}/replay", paymentHandler.ReplayWebhookEvent).Methods("POST")
+	adminRoutes.Handle("/reservations/{id:[0-9]+}/refunds", idempotent(http.HandlerFunc(refundHandler.AdminRefundReservation))).Methods("POST")
 
 	return router
 }
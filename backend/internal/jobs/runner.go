@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a unit of background work run periodically by the Runner.
+// Run returns the number of records it processed, used for logging.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) (int64, error)
+}
+
+// Runner runs registered jobs on their own intervals until stopped
+type Runner struct {
+	logger *slog.Logger
+	jobs   []Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRunner(logger *slog.Logger) *Runner {
+	return &Runner{
+		logger: logger.With("component", "jobs"),
+	}
+}
+
+// Register adds a job to the runner. Jobs must be registered before Start is called.
+func (r *Runner) Register(job Job) {
+	r.jobs = append(r.jobs, job)
+}
+
+// Start launches every registered job in its own goroutine. Each job runs once immediately
+// and then on every tick of its interval until ctx is cancelled or Stop is called.
+func (r *Runner) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	for _, job := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, job)
+	}
+
+	r.logger.Info("job runner started", "jobs", len(r.jobs))
+}
+
+// Stop cancels all jobs and waits for the ones currently running to finish
+func (r *Runner) Stop() {
+	if r.cancel == nil {
+		return
+	}
+
+	r.cancel()
+	r.wg.Wait()
+	r.logger.Info("job runner stopped")
+}
+
+func (r *Runner) loop(ctx context.Context, job Job) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	r.logger.Info("job scheduled", "job", job.Name, "interval", job.Interval.String())
+
+	for {
+		r.runOnce(ctx, job)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce runs a job a single time, logging its outcome and recovering from panics
+// so one failing job cannot take the server down
+func (r *Runner) runOnce(ctx context.Context, job Job) {
+	started := time.Now()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			r.logger.Error("job panicked", "job", job.Name, "panic", recovered)
+		}
+	}()
+
+	processed, err := job.Run(ctx)
+	duration := time.Since(started)
+
+	if err != nil {
+		r.logger.Error("job failed", "job", job.Name, "duration", duration.String(), "error", err)
+		return
+	}
+
+	if processed > 0 {
+		r.logger.Info("job completed", "job", job.Name, "duration", duration.String(), "processed", processed)
+	} else {
+		r.logger.Debug("job completed", "job", job.Name, "duration", duration.String(), "processed", processed)
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// IdempotencyKeyHeader is the request header clients set to make retries of a request safe
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	idempotencyKeyTTL        = 24 * time.Hour
+	maxIdempotencyKeyLength  = 255
+	maxIdempotentRequestSize = 1 << 20
+)
+
+// IdempotencyStore persists the first response to each user's idempotency key
+type IdempotencyStore interface {
+	// Claim reserves the key for a request; when it is already held, claimed is false and the held record is returned
+	Claim(userID int64, key, requestHash string, ttl time.Duration) (record *model.IdempotencyKey, claimed bool, err error)
+
+	// Complete saves the response to a claimed key
+	Complete(userID int64, key string, statusCode int, contentType string, body []byte) error
+
+	// Release frees a claimed key without saving a response
+	Release(userID int64, key string) error
+}
+
+// Idempotency makes authenticated requests carrying an Idempotency-Key header safe to retry. The first
+// response for a user and key is saved and replayed to retries with an Idempotent-Replayed header.
+// A key reused for a different request is rejected with 422, and one whose first request is still being
+// processed with 409. Bodies over 1 MB are rejected with 413. Server errors are not saved, so the request may be retried with the same key.
+// Requests without the header are passed through unchanged.
+func Idempotency(store IdempotencyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				writeError(w, http.StatusBadRequest, "Idempotency-Key cannot be longer than 255 characters")
+				return
+			}
+
+			claims, ok := GetUserFromContext(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentRequestSize))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid request payload")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := sha256.New()
+			io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+			hash.Write(body)
+			requestHash := hex.EncodeToString(hash.Sum(nil))
+
+			record, claimed, err := store.Claim(claims.UserID, key, requestHash, idempotencyKeyTTL)
+			if err != nil {
+				log.Printf("[IDEMPOTENCY] Failed to claim key for user %d: %v", claims.UserID, err)
+				writeError(w, http.StatusInternalServerError, "Failed to process request")
+				return
+			}
+
+			if !claimed {
+				switch {
+				case record.RequestHash != requestHash:
+					writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+				case record.StatusCode == nil:
+					writeError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+				default:
+					if record.ContentType != nil {
+						w.Header().Set("Content-Type", *record.ContentType)
+					}
+					w.Header().Set("Idempotent-Replayed", "true")
+					w.WriteHeader(*record.StatusCode)
+					w.Write(record.ResponseBody)
+				}
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			saved := false
+			defer func() {
+				// Free the key if the handler panicked or failed, so a retry is processed again
+				if !saved {
+					if err := store.Release(claims.UserID, key); err != nil {
+						log.Printf("[IDEMPOTENCY] Failed to release key for user %d: %v", claims.UserID, err)
+					}
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= http.StatusInternalServerError {
+				return
+			}
+			err = store.Complete(claims.UserID, key, recorder.status, w.Header().Get("Content-Type"), recorder.body.Bytes())
+			if err != nil {
+				log.Printf("[IDEMPOTENCY] Failed to save response for user %d: %v", claims.UserID, err)
+				return
+			}
+			saved = true
+		})
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of its status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// memoryIdempotencyStore keeps idempotency keys in memory, as the repository does in the database
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*model.IdempotencyKey
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: map[string]*model.IdempotencyKey{}}
+}
+
+func storeKey(userID int64, key string) string {
+	return fmt.Sprintf("%d/%s", userID, key)
+}
+
+func (s *memoryIdempotencyStore) Claim(userID int64, key, requestHash string, ttl time.Duration) (*model.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[storeKey(userID, key)]; ok && record.ExpiresAt.After(time.Now()) {
+		return record, false, nil
+	}
+	record := &model.IdempotencyKey{UserID: userID, Key: key, RequestHash: requestHash, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(ttl)}
+	s.records[storeKey(userID, key)] = record
+	return record, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(userID int64, key string, statusCode int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[storeKey(userID, key)]
+	record.StatusCode, record.ContentType, record.ResponseBody = &statusCode, &contentType, body
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(userID int64, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[storeKey(userID, key)]; ok && record.StatusCode == nil {
+		delete(s.records, storeKey(userID, key))
+	}
+	return nil
+}
+
+// idempotencyFixture counts the requests that reach the handler behind the middleware
+type idempotencyFixture struct {
+	store   *memoryIdempotencyStore
+	handler http.Handler
+	calls   int
+	status  int
+}
+
+func newIdempotencyFixture() *idempotencyFixture {
+	f := &idempotencyFixture{store: newMemoryIdempotencyStore(), status: http.StatusCreated}
+	f.handler = Idempotency(f.store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(f.status)
+		fmt.Fprintf(w, `{"call":%d,"body":%s}`, f.calls, body)
+	}))
+	return f
+}
+
+// do sends a request as user 1, with the Idempotency-Key header when key is not empty
+func (f *idempotencyFixture) do(path, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), UserContextKey, &UserClaims{UserID: 1}))
+
+	w := httptest.NewRecorder()
+	f.handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	first := f.do("/api/reservations", "key-1", `{"facility_id":1}`)
+	second := f.do("/api/reservations", "key-1", `{"facility_id":1}`)
+
+	if f.calls != 1 {
+		t.Fatalf("handler ran %d times, want once", f.calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("only the replay should carry the Idempotent-Replayed header")
+	}
+	if got := second.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("replay content type = %q, want application/json", got)
+	}
+}
+
+func TestIdempotencyRejectsKeyReusedForAnotherRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{name: "other body", path: "/api/reservations", body: `{"facility_id":2}`},
+		{name: "other path", path: "/api/reservations/checkout", body: `{"facility_id":1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newIdempotencyFixture()
+			f.do("/api/reservations", "key-1", `{"facility_id":1}`)
+
+			if w := f.do(tt.path, "key-1", tt.body); w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+			}
+			if f.calls != 1 {
+				t.Errorf("handler ran %d times, want once", f.calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyRejectsRequestStillInProgress(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	// The first request is still being handled when its retry arrives
+	var retry *httptest.ResponseRecorder
+	f.handler = Idempotency(f.store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.calls++
+		if retry == nil {
+			retry = f.do("/api/reservations", "key-1", `{"facility_id":1}`)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	f.do("/api/reservations", "key-1", `{"facility_id":1}`)
+
+	if retry.Code != http.StatusConflict {
+		t.Errorf("retry status = %d, want %d", retry.Code, http.StatusConflict)
+	}
+	if f.calls != 1 {
+		t.Errorf("handler ran %d times, want once", f.calls)
+	}
+}
+
+func TestIdempotencyRetriesServerErrors(t *testing.T) {
+	f := newIdempotencyFixture()
+	f.status = http.StatusInternalServerError
+
+	f.do("/api/reservations", "key-1", `{"facility_id":1}`)
+	f.status = http.StatusCreated
+	w := f.do("/api/reservations", "key-1", `{"facility_id":1}`)
+
+	if f.calls != 2 || w.Code != http.StatusCreated {
+		t.Errorf("retry after a server error ran the handler %d times with status %d, want twice with %d", f.calls, w.Code, http.StatusCreated)
+	}
+}
+
+func TestIdempotencyPassesRequestsWithoutKey(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	f.do("/api/reservations", "", `{"facility_id":1}`)
+	w := f.do("/api/reservations", "", `{"facility_id":1}`)
+
+	if f.calls != 2 || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("requests without a key ran the handler %d times, want twice without replay", f.calls)
+	}
+}
+
+func TestIdempotencyRejectsInvalidRequests(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	if w := f.do("/api/reservations", strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("overlong key status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/reservations", strings.NewReader(`{}`))
+	r.Header.Set(IdempotencyKeyHeader, "key-1")
+	w := httptest.NewRecorder()
+	f.handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	// An oversized body is rejected rather than hashed and passed on cut short
+	body := `{"notes":"` + strings.Repeat("x", maxIdempotentRequestSize) + `"}`
+	if w := f.do("/api/reservations", "key-2", body); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	if f.calls != 0 {
+		t.Errorf("handler ran %d times for invalid requests", f.calls)
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Balance charge statuses
+const (
+	BalanceChargePending = "pending" // not paid yet; FailureReason says why the last attempt was declined
+	BalanceChargePaid    = "paid"    // paid by card and added to the payment's amount
+)
+
+// BalanceCharge is a card payment of the balance due on a paid booking after it moved to a dearer time
+type BalanceCharge struct {
+	ID                   int64      `json:"id"`
+	PaymentID            int64      `json:"payment_id"`
+	Amount               float64    `json:"amount"`
+	Status               string     `json:"status"`
+	Gateway              string     `json:"gateway"`
+	GatewayTransactionID *string    `json:"gateway_transaction_id,omitempty"` // Set while the gateway settles a pending charge
+	FailureReason        *string    `json:"failure_reason,omitempty"`
+	RefundedAmount       float64    `json:"refunded_amount"`
+	PaidAt               *time.Time `json:"paid_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+}
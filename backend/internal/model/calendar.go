@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// CalendarEntry is a reservation or event as published in iCalendar feeds, email attachments and synced calendars
+type CalendarEntry struct {
+	UID         string // Stable across updates so calendar apps replace rather than duplicate the entry
+	Summary     string
+	Description string
+	Location    string
+	StartTime   time.Time
+	EndTime     time.Time
+	TimeZone    string    // IANA zone providers show the times in; feeds always use UTC
+	UpdatedAt   time.Time // Zero when the source row does not track modifications
+	Sequence    int
+	Cancelled   bool
+}
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// Calendar sync job actions
+const (
+	CalendarSyncCreate = "create"
+	CalendarSyncUpdate = "update"
+	CalendarSyncDelete = "delete"
+)
+
+// Calendar providers a user can sync bookings to
+const (
+	CalendarProviderGoogle = "google"
+	CalendarProviderCalDAV = "caldav"
+)
+
+// Calendar sync job statuses
+const (
+	CalendarSyncPending = "pending" // waiting for its first or next attempt
+	CalendarSyncDone    = "done"    // applied to the user's calendar
+	CalendarSyncSkipped = "skipped" // nothing to do, e.g. the calendar was disconnected
+	CalendarSyncFailed  = "failed"  // gave up after repeated errors
+)
+
+// CalendarSyncJob is a queued calendar change for a reservation
+type CalendarSyncJob struct {
+	ID            int64     `json:"id"`
+	ReservationID int64     `json:"reservation_id"`
+	UserID        int64     `json:"user_id"`
+	Action        string    `json:"action"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     *string   `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// CalendarSyncStatus is the calendar sync state of a reservation
+type CalendarSyncStatus struct {
+	ReservationID         int64            `json:"reservation_id"`
+	Status                string           `json:"status"` // 'not_synced' when no job was ever queued, else the latest job's status
+	Provider              *string          `json:"provider,omitempty"`
+	GoogleCalendarEventID *string          `json:"google_calendar_event_id,omitempty"`
+	LatestJob             *CalendarSyncJob `json:"latest_job,omitempty"`
+}
+
+// CalDAVAccount is the CalDAV calendar a user syncs bookings to
+type CalDAVAccount struct {
+	UserID      int64     `json:"user_id"`
+	CalendarURL string    `json:"calendar_url"`
+	Username    string    `json:"username"`
+	Password    string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
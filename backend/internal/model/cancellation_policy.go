@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// CancellationRule refunds RefundPercent of the amount paid when a booking is cancelled
+// at least HoursBefore hours before it starts
+type CancellationRule struct {
+	ID             int64     `json:"id"`
+	FacilityID     *int64    `json:"facility_id,omitempty"`
+	SportComplexID *int64    `json:"sport_complex_id,omitempty"`
+	HoursBefore    int       `json:"hours_before"`
+	RefundPercent  float64   `json:"refund_percent"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CancellationPolicy is the set of refund tiers that applies to a facility or a sport complex.
+// Without rules every cancellation is refunded in full; with rules, a cancellation later than
+// the smallest HoursBefore is not refunded.
+type CancellationPolicy struct {
+	FacilityID     *int64             `json:"facility_id,omitempty"`
+	SportComplexID *int64             `json:"sport_complex_id,omitempty"`
+	Inherited      bool               `json:"inherited"` // Facility rules come from its sport complex
+	Rules          []CancellationRule `json:"rules"`     // Ordered by HoursBefore, descending
+}
+
+// CancellationOutcome describes how a cancellation was settled under the applicable policy
+type CancellationOutcome struct {
+	ReservationID int64             `json:"reservation_id"`
+	HoursBefore   float64           `json:"hours_before"`           // Hours between the cancellation and the booking start
+	AppliedRule   *CancellationRule `json:"applied_rule,omitempty"` // nil when no policy or no tier applied
+	RefundPercent float64           `json:"refund_percent"`
+	AmountPaid    float64           `json:"amount_paid"`
+	RefundAmount  float64           `json:"refund_amount"`
+	PaymentStatus *string           `json:"payment_status,omitempty"` // nil when the booking had no payment
+	CancelledAt   time.Time         `json:"cancelled_at"`
+}
@@ -1,5 +1,8 @@
 package model
 
+// DefaultTimeZone is the IANA time zone of facilities and sport complexes created without one
+const DefaultTimeZone = "Europe/Sofia"
+
 type Facility struct {
 	ID             int64  `json:"id"`
 	Name           string `json:"name"`
@@ -11,6 +14,7 @@ type Facility struct {
 	Address        string `json:"address"`
 	Description    string `json:"description"`
 	Capacity       int    `json:"capacity"`
+	TimeZone       string `json:"time_zone"` // IANA zone opening hours and prices are defined in
 	IsVerified     bool   `json:"is_verified"`
 	IsActive       bool   `json:"is_active"`
 }
@@ -1,13 +1,59 @@
 package model
 
-// DayType represents the type of day for scheduling
+import "time"
+
+// DayType represents the day of the week a schedule or price band applies to
 type DayType string
 
 const (
+	DayTypeMonday    DayType = "monday"
+	DayTypeTuesday   DayType = "tuesday"
+	DayTypeWednesday DayType = "wednesday"
+	DayTypeThursday  DayType = "thursday"
+	DayTypeFriday    DayType = "friday"
+	DayTypeSaturday  DayType = "saturday"
+	DayTypeSunday    DayType = "sunday"
+
+	// Legacy day groups, still accepted as input and expanded into individual days
 	DayTypeWeekday DayType = "weekday"
 	DayTypeWeekend DayType = "weekend"
 )
 
+// DayTypeForWeekday returns the day type matching a time.Weekday
+func DayTypeForWeekday(weekday time.Weekday) DayType {
+	switch weekday {
+	case time.Monday:
+		return DayTypeMonday
+	case time.Tuesday:
+		return DayTypeTuesday
+	case time.Wednesday:
+		return DayTypeWednesday
+	case time.Thursday:
+		return DayTypeThursday
+	case time.Friday:
+		return DayTypeFriday
+	case time.Saturday:
+		return DayTypeSaturday
+	default:
+		return DayTypeSunday
+	}
+}
+
+// ExpandDayType returns the individual days covered by a day type.
+// Legacy groups expand to several days; unknown values return nil.
+func ExpandDayType(dayType DayType) []DayType {
+	switch dayType {
+	case DayTypeWeekday:
+		return []DayType{DayTypeMonday, DayTypeTuesday, DayTypeWednesday, DayTypeThursday, DayTypeFriday}
+	case DayTypeWeekend:
+		return []DayType{DayTypeSaturday, DayTypeSunday}
+	case DayTypeMonday, DayTypeTuesday, DayTypeWednesday, DayTypeThursday, DayTypeFriday, DayTypeSaturday, DayTypeSunday:
+		return []DayType{dayType}
+	default:
+		return nil
+	}
+}
+
 // FacilitySchedule represents the working hours for a facility
 type FacilitySchedule struct {
 	ID         int64   `json:"id"`
@@ -26,3 +72,36 @@ type FacilityPricing struct {
 	EndHour      string  `json:"end_hour"`   // HH:MM format
 	PricePerHour float64 `json:"price_per_hour"`
 }
+
+// FacilityBookingPolicy defines slot granularity, duration limits, reschedule rules and availability mode for booking a facility
+type FacilityBookingPolicy struct {
+	ID                      int64  `json:"id"`
+	FacilityID              int64  `json:"facility_id"`
+	SlotMinutes             int    `json:"slot_minutes"`
+	MinDurationMinutes      int    `json:"min_duration_minutes"`
+	MaxDurationMinutes      *int   `json:"max_duration_minutes"` // nil means no upper limit
+	BufferMinutes           int    `json:"buffer_minutes"`       // Minimum gap between two bookings
+	AllowReschedule         bool   `json:"allow_reschedule"`
+	RescheduleCutoffMinutes int    `json:"reschedule_cutoff_minutes"` // Latest a booking can be moved, before its start
+	MaxReschedules          *int   `json:"max_reschedules"`           // nil means no limit
+	AvailabilityMode        string `json:"availability_mode"`
+	Capacity                int    `json:"-"` // Facility capacity, the units that can be booked at once in shared mode
+}
+
+// Availability modes of a facility
+const (
+	AvailabilityExclusive = "exclusive" // one booking at a time uses the whole facility
+	AvailabilityShared    = "shared"    // simultaneous bookings each take units, up to the facility capacity
+)
+
+// DefaultBookingPolicy returns the policy used for facilities without a stored one (hourly slots, no buffer)
+func DefaultBookingPolicy(facilityID int64) *FacilityBookingPolicy {
+	return &FacilityBookingPolicy{
+		FacilityID:         facilityID,
+		SlotMinutes:        60,
+		MinDurationMinutes: 60,
+		BufferMinutes:      0,
+		AllowReschedule:    true,
+		AvailabilityMode:   AvailabilityExclusive,
+	}
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// IdempotencyKey is the first request a user sent with an Idempotency-Key and, once handled, its response
+type IdempotencyKey struct {
+	UserID       int64
+	Key          string
+	RequestHash  string // SHA-256 of the method, path and body
+	StatusCode   *int   // nil while the first request is still being processed
+	ContentType  *string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
@@ -3,14 +3,20 @@ package model
 import "time"
 
 type Payment struct {
-	ID            int64      `json:"id"`
-	UserID        int64      `json:"user_id"`
-	ReservationID int64      `json:"reservation_id"`
-	Amount        float64    `json:"amount"`
-	Currency      string     `json:"currency"`
-	PaymentMethod string     `json:"payment_method"` // 'on_place', 'card'
-	PaymentStatus string     `json:"payment_status"` // 'pending', 'completed', 'failed', 'refunded'
-	ExpiredAt     *time.Time `json:"expired_at,omitempty"`
-	PaidAt        *time.Time `json:"paid_at,omitempty"`
-	CreatedAt     time.Time  `json:"created_at"`
+	ID                   int64      `json:"id"`
+	UserID               int64      `json:"user_id"`
+	ReservationID        int64      `json:"reservation_id"`
+	Amount               float64    `json:"amount"`
+	Currency             string     `json:"currency"`
+	PaymentMethod        string     `json:"payment_method"`    // 'on_place', 'card', 'split'
+	PaymentStatus        string     `json:"payment_status"`    // 'pending', 'authorized', 'completed', 'failed', 'refunded', 'partially_refunded'
+	BalanceDue           float64    `json:"balance_due"`       // Left to pay after a paid booking moved to a dearer time; negative while a difference is owed back
+	RefundedAmount       float64    `json:"refunded_amount"`   // Returned to the customer after a cancellation
+	Gateway              *string    `json:"gateway,omitempty"` // Processor of card payments: 'simulated', 'stripe'
+	GatewayTransactionID *string    `json:"gateway_transaction_id,omitempty"`
+	FailureReason        *string    `json:"failure_reason,omitempty"` // Why the gateway declined the payment
+	ExpiredAt            *time.Time `json:"expired_at,omitempty"`
+	AuthorizedAt         *time.Time `json:"authorized_at,omitempty"`
+	PaidAt               *time.Time `json:"paid_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
 }
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// PaymentMethodSplit is the payment method of a booking whose cost is split among event participants
+const PaymentMethodSplit = "split"
+
+// Payment share statuses
+const (
+	ShareStatusPending   = "pending"   // requested, not yet paid
+	ShareStatusPaid      = "paid"      // paid by card; counts towards the booking's payment
+	ShareStatusCancelled = "cancelled" // no longer owed, e.g. the participant left or the cost was split again
+)
+
+// PaymentShare is the part of a booking's payment an event participant was asked to pay
+type PaymentShare struct {
+	ID                   int64      `json:"id"`
+	PaymentID            int64      `json:"payment_id"`
+	EventID              *int64     `json:"event_id,omitempty"`
+	UserID               int64      `json:"user_id"`
+	Amount               float64    `json:"amount"`
+	Status               string     `json:"status"`
+	Gateway              *string    `json:"gateway,omitempty"`
+	GatewayTransactionID *string    `json:"gateway_transaction_id,omitempty"` // Set while the gateway settles a pending share
+	FailureReason        *string    `json:"failure_reason,omitempty"`         // Why the gateway declined the last attempt
+	RefundedAmount       float64    `json:"refunded_amount"`
+	RemindersSent        int        `json:"reminders_sent"`
+	LastRemindedAt       *time.Time `json:"last_reminded_at,omitempty"`
+	PaidAt               *time.Time `json:"paid_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+
+	// Joined data
+	UserName string `json:"user_name,omitempty"`
+}
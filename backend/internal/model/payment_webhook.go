@@ -0,0 +1,32 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Payment webhook event statuses
+const (
+	WebhookReceived  = "received"  // stored, not applied yet
+	WebhookProcessed = "processed" // changed the payment it concerns
+	WebhookIgnored   = "ignored"   // nothing to do, e.g. the payment had already moved on
+	WebhookFailed    = "failed"    // applying it failed; the gateway retries, or it can be replayed
+)
+
+// PaymentWebhookEvent is a verified webhook from a payment gateway, stored for audit and replay
+type PaymentWebhookEvent struct {
+	ID            int64           `json:"id"`
+	Provider      string          `json:"provider"`
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	TransactionID *string         `json:"transaction_id,omitempty"`
+	EventStatus   *string         `json:"event_status,omitempty"` // Transaction outcome the event reports: 'authorized', 'captured', 'declined', ...
+	FailureReason *string         `json:"failure_reason,omitempty"`
+	Amount        *float64        `json:"amount,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Error         *string         `json:"error,omitempty"`
+	Deliveries    int             `json:"deliveries"` // How often the gateway sent the event
+	ReceivedAt    time.Time       `json:"received_at"`
+	ProcessedAt   *time.Time      `json:"processed_at,omitempty"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Who a refund was initiated by
+const (
+	RefundInitiatorUser    = "user_cancellation" // the customer cancelled the booking
+	RefundInitiatorManager = "manager"           // the facility's manager, by cancelling or refunding a booking
+	RefundInitiatorAdmin   = "admin"
+	RefundInitiatorSystem  = "system" // PlaySpot, returning a payment captured after its reservation lapsed, or the difference after a booking moved to a cheaper time
+)
+
+// Refund statuses
+const (
+	RefundPending   = "pending"   // recorded, not yet returned
+	RefundSucceeded = "succeeded" // returned through the gateway or recorded as returned outside PlaySpot
+	RefundFailed    = "failed"    // the gateway refused it; the amount no longer counts as refunded
+)
+
+// Refund is money returned from a payment
+type Refund struct {
+	ID              int64      `json:"id"`
+	PaymentID       int64      `json:"payment_id"`
+	ReservationID   *int64     `json:"reservation_id,omitempty"`
+	Amount          float64    `json:"amount"`
+	Reason          *string    `json:"reason,omitempty"`
+	Initiator       string     `json:"initiator"`
+	InitiatedBy     *int64     `json:"initiated_by,omitempty"`
+	Status          string     `json:"status"`
+	GatewayRefundID *string    `json:"gateway_refund_id,omitempty"`
+	FailureReason   *string    `json:"failure_reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ProcessedAt     *time.Time `json:"processed_at,omitempty"`
+}
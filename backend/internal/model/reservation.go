@@ -3,26 +3,130 @@ package model
 import "time"
 
 type FacilityReservation struct {
-	ID                    int64     `json:"id"`
-	UserID                int64     `json:"user_id"`
-	FacilityID            int64     `json:"facility_id"`
-	StartTime             time.Time `json:"start_time"`
-	EndTime               time.Time `json:"end_time"`
-	Status                string    `json:"status"` // 'pending', 'confirmed', 'cancelled', 'completed'
-	TotalPrice            float64   `json:"total_price"`
-	CreatedAt             time.Time `json:"created_at"`
-	GoogleCalendarEventID *string   `json:"google_calendar_event_id,omitempty"`
+	ID                    int64       `json:"id"`
+	UserID                int64       `json:"user_id"` // 0 for a guest booking made by staff
+	FacilityID            int64       `json:"facility_id"`
+	StartTime             time.Time   `json:"start_time"`
+	EndTime               time.Time   `json:"end_time"`
+	Status                string      `json:"status"` // 'pending', 'confirmed', 'cancelled', 'completed', 'expired', 'no_show'
+	TotalPrice            float64     `json:"total_price"`
+	Units                 int         `json:"units"` // Units of a shared facility taken by the booking; 1 for exclusive use
+	CreatedAt             time.Time   `json:"created_at"`
+	GoogleCalendarEventID *string     `json:"google_calendar_event_id,omitempty"`
+	CalendarProvider      *string     `json:"calendar_provider,omitempty"` // 'google' or 'caldav', the calendar holding GoogleCalendarEventID
+	PriceBreakdown        []PriceItem `json:"price_breakdown,omitempty"`
+	SeriesID              *int64      `json:"series_id,omitempty"`
+	ExpiresAt             *time.Time  `json:"expires_at,omitempty"` // When an unpaid pending reservation releases its slot
+	RescheduleCount       int         `json:"reschedule_count"`
+	CancelledBy           *int64      `json:"cancelled_by,omitempty"`
+	CancellationReason    *string     `json:"cancellation_reason,omitempty"`
+	CreatedBy             *int64      `json:"created_by,omitempty"` // Staff member who booked on the customer's behalf
+	GuestName             *string     `json:"guest_name,omitempty"`
+	GuestPhone            *string     `json:"guest_phone,omitempty"`
+}
+
+// PriceItem is one segment of a reservation charged at a single hourly rate
+type PriceItem struct {
+	ID            int64     `json:"id,omitempty"`
+	ReservationID int64     `json:"reservation_id,omitempty"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	PricePerHour  float64   `json:"price_per_hour"`
+	Amount        float64   `json:"amount"`
 }
 
 type AvailableSlot struct {
-	StartTime    string  `json:"start_time"`
-	EndTime      string  `json:"end_time"`
-	PricePerHour float64 `json:"price_per_hour"`
-	Available    bool    `json:"available"`
+	StartTime      string    `json:"start_time"` // Local time of day in the facility's time zone
+	EndTime        string    `json:"end_time"`
+	StartsAt       time.Time `json:"starts_at"` // Exact instant, unambiguous on daylight saving changes
+	EndsAt         time.Time `json:"ends_at"`
+	PricePerHour   float64   `json:"price_per_hour"`
+	Available      bool      `json:"available"`
+	RemainingUnits *int      `json:"remaining_units,omitempty"` // Units still free, for facilities in shared mode
 }
 
 type DayAvailability struct {
-	Date   string          `json:"date"`
-	IsOpen bool            `json:"is_open"`
-	Slots  []AvailableSlot `json:"slots"`
+	Date          string          `json:"date"`
+	TimeZone      string          `json:"time_zone"`
+	IsOpen        bool            `json:"is_open"`
+	ClosureReason *string         `json:"closure_reason,omitempty"`
+	Slots         []AvailableSlot `json:"slots"`
+}
+
+// RecurrenceFrequency is how often a reservation series repeats
+type RecurrenceFrequency string
+
+const (
+	RecurrenceWeekly   RecurrenceFrequency = "weekly"
+	RecurrenceBiweekly RecurrenceFrequency = "biweekly"
+)
+
+// ReservationSeries is a recurring booking of the same facility and time, ending on a date or after a count
+type ReservationSeries struct {
+	ID              int64               `json:"id"`
+	UserID          int64               `json:"user_id"`
+	FacilityID      int64               `json:"facility_id"`
+	Frequency       RecurrenceFrequency `json:"frequency"`
+	FirstStartTime  time.Time           `json:"first_start_time"`
+	FirstEndTime    time.Time           `json:"first_end_time"`
+	UntilDate       *string             `json:"until_date,omitempty"` // YYYY-MM-DD format
+	OccurrenceCount *int                `json:"occurrence_count,omitempty"`
+	Status          string              `json:"status"` // 'active', 'cancelled'
+	CreatedAt       time.Time           `json:"created_at"`
+}
+
+// Occurrence statuses reported when creating a reservation series
+const (
+	OccurrenceCreated   = "created"   // the reservation was created
+	OccurrenceAvailable = "available" // bookable, but not created because the series was rejected
+	OccurrenceConflict  = "conflict"  // the slot is already reserved
+	OccurrenceInvalid   = "invalid"   // outside opening hours, closed, unpriced or in the past
+)
+
+// SeriesOccurrence is a single date of a reservation series and the outcome of booking it
+type SeriesOccurrence struct {
+	StartTime     time.Time   `json:"start_time"`
+	EndTime       time.Time   `json:"end_time"`
+	Status        string      `json:"status"`
+	Reason        string      `json:"reason,omitempty"`
+	ReservationID *int64      `json:"reservation_id,omitempty"`
+	TotalPrice    float64     `json:"total_price"`
+	PriceItems    []PriceItem `json:"-"`
+}
+
+// BasketItem is one facility and interval booked as part of a basket checkout
+type BasketItem struct {
+	FacilityID int64         `json:"facility_id"`
+	StartTime  time.Time     `json:"start_time"`
+	EndTime    time.Time     `json:"end_time"`
+	Units      int           `json:"units"`
+	Buffer     time.Duration `json:"-"`
+	TotalPrice float64       `json:"total_price"`
+	PriceItems []PriceItem   `json:"-"`
+}
+
+// PeakUnits returns the most units booked at the same time within [start, end) by the given reservations
+func PeakUnits(reservations []FacilityReservation, start, end time.Time) int {
+	peak := 0
+	for _, candidate := range reservations {
+		// Usage only rises when a reservation starts, so the peak is at start or at a later start time
+		at := candidate.StartTime
+		if at.Before(start) {
+			at = start
+		}
+		if !at.Before(end) {
+			continue
+		}
+
+		used := 0
+		for _, r := range reservations {
+			if !r.StartTime.After(at) && r.EndTime.After(at) {
+				used += r.Units
+			}
+		}
+		if used > peak {
+			peak = used
+		}
+	}
+	return peak
 }
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// ScheduleExceptionType represents the kind of date-specific schedule exception
+type ScheduleExceptionType string
+
+const (
+	// ScheduleExceptionClosed blocks the whole day, or only StartTime-EndTime when both are set
+	ScheduleExceptionClosed ScheduleExceptionType = "closed"
+	// ScheduleExceptionSpecialHours replaces the regular opening hours with StartTime-EndTime
+	ScheduleExceptionSpecialHours ScheduleExceptionType = "special_hours"
+	// ScheduleExceptionSpecialPrice overrides the hourly price for the whole day or StartTime-EndTime
+	ScheduleExceptionSpecialPrice ScheduleExceptionType = "special_price"
+)
+
+// ScheduleException represents a closure, special opening hours or special price on a specific date.
+// It applies either to a single facility or to every facility of a sport complex.
+type ScheduleException struct {
+	ID             int64                 `json:"id"`
+	FacilityID     *int64                `json:"facility_id,omitempty"`
+	SportComplexID *int64                `json:"sport_complex_id,omitempty"`
+	Date           string                `json:"date"` // YYYY-MM-DD format
+	Type           ScheduleExceptionType `json:"type"`
+	StartTime      *string               `json:"start_time,omitempty"` // HH:MM:SS format
+	EndTime        *string               `json:"end_time,omitempty"`   // HH:MM:SS format
+	PricePerHour   *float64              `json:"price_per_hour,omitempty"`
+	Reason         *string               `json:"reason,omitempty"`
+	CreatedBy      *int64                `json:"created_by,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+}
@@ -6,6 +6,7 @@ type SportComplex struct {
 	Address     string  `json:"address"`
 	City        string  `json:"city"`
 	Description string  `json:"description"`
+	TimeZone    string  `json:"time_zone"`
 	ManagerID   *int64  `json:"manager_id"`
 	IsVerified  bool    `json:"is_verified"`
 	IsActive    bool    `json:"is_active"`
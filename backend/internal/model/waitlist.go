@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Waitlist entry statuses
+const (
+	WaitlistWaiting   = "waiting"   // in line for the slot
+	WaitlistOffered   = "offered"   // the slot is held for the user until OfferExpiresAt
+	WaitlistClaimed   = "claimed"   // the user accepted the offer
+	WaitlistExpired   = "expired"   // the offer, or a claimed hold left unpaid, lapsed or the slot has passed
+	WaitlistCancelled = "cancelled" // the user left the waitlist
+)
+
+// WaitlistEntry is a user waiting for a taken facility slot to free up
+type WaitlistEntry struct {
+	ID             int64      `json:"id"`
+	UserID         int64      `json:"user_id"`
+	FacilityID     int64      `json:"facility_id"`
+	StartTime      time.Time  `json:"start_time"`
+	EndTime        time.Time  `json:"end_time"`
+	Units          int        `json:"units"`
+	Status         string     `json:"status"`
+	ReservationID  *int64     `json:"reservation_id,omitempty"` // Pending reservation holding the offered slot
+	OfferedAt      *time.Time `json:"offered_at,omitempty"`
+	OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty"`
+	Offered        bool       `json:"offered"` // The slot is currently held for the user to claim
+	CreatedAt      time.Time  `json:"created_at"`
+}
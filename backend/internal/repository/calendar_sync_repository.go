@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+type CalendarSyncRepository struct {
+	db *sql.DB
+}
+
+func NewCalendarSyncRepository(db *sql.DB) *CalendarSyncRepository {
+	return &CalendarSyncRepository{db: db}
+}
+
+const calendarSyncColumns = `id, reservation_id, user_id, action, status, attempts, next_attempt_at, last_error, created_at, updated_at`
+
+// EnqueueJob queues a calendar change for a reservation, unless the same change is already pending
+func (r *CalendarSyncRepository) EnqueueJob(reservationID, userID int64, action string) error {
+	query := `
+		INSERT INTO calendar_sync_jobs (reservation_id, user_id, action)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (reservation_id, action) WHERE status = 'pending' DO NOTHING
+	`
+	_, err := r.db.Exec(query, reservationID, userID, action)
+	return err
+}
+
+// ClaimDueJobs claims up to limit due jobs, each the oldest pending job of its reservation so changes
+// apply in order. Claimed jobs count an attempt and are leased until lease passes, after which
+// another worker may claim them if the attempt never finished.
+func (r *CalendarSyncRepository) ClaimDueJobs(limit int, lease time.Duration) ([]model.CalendarSyncJob, error) {
+	query := `
+		UPDATE calendar_sync_jobs
+		SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id IN (
+			SELECT j.id FROM calendar_sync_jobs j
+			WHERE j.status = 'pending' AND j.next_attempt_at <= NOW()
+			AND NOT EXISTS (
+				SELECT 1 FROM calendar_sync_jobs earlier
+				WHERE earlier.reservation_id = j.reservation_id AND earlier.status = 'pending' AND earlier.id < j.id
+			)
+			ORDER BY j.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + calendarSyncColumns
+
+	rows, err := r.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []model.CalendarSyncJob
+	for rows.Next() {
+		job, err := scanCalendarSyncJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// FinishJob records the final status of a job; message explains a skipped or failed job
+func (r *CalendarSyncRepository) FinishJob(jobID int64, status string, message *string) error {
+	query := `UPDATE calendar_sync_jobs SET status = $2, last_error = $3, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.Exec(query, jobID, status, message)
+	return err
+}
+
+// RetryJob schedules another attempt of a job after a failed one
+func (r *CalendarSyncRepository) RetryJob(jobID int64, nextAttemptAt time.Time, lastError string) error {
+	query := `UPDATE calendar_sync_jobs SET next_attempt_at = $2, last_error = $3, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.Exec(query, jobID, nextAttemptAt, lastError)
+	return err
+}
+
+// GetLatestJob returns the most recently queued job of a reservation, or nil when none was queued
+func (r *CalendarSyncRepository) GetLatestJob(reservationID int64) (*model.CalendarSyncJob, error) {
+	query := `SELECT ` + calendarSyncColumns + ` FROM calendar_sync_jobs WHERE reservation_id = $1 ORDER BY id DESC LIMIT 1`
+
+	job, err := scanCalendarSyncJob(r.db.QueryRow(query, reservationID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+func scanCalendarSyncJob(row interface{ Scan(...interface{}) error }) (*model.CalendarSyncJob, error) {
+	var job model.CalendarSyncJob
+	err := row.Scan(
+		&job.ID, &job.ReservationID, &job.UserID, &job.Action, &job.Status, &job.Attempts,
+		&job.NextAttemptAt, &job.LastError, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetCalDAVAccount returns the user's CalDAV calendar, or nil when none is connected
+func (r *CalendarSyncRepository) GetCalDAVAccount(userID int64) (*model.CalDAVAccount, error) {
+	query := `SELECT user_id, calendar_url, username, password, created_at, updated_at FROM caldav_accounts WHERE user_id = $1`
+
+	var account model.CalDAVAccount
+	err := r.db.QueryRow(query, userID).Scan(&account.UserID, &account.CalendarURL, &account.Username,
+		&account.Password, &account.CreatedAt, &account.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// SaveCalDAVAccount connects or replaces the user's CalDAV calendar
+func (r *CalendarSyncRepository) SaveCalDAVAccount(account *model.CalDAVAccount) error {
+	query := `
+		INSERT INTO caldav_accounts (user_id, calendar_url, username, password)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET calendar_url = EXCLUDED.calendar_url, username = EXCLUDED.username, password = EXCLUDED.password, updated_at = NOW()
+		RETURNING created_at, updated_at
+	`
+	return r.db.QueryRow(query, account.UserID, account.CalendarURL, account.Username, account.Password).
+		Scan(&account.CreatedAt, &account.UpdatedAt)
+}
+
+// DeleteCalDAVAccount disconnects the user's CalDAV calendar
+func (r *CalendarSyncRepository) DeleteCalDAVAccount(userID int64) error {
+	_, err := r.db.Exec(`DELETE FROM caldav_accounts WHERE user_id = $1`, userID)
+	return err
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+type CancellationPolicyRepository struct {
+	db *sql.DB
+}
+
+func NewCancellationPolicyRepository(db *sql.DB) *CancellationPolicyRepository {
+	return &CancellationPolicyRepository{db: db}
+}
+
+const cancellationRuleColumns = `id, facility_id, sport_complex_id, hours_before, refund_percent, created_at`
+
+// ReplaceFacilityRules replaces all cancellation rules of a facility, filling in the new rule IDs
+func (r *CancellationPolicyRepository) ReplaceFacilityRules(facilityID int64, rules []model.CancellationRule) error {
+	return r.replaceRules("facility_id", facilityID, rules)
+}
+
+// ReplaceComplexRules replaces all cancellation rules of a sport complex, filling in the new rule IDs
+func (r *CancellationPolicyRepository) ReplaceComplexRules(complexID int64, rules []model.CancellationRule) error {
+	return r.replaceRules("sport_complex_id", complexID, rules)
+}
+
+// GetFacilityPolicy returns the policy that applies to a facility: its own rules,
+// or those of its sport complex when it has none
+func (r *CancellationPolicyRepository) GetFacilityPolicy(facilityID int64) (*model.CancellationPolicy, error) {
+	return getFacilityCancellationPolicy(r.db, facilityID)
+}
+
+// GetComplexPolicy returns the rules defined for a whole sport complex
+func (r *CancellationPolicyRepository) GetComplexPolicy(complexID int64) (*model.CancellationPolicy, error) {
+	rules, err := queryCancellationRules(r.db, `SELECT `+cancellationRuleColumns+`
+		FROM cancellation_policy_rules
+		WHERE sport_complex_id = $1
+		ORDER BY hours_before DESC`, complexID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CancellationPolicy{SportComplexID: &complexID, Rules: rules}, nil
+}
+
+// replaceRules deletes the existing rules of the target and inserts the given ones in a single transaction.
+// column is either facility_id or sport_complex_id.
+func (r *CancellationPolicyRepository) replaceRules(column string, id int64, rules []model.CancellationRule) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM cancellation_policy_rules WHERE `+column+` = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete cancellation rules: %w", err)
+	}
+
+	query := `
+		INSERT INTO cancellation_policy_rules (` + column + `, hours_before, refund_percent)
+		VALUES ($1, $2, $3)
+		RETURNING ` + cancellationRuleColumns
+	for i := range rules {
+		rule := &rules[i]
+		err := tx.QueryRow(query, id, rule.HoursBefore, rule.RefundPercent).Scan(
+			&rule.ID, &rule.FacilityID, &rule.SportComplexID, &rule.HoursBefore, &rule.RefundPercent, &rule.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create cancellation rule: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func getFacilityCancellationPolicy(db *sql.DB, facilityID int64) (*model.CancellationPolicy, error) {
+	policy := &model.CancellationPolicy{FacilityID: &facilityID}
+
+	rules, err := queryCancellationRules(db, `SELECT `+cancellationRuleColumns+`
+		FROM cancellation_policy_rules
+		WHERE facility_id = $1
+		ORDER BY hours_before DESC`, facilityID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rules) == 0 {
+		rules, err = queryCancellationRules(db, `SELECT `+cancellationRuleColumns+`
+			FROM cancellation_policy_rules
+			WHERE sport_complex_id = (SELECT sport_complex_id FROM facilities WHERE id = $1)
+			ORDER BY hours_before DESC`, facilityID)
+		if err != nil {
+			return nil, err
+		}
+		policy.Inherited = len(rules) > 0
+	}
+
+	policy.Rules = rules
+	return policy, nil
+}
+
+func queryCancellationRules(db *sql.DB, query string, args ...interface{}) ([]model.CancellationRule, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []model.CancellationRule{}
+	for rows.Next() {
+		var rule model.CancellationRule
+		err := rows.Scan(&rule.ID, &rule.FacilityID, &rule.SportComplexID, &rule.HoursBefore, &rule.RefundPercent, &rule.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
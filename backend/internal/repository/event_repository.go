@@ -205,6 +205,21 @@ func (r *EventRepository) UpdateEvent(eventID int64, updates map[string]interfac
 	return err
 }
 
+// CompleteFinishedEvents marks upcoming or full events whose end time has passed as completed.
+// It returns the number of events updated.
+func (r *EventRepository) CompleteFinishedEvents() (int64, error) {
+	query := `
+		UPDATE events
+		SET status = 'COMPLETED', updated_at = NOW()
+		WHERE status IN ('UPCOMING', 'FULL') AND end_time <= NOW()
+	`
+	result, err := r.db.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // DeleteEvent deletes an event
 func (r *EventRepository) DeleteEvent(eventID int64) error {
 	query := "DELETE FROM events WHERE id = $1"
@@ -15,14 +15,14 @@ func NewFacilityRepository(db *sql.DB) *FacilityRepository {
 	return &FacilityRepository{db: db}
 }
 
-func (r *FacilityRepository) CreateFacility(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, managerID int64) (*model.Facility, error) {
+func (r *FacilityRepository) CreateFacility(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, timeZone string, managerID int64) (*model.Facility, error) {
 	query := `
-		INSERT INTO facilities (name, sport_complex_id, category_id, surface_id, environment_id, city, address, description, capacity, manager_id, is_verified, is_active)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, false, false)
+		INSERT INTO facilities (name, sport_complex_id, category_id, surface_id, environment_id, city, address, description, capacity, time_zone, manager_id, is_verified, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, false, false)
 		RETURNING id
 	`
 	var id int64
-	err := r.db.QueryRow(query, name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, managerID).Scan(&id)
+	err := r.db.QueryRow(query, name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, timeZone, managerID).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +38,7 @@ func (r *FacilityRepository) CreateFacility(name string, sportComplexID *int64,
 		Address:        address,
 		Description:    description,
 		Capacity:       capacity,
+		TimeZone:       timeZone,
 		IsVerified:     false,
 		IsActive:       false,
 	}, nil
@@ -47,7 +48,7 @@ func (r *FacilityRepository) GetAllFacilities() ([]model.FacilityDetails, error)
 	query := `
 		SELECT 
 			f.id, f.name, f.sport_complex_id, f.category_id, f.surface_id, f.environment_id, 
-			f.city, f.address, f.description, f.capacity, f.is_verified, f.is_active,
+			f.city, f.address, f.description, f.capacity, f.time_zone, f.is_verified, f.is_active,
 			c.name as category_name, s.name as surface_name, e.name as environment_name,
 			sp.name as sport_name,
 			COALESCE(sc.name, '') as sport_complex_name,
@@ -73,7 +74,7 @@ func (r *FacilityRepository) GetAllFacilities() ([]model.FacilityDetails, error)
 		err := rows.Scan(
 			&facility.ID, &facility.Name, &facility.SportComplexID, &facility.CategoryID,
 			&facility.SurfaceID, &facility.EnvironmentID, &facility.City, &facility.Address,
-			&facility.Description, &facility.Capacity, &facility.IsVerified, &facility.IsActive,
+			&facility.Description, &facility.Capacity, &facility.TimeZone, &facility.IsVerified, &facility.IsActive,
 			&facility.CategoryName, &facility.SurfaceName, &facility.EnvironmentName,
 			&facility.SportName, &facility.SportComplexName, &facility.ManagerID,
 		)
@@ -90,7 +91,7 @@ func (r *FacilityRepository) SearchFacilities(params model.FacilitySearchParams)
 	query := `
 		SELECT 
 			f.id, f.name, f.sport_complex_id, f.category_id, f.surface_id, f.environment_id, 
-			f.city, f.address, f.description, f.capacity, f.is_verified, f.is_active,
+			f.city, f.address, f.description, f.capacity, f.time_zone, f.is_verified, f.is_active,
 			c.name as category_name, s.name as surface_name, e.name as environment_name,
 			sp.name as sport_name,
 			COALESCE(sc.name, '') as sport_complex_name,
@@ -180,7 +181,7 @@ func (r *FacilityRepository) SearchFacilities(params model.FacilitySearchParams)
 		err := rows.Scan(
 			&facility.ID, &facility.Name, &facility.SportComplexID, &facility.CategoryID,
 			&facility.SurfaceID, &facility.EnvironmentID, &facility.City, &facility.Address,
-			&facility.Description, &facility.Capacity, &facility.IsVerified, &facility.IsActive,
+			&facility.Description, &facility.Capacity, &facility.TimeZone, &facility.IsVerified, &facility.IsActive,
 			&facility.CategoryName, &facility.SurfaceName, &facility.EnvironmentName,
 			&facility.SportName, &facility.SportComplexName, &facility.ManagerID,
 		)
@@ -197,7 +198,7 @@ func (r *FacilityRepository) GetFacilityByID(id int64) (*model.FacilityDetails,
 	query := `
 		SELECT 
 			f.id, f.name, f.sport_complex_id, f.category_id, f.surface_id, f.environment_id, 
-			f.city, f.address, f.description, f.capacity, f.is_verified, f.is_active,
+			f.city, f.address, f.description, f.capacity, f.time_zone, f.is_verified, f.is_active,
 			c.name as category_name, s.name as surface_name, e.name as environment_name,
 			sp.name as sport_name,
 			COALESCE(sc.name, '') as sport_complex_name,
@@ -214,7 +215,7 @@ func (r *FacilityRepository) GetFacilityByID(id int64) (*model.FacilityDetails,
 	err := r.db.QueryRow(query, id).Scan(
 		&facility.ID, &facility.Name, &facility.SportComplexID, &facility.CategoryID,
 		&facility.SurfaceID, &facility.EnvironmentID, &facility.City, &facility.Address,
-		&facility.Description, &facility.Capacity, &facility.IsVerified, &facility.IsActive,
+		&facility.Description, &facility.Capacity, &facility.TimeZone, &facility.IsVerified, &facility.IsActive,
 		&facility.CategoryName, &facility.SurfaceName, &facility.EnvironmentName,
 		&facility.SportName, &facility.SportComplexName, &facility.ManagerID,
 	)
@@ -229,7 +230,7 @@ func (r *FacilityRepository) GetFacilitiesByComplexID(complexID int64) ([]model.
 	query := `
 		SELECT 
 			f.id, f.name, f.sport_complex_id, f.category_id, f.surface_id, f.environment_id, 
-			f.city, f.address, f.description, f.capacity, f.is_verified, f.is_active,
+			f.city, f.address, f.description, f.capacity, f.time_zone, f.is_verified, f.is_active,
 			c.name as category_name, s.name as surface_name, e.name as environment_name,
 			sp.name as sport_name,
 			COALESCE(sc.name, '') as sport_complex_name,
@@ -255,7 +256,7 @@ func (r *FacilityRepository) GetFacilitiesByComplexID(complexID int64) ([]model.
 		err := rows.Scan(
 			&facility.ID, &facility.Name, &facility.SportComplexID, &facility.CategoryID,
 			&facility.SurfaceID, &facility.EnvironmentID, &facility.City, &facility.Address,
-			&facility.Description, &facility.Capacity, &facility.IsVerified, &facility.IsActive,
+			&facility.Description, &facility.Capacity, &facility.TimeZone, &facility.IsVerified, &facility.IsActive,
 			&facility.CategoryName, &facility.SurfaceName, &facility.EnvironmentName,
 			&facility.SportName, &facility.SportComplexName, &facility.ManagerID,
 		)
@@ -272,7 +273,7 @@ func (r *FacilityRepository) GetFacilitiesByManagerID(managerID int64) ([]model.
 	query := `
 		SELECT 
 			f.id, f.name, f.sport_complex_id, f.category_id, f.surface_id, f.environment_id, 
-			f.city, f.address, f.description, f.capacity, f.is_verified, f.is_active,
+			f.city, f.address, f.description, f.capacity, f.time_zone, f.is_verified, f.is_active,
 			c.name as category_name, s.name as surface_name, e.name as environment_name,
 			sp.name as sport_name,
 			COALESCE(sc.name, '') as sport_complex_name,
@@ -299,7 +300,7 @@ func (r *FacilityRepository) GetFacilitiesByManagerID(managerID int64) ([]model.
 		err := rows.Scan(
 			&facility.ID, &facility.Name, &facility.SportComplexID, &facility.CategoryID,
 			&facility.SurfaceID, &facility.EnvironmentID, &facility.City, &facility.Address,
-			&facility.Description, &facility.Capacity, &facility.IsVerified, &facility.IsActive,
+			&facility.Description, &facility.Capacity, &facility.TimeZone, &facility.IsVerified, &facility.IsActive,
 			&facility.CategoryName, &facility.SurfaceName, &facility.EnvironmentName,
 			&facility.SportName, &facility.SportComplexName, &facility.ManagerID,
 		)
@@ -316,7 +317,7 @@ func (r *FacilityRepository) GetPendingFacilities() ([]model.FacilityDetails, er
 	query := `
 		SELECT 
 			f.id, f.name, f.sport_complex_id, f.category_id, f.surface_id, f.environment_id, 
-			f.city, f.address, f.description, f.capacity, f.is_verified, f.is_active,
+			f.city, f.address, f.description, f.capacity, f.time_zone, f.is_verified, f.is_active,
 			c.name as category_name, s.name as surface_name, e.name as environment_name,
 			sp.name as sport_name,
 			COALESCE(sc.name, '') as sport_complex_name,
@@ -344,7 +345,7 @@ func (r *FacilityRepository) GetPendingFacilities() ([]model.FacilityDetails, er
 		err := rows.Scan(
 			&facility.ID, &facility.Name, &facility.SportComplexID, &facility.CategoryID,
 			&facility.SurfaceID, &facility.EnvironmentID, &facility.City, &facility.Address,
-			&facility.Description, &facility.Capacity, &facility.IsVerified, &facility.IsActive,
+			&facility.Description, &facility.Capacity, &facility.TimeZone, &facility.IsVerified, &facility.IsActive,
 			&facility.CategoryName, &facility.SurfaceName, &facility.EnvironmentName,
 			&facility.SportName, &facility.SportComplexName,
 			&facility.ManagerName, &facility.ManagerEmail,
@@ -379,14 +380,16 @@ func (r *FacilityRepository) ToggleFacilityStatus(id int64, isActive bool) error
 	return err
 }
 
-func (r *FacilityRepository) UpdateFacility(id int64, name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int) error {
+// UpdateFacility updates a facility's details. An empty timeZone keeps the current one.
+func (r *FacilityRepository) UpdateFacility(id int64, name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, timeZone string) error {
 	query := `
 		UPDATE facilities 
 		SET name = $1, sport_complex_id = $2, category_id = $3, surface_id = $4, 
-		    environment_id = $5, city = $6, address = $7, description = $8, capacity = $9
-		WHERE id = $10
+		    environment_id = $5, city = $6, address = $7, description = $8, capacity = $9,
+		    time_zone = COALESCE(NULLIF($10, ''), time_zone)
+		WHERE id = $11
 	`
-	_, err := r.db.Exec(query, name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, id)
+	_, err := r.db.Exec(query, name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, timeZone, id)
 	return err
 }
 
@@ -420,13 +423,34 @@ func (r *FacilityRepository) CreatePricing(pricing *model.FacilityPricing) error
 	return r.db.QueryRow(query, pricing.FacilityID, pricing.DayType, pricing.StartHour, pricing.EndHour, pricing.PricePerHour).Scan(&pricing.ID)
 }
 
+// UpsertBookingPolicy creates or replaces the booking policy of a facility
+func (r *FacilityRepository) UpsertBookingPolicy(policy *model.FacilityBookingPolicy) error {
+	query := `
+		INSERT INTO facility_booking_policies (facility_id, slot_minutes, min_duration_minutes, max_duration_minutes, buffer_minutes,
+		                                       allow_reschedule, reschedule_cutoff_minutes, max_reschedules, availability_mode)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (facility_id) DO UPDATE
+		SET slot_minutes = EXCLUDED.slot_minutes,
+		    min_duration_minutes = EXCLUDED.min_duration_minutes,
+		    max_duration_minutes = EXCLUDED.max_duration_minutes,
+		    buffer_minutes = EXCLUDED.buffer_minutes,
+		    allow_reschedule = EXCLUDED.allow_reschedule,
+		    reschedule_cutoff_minutes = EXCLUDED.reschedule_cutoff_minutes,
+		    max_reschedules = EXCLUDED.max_reschedules,
+		    availability_mode = EXCLUDED.availability_mode
+		RETURNING id
+	`
+	return r.db.QueryRow(query, policy.FacilityID, policy.SlotMinutes, policy.MinDurationMinutes, policy.MaxDurationMinutes, policy.BufferMinutes,
+		policy.AllowReschedule, policy.RescheduleCutoffMinutes, policy.MaxReschedules, policy.AvailabilityMode).Scan(&policy.ID)
+}
+
 // GetSchedulesByFacilityID retrieves all schedules for a facility
 func (r *FacilityRepository) GetSchedulesByFacilityID(facilityID int64) ([]*model.FacilitySchedule, error) {
 	query := `
 		SELECT id, facility_id, day_type, open_time, close_time
 		FROM facility_schedules
 		WHERE facility_id = $1
-		ORDER BY day_type
+		ORDER BY array_position(ARRAY['monday', 'tuesday', 'wednesday', 'thursday', 'friday', 'saturday', 'sunday']::varchar[], day_type)
 	`
 	rows, err := r.db.Query(query, facilityID)
 	if err != nil {
@@ -453,7 +477,7 @@ func (r *FacilityRepository) GetPricingByFacilityID(facilityID int64) ([]*model.
 		SELECT id, facility_id, day_type, start_hour, end_hour, price_per_hour
 		FROM facility_pricings
 		WHERE facility_id = $1
-		ORDER BY day_type, start_hour
+		ORDER BY array_position(ARRAY['monday', 'tuesday', 'wednesday', 'thursday', 'friday', 'saturday', 'sunday']::varchar[], day_type), start_hour
 	`
 	rows, err := r.db.Query(query, facilityID)
 	if err != nil {
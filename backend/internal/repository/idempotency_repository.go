@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+const idempotencyColumns = `user_id, idempotency_key, request_hash, status_code, content_type, response_body, created_at, expires_at`
+
+// Claim reserves a user's idempotency key for a request until ttl passes. claimed is false when the key
+// is already held, by a request still in progress or one whose response was saved; that record is returned.
+// An expired key is claimed anew.
+func (r *IdempotencyRepository) Claim(userID int64, key, requestHash string, ttl time.Duration) (*model.IdempotencyKey, bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, expires_at)
+		VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL, response_body = NULL,
+		    created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		RETURNING ` + idempotencyColumns
+
+	record, err := scanIdempotencyKey(r.db.QueryRow(query, userID, key, requestHash, ttl.Seconds()))
+	if err == nil {
+		return record, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	query = `SELECT ` + idempotencyColumns + ` FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2`
+	record, err = scanIdempotencyKey(r.db.QueryRow(query, userID, key))
+	if err != nil {
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+// Complete saves the response to a claimed key so retries replay it
+func (r *IdempotencyRepository) Complete(userID int64, key string, statusCode int, contentType string, body []byte) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5
+		WHERE user_id = $1 AND idempotency_key = $2
+	`
+	_, err := r.db.Exec(query, userID, key, statusCode, contentType, body)
+	return err
+}
+
+// Release frees a claimed key whose request produced no response worth replaying, so a retry runs again
+func (r *IdempotencyRepository) Release(userID int64, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND status_code IS NULL`
+	_, err := r.db.Exec(query, userID, key)
+	return err
+}
+
+// DeleteExpiredKeys removes expired keys and returns how many were removed
+func (r *IdempotencyRepository) DeleteExpiredKeys() (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanIdempotencyKey(row interface{ Scan(...interface{}) error }) (*model.IdempotencyKey, error) {
+	var record model.IdempotencyKey
+	err := row.Scan(
+		&record.UserID, &record.Key, &record.RequestHash, &record.StatusCode, &record.ContentType,
+		&record.ResponseBody, &record.CreatedAt, &record.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -2,12 +2,27 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/Radi03825/PlaySpot/internal/model"
 )
 
+// ErrPaymentInProgress is returned when another request is already sending a payment to the gateway
+var ErrPaymentInProgress = errors.New("the payment is already being processed, please wait for it to finish")
+
+// ErrNoBalanceDue is returned when paying the balance of a reservation that has none left to pay
+var ErrNoBalanceDue = errors.New("nothing is left to pay for this reservation")
+
+// ErrBalanceChargeNotPending is returned when recording a balance charge that was already paid
+var ErrBalanceChargeNotPending = errors.New("balance charge not found or already paid")
+
+// ErrPaymentLapsed is returned when a payment is captured after it failed, e.g. because the reservations
+// it pays for expired or were cancelled
+var ErrPaymentLapsed = errors.New("the reservation expired before the payment completed")
+
 type PaymentRepository struct {
 	db *sql.DB
 }
@@ -16,57 +31,38 @@ func NewPaymentRepository(db *sql.DB) *PaymentRepository {
 	return &PaymentRepository{db: db}
 }
 
-func (r *PaymentRepository) CreatePayment(userID, reservationID int64, amount float64, currency string) (*model.Payment, error) {
-	query := `
-		INSERT INTO payments (user_id, reservation_id, amount, currency, payment_method, payment_status, created_at)
-		VALUES ($1, $2, $3, $4, 'pending', 'pending', NOW())
-		RETURNING id, user_id, reservation_id, amount, currency, payment_method, payment_status, expired_at, paid_at, created_at
-	`
+// paymentColumns lists the payments columns read by scanPayment
+const paymentColumns = `id, COALESCE(user_id, 0), reservation_id, amount, currency, payment_method, payment_status, balance_due, refunded_amount,
+	gateway, gateway_transaction_id, failure_reason, expired_at, authorized_at, paid_at, created_at`
 
-	var payment model.Payment
-	err := r.db.QueryRow(query, userID, reservationID, amount, currency).Scan(
-		&payment.ID,
-		&payment.UserID,
-		&payment.ReservationID,
-		&payment.Amount,
-		&payment.Currency,
-		&payment.PaymentMethod,
-		&payment.PaymentStatus,
-		&payment.ExpiredAt,
-		&payment.PaidAt,
-		&payment.CreatedAt,
-	)
+// CreatePayment creates a pending payment; expiredAt is when the reservation hold lapses and may be nil
+func (r *PaymentRepository) CreatePayment(userID, reservationID int64, amount float64, currency string, expiredAt *time.Time) (*model.Payment, error) {
+	query := `
+		INSERT INTO payments (user_id, reservation_id, amount, currency, payment_method, payment_status, expired_at, created_at)
+		VALUES ($1, $2, $3, $4, 'pending', 'pending', $5, NOW())
+		RETURNING ` + paymentColumns
 
+	payment, err := scanPayment(r.db.QueryRow(query, userID, reservationID, amount, currency, expiredAt))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create payment: %w", err)
 	}
 
-	return &payment, nil
+	return payment, nil
 }
 
+// GetPaymentByReservationID returns the latest payment covering a reservation, including a basket payment
+// covering it together with other reservations
 func (r *PaymentRepository) GetPaymentByReservationID(reservationID int64) (*model.Payment, error) {
 	query := `
-		SELECT id, user_id, reservation_id, amount, currency, payment_method, payment_status, expired_at, paid_at, created_at
+		SELECT ` + paymentColumns + `
 		FROM payments
 		WHERE reservation_id = $1
+		OR id IN (SELECT payment_id FROM payment_reservations WHERE reservation_id = $1)
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 
-	var payment model.Payment
-	err := r.db.QueryRow(query, reservationID).Scan(
-		&payment.ID,
-		&payment.UserID,
-		&payment.ReservationID,
-		&payment.Amount,
-		&payment.Currency,
-		&payment.PaymentMethod,
-		&payment.PaymentStatus,
-		&payment.ExpiredAt,
-		&payment.PaidAt,
-		&payment.CreatedAt,
-	)
-
+	payment, err := scanPayment(r.db.QueryRow(query, reservationID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -75,43 +71,602 @@ func (r *PaymentRepository) GetPaymentByReservationID(reservationID int64) (*mod
 		return nil, fmt.Errorf("failed to get payment: %w", err)
 	}
 
-	return &payment, nil
+	return payment, nil
 }
 
-func (r *PaymentRepository) ProcessPayment(paymentID int64, paymentMethod string) error {
-	now := time.Now()
-	query := `
+// ProcessPayment completes a payment that is settled without a gateway (paid on site) and confirms the
+// pending reservations it covers, in one transaction like ConfirmPayment. A pending payment qualifies unless a
+// card attempt is still with the gateway, which returns ErrPaymentInProgress, and so does a failed one, so a
+// declined card can be paid on site instead. Gateway details of the failed attempt are cleared. If any of the
+// reservations expired or all were cancelled meanwhile nothing is changed and ErrPaymentLapsed is returned.
+func (r *PaymentRepository) ProcessPayment(paymentID int64, paymentMethod string) (*model.Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var reservationID int64
+	err = tx.QueryRow(`SELECT reservation_id FROM payments WHERE id = $1`, paymentID).Scan(&reservationID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("payment not found or already processed")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	expired, active, err := lockPaymentReservations(tx, paymentID, reservationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock reservations: %w", err)
+	}
+
+	var status string
+	var inFlight bool
+	err = tx.QueryRow(`
+		SELECT payment_status, gateway_attempt_id IS NOT NULL OR gateway_transaction_id IS NOT NULL
+		FROM payments
+		WHERE id = $1
+		FOR UPDATE
+	`, paymentID).Scan(&status, &inFlight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock payment: %w", err)
+	}
+
+	switch {
+	case status == "pending" && inFlight:
+		return nil, ErrPaymentInProgress
+	case status != "pending" && status != "failed":
+		return nil, fmt.Errorf("payment not found or already processed")
+	case expired > 0 || active == 0:
+		return nil, ErrPaymentLapsed
+	}
+
+	payment, err := scanPayment(tx.QueryRow(`
 		UPDATE payments
-		SET payment_method = $1, payment_status = 'completed', paid_at = $2
-		WHERE id = $3 AND payment_status = 'pending'
-	`
+		SET payment_method = $2, payment_status = 'completed', paid_at = NOW(), failure_reason = NULL,
+		    gateway = NULL, gateway_transaction_id = NULL, gateway_attempt_id = NULL, gateway_attempt_at = NULL
+		WHERE id = $1
+		RETURNING `+paymentColumns,
+		paymentID, paymentMethod))
+	if err != nil {
+		return nil, fmt.Errorf("failed to process payment: %w", err)
+	}
+
+	if err = confirmPaymentReservations(tx, paymentID, reservationID); err != nil {
+		return nil, fmt.Errorf("failed to confirm reservations: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
 
-	result, err := r.db.Exec(query, paymentMethod, now, paymentID)
+// StartGatewayPayment claims a payment for an attempt to authorize it through gateway. A pending payment not
+// yet sent to a gateway qualifies, and so does a failed one, so a declined card can be retried with another.
+// The claim holds for hold, and the hold of its pending reservations is extended to last as long; another
+// request claiming the payment meanwhile gets ErrPaymentInProgress. It returns the attempt ID to send as the
+// gateway's idempotency key: attemptID, or the ID of an earlier attempt whose claim lapsed without an answer
+// from the gateway, so retrying it cannot authorize the payment twice.
+func (r *PaymentRepository) StartGatewayPayment(paymentID int64, paymentMethod, gateway, attemptID string, hold time.Duration) (string, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to process payment: %w", err)
+		return "", err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	var claimedID string
+	var reservationID int64
+	err = tx.QueryRow(`
+		UPDATE payments
+		SET payment_method = $2, payment_status = 'pending', gateway = $3,
+		    gateway_transaction_id = NULL, failure_reason = NULL,
+		    gateway_attempt_id = CASE
+		        WHEN payment_status = 'pending' AND gateway_attempt_id IS NOT NULL THEN gateway_attempt_id
+		        ELSE $4
+		    END,
+		    gateway_attempt_at = NOW()
+		WHERE id = $1
+		AND ((payment_status = 'pending' AND gateway_transaction_id IS NULL
+		      AND (gateway_attempt_id IS NULL OR gateway_attempt_at <= NOW() - $5::double precision * INTERVAL '1 second'))
+		     OR payment_status = 'failed')
+		RETURNING gateway_attempt_id, reservation_id
+	`, paymentID, paymentMethod, gateway, attemptID, hold.Seconds()).Scan(&claimedID, &reservationID)
+	if err == sql.ErrNoRows {
+		return "", ErrPaymentInProgress
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return "", fmt.Errorf("failed to start payment: %w", err)
 	}
 
-	if rowsAffected == 0 {
+	if err = extendPaymentHold(tx, paymentID, reservationID, hold); err != nil {
+		return "", fmt.Errorf("failed to extend reservation hold: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return claimedID, nil
+}
+
+// SetPaymentTransaction records the gateway transaction of a payment whose outcome is not known yet and
+// extends the hold of its pending reservations by hold, so they do not lapse while the gateway settles it
+func (r *PaymentRepository) SetPaymentTransaction(paymentID int64, transactionID string, hold time.Duration) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var reservationID int64
+	err = tx.QueryRow(`
+		UPDATE payments
+		SET gateway_transaction_id = $2
+		WHERE id = $1 AND payment_status = 'pending'
+		RETURNING reservation_id
+	`, paymentID, transactionID).Scan(&reservationID)
+	if err == sql.ErrNoRows {
 		return fmt.Errorf("payment not found or already processed")
 	}
+	if err != nil {
+		return fmt.Errorf("failed to record payment transaction: %w", err)
+	}
 
-	return nil
+	if err = extendPaymentHold(tx, paymentID, reservationID, hold); err != nil {
+		return fmt.Errorf("failed to extend reservation hold: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// extendPaymentHold makes the unexpired holds of the pending reservations a payment covers last at least
+// hold from now. Holds that already lapsed are left to expire.
+func extendPaymentHold(tx *sql.Tx, paymentID, reservationID int64, hold time.Duration) error {
+	_, err := tx.Exec(`
+		UPDATE facility_reservations
+		SET expires_at = GREATEST(expires_at, NOW() + $3::double precision * INTERVAL '1 second')
+		WHERE status = 'pending' AND expires_at > NOW()
+		AND (id = $2 OR id IN (SELECT reservation_id FROM payment_reservations WHERE payment_id = $1))
+	`, paymentID, reservationID, hold.Seconds())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE payments
+		SET expired_at = GREATEST(expired_at, NOW() + $2::double precision * INTERVAL '1 second')
+		WHERE id = $1 AND expired_at > NOW()
+	`, paymentID, hold.Seconds())
+	return err
+}
+
+// AuthorizePayment moves a pending payment to authorized once the gateway reserved the funds
+func (r *PaymentRepository) AuthorizePayment(paymentID int64, transactionID string) error {
+	result, err := r.db.Exec(`
+		UPDATE payments
+		SET payment_status = 'authorized', gateway_transaction_id = $2, authorized_at = NOW()
+		WHERE id = $1 AND payment_status = 'pending'
+	`, paymentID, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to authorize payment: %w", err)
+	}
+
+	return expectPaymentUpdated(result)
+}
+
+// ConfirmPayment completes a pending or authorized payment once the gateway captured it and confirms the
+// pending reservations it covers, in one transaction so a paid booking is never left unconfirmed.
+// A payment that already failed, or whose reservations expired or were all cancelled meanwhile, is left
+// failed and ErrPaymentLapsed returned, so the captured amount can be given back.
+func (r *PaymentRepository) ConfirmPayment(paymentID int64) (*model.Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var reservationID int64
+	err = tx.QueryRow(`SELECT reservation_id FROM payments WHERE id = $1`, paymentID).Scan(&reservationID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("payment not found or already processed")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	expired, active, err := lockPaymentReservations(tx, paymentID, reservationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock reservations: %w", err)
+	}
+
+	var status string
+	err = tx.QueryRow(`SELECT payment_status FROM payments WHERE id = $1 FOR UPDATE`, paymentID).Scan(&status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock payment: %w", err)
+	}
+	switch {
+	case status == "failed":
+		return nil, ErrPaymentLapsed
+	case status != "pending" && status != "authorized":
+		return nil, fmt.Errorf("payment not found or already processed")
+	case expired > 0 || active == 0:
+		_, err = tx.Exec(`
+			UPDATE payments SET payment_status = 'failed', failure_reason = $2 WHERE id = $1
+		`, paymentID, ErrPaymentLapsed.Error())
+		if err != nil {
+			return nil, fmt.Errorf("failed to record lapsed payment: %w", err)
+		}
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrPaymentLapsed
+	}
+
+	payment, err := scanPayment(tx.QueryRow(`
+		UPDATE payments
+		SET payment_status = 'completed', paid_at = NOW()
+		WHERE id = $1
+		RETURNING `+paymentColumns,
+		paymentID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete payment: %w", err)
+	}
+
+	if err = confirmPaymentReservations(tx, paymentID, payment.ReservationID); err != nil {
+		return nil, fmt.Errorf("failed to confirm reservations: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// lockPaymentReservations locks the reservations a payment covers, in the order expiry and cancellation do,
+// so neither can lapse them until the payment is settled. It returns how many lapsed and how many are still
+// active. A pending hold that ran out counts as lapsed even before the expiry job recorded it, since its slot
+// may have been booked again.
+func lockPaymentReservations(tx *sql.Tx, paymentID, reservationID int64) (expired, active int, err error) {
+	err = tx.QueryRow(`
+		WITH covered AS (
+			SELECT status, status = 'expired' OR (status = 'pending' AND COALESCE(expires_at <= NOW(), FALSE)) AS lapsed
+			FROM facility_reservations
+			WHERE id = $2 OR id IN (SELECT reservation_id FROM payment_reservations WHERE payment_id = $1)
+			ORDER BY id
+			FOR UPDATE
+		)
+		SELECT COUNT(*) FILTER (WHERE lapsed),
+		       COUNT(*) FILTER (WHERE NOT lapsed AND status IN ('pending', 'confirmed', 'completed'))
+		FROM covered
+	`, paymentID, reservationID).Scan(&expired, &active)
+	return expired, active, err
+}
+
+// confirmPaymentReservations confirms the pending reservations a payment covers whose hold has not run out.
+// A basket payment covers its reservations through payment_reservations.
+func confirmPaymentReservations(tx *sql.Tx, paymentID, reservationID int64) error {
+	_, err := tx.Exec(`
+		UPDATE facility_reservations
+		SET status = 'confirmed'
+		WHERE status = 'pending' AND COALESCE(expires_at > NOW(), TRUE)
+		AND (id = $2 OR id IN (SELECT reservation_id FROM payment_reservations WHERE payment_id = $1))
+	`, paymentID, reservationID)
+	return err
+}
+
+// SetLapsedTransaction records the transaction the gateway returned for a payment that failed while the
+// gateway was authorizing it, so the funds it reserved or took can be given back
+func (r *PaymentRepository) SetLapsedTransaction(paymentID int64, transactionID string) error {
+	result, err := r.db.Exec(`
+		UPDATE payments
+		SET gateway_transaction_id = $2
+		WHERE id = $1 AND payment_status = 'failed' AND gateway_transaction_id IS NULL
+	`, paymentID, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to record payment transaction: %w", err)
+	}
+
+	return expectPaymentUpdated(result)
+}
+
+// FailPayment marks a pending or authorized payment failed with the gateway's reason.
+// transactionID is recorded when the gateway returned one.
+func (r *PaymentRepository) FailPayment(paymentID int64, transactionID, reason string) error {
+	result, err := r.db.Exec(`
+		UPDATE payments
+		SET payment_status = 'failed', failure_reason = $3,
+		    gateway_transaction_id = COALESCE(NULLIF($2, ''), gateway_transaction_id)
+		WHERE id = $1 AND payment_status IN ('pending', 'authorized')
+	`, paymentID, transactionID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to record failed payment: %w", err)
+	}
+
+	return expectPaymentUpdated(result)
 }
 
 func (r *PaymentRepository) GetPaymentByID(paymentID int64) (*model.Payment, error) {
 	query := `
-		SELECT id, user_id, reservation_id, amount, currency, payment_method, payment_status, expired_at, paid_at, created_at
+		SELECT ` + paymentColumns + `
 		FROM payments
 		WHERE id = $1
 	`
 
+	payment, err := scanPayment(r.db.QueryRow(query, paymentID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// GetPaymentByTransaction returns the payment processed by gateway as transactionID, or nil when none is
+func (r *PaymentRepository) GetPaymentByTransaction(gateway, transactionID string) (*model.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE gateway = $1 AND gateway_transaction_id = $2`
+
+	payment, err := scanPayment(r.db.QueryRow(query, gateway, transactionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// GetPaymentReservationIDs returns the reservations covered by a basket payment, or none for a single-reservation payment
+func (r *PaymentRepository) GetPaymentReservationIDs(paymentID int64) ([]int64, error) {
+	rows, err := r.db.Query(`
+		SELECT reservation_id
+		FROM payment_reservations
+		WHERE payment_id = $1
+		ORDER BY reservation_id
+	`, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var reservationIDs []int64
+	for rows.Next() {
+		var reservationID int64
+		if err := rows.Scan(&reservationID); err != nil {
+			return nil, fmt.Errorf("failed to scan payment reservation: %w", err)
+		}
+		reservationIDs = append(reservationIDs, reservationID)
+	}
+
+	return reservationIDs, rows.Err()
+}
+
+// balanceChargeColumns lists the payment_balance_charges columns read by scanBalanceCharge
+const balanceChargeColumns = `id, payment_id, amount, status, gateway, gateway_transaction_id, failure_reason, refunded_amount, paid_at, created_at`
+
+// StartBalanceCharge claims the pending balance charge of a completed payment for an attempt to pay its
+// balance due through gateway, for hold, creating the charge when there is none. The charge is for the whole
+// balance due. Returns ErrNoBalanceDue when nothing is left to pay, and ErrPaymentInProgress when another
+// request claimed the charge meanwhile or the gateway is settling an earlier attempt. It also returns the
+// attempt ID to send as the gateway's idempotency key: attemptID, or the ID of an earlier attempt whose claim
+// lapsed without an answer from the gateway.
+func (r *PaymentRepository) StartBalanceCharge(paymentID int64, gateway, attemptID string, hold time.Duration) (*model.BalanceCharge, string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	var balanceDue float64
+	err = tx.QueryRow(`
+		SELECT balance_due FROM payments
+		WHERE id = $1 AND payment_status IN ('completed', 'partially_refunded', 'refunded')
+		FOR UPDATE
+	`, paymentID).Scan(&balanceDue)
+	if err == sql.ErrNoRows || (err == nil && balanceDue <= 0) {
+		return nil, "", ErrNoBalanceDue
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var claimedID string
+	charge, err := scanBalanceCharge(tx.QueryRow(`
+		UPDATE payment_balance_charges
+		SET amount = $2, gateway = $3, failure_reason = NULL,
+		    gateway_attempt_id = COALESCE(gateway_attempt_id, $4),
+		    gateway_attempt_at = NOW()
+		WHERE payment_id = $1 AND status = 'pending' AND gateway_transaction_id IS NULL
+		AND (gateway_attempt_id IS NULL OR gateway_attempt_at <= NOW() - $5::double precision * INTERVAL '1 second')
+		RETURNING `+balanceChargeColumns+`, gateway_attempt_id`,
+		paymentID, balanceDue, gateway, attemptID, hold.Seconds()), &claimedID)
+	if err == sql.ErrNoRows {
+		var pending bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM payment_balance_charges WHERE payment_id = $1 AND status = 'pending')`, paymentID).Scan(&pending)
+		if err != nil {
+			return nil, "", err
+		}
+		if pending {
+			return nil, "", ErrPaymentInProgress
+		}
+
+		charge, err = scanBalanceCharge(tx.QueryRow(`
+			INSERT INTO payment_balance_charges (payment_id, amount, gateway, gateway_attempt_id, gateway_attempt_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			RETURNING `+balanceChargeColumns+`, gateway_attempt_id`,
+			paymentID, balanceDue, gateway, attemptID), &claimedID)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to start balance charge: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, "", err
+	}
+
+	return charge, claimedID, nil
+}
+
+// SetBalanceChargeTransaction records the transaction of a balance charge the gateway settles asynchronously
+func (r *PaymentRepository) SetBalanceChargeTransaction(chargeID int64, transactionID string) error {
+	result, err := r.db.Exec(`
+		UPDATE payment_balance_charges
+		SET gateway_transaction_id = $2
+		WHERE id = $1 AND status = 'pending'
+	`, chargeID, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to record balance charge transaction: %w", err)
+	}
+	return expectPaymentUpdated(result)
+}
+
+// FailBalanceCharge records why the gateway declined a balance charge and ends the attempt; the charge stays
+// pending and can be paid again
+func (r *PaymentRepository) FailBalanceCharge(chargeID int64, reason string) error {
+	_, err := r.db.Exec(`
+		UPDATE payment_balance_charges
+		SET failure_reason = $2, gateway_transaction_id = NULL, gateway_attempt_id = NULL
+		WHERE id = $1 AND status = 'pending'
+	`, chargeID, reason)
+	return err
+}
+
+// PayBalanceCharge marks a pending balance charge paid with its gateway transaction and adds it to the payment's
+// amount, taking it off the balance due. Whatever the charge took beyond the balance still due, e.g. because the
+// reservation was cancelled meanwhile, is recorded as a pending refund. Returns ErrBalanceChargeNotPending when
+// the charge was already paid.
+func (r *PaymentRepository) PayBalanceCharge(chargeID int64, transactionID string) (*model.Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var paymentID int64
+	var amount float64
+	err = tx.QueryRow(`
+		UPDATE payment_balance_charges
+		SET status = 'paid', gateway_transaction_id = $2, failure_reason = NULL, paid_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING payment_id, amount
+	`, chargeID, transactionID).Scan(&paymentID, &amount)
+	if err == sql.ErrNoRows {
+		return nil, ErrBalanceChargeNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var balanceDue float64
+	var reservationID int64
+	err = tx.QueryRow(`
+		UPDATE payments
+		SET amount = amount + $2, balance_due = balance_due - $2
+		WHERE id = $1
+		RETURNING balance_due, reservation_id
+	`, paymentID, amount).Scan(&balanceDue, &reservationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if excess := math.Round(-balanceDue*100) / 100; excess > 0 {
+		reason := "Charged beyond the balance due"
+		if _, err = insertRefund(tx, paymentID, &reservationID, excess, model.RefundInitiatorSystem, nil, &reason); err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`UPDATE payments SET refunded_amount = refunded_amount + $2, balance_due = 0 WHERE id = $1`, paymentID, excess)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	payment, err := scanPayment(tx.QueryRow(`UPDATE payments SET payment_status = `+refundedAmountStatus+` WHERE id = $1 RETURNING `+paymentColumns, paymentID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// GetBalanceCharge returns a balance charge, or nil when it does not exist
+func (r *PaymentRepository) GetBalanceCharge(chargeID int64) (*model.BalanceCharge, error) {
+	charge, err := scanBalanceCharge(r.db.QueryRow(`SELECT `+balanceChargeColumns+` FROM payment_balance_charges WHERE id = $1`, chargeID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return charge, err
+}
+
+// GetBalanceChargeByTransaction returns the balance charge paid through gateway as transactionID, or nil when none is
+func (r *PaymentRepository) GetBalanceChargeByTransaction(gateway, transactionID string) (*model.BalanceCharge, error) {
+	charge, err := scanBalanceCharge(r.db.QueryRow(`
+		SELECT `+balanceChargeColumns+` FROM payment_balance_charges WHERE gateway = $1 AND gateway_transaction_id = $2
+	`, gateway, transactionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return charge, err
+}
+
+// GetRefundableBalanceCharges returns the paid balance charges of a payment with money left to refund, newest first
+func (r *PaymentRepository) GetRefundableBalanceCharges(paymentID int64) ([]model.BalanceCharge, error) {
+	rows, err := r.db.Query(`
+		SELECT `+balanceChargeColumns+`
+		FROM payment_balance_charges
+		WHERE payment_id = $1 AND status = 'paid' AND amount > refunded_amount
+		ORDER BY id DESC
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var charges []model.BalanceCharge
+	for rows.Next() {
+		charge, err := scanBalanceCharge(rows)
+		if err != nil {
+			return nil, err
+		}
+		charges = append(charges, *charge)
+	}
+
+	return charges, rows.Err()
+}
+
+// AddBalanceChargeRefund records amount returned from a paid balance charge
+func (r *PaymentRepository) AddBalanceChargeRefund(chargeID int64, amount float64) error {
+	_, err := r.db.Exec(`UPDATE payment_balance_charges SET refunded_amount = refunded_amount + $2 WHERE id = $1`, chargeID, amount)
+	return err
+}
+
+// expectPaymentUpdated fails when a payment status change matched no row
+func expectPaymentUpdated(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("payment not found or already processed")
+	}
+
+	return nil
+}
+
+func scanPayment(row interface{ Scan(...interface{}) error }) (*model.Payment, error) {
 	var payment model.Payment
-	err := r.db.QueryRow(query, paymentID).Scan(
+	err := row.Scan(
 		&payment.ID,
 		&payment.UserID,
 		&payment.ReservationID,
@@ -119,18 +674,31 @@ func (r *PaymentRepository) GetPaymentByID(paymentID int64) (*model.Payment, err
 		&payment.Currency,
 		&payment.PaymentMethod,
 		&payment.PaymentStatus,
+		&payment.BalanceDue,
+		&payment.RefundedAmount,
+		&payment.Gateway,
+		&payment.GatewayTransactionID,
+		&payment.FailureReason,
 		&payment.ExpiredAt,
+		&payment.AuthorizedAt,
 		&payment.PaidAt,
 		&payment.CreatedAt,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to get payment: %w", err)
+		return nil, err
 	}
-
 	return &payment, nil
 }
+
+// scanBalanceCharge scans balanceChargeColumns followed by any extra columns into extra
+func scanBalanceCharge(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*model.BalanceCharge, error) {
+	var charge model.BalanceCharge
+	dest := []interface{}{
+		&charge.ID, &charge.PaymentID, &charge.Amount, &charge.Status, &charge.Gateway, &charge.GatewayTransactionID,
+		&charge.FailureReason, &charge.RefundedAmount, &charge.PaidAt, &charge.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
@@ -0,0 +1,441 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// ErrNothingToSplit is returned when splitting a payment that paid or settling shares already cover
+var ErrNothingToSplit = errors.New("the shares already paid cover the booking")
+
+// ErrShareNotPending is returned when paying a share that was already paid or cancelled
+var ErrShareNotPending = errors.New("share not found or already paid")
+
+type PaymentShareRepository struct {
+	db *sql.DB
+}
+
+func NewPaymentShareRepository(db *sql.DB) *PaymentShareRepository {
+	return &PaymentShareRepository{db: db}
+}
+
+// shareColumns lists the payment_shares columns, aliased s, read by scanShare
+const shareColumns = `s.id, s.payment_id, s.event_id, s.user_id, s.amount, s.status, s.gateway, s.gateway_transaction_id,
+	s.failure_reason, s.refunded_amount, s.reminders_sent, s.last_reminded_at, s.paid_at, s.created_at`
+
+// SplitPayment splits what is left of a booking's payment into equal shares for userIDs, in cents, with
+// any remainder going to the first users. Shares already paid, or being settled by the gateway, are kept
+// and their users get no new share; other pending shares are cancelled. The payment becomes a pending
+// split payment and the reservation is confirmed, since the slot is kept while the shares are collected.
+// Returns ErrNothingToSplit if no amount is left to split, and ErrPaymentInProgress while a card attempt
+// for the whole payment is with the gateway.
+func (r *PaymentShareRepository) SplitPayment(paymentID, reservationID, eventID int64, userIDs []int64) (*model.Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var amount float64
+	var status string
+	var inFlight bool
+	err = tx.QueryRow(`
+		SELECT amount, payment_status, gateway_attempt_id IS NOT NULL OR gateway_transaction_id IS NOT NULL
+		FROM payments
+		WHERE id = $1
+		FOR UPDATE
+	`, paymentID).Scan(&amount, &status, &inFlight)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case status == "pending" && inFlight:
+		return nil, ErrPaymentInProgress
+	case status != "pending" && status != "failed":
+		return nil, fmt.Errorf("payment is already %s", status)
+	}
+
+	result, err := tx.Exec(`
+		UPDATE facility_reservations
+		SET status = 'confirmed'
+		WHERE id = $1
+		AND (status = 'confirmed' OR (status = 'pending' AND (expires_at IS NULL OR expires_at > NOW())))
+	`, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, errors.New("reservation is no longer active")
+	}
+
+	// Shares paid or being settled keep their amounts
+	committed := map[int64]bool{}
+	var committedAmount float64
+	rows, err := tx.Query(`
+		SELECT user_id, amount
+		FROM payment_shares
+		WHERE payment_id = $1
+		AND (status = 'paid' OR (status = 'pending' AND (gateway_transaction_id IS NOT NULL OR gateway_attempt_id IS NOT NULL)))
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var userID int64
+		var shareAmount float64
+		if err := rows.Scan(&userID, &shareAmount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		committed[userID] = true
+		committedAmount += shareAmount
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var payers []int64
+	for _, userID := range userIDs {
+		if !committed[userID] {
+			payers = append(payers, userID)
+		}
+	}
+
+	left := int64(math.Round((amount - committedAmount) * 100))
+	if left <= 0 {
+		return nil, ErrNothingToSplit
+	}
+	if len(payers) == 0 {
+		return nil, errors.New("every participant has already paid a share")
+	}
+
+	_, err = tx.Exec(`
+		UPDATE payment_shares
+		SET status = 'cancelled'
+		WHERE payment_id = $1 AND status = 'pending' AND gateway_transaction_id IS NULL AND gateway_attempt_id IS NULL
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, userID := range payers {
+		cents := left / int64(len(payers))
+		if int64(i) < left%int64(len(payers)) {
+			cents++
+		}
+		if cents == 0 {
+			continue
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO payment_shares (payment_id, event_id, user_id, amount)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (payment_id, user_id) DO UPDATE
+			SET event_id = EXCLUDED.event_id, amount = EXCLUDED.amount, status = 'pending', gateway = NULL,
+			    failure_reason = NULL, reminders_sent = 0, last_reminded_at = NULL, created_at = NOW()
+			WHERE payment_shares.status = 'cancelled'
+		`, paymentID, eventID, userID, float64(cents)/100)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	payment, err := scanPayment(tx.QueryRow(`
+		UPDATE payments
+		SET payment_method = 'split', payment_status = 'pending', gateway = NULL, gateway_transaction_id = NULL,
+		    gateway_attempt_id = NULL, gateway_attempt_at = NULL, failure_reason = NULL, expired_at = NULL
+		WHERE id = $1
+		RETURNING `+paymentColumns,
+		paymentID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// GetPaymentShares returns the shares of a payment with their users' names, oldest first
+func (r *PaymentShareRepository) GetPaymentShares(paymentID int64) ([]model.PaymentShare, error) {
+	rows, err := r.db.Query(`
+		SELECT `+shareColumns+`, COALESCE(u.name, '')
+		FROM payment_shares s
+		LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.payment_id = $1
+		ORDER BY s.id
+	`, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment shares: %w", err)
+	}
+	defer rows.Close()
+
+	shares := []model.PaymentShare{}
+	for rows.Next() {
+		var userName string
+		share, err := scanShare(rows, &userName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment share: %w", err)
+		}
+		share.UserName = userName
+		shares = append(shares, *share)
+	}
+
+	return shares, rows.Err()
+}
+
+// GetUserShare returns a user's share of a payment, or nil when the user has none
+func (r *PaymentShareRepository) GetUserShare(paymentID, userID int64) (*model.PaymentShare, error) {
+	share, err := scanShare(r.db.QueryRow(`SELECT `+shareColumns+` FROM payment_shares s WHERE s.payment_id = $1 AND s.user_id = $2`, paymentID, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return share, err
+}
+
+// GetShare returns a share, or nil when it does not exist
+func (r *PaymentShareRepository) GetShare(shareID int64) (*model.PaymentShare, error) {
+	share, err := scanShare(r.db.QueryRow(`SELECT `+shareColumns+` FROM payment_shares s WHERE s.id = $1`, shareID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return share, err
+}
+
+// GetShareByTransaction returns the share paid through gateway as transactionID, or nil when none is
+func (r *PaymentShareRepository) GetShareByTransaction(gateway, transactionID string) (*model.PaymentShare, error) {
+	share, err := scanShare(r.db.QueryRow(`
+		SELECT `+shareColumns+` FROM payment_shares s WHERE s.gateway = $1 AND s.gateway_transaction_id = $2
+	`, gateway, transactionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return share, err
+}
+
+// StartSharePayment claims a pending share for an attempt to pay it through gateway, for hold. Another request
+// claiming it meanwhile, or while the gateway settles an earlier attempt, gets ErrPaymentInProgress. It returns
+// the attempt ID to send as the gateway's idempotency key: attemptID, or the ID of an earlier attempt whose
+// claim lapsed without an answer from the gateway.
+func (r *PaymentShareRepository) StartSharePayment(shareID int64, gateway, attemptID string, hold time.Duration) (string, error) {
+	var claimedID string
+	err := r.db.QueryRow(`
+		UPDATE payment_shares
+		SET gateway = $2, failure_reason = NULL,
+		    gateway_attempt_id = COALESCE(gateway_attempt_id, $3),
+		    gateway_attempt_at = NOW()
+		WHERE id = $1 AND status = 'pending' AND gateway_transaction_id IS NULL
+		AND (gateway_attempt_id IS NULL OR gateway_attempt_at <= NOW() - $4::double precision * INTERVAL '1 second')
+		RETURNING gateway_attempt_id
+	`, shareID, gateway, attemptID, hold.Seconds()).Scan(&claimedID)
+	if err == sql.ErrNoRows {
+		return "", ErrPaymentInProgress
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to start share payment: %w", err)
+	}
+	return claimedID, nil
+}
+
+// SetShareTransaction records the transaction of a share the gateway settles asynchronously
+func (r *PaymentShareRepository) SetShareTransaction(shareID int64, transactionID string) error {
+	result, err := r.db.Exec(`
+		UPDATE payment_shares
+		SET gateway_transaction_id = $2
+		WHERE id = $1 AND status = 'pending'
+	`, shareID, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to record share transaction: %w", err)
+	}
+	return expectShareUpdated(result)
+}
+
+// FailShare records why the gateway declined paying a share and ends the attempt; the share stays pending
+// and can be paid again
+func (r *PaymentShareRepository) FailShare(shareID int64, reason string) error {
+	_, err := r.db.Exec(`
+		UPDATE payment_shares
+		SET failure_reason = $2, gateway_transaction_id = NULL, gateway_attempt_id = NULL
+		WHERE id = $1 AND status = 'pending'
+	`, shareID, reason)
+	return err
+}
+
+// PayShare marks a pending share paid with its gateway transaction and, once the paid shares cover the
+// payment, completes the payment. It returns the payment and whether this share completed it.
+func (r *PaymentShareRepository) PayShare(shareID int64, transactionID string) (*model.Payment, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var paymentID int64
+	err = tx.QueryRow(`
+		UPDATE payment_shares
+		SET status = 'paid', gateway_transaction_id = $2, failure_reason = NULL, paid_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING payment_id
+	`, shareID, transactionID).Scan(&paymentID)
+	if err == sql.ErrNoRows {
+		return nil, false, ErrShareNotPending
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	payment, err := scanPayment(tx.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = $1 FOR UPDATE`, paymentID))
+	if err != nil {
+		return nil, false, err
+	}
+
+	completed := false
+	if payment.PaymentStatus == "pending" && payment.PaymentMethod == model.PaymentMethodSplit {
+		var paid float64
+		err = tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM payment_shares WHERE payment_id = $1 AND status = 'paid'`, paymentID).Scan(&paid)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if math.Round(paid*100) >= math.Round(payment.Amount*100) {
+			payment, err = scanPayment(tx.QueryRow(`
+				UPDATE payments
+				SET payment_status = 'completed', paid_at = NOW()
+				WHERE id = $1
+				RETURNING `+paymentColumns,
+				paymentID))
+			if err != nil {
+				return nil, false, err
+			}
+
+			// Pending shares are no longer owed
+			_, err = tx.Exec(`
+				UPDATE payment_shares
+				SET status = 'cancelled'
+				WHERE payment_id = $1 AND status = 'pending' AND gateway_transaction_id IS NULL AND gateway_attempt_id IS NULL
+			`, paymentID)
+			if err != nil {
+				return nil, false, err
+			}
+			completed = true
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	return payment, completed, nil
+}
+
+// CancelUserShares cancels the unpaid shares of a user in an event, e.g. because the user left it.
+// Shares the gateway is settling are kept.
+func (r *PaymentShareRepository) CancelUserShares(eventID, userID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE payment_shares
+		SET status = 'cancelled'
+		WHERE event_id = $1 AND user_id = $2 AND status = 'pending' AND gateway_transaction_id IS NULL AND gateway_attempt_id IS NULL
+	`, eventID, userID)
+	return err
+}
+
+// GetRefundableShares returns the paid shares of a payment with money left to refund, oldest first
+func (r *PaymentShareRepository) GetRefundableShares(paymentID int64) ([]model.PaymentShare, error) {
+	rows, err := r.db.Query(`
+		SELECT `+shareColumns+`
+		FROM payment_shares s
+		WHERE s.payment_id = $1 AND s.status = 'paid' AND s.amount > s.refunded_amount
+		ORDER BY s.id
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []model.PaymentShare
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *share)
+	}
+
+	return shares, rows.Err()
+}
+
+// AddShareRefund records amount returned from a paid share
+func (r *PaymentShareRepository) AddShareRefund(shareID int64, amount float64) error {
+	_, err := r.db.Exec(`UPDATE payment_shares SET refunded_amount = refunded_amount + $2 WHERE id = $1`, shareID, amount)
+	return err
+}
+
+// ClaimDueReminders returns the unpaid shares of upcoming split bookings that were requested or last
+// reminded at least interval ago, and records them as reminded
+func (r *PaymentShareRepository) ClaimDueReminders(interval time.Duration) ([]model.PaymentShare, error) {
+	rows, err := r.db.Query(`
+		UPDATE payment_shares s
+		SET reminders_sent = s.reminders_sent + 1, last_reminded_at = NOW()
+		FROM payments p
+		JOIN facility_reservations fr ON fr.id = p.reservation_id
+		WHERE p.id = s.payment_id
+		AND s.status = 'pending' AND s.gateway_transaction_id IS NULL AND s.gateway_attempt_id IS NULL AND s.event_id IS NOT NULL
+		AND p.payment_status = 'pending' AND p.payment_method = 'split'
+		AND fr.status = 'confirmed' AND fr.start_time > NOW()
+		AND COALESCE(s.last_reminded_at, s.created_at) <= NOW() - $1::double precision * INTERVAL '1 second'
+		RETURNING `+shareColumns,
+		interval.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []model.PaymentShare
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *share)
+	}
+
+	return shares, rows.Err()
+}
+
+// expectShareUpdated fails when a share status change matched no row
+func expectShareUpdated(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("share not found or already being paid")
+	}
+
+	return nil
+}
+
+// scanShare scans shareColumns followed by any extra columns into extra
+func scanShare(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*model.PaymentShare, error) {
+	var share model.PaymentShare
+	dest := []interface{}{
+		&share.ID, &share.PaymentID, &share.EventID, &share.UserID, &share.Amount, &share.Status, &share.Gateway,
+		&share.GatewayTransactionID, &share.FailureReason, &share.RefundedAmount, &share.RemindersSent,
+		&share.LastRemindedAt, &share.PaidAt, &share.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
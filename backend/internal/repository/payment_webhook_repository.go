@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+type PaymentWebhookRepository struct {
+	db *sql.DB
+}
+
+func NewPaymentWebhookRepository(db *sql.DB) *PaymentWebhookRepository {
+	return &PaymentWebhookRepository{db: db}
+}
+
+const paymentWebhookColumns = `id, provider, event_id, event_type, transaction_id, event_status, failure_reason, amount, payload,
+	status, error, deliveries, received_at, processed_at`
+
+// SaveEvent stores a received webhook event. A repeated delivery of an already stored event only
+// counts the delivery; the stored event is returned either way so its status shows whether it was applied.
+func (r *PaymentWebhookRepository) SaveEvent(event *model.PaymentWebhookEvent) (*model.PaymentWebhookEvent, error) {
+	query := `
+		INSERT INTO payment_webhook_events (provider, event_id, event_type, transaction_id, event_status, failure_reason, amount, payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (provider, event_id) DO UPDATE SET deliveries = payment_webhook_events.deliveries + 1
+		RETURNING ` + paymentWebhookColumns
+
+	return scanPaymentWebhookEvent(r.db.QueryRow(query,
+		event.Provider, event.EventID, event.EventType, event.TransactionID, event.EventStatus,
+		event.FailureReason, event.Amount, []byte(event.Payload),
+	))
+}
+
+// FinishEvent records the outcome of applying an event; message explains an ignored or failed one
+func (r *PaymentWebhookRepository) FinishEvent(eventID int64, status string, message *string) error {
+	query := `UPDATE payment_webhook_events SET status = $2, error = $3, processed_at = NOW() WHERE id = $1`
+	_, err := r.db.Exec(query, eventID, status, message)
+	return err
+}
+
+// GetEvent returns a stored event, or nil when it does not exist
+func (r *PaymentWebhookRepository) GetEvent(eventID int64) (*model.PaymentWebhookEvent, error) {
+	query := `SELECT ` + paymentWebhookColumns + ` FROM payment_webhook_events WHERE id = $1`
+
+	event, err := scanPaymentWebhookEvent(r.db.QueryRow(query, eventID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return event, err
+}
+
+// ListEvents returns the latest stored events, newest first, optionally only those with the given status
+func (r *PaymentWebhookRepository) ListEvents(status string, limit int) ([]model.PaymentWebhookEvent, error) {
+	query := `
+		SELECT ` + paymentWebhookColumns + `
+		FROM payment_webhook_events
+		WHERE $1 = '' OR status = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []model.PaymentWebhookEvent{}
+	for rows.Next() {
+		event, err := scanPaymentWebhookEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+
+	return events, rows.Err()
+}
+
+func scanPaymentWebhookEvent(row interface{ Scan(...interface{}) error }) (*model.PaymentWebhookEvent, error) {
+	var event model.PaymentWebhookEvent
+	var payload []byte
+	err := row.Scan(
+		&event.ID, &event.Provider, &event.EventID, &event.EventType, &event.TransactionID, &event.EventStatus,
+		&event.FailureReason, &event.Amount, &payload, &event.Status, &event.Error, &event.Deliveries,
+		&event.ReceivedAt, &event.ProcessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	event.Payload = payload
+	return &event, nil
+}
@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// ErrNotRefundable is returned when refunding a reservation whose payment was not completed
+var ErrNotRefundable = errors.New("only paid reservations can be refunded")
+
+// ErrRefundTooLarge is returned when a refund exceeds what is left to refund of a reservation's payment
+var ErrRefundTooLarge = errors.New("refund exceeds the amount left to refund")
+
+type RefundRepository struct {
+	db *sql.DB
+}
+
+func NewRefundRepository(db *sql.DB) *RefundRepository {
+	return &RefundRepository{db: db}
+}
+
+const refundColumns = `id, payment_id, reservation_id, amount, reason, initiator, initiated_by, status, gateway_refund_id, failure_reason, created_at, processed_at`
+
+// refundedAmountStatus recomputes payment_status from refunded_amount after it changed
+const refundedAmountStatus = `
+	CASE
+		WHEN refunded_amount >= amount THEN 'refunded'
+		WHEN refunded_amount > 0 THEN 'partially_refunded'
+		ELSE 'completed'
+	END`
+
+// CreateRefund records a pending refund from the payment of a reservation and counts it as refunded.
+// An amount of 0 refunds everything left: the whole payment, or the reservation's share of a basket payment.
+// Returns ErrNotRefundable if the payment was not completed and ErrRefundTooLarge if amount is more than is left.
+func (r *RefundRepository) CreateRefund(reservationID int64, amount float64, initiator string, initiatedBy int64, reason *string) (*model.Refund, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	payment, err := lockReservationPayment(tx, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil || (payment.status != "completed" && payment.status != "partially_refunded") {
+		return nil, ErrNotRefundable
+	}
+
+	left, err := refundableAmount(tx, payment, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if amount == 0 {
+		amount = left
+	}
+	if amount <= 0 || amount > left {
+		return nil, ErrRefundTooLarge
+	}
+
+	refund, err := insertRefund(tx, payment.id, &reservationID, amount, initiator, &initiatedBy, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE payments SET refunded_amount = refunded_amount + $2 WHERE id = $1`, payment.id, amount)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`UPDATE payments SET payment_status = `+refundedAmountStatus+` WHERE id = $1`, payment.id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// CreateLapsedRefund records a pending refund of everything left of a failed payment that the gateway
+// captured anyway, after its reservations lapsed. It returns nil when nothing is left to refund.
+func (r *RefundRepository) CreateLapsedRefund(paymentID int64, reason string) (*model.Refund, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var left float64
+	var reservationID int64
+	err = tx.QueryRow(`
+		SELECT amount - refunded_amount, reservation_id
+		FROM payments
+		WHERE id = $1 AND payment_status = 'failed'
+		FOR UPDATE
+	`, paymentID).Scan(&left, &reservationID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotRefundable
+	}
+	if err != nil {
+		return nil, err
+	}
+	left = math.Round(left*100) / 100
+	if left <= 0 {
+		return nil, nil
+	}
+
+	refund, err := insertRefund(tx, paymentID, &reservationID, left, model.RefundInitiatorSystem, nil, &reason)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE payments SET refunded_amount = refunded_amount + $2 WHERE id = $1`, paymentID, left)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// GetRefund returns a refund, or nil when it does not exist
+func (r *RefundRepository) GetRefund(refundID int64) (*model.Refund, error) {
+	refund, err := scanRefund(r.db.QueryRow(`SELECT `+refundColumns+` FROM refunds WHERE id = $1`, refundID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return refund, err
+}
+
+// GetPaymentRefunds returns the refunds of a payment, oldest first
+func (r *RefundRepository) GetPaymentRefunds(paymentID int64) ([]model.Refund, error) {
+	return r.queryRefunds(`SELECT `+refundColumns+` FROM refunds WHERE payment_id = $1 ORDER BY created_at, id`, paymentID)
+}
+
+// GetPendingRefunds returns the refunds of a payment that still have to be returned
+func (r *RefundRepository) GetPendingRefunds(paymentID int64) ([]model.Refund, error) {
+	return r.queryRefunds(`SELECT `+refundColumns+` FROM refunds WHERE payment_id = $1 AND status = 'pending' ORDER BY id`, paymentID)
+}
+
+// CompleteRefund marks a pending refund as returned; gatewayRefundID is nil when no gateway was involved
+func (r *RefundRepository) CompleteRefund(refundID int64, gatewayRefundID *string) error {
+	_, err := r.db.Exec(`
+		UPDATE refunds
+		SET status = 'succeeded', gateway_refund_id = $2, processed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`, refundID, gatewayRefundID)
+	return err
+}
+
+// FailRefund marks a pending refund as failed and takes its amount back out of the payment's refunded amount.
+// The status of a payment that was never completed, such as a failed one, is left as it is.
+func (r *RefundRepository) FailRefund(refundID int64, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var paymentID int64
+	var amount float64
+	err = tx.QueryRow(`
+		UPDATE refunds
+		SET status = 'failed', failure_reason = $2, processed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING payment_id, amount
+	`, refundID, reason).Scan(&paymentID, &amount)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE payments SET refunded_amount = GREATEST(refunded_amount - $2, 0) WHERE id = $1`, paymentID, amount)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE payments SET payment_status = `+refundedAmountStatus+`
+		WHERE id = $1 AND payment_status IN ('completed', 'partially_refunded', 'refunded')
+	`, paymentID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CompletePartialRefund marks a pending refund as returned for only the amount that came back, and records
+// the rest as a separate failed refund taken back out of the payment's refunded amount.
+func (r *RefundRepository) CompletePartialRefund(refundID int64, returned float64, gatewayRefundID *string, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	refund, err := scanRefund(tx.QueryRow(`SELECT `+refundColumns+` FROM refunds WHERE id = $1 AND status = 'pending' FOR UPDATE`, refundID))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	remainder := math.Round((refund.Amount-returned)*100) / 100
+	if remainder <= 0 {
+		return errors.New("a partial refund must return less than the refund amount")
+	}
+
+	_, err = tx.Exec(`
+		UPDATE refunds
+		SET amount = $2, status = 'succeeded', gateway_refund_id = $3, processed_at = NOW()
+		WHERE id = $1
+	`, refundID, returned, gatewayRefundID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refunds (payment_id, reservation_id, amount, initiator, initiated_by, reason, status, failure_reason, processed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'failed', $7, NOW())
+	`, refund.PaymentID, refund.ReservationID, remainder, refund.Initiator, refund.InitiatedBy, refund.Reason, reason)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE payments SET refunded_amount = GREATEST(refunded_amount - $2, 0) WHERE id = $1`, refund.PaymentID, remainder)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE payments SET payment_status = `+refundedAmountStatus+`
+		WHERE id = $1 AND payment_status IN ('completed', 'partially_refunded', 'refunded')
+	`, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *RefundRepository) queryRefunds(query string, args ...interface{}) ([]model.Refund, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []model.Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, *refund)
+	}
+
+	return refunds, rows.Err()
+}
+
+// refundableAmount returns how much of a locked payment can still be refunded for a reservation:
+// what is left of the payment, and for a basket payment at most what is left of the reservation's share
+func refundableAmount(tx *sql.Tx, payment *reservationPayment, reservationID int64) (float64, error) {
+	var paymentRefunded, reservationRefunded float64
+	err := tx.QueryRow(`
+		SELECT p.refunded_amount,
+		       COALESCE((SELECT SUM(amount) FROM refunds WHERE payment_id = p.id AND reservation_id = $2 AND status <> 'failed'), 0)
+		FROM payments p
+		WHERE p.id = $1
+	`, payment.id, reservationID).Scan(&paymentRefunded, &reservationRefunded)
+	if err != nil {
+		return 0, err
+	}
+
+	left := payment.amount - paymentRefunded
+	if payment.combined {
+		left = math.Min(left, payment.share-reservationRefunded)
+	}
+	return math.Max(math.Round(left*100)/100, 0), nil
+}
+
+// insertRefund records a pending refund within tx; the caller updates the payment's refunded amount
+func insertRefund(tx *sql.Tx, paymentID int64, reservationID *int64, amount float64, initiator string, initiatedBy *int64, reason *string) (*model.Refund, error) {
+	return scanRefund(tx.QueryRow(`
+		INSERT INTO refunds (payment_id, reservation_id, amount, initiator, initiated_by, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+refundColumns,
+		paymentID, reservationID, amount, initiator, initiatedBy, reason))
+}
+
+func scanRefund(row interface{ Scan(...interface{}) error }) (*model.Refund, error) {
+	var refund model.Refund
+	err := row.Scan(
+		&refund.ID, &refund.PaymentID, &refund.ReservationID, &refund.Amount, &refund.Reason, &refund.Initiator,
+		&refund.InitiatedBy, &refund.Status, &refund.GatewayRefundID, &refund.FailureReason, &refund.CreatedAt, &refund.ProcessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
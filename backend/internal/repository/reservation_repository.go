@@ -2,12 +2,26 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/Radi03825/PlaySpot/internal/dto"
 	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/lib/pq"
 )
 
+// ErrSlotTaken is returned when a reservation overlaps an existing active reservation
+var ErrSlotTaken = errors.New("this time slot is already reserved")
+
+// ErrSplitPaymentReschedule is returned when moving a reservation whose cost is split among event participants
+var ErrSplitPaymentReschedule = errors.New("a booking whose cost is split among event participants cannot be rescheduled")
+
+// activeReservationFilter matches reservations that still hold their slot: not cancelled or expired,
+// and not a pending hold whose expiry time has already passed but has not been swept yet
+const activeReservationFilter = `status NOT IN ('cancelled', 'expired') AND (status <> 'pending' OR expires_at IS NULL OR expires_at > NOW())`
+
 type ReservationRepository struct {
 	db *sql.DB
 }
@@ -21,7 +35,7 @@ func (r *ReservationRepository) GetFacilitySchedules(facilityID int64) ([]model.
 		SELECT id, facility_id, open_time::text, close_time::text, day_type
 		FROM facility_schedules
 		WHERE facility_id = $1
-		ORDER BY day_type
+		ORDER BY array_position(ARRAY['monday', 'tuesday', 'wednesday', 'thursday', 'friday', 'saturday', 'sunday']::varchar[], day_type)
 	`
 	rows, err := r.db.Query(query, facilityID)
 	if err != nil {
@@ -47,7 +61,7 @@ func (r *ReservationRepository) GetFacilityPricing(facilityID int64) ([]model.Fa
 		SELECT id, facility_id, day_type, start_hour::text, end_hour::text, price_per_hour
 		FROM facility_pricings
 		WHERE facility_id = $1
-		ORDER BY day_type, start_hour
+		ORDER BY array_position(ARRAY['monday', 'tuesday', 'wednesday', 'thursday', 'friday', 'saturday', 'sunday']::varchar[], day_type), start_hour
 	`
 	rows, err := r.db.Query(query, facilityID)
 	if err != nil {
@@ -68,14 +82,53 @@ func (r *ReservationRepository) GetFacilityPricing(facilityID int64) ([]model.Fa
 	return pricings, nil
 }
 
+// GetFacilityTimeZone returns the IANA time zone of a facility
+func (r *ReservationRepository) GetFacilityTimeZone(facilityID int64) (string, error) {
+	var timeZone string
+	err := r.db.QueryRow(`SELECT time_zone FROM facilities WHERE id = $1`, facilityID).Scan(&timeZone)
+	return timeZone, err
+}
+
+// GetFacilityBookingPolicy returns the booking policy for a facility, or nil if none is configured
+func (r *ReservationRepository) GetFacilityBookingPolicy(facilityID int64) (*model.FacilityBookingPolicy, error) {
+	query := `
+		SELECT p.id, p.facility_id, p.slot_minutes, p.min_duration_minutes, p.max_duration_minutes, p.buffer_minutes,
+		       p.allow_reschedule, p.reschedule_cutoff_minutes, p.max_reschedules, p.availability_mode, f.capacity
+		FROM facility_booking_policies p
+		JOIN facilities f ON f.id = p.facility_id
+		WHERE p.facility_id = $1
+	`
+	var policy model.FacilityBookingPolicy
+	err := r.db.QueryRow(query, facilityID).Scan(
+		&policy.ID, &policy.FacilityID, &policy.SlotMinutes,
+		&policy.MinDurationMinutes, &policy.MaxDurationMinutes, &policy.BufferMinutes,
+		&policy.AllowReschedule, &policy.RescheduleCutoffMinutes, &policy.MaxReschedules,
+		&policy.AvailabilityMode, &policy.Capacity,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// GetFacilityScheduleExceptions returns closures, special hours and special prices affecting a facility,
+// including those defined for its sport complex
+func (r *ReservationRepository) GetFacilityScheduleExceptions(facilityID int64, startDate, endDate time.Time) ([]model.ScheduleException, error) {
+	return getExceptionsForFacility(r.db, facilityID, startDate, endDate)
+}
+
 func (r *ReservationRepository) GetReservationsByFacilityAndDateRange(facilityID int64, startDate, endDate time.Time) ([]model.FacilityReservation, error) {
 	query := `
-		SELECT id, user_id, facility_id, start_time, end_time, status, total_price, created_at
+		SELECT id, COALESCE(user_id, 0), facility_id, start_time, end_time, status, total_price, units, created_at
 		FROM facility_reservations
 		WHERE facility_id = $1 
 		AND start_time >= $2 
 		AND end_time <= $3
-		AND status != 'cancelled'
+		AND ` + activeReservationFilter + `
 		ORDER BY start_time
 	`
 	rows, err := r.db.Query(query, facilityID, startDate, endDate)
@@ -89,7 +142,7 @@ func (r *ReservationRepository) GetReservationsByFacilityAndDateRange(facilityID
 		var reservation model.FacilityReservation
 		err := rows.Scan(&reservation.ID, &reservation.UserID, &reservation.FacilityID,
 			&reservation.StartTime, &reservation.EndTime, &reservation.Status,
-			&reservation.TotalPrice, &reservation.CreatedAt)
+			&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -99,49 +152,384 @@ func (r *ReservationRepository) GetReservationsByFacilityAndDateRange(facilityID
 	return reservations, nil
 }
 
-func (r *ReservationRepository) CreateReservation(userID, facilityID int64, startTime, endTime time.Time, totalPrice float64) (*model.FacilityReservation, error) {
+// CreateReservation atomically checks for overlapping reservations and inserts a new pending one
+// taking units of the facility, together with its price breakdown. The pending reservation holds
+// the slot for hold, after which it expires unless paid.
+// Existing reservations closer than buffer to the requested interval also count as conflicts.
+// Bookings for the same facility are serialized by locking the facility row, and the
+// facility_reservations_no_overlap exclusion constraint acts as a final safeguard.
+func (r *ReservationRepository) CreateReservation(userID, facilityID int64, startTime, endTime time.Time, units int, buffer, hold time.Duration, totalPrice float64, priceItems []model.PriceItem) (*model.FacilityReservation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = lockAndCheckSlot(tx, facilityID, startTime, endTime, buffer, units, 0); err != nil {
+		return nil, err
+	}
+
+	reservation, err := insertReservation(tx, userID, facilityID, nil, startTime, endTime, units, hold, totalPrice, priceItems)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrSlotTaken
+		}
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// CreateCheckout atomically books every basket item as a pending reservation held for hold, and creates
+// one pending payment covering all of them. Either every item is booked or none is: if any item overlaps
+// an active reservation, or another item of the same basket, ErrSlotTaken is returned and nothing is stored.
+// Facilities are locked in ID order so concurrent baskets sharing facilities cannot deadlock.
+func (r *ReservationRepository) CreateCheckout(userID int64, items []model.BasketItem, hold time.Duration, currency string) ([]model.FacilityReservation, *model.Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	facilityIDs := make([]int64, 0, len(items))
+	for _, item := range items {
+		facilityIDs = append(facilityIDs, item.FacilityID)
+	}
+	_, err = tx.Exec(`SELECT id FROM facilities WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(facilityIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reservations := make([]model.FacilityReservation, 0, len(items))
+	var total float64
+	for i, item := range items {
+		err = lockAndCheckSlot(tx, item.FacilityID, item.StartTime, item.EndTime, item.Buffer, item.Units, 0)
+		if errors.Is(err, ErrSlotTaken) {
+			return nil, nil, fmt.Errorf("item %d: %w", i+1, err)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		reservation, err := insertReservation(tx, userID, item.FacilityID, nil, item.StartTime, item.EndTime, item.Units, hold, item.TotalPrice, item.PriceItems)
+		if err != nil {
+			return nil, nil, err
+		}
+		reservations = append(reservations, *reservation)
+		total += item.TotalPrice
+	}
+
+	payment, err := scanPayment(tx.QueryRow(`
+		INSERT INTO payments (user_id, reservation_id, amount, currency, payment_method, payment_status, expired_at, created_at)
+		VALUES ($1, $2, $3, $4, 'pending', 'pending', $5, NOW())
+		RETURNING `+paymentColumns,
+		userID, reservations[0].ID, math.Round(total*100)/100, currency, reservations[0].ExpiresAt))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	for _, reservation := range reservations {
+		_, err = tx.Exec(`
+			INSERT INTO payment_reservations (payment_id, reservation_id, amount)
+			VALUES ($1, $2, $3)
+		`, payment.ID, reservation.ID, reservation.TotalPrice)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		if isExclusionViolation(err) {
+			return nil, nil, ErrSlotTaken
+		}
+		return nil, nil, err
+	}
+
+	return reservations, payment, nil
+}
+
+// CreateManagerBooking atomically books units of a slot on behalf of a customer, as a confirmed reservation
+// recorded as created by createdBy. userID is nil for a guest, who is identified by guestName and guestPhone.
+// An on-site payment is recorded with it, completed when paid and pending otherwise.
+// Returns ErrSlotTaken if the interval, widened by buffer, overlaps an active reservation.
+func (r *ReservationRepository) CreateManagerBooking(userID *int64, guestName, guestPhone *string, createdBy, facilityID int64, startTime, endTime time.Time, units int, buffer time.Duration, totalPrice float64, priceItems []model.PriceItem, paid bool, currency string) (*model.FacilityReservation, *model.Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if err = lockAndCheckSlot(tx, facilityID, startTime, endTime, buffer, units, 0); err != nil {
+		return nil, nil, err
+	}
+
+	var reservation model.FacilityReservation
+	err = tx.QueryRow(`
+		INSERT INTO facility_reservations (user_id, facility_id, start_time, end_time, status, total_price, created_at, created_by, guest_name, guest_phone,
+		                                   units, exclusive_use)
+		VALUES ($1, $2, $3, $4, 'confirmed', $5, NOW(), $6, $7, $8, $9, `+exclusiveUseExpr+`)
+		RETURNING id, COALESCE(user_id, 0), facility_id, start_time, end_time, status, total_price, units, created_at, created_by, guest_name, guest_phone
+	`, userID, facilityID, startTime, endTime, totalPrice, createdBy, guestName, guestPhone, units).Scan(
+		&reservation.ID, &reservation.UserID, &reservation.FacilityID,
+		&reservation.StartTime, &reservation.EndTime, &reservation.Status,
+		&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt,
+		&reservation.CreatedBy, &reservation.GuestName, &reservation.GuestPhone,
+	)
+	if err != nil {
+		if isExclusionViolation(err) {
+			return nil, nil, ErrSlotTaken
+		}
+		return nil, nil, err
+	}
+
+	if err = insertPriceItems(tx, reservation.ID, priceItems); err != nil {
+		return nil, nil, err
+	}
+	reservation.PriceBreakdown = priceItems
+
+	status := "pending"
+	if paid {
+		status = "completed"
+	}
+	payment, err := scanPayment(tx.QueryRow(`
+		INSERT INTO payments (user_id, reservation_id, amount, currency, payment_method, payment_status, paid_at, created_at)
+		VALUES ($1, $2, $3, $4, 'on_place', $5, CASE WHEN $5 = 'completed' THEN NOW() END, NOW())
+		RETURNING `+paymentColumns,
+		userID, reservation.ID, totalPrice, currency, status))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		if isExclusionViolation(err) {
+			return nil, nil, ErrSlotTaken
+		}
+		return nil, nil, err
+	}
+
+	return &reservation, payment, nil
+}
+
+// MarkReservationPaidOnSite completes the pending payment of a reservation as paid on site.
+// Returns nil if the reservation has no pending payment of its own.
+func (r *ReservationRepository) MarkReservationPaidOnSite(reservationID int64) (*model.Payment, error) {
+	payment, err := scanPayment(r.db.QueryRow(`
+		UPDATE payments
+		SET payment_method = 'on_place', payment_status = 'completed', paid_at = NOW()
+		WHERE id = (SELECT id FROM payments WHERE reservation_id = $1 ORDER BY created_at DESC LIMIT 1)
+		AND payment_status = 'pending'
+		AND NOT EXISTS (SELECT 1 FROM payment_reservations WHERE payment_id = payments.id)
+		RETURNING `+paymentColumns,
+		reservationID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// lockAndCheckSlot locks the facility row so concurrent bookings for it are processed one at a time,
+// then returns ErrSlotTaken if units cannot be booked for the interval widened by buffer.
+// A non-zero excludeReservationID is left out of the check, used when moving that reservation.
+func lockAndCheckSlot(tx *sql.Tx, facilityID int64, startTime, endTime time.Time, buffer time.Duration, units int, excludeReservationID int64) error {
+	var lockedID int64
+	err := tx.QueryRow(`SELECT id FROM facilities WHERE id = $1 FOR UPDATE`, facilityID).Scan(&lockedID)
+	if err != nil {
+		return err
+	}
+
+	// Release lapsed holds right away so the exclusion constraint does not count them
+	_, err = tx.Exec(`
+		UPDATE facility_reservations
+		SET status = 'expired'
+		WHERE facility_id = $1 AND status = 'pending' AND expires_at <= NOW()
+	`, facilityID)
+	if err != nil {
+		return err
+	}
+
+	return checkSlotCapacity(tx, facilityID, startTime.Add(-buffer), endTime.Add(buffer), units, excludeReservationID)
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// checkSlotCapacity returns ErrSlotTaken if units cannot be booked for the interval.
+// An exclusive-use facility is taken by any overlapping active reservation; a shared facility
+// is taken when the units already booked at the busiest moment plus units exceed its capacity.
+// A non-zero excludeReservationID is left out of the check.
+func checkSlotCapacity(q queryer, facilityID int64, startTime, endTime time.Time, units int, excludeReservationID int64) error {
+	var shared bool
+	var capacity int
+	err := q.QueryRow(`
+		SELECT COALESCE(p.availability_mode = 'shared', FALSE), f.capacity
+		FROM facilities f
+		LEFT JOIN facility_booking_policies p ON p.facility_id = f.id
+		WHERE f.id = $1
+	`, facilityID).Scan(&shared, &capacity)
+	if err != nil {
+		return err
+	}
+
+	rows, err := q.Query(`
+		SELECT start_time, end_time, units
+		FROM facility_reservations
+		WHERE facility_id = $1
+		AND `+activeReservationFilter+`
+		AND start_time < $3
+		AND end_time > $2
+		AND id <> $4
+	`, facilityID, startTime, endTime, excludeReservationID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var overlapping []model.FacilityReservation
+	for rows.Next() {
+		var reservation model.FacilityReservation
+		if err := rows.Scan(&reservation.StartTime, &reservation.EndTime, &reservation.Units); err != nil {
+			return err
+		}
+		overlapping = append(overlapping, reservation)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if !shared {
+		if len(overlapping) > 0 {
+			return ErrSlotTaken
+		}
+		return nil
+	}
+
+	if model.PeakUnits(overlapping, startTime, endTime)+units > capacity {
+		return ErrSlotTaken
+	}
+
+	return nil
+}
+
+// exclusiveUseExpr evaluates whether a new reservation for facility $2 uses the whole facility,
+// which subjects it to the facility_reservations_no_overlap constraint
+const exclusiveUseExpr = `NOT EXISTS (SELECT 1 FROM facility_booking_policies WHERE facility_id = $2 AND availability_mode = 'shared')`
+
+// insertReservation inserts a pending reservation taking units of the facility, and its price items, within tx.
+// seriesID links the reservation to a recurring series and may be nil.
+// A positive hold sets when the pending reservation expires; zero keeps it until cancelled.
+func insertReservation(tx *sql.Tx, userID, facilityID int64, seriesID *int64, startTime, endTime time.Time, units int, hold time.Duration, totalPrice float64, priceItems []model.PriceItem) (*model.FacilityReservation, error) {
+	var holdSeconds *int64
+	if hold > 0 {
+		seconds := int64(hold / time.Second)
+		holdSeconds = &seconds
+	}
+
 	query := `
-		INSERT INTO facility_reservations (user_id, facility_id, start_time, end_time, status, total_price, created_at)
-		VALUES ($1, $2, $3, $4, 'pending', $5, NOW())
-		RETURNING id, user_id, facility_id, start_time, end_time, status, total_price, created_at
+		INSERT INTO facility_reservations (user_id, facility_id, start_time, end_time, status, total_price, created_at, series_id, expires_at,
+		                                   units, exclusive_use)
+		VALUES ($1, $2, $3, $4, 'pending', $5, NOW(), $6, NOW() + $7::double precision * INTERVAL '1 second', $8, ` + exclusiveUseExpr + `)
+		RETURNING id, user_id, facility_id, start_time, end_time, status, total_price, units, created_at, series_id, expires_at
 	`
 	var reservation model.FacilityReservation
-	err := r.db.QueryRow(query, userID, facilityID, startTime, endTime, totalPrice).Scan(
+	err := tx.QueryRow(query, userID, facilityID, startTime, endTime, totalPrice, seriesID, holdSeconds, units).Scan(
 		&reservation.ID, &reservation.UserID, &reservation.FacilityID,
 		&reservation.StartTime, &reservation.EndTime, &reservation.Status,
-		&reservation.TotalPrice, &reservation.CreatedAt,
+		&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &reservation.SeriesID, &reservation.ExpiresAt,
 	)
 	if err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrSlotTaken
+		}
+		return nil, err
+	}
+
+	if err = insertPriceItems(tx, reservation.ID, priceItems); err != nil {
 		return nil, err
 	}
+	reservation.PriceBreakdown = priceItems
 
 	return &reservation, nil
 }
 
-func (r *ReservationRepository) CheckReservationConflict(facilityID int64, startTime, endTime time.Time) (bool, error) {
+// insertPriceItems stores the itemised price breakdown of a reservation, filling in the item IDs
+func insertPriceItems(tx *sql.Tx, reservationID int64, priceItems []model.PriceItem) error {
 	query := `
-		SELECT COUNT(*) 
-		FROM facility_reservations
-		WHERE facility_id = $1 
-		AND status != 'cancelled'
-		AND (
-			(start_time <= $2 AND end_time > $2) OR
-			(start_time < $3 AND end_time >= $3) OR
-			(start_time >= $2 AND end_time <= $3)
-		)
+		INSERT INTO reservation_price_items (reservation_id, start_time, end_time, price_per_hour, amount)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
 	`
-	var count int
-	err := r.db.QueryRow(query, facilityID, startTime, endTime).Scan(&count)
+	for i := range priceItems {
+		item := &priceItems[i]
+		item.ReservationID = reservationID
+		err := tx.QueryRow(query, reservationID, item.StartTime, item.EndTime, item.PricePerHour, item.Amount).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetReservationPriceItems returns the itemised price breakdown of a reservation
+func (r *ReservationRepository) GetReservationPriceItems(reservationID int64) ([]model.PriceItem, error) {
+	query := `
+		SELECT id, reservation_id, start_time, end_time, price_per_hour, amount
+		FROM reservation_price_items
+		WHERE reservation_id = $1
+		ORDER BY start_time
+	`
+	rows, err := r.db.Query(query, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.PriceItem
+	for rows.Next() {
+		var item model.PriceItem
+		err := rows.Scan(&item.ID, &item.ReservationID, &item.StartTime, &item.EndTime, &item.PricePerHour, &item.Amount)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// CheckReservationConflict reports whether units can no longer be booked for the interval:
+// any overlapping reservation for an exclusive-use facility, or too few free units for a shared one
+func (r *ReservationRepository) CheckReservationConflict(facilityID int64, startTime, endTime time.Time, units int) (bool, error) {
+	err := checkSlotCapacity(r.db, facilityID, startTime, endTime, units, 0)
+	if errors.Is(err, ErrSlotTaken) {
+		return true, nil
+	}
 	if err != nil {
 		return false, err
 	}
 
-	return count > 0, nil
+	return false, nil
+}
+
+// isExclusionViolation reports whether err was raised by an exclusion constraint
+func isExclusionViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23P01"
 }
 
 func (r *ReservationRepository) GetUserReservations(userID int64) ([]model.FacilityReservation, error) {
 	query := `
-		SELECT id, user_id, facility_id, start_time, end_time, status, total_price, created_at, google_calendar_event_id
+		SELECT id, user_id, facility_id, start_time, end_time, status, total_price, units, created_at, google_calendar_event_id
 		FROM facility_reservations
 		WHERE user_id = $1
 		ORDER BY start_time DESC
@@ -158,7 +546,7 @@ func (r *ReservationRepository) GetUserReservations(userID int64) ([]model.Facil
 		var eventID sql.NullString
 		err := rows.Scan(&reservation.ID, &reservation.UserID, &reservation.FacilityID,
 			&reservation.StartTime, &reservation.EndTime, &reservation.Status,
-			&reservation.TotalPrice, &reservation.CreatedAt, &eventID)
+			&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &eventID)
 		if err != nil {
 			return nil, err
 		}
@@ -173,7 +561,7 @@ func (r *ReservationRepository) GetUserReservations(userID int64) ([]model.Facil
 
 func (r *ReservationRepository) GetUpcomingConfirmedReservations(userID int64) ([]model.FacilityReservation, error) {
 	query := `
-		SELECT id, user_id, facility_id, start_time, end_time, status, total_price, created_at, google_calendar_event_id
+		SELECT id, user_id, facility_id, start_time, end_time, status, total_price, units, created_at, google_calendar_event_id
 		FROM facility_reservations
 		WHERE user_id = $1 AND status = 'confirmed' AND start_time > NOW()
 		ORDER BY start_time ASC
@@ -190,7 +578,7 @@ func (r *ReservationRepository) GetUpcomingConfirmedReservations(userID int64) (
 		var eventID sql.NullString
 		err := rows.Scan(&reservation.ID, &reservation.UserID, &reservation.FacilityID,
 			&reservation.StartTime, &reservation.EndTime, &reservation.Status,
-			&reservation.TotalPrice, &reservation.CreatedAt, &eventID)
+			&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &eventID)
 		if err != nil {
 			return nil, err
 		}
@@ -207,7 +595,7 @@ func (r *ReservationRepository) GetUpcomingConfirmedReservationsWithDetails(user
 	query := `
 		SELECT 
 			fr.id, fr.user_id, fr.facility_id, fr.start_time, fr.end_time, 
-			fr.status, fr.total_price, fr.created_at,
+			fr.status, fr.total_price, fr.units, fr.created_at,
 			f.name as facility_name,
 			f.city as facility_city,
 			f.address as facility_address,
@@ -220,6 +608,7 @@ func (r *ReservationRepository) GetUpcomingConfirmedReservationsWithDetails(user
 		INNER JOIN sports s ON c.sport_id = s.id
 		LEFT JOIN sport_complexes sc ON f.sport_complex_id = sc.id
 		WHERE fr.user_id = $1 AND fr.status IN ('confirmed', 'pending') AND fr.start_time > NOW()
+		AND (fr.status <> 'pending' OR fr.expires_at IS NULL OR fr.expires_at > NOW())
 		ORDER BY fr.start_time ASC
 	`
 	rows, err := r.db.Query(query, userID)
@@ -236,7 +625,60 @@ func (r *ReservationRepository) GetUpcomingConfirmedReservationsWithDetails(user
 		err := rows.Scan(
 			&reservation.ID, &reservation.UserID, &reservation.FacilityID,
 			&reservation.StartTime, &reservation.EndTime, &reservation.Status,
-			&reservation.TotalPrice, &reservation.CreatedAt,
+			&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt,
+			&reservation.FacilityName, &reservation.FacilityCity,
+			&reservation.FacilityAddress, &reservation.FacilitySportID,
+			&reservation.FacilitySport, &complexName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if complexName.Valid {
+			reservation.ComplexName = &complexName.String
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, nil
+}
+
+// GetUserCalendarReservations returns the user's confirmed, completed and cancelled reservations
+// starting after since, for the user's calendar feed
+func (r *ReservationRepository) GetUserCalendarReservations(userID int64, since time.Time) ([]dto.ReservationWithFacilityDTO, error) {
+	query := `
+		SELECT 
+			fr.id, fr.user_id, fr.facility_id, fr.start_time, fr.end_time, 
+			fr.status, fr.total_price, fr.units, fr.created_at, fr.reschedule_count,
+			f.name as facility_name,
+			f.city as facility_city,
+			f.address as facility_address,
+			s.id as sport_id,
+			s.name as sport_name,
+			sc.name as complex_name
+		FROM facility_reservations fr
+		INNER JOIN facilities f ON fr.facility_id = f.id
+		INNER JOIN categories c ON f.category_id = c.id
+		INNER JOIN sports s ON c.sport_id = s.id
+		LEFT JOIN sport_complexes sc ON f.sport_complex_id = sc.id
+		WHERE fr.user_id = $1 AND fr.status IN ('confirmed', 'completed', 'no_show', 'cancelled') AND fr.start_time >= $2
+		ORDER BY fr.start_time ASC
+	`
+	rows, err := r.db.Query(query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []dto.ReservationWithFacilityDTO
+	for rows.Next() {
+		var reservation dto.ReservationWithFacilityDTO
+		var complexName sql.NullString
+
+		err := rows.Scan(
+			&reservation.ID, &reservation.UserID, &reservation.FacilityID,
+			&reservation.StartTime, &reservation.EndTime, &reservation.Status,
+			&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &reservation.RescheduleCount,
 			&reservation.FacilityName, &reservation.FacilityCity,
 			&reservation.FacilityAddress, &reservation.FacilitySportID,
 			&reservation.FacilitySport, &complexName,
@@ -259,43 +701,437 @@ func (r *ReservationRepository) GetPendingReservationsCount(userID int64) (int,
 		SELECT COUNT(*)
 		FROM facility_reservations
 		WHERE user_id = $1 AND status = 'pending' AND start_time > NOW()
+		AND (expires_at IS NULL OR expires_at > NOW())
 	`
 	var count int
 	err := r.db.QueryRow(query, userID).Scan(&count)
 	return count, err
 }
 
-func (r *ReservationRepository) CancelReservation(reservationID, userID int64) (*model.FacilityReservation, error) {
+// GetFacilityCancellationPolicy returns the cancellation policy that applies to a facility,
+// falling back to the rules of its sport complex
+func (r *ReservationRepository) GetFacilityCancellationPolicy(facilityID int64) (*model.CancellationPolicy, error) {
+	return getFacilityCancellationPolicy(r.db, facilityID)
+}
+
+// CancelReservation cancels an active reservation of the user that has not started yet and settles its payment in the same transaction.
+// A completed payment is refunded refundPercent of the price it covered; a pending payment is marked failed.
+// The returned payment is nil when the reservation had none.
+func (r *ReservationRepository) CancelReservation(reservationID, userID int64, refundPercent float64) (*model.FacilityReservation, *model.Payment, error) {
+	return r.cancelReservation(reservationID, &userID, userID, nil, refundPercent)
+}
+
+// CancelReservationByManager cancels an active reservation on behalf of the facility and refunds its payment in full
+func (r *ReservationRepository) CancelReservationByManager(reservationID, managerID int64, reason string) (*model.FacilityReservation, *model.Payment, error) {
+	return r.cancelReservation(reservationID, nil, managerID, &reason, 100)
+}
+
+// cancelReservation cancels a reservation, restricted to ownerID when it is set, and settles its payment.
+// Owners can only cancel reservations that have not started yet. cancelledBy and reason are recorded on the reservation.
+func (r *ReservationRepository) cancelReservation(reservationID int64, ownerID *int64, cancelledBy int64, reason *string, refundPercent float64) (*model.FacilityReservation, *model.Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE facility_reservations
-		SET status = 'cancelled'
-		WHERE id = $1 AND user_id = $2 AND status != 'cancelled'
-		RETURNING id, user_id, facility_id, start_time, end_time, status, total_price, created_at, google_calendar_event_id
+		SET status = 'cancelled', cancelled_by = $3, cancellation_reason = $4
+		WHERE id = $1 AND ($2::bigint IS NULL OR (user_id = $2 AND start_time > NOW()))
+		AND status NOT IN ('cancelled', 'expired', 'completed', 'no_show')
+		RETURNING id, COALESCE(user_id, 0), facility_id, start_time, end_time, status, total_price, units, created_at, google_calendar_event_id,
+		          reschedule_count, cancelled_by, cancellation_reason
 	`
 	var reservation model.FacilityReservation
 	var eventID sql.NullString
-	err := r.db.QueryRow(query, reservationID, userID).Scan(
+	err = tx.QueryRow(query, reservationID, ownerID, cancelledBy, reason).Scan(
 		&reservation.ID, &reservation.UserID, &reservation.FacilityID,
 		&reservation.StartTime, &reservation.EndTime, &reservation.Status,
-		&reservation.TotalPrice, &reservation.CreatedAt, &eventID,
+		&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &eventID,
+		&reservation.RescheduleCount, &reservation.CancelledBy, &reservation.CancellationReason,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if eventID.Valid {
 		reservation.GoogleCalendarEventID = &eventID.String
 	}
 
+	initiator := model.RefundInitiatorUser
+	if ownerID == nil {
+		initiator = model.RefundInitiatorManager
+	}
+	payment, err := settleCancelledPayment(tx, &reservation, refundPercent, initiator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return &reservation, payment, nil
+}
+
+// MarkReservationNoShow marks a reservation that has ended as a no-show.
+// Returns nil if the reservation is not confirmed or completed, or has not ended yet.
+func (r *ReservationRepository) MarkReservationNoShow(reservationID int64) (*model.FacilityReservation, error) {
+	query := `
+		UPDATE facility_reservations
+		SET status = 'no_show'
+		WHERE id = $1 AND status IN ('confirmed', 'completed') AND end_time <= NOW()
+		RETURNING id, COALESCE(user_id, 0), facility_id, start_time, end_time, status, total_price, units, created_at
+	`
+	var reservation model.FacilityReservation
+	err := r.db.QueryRow(query, reservationID).Scan(
+		&reservation.ID, &reservation.UserID, &reservation.FacilityID,
+		&reservation.StartTime, &reservation.EndTime, &reservation.Status,
+		&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	return &reservation, nil
 }
 
-func (r *ReservationRepository) UpdateReservationCalendarEventID(reservationID int64, eventID string) error {
+// GetUserNoShowCount returns how many bookings a user did not show up for
+func (r *ReservationRepository) GetUserNoShowCount(userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM facility_reservations WHERE user_id = $1 AND status = 'no_show'`, userID).Scan(&count)
+	return count, err
+}
+
+// reservationPayment is the latest payment covering a reservation, locked within a transaction
+type reservationPayment struct {
+	id         int64
+	amount     float64
+	status     string
+	balanceDue float64
+	share      float64 // The reservation's part of amount
+	combined   bool    // The payment covers several reservations of a basket
+}
+
+// lockReservationPayment locks the latest payment covering a reservation, directly or as part of a basket.
+// It returns nil if the reservation has no payment.
+func lockReservationPayment(tx *sql.Tx, reservationID int64) (*reservationPayment, error) {
+	var payment reservationPayment
+	var share sql.NullFloat64
+	err := tx.QueryRow(`
+		SELECT p.id, p.amount, p.payment_status, p.balance_due, pr.amount
+		FROM payments p
+		LEFT JOIN payment_reservations pr ON pr.payment_id = p.id AND pr.reservation_id = $1
+		WHERE p.reservation_id = $1 OR pr.reservation_id IS NOT NULL
+		ORDER BY p.created_at DESC
+		LIMIT 1
+		FOR UPDATE OF p
+	`, reservationID).Scan(&payment.id, &payment.amount, &payment.status, &payment.balanceDue, &share)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	payment.share = payment.amount
+	if share.Valid {
+		payment.share = share.Float64
+		payment.combined = true
+	}
+
+	return &payment, nil
+}
+
+// settleCancelledPayment refunds or releases the payment of a cancelled reservation within tx.
+// A pending payment is failed, or reduced by the reservation's share when other basket reservations remain.
+// A completed payment is refunded refundPercent of the reservation's share, recorded as a pending refund by initiator.
+// The shares already paid of a split payment that had not been completed are refunded in full.
+func settleCancelledPayment(tx *sql.Tx, reservation *model.FacilityReservation, refundPercent float64, initiator string) (*model.Payment, error) {
+	payment, err := lockReservationPayment(tx, reservation.ID)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+
+	switch payment.status {
+	case "pending", "authorized":
+		if payment.share < payment.amount {
+			_, err = tx.Exec(`UPDATE payments SET amount = amount - $2 WHERE id = $1`, payment.id, payment.share)
+			break
+		}
+		_, err = tx.Exec(`UPDATE payments SET payment_status = 'failed' WHERE id = $1`, payment.id)
+		if err != nil {
+			return nil, err
+		}
+		// Shares of a split payment paid so far are returned in full
+		var collected float64
+		err = tx.QueryRow(`
+			SELECT COALESCE(SUM(amount - refunded_amount), 0) FROM payment_shares WHERE payment_id = $1 AND status = 'paid'
+		`, payment.id).Scan(&collected)
+		if err != nil || collected <= 0 {
+			break
+		}
+		_, err = insertRefund(tx, payment.id, &reservation.ID, collected, initiator, reservation.CancelledBy, reservation.CancellationReason)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`UPDATE payments SET refunded_amount = refunded_amount + $2 WHERE id = $1`, payment.id, collected)
+	case "completed", "partially_refunded":
+		refund := cancellationRefund(paidTowardsPrice(payment, reservation.TotalPrice), reservation.TotalPrice, refundPercent)
+		// Staff may already have refunded part of the payment
+		var left float64
+		left, err = refundableAmount(tx, payment, reservation.ID)
+		if err != nil {
+			return nil, err
+		}
+		refund = math.Min(refund, left)
+		if refund > 0 {
+			_, err = insertRefund(tx, payment.id, &reservation.ID, refund, initiator, reservation.CancelledBy, reservation.CancellationReason)
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err = tx.Exec(`
+			UPDATE payments
+			SET refunded_amount = refunded_amount + $2,
+			    payment_status = CASE
+			        WHEN refunded_amount + $2 >= amount THEN 'refunded'
+			        WHEN refunded_amount + $2 > 0 THEN 'partially_refunded'
+			        ELSE payment_status
+			    END,
+			    balance_due = CASE WHEN $3 THEN balance_due ELSE 0 END
+			WHERE id = $1
+		`, payment.id, refund, payment.combined)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPayment(tx.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = $1`, payment.id))
+}
+
+// paidTowardsPrice returns how much of a completed payment went towards a reservation now priced totalPrice.
+// A moved reservation has paid its price less the balance due: less what is left to pay after a dearer slot,
+// or more when the difference of a cheaper slot is still owed back. A basket payment has paid the
+// reservation's share.
+func paidTowardsPrice(payment *reservationPayment, totalPrice float64) float64 {
+	if payment.combined {
+		return payment.share
+	}
+	return math.Round((totalPrice-payment.balanceDue)*100) / 100
+}
+
+// cancellationRefund returns refundPercent of the part of the booking price that was paid, plus anything
+// paid beyond the current price after the booking was moved to a cheaper slot
+func cancellationRefund(amountPaid, totalPrice, refundPercent float64) float64 {
+	covered := math.Min(amountPaid, totalPrice)
+	refund := math.Max(amountPaid-totalPrice, 0) + covered*refundPercent/100
+	return math.Min(math.Round(refund*100)/100, amountPaid)
+}
+
+// RescheduleReservation atomically moves an active reservation to a new interval and replaces its price breakdown.
+// The price difference is added to the amount of an unpaid payment, or to the balance due of a completed one; a
+// balance turned negative is recorded as a pending refund of the difference. For a basket payment the
+// reservation's share is updated too. Reminders already sent are cleared so they are sent again for the new time.
+// The returned payment is nil when the reservation has none.
+// Returns ErrSlotTaken if the new interval, widened by buffer, overlaps another active reservation,
+// ErrPaymentInProgress while the gateway is processing its payment and ErrSplitPaymentReschedule if its cost is
+// split among event participants.
+func (r *ReservationRepository) RescheduleReservation(reservationID int64, startTime, endTime time.Time, buffer time.Duration, totalPrice float64, priceItems []model.PriceItem) (*model.FacilityReservation, *model.Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var facilityID int64
+	var units int
+	err = tx.QueryRow(`SELECT facility_id, units FROM facility_reservations WHERE id = $1`, reservationID).Scan(&facilityID, &units)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = lockAndCheckSlot(tx, facilityID, startTime, endTime, buffer, units, reservationID); err != nil {
+		return nil, nil, err
+	}
+
+	// Read the previous price under the facility lock, so concurrent reschedules see each other's changes
+	var previousPrice float64
+	err = tx.QueryRow(`SELECT total_price FROM facility_reservations WHERE id = $1`, reservationID).Scan(&previousPrice)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payment, err := lockReservationPayment(tx, reservationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payment != nil {
+		if err = checkReschedulablePayment(tx, payment.id); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	query := `
 		UPDATE facility_reservations
-		SET google_calendar_event_id = $1
-		WHERE id = $2
+		SET start_time = $2, end_time = $3, total_price = $4, reschedule_count = reschedule_count + 1
+		WHERE id = $1 AND ` + activeReservationFilter + `
+		RETURNING id, user_id, facility_id, start_time, end_time, status, total_price, units, created_at,
+		          google_calendar_event_id, series_id, expires_at, reschedule_count
+	`
+	var reservation model.FacilityReservation
+	var eventID sql.NullString
+	err = tx.QueryRow(query, reservationID, startTime, endTime, totalPrice).Scan(
+		&reservation.ID, &reservation.UserID, &reservation.FacilityID,
+		&reservation.StartTime, &reservation.EndTime, &reservation.Status,
+		&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &eventID,
+		&reservation.SeriesID, &reservation.ExpiresAt, &reservation.RescheduleCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil, errors.New("reservation is no longer active")
+	}
+	if err != nil {
+		if isExclusionViolation(err) {
+			return nil, nil, ErrSlotTaken
+		}
+		return nil, nil, err
+	}
+	if eventID.Valid {
+		reservation.GoogleCalendarEventID = &eventID.String
+	}
+
+	if _, err = tx.Exec(`DELETE FROM reservation_price_items WHERE reservation_id = $1`, reservationID); err != nil {
+		return nil, nil, err
+	}
+	if err = insertPriceItems(tx, reservationID, priceItems); err != nil {
+		return nil, nil, err
+	}
+	reservation.PriceBreakdown = priceItems
+
+	if _, err = tx.Exec(`DELETE FROM reservation_reminders WHERE reservation_id = $1`, reservationID); err != nil {
+		return nil, nil, err
+	}
+
+	var updatedPayment *model.Payment
+	if payment != nil {
+		delta := totalPrice - previousPrice
+		switch payment.status {
+		case "pending", "failed":
+			_, err = tx.Exec(`UPDATE payments SET amount = amount + $2 WHERE id = $1`, payment.id, delta)
+		case "completed", "partially_refunded", "refunded":
+			err = addRescheduleBalance(tx, payment, reservationID, delta)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if payment.combined {
+			_, err = tx.Exec(`UPDATE payment_reservations SET amount = $3 WHERE payment_id = $1 AND reservation_id = $2`, payment.id, reservationID, totalPrice)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		updatedPayment, err = scanPayment(tx.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = $1`, payment.id))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		if isExclusionViolation(err) {
+			return nil, nil, ErrSlotTaken
+		}
+		return nil, nil, err
+	}
+
+	return &reservation, updatedPayment, nil
+}
+
+// checkReschedulablePayment refuses to move a reservation whose payment is split among event participants,
+// or that the gateway is processing: a card payment being authorized or captured, or a balance charge being
+// paid. Their amounts are fixed until the gateway answers.
+func checkReschedulablePayment(tx *sql.Tx, paymentID int64) error {
+	var method string
+	var inProgress bool
+	err := tx.QueryRow(`
+		SELECT payment_method,
+		       payment_status = 'authorized'
+		       OR (payment_status = 'pending' AND (gateway_transaction_id IS NOT NULL OR gateway_attempt_id IS NOT NULL))
+		       OR EXISTS (
+		           SELECT 1 FROM payment_balance_charges c
+		           WHERE c.payment_id = payments.id AND c.status = 'pending'
+		           AND (c.gateway_transaction_id IS NOT NULL OR c.gateway_attempt_id IS NOT NULL)
+		       )
+		FROM payments
+		WHERE id = $1
+	`, paymentID).Scan(&method, &inProgress)
+	if err != nil {
+		return err
+	}
+
+	if method == model.PaymentMethodSplit {
+		return ErrSplitPaymentReschedule
+	}
+	if inProgress {
+		return ErrPaymentInProgress
+	}
+	return nil
+}
+
+// addRescheduleBalance adds the price difference of a moved reservation to the balance due of its completed
+// payment. When the balance turns negative, the difference owed back is recorded as a pending refund, up to
+// what is left to refund of the payment.
+func addRescheduleBalance(tx *sql.Tx, payment *reservationPayment, reservationID int64, delta float64) error {
+	var balanceDue float64
+	err := tx.QueryRow(`UPDATE payments SET balance_due = balance_due + $2 WHERE id = $1 RETURNING balance_due`, payment.id, delta).Scan(&balanceDue)
+	if err != nil || balanceDue >= 0 {
+		return err
+	}
+
+	left, err := refundableAmount(tx, payment, reservationID)
+	if err != nil {
+		return err
+	}
+	refund := math.Min(math.Round(-balanceDue*100)/100, left)
+	if refund <= 0 {
+		return nil
+	}
+
+	// A basket payment's share of the reservation becomes its new price, so the refund is not counted against it
+	refundReservationID := &reservationID
+	if payment.combined {
+		refundReservationID = nil
+	}
+	reason := "Moved to a cheaper time"
+	if _, err = insertRefund(tx, payment.id, refundReservationID, refund, model.RefundInitiatorSystem, nil, &reason); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE payments SET refunded_amount = refunded_amount + $2, balance_due = balance_due + $2 WHERE id = $1`, payment.id, refund)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE payments SET payment_status = `+refundedAmountStatus+` WHERE id = $1`, payment.id)
+	return err
+}
+
+// UpdateReservationCalendarEventID links a reservation to its event in the given calendar provider
+func (r *ReservationRepository) UpdateReservationCalendarEventID(reservationID int64, provider, eventID string) error {
+	query := `
+		UPDATE facility_reservations
+		SET google_calendar_event_id = $1, calendar_provider = $2
+		WHERE id = $3
 	`
-	_, err := r.db.Exec(query, eventID, reservationID)
+	_, err := r.db.Exec(query, eventID, provider, reservationID)
+	return err
+}
+
+// ClearReservationCalendarEventID unlinks a reservation from its calendar event
+func (r *ReservationRepository) ClearReservationCalendarEventID(reservationID int64) error {
+	_, err := r.db.Exec(`UPDATE facility_reservations SET google_calendar_event_id = NULL, calendar_provider = NULL WHERE id = $1`, reservationID)
 	return err
 }
 
@@ -311,7 +1147,8 @@ func (r *ReservationRepository) UpdateReservationStatus(reservationID int64, sta
 
 func (r *ReservationRepository) GetReservationByID(reservationID int64) (*model.FacilityReservation, error) {
 	query := `
-		SELECT id, user_id, facility_id, start_time, end_time, status, total_price, created_at, google_calendar_event_id
+		SELECT id, COALESCE(user_id, 0), facility_id, start_time, end_time, status, total_price, units, created_at, google_calendar_event_id, series_id, expires_at,
+		       reschedule_count, cancelled_by, cancellation_reason, created_by, guest_name, guest_phone, calendar_provider
 		FROM facility_reservations
 		WHERE id = $1
 	`
@@ -320,7 +1157,9 @@ func (r *ReservationRepository) GetReservationByID(reservationID int64) (*model.
 	err := r.db.QueryRow(query, reservationID).Scan(
 		&reservation.ID, &reservation.UserID, &reservation.FacilityID,
 		&reservation.StartTime, &reservation.EndTime, &reservation.Status,
-		&reservation.TotalPrice, &reservation.CreatedAt, &eventID,
+		&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &eventID, &reservation.SeriesID, &reservation.ExpiresAt,
+		&reservation.RescheduleCount, &reservation.CancelledBy, &reservation.CancellationReason,
+		&reservation.CreatedBy, &reservation.GuestName, &reservation.GuestPhone, &reservation.CalendarProvider,
 	)
 	if err != nil {
 		return nil, err
@@ -332,13 +1171,90 @@ func (r *ReservationRepository) GetReservationByID(reservationID int64) (*model.
 	return &reservation, nil
 }
 
+// ExpirePendingReservations marks pending reservations whose hold has lapsed as expired
+// and fails their pending payments. It returns the number of reservations expired and the failed
+// payments that had been sent to a gateway, whose authorizations are to be released.
+func (r *ReservationRepository) ExpirePendingReservations() (int64, []model.Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE facility_reservations
+		SET status = 'expired'
+		WHERE status = 'pending' AND expires_at <= NOW()
+	`)
+	if err != nil {
+		return 0, nil, err
+	}
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Also covers holds released while booking the same slot; basket reservations share one hold and expire together
+	rows, err := tx.Query(`
+		UPDATE payments
+		SET payment_status = 'failed', failure_reason = 'the reservation hold expired before the payment completed'
+		WHERE payment_status IN ('pending', 'authorized')
+		AND (reservation_id IN (SELECT id FROM facility_reservations WHERE status = 'expired')
+		     OR id IN (
+		         SELECT pr.payment_id FROM payment_reservations pr
+		         JOIN facility_reservations fr ON fr.id = pr.reservation_id
+		         WHERE fr.status = 'expired'
+		     ))
+		RETURNING ` + paymentColumns)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var payments []model.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return 0, nil, err
+		}
+		if payment.GatewayTransactionID != nil {
+			payments = append(payments, *payment)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	rows.Close()
+
+	if err = tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+
+	return expired, payments, nil
+}
+
+// CompleteFinishedReservations marks confirmed reservations whose end time has passed as completed.
+// It returns the number of reservations updated.
+func (r *ReservationRepository) CompleteFinishedReservations() (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE facility_reservations
+		SET status = 'completed'
+		WHERE status = 'confirmed' AND end_time <= NOW()
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (r *ReservationRepository) GetUpcomingReservations(fromTime, toTime time.Time) ([]model.FacilityReservation, error) {
 	query := `
-		SELECT id, user_id, facility_id, start_time, end_time, status, total_price, created_at, google_calendar_event_id
+		SELECT id, user_id, facility_id, start_time, end_time, status, total_price, units, created_at, google_calendar_event_id
 		FROM facility_reservations
 		WHERE start_time >= $1 
 		AND start_time <= $2
 		AND status = 'confirmed'
+		AND user_id IS NOT NULL
 		ORDER BY start_time
 	`
 	rows, err := r.db.Query(query, fromTime, toTime)
@@ -353,7 +1269,7 @@ func (r *ReservationRepository) GetUpcomingReservations(fromTime, toTime time.Ti
 		var eventID sql.NullString
 		err := rows.Scan(&reservation.ID, &reservation.UserID, &reservation.FacilityID,
 			&reservation.StartTime, &reservation.EndTime, &reservation.Status,
-			&reservation.TotalPrice, &reservation.CreatedAt, &eventID)
+			&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &eventID)
 		if err != nil {
 			return nil, err
 		}
@@ -366,16 +1282,46 @@ func (r *ReservationRepository) GetUpcomingReservations(fromTime, toTime time.Ti
 	return reservations, nil
 }
 
-// GetFacilityBookingsWithUserDetails gets reservations for a facility with user information
+// ClaimReminder records that the reminder with the given lead time is being sent for a reservation.
+// It returns false if that reminder was already claimed, so each reminder is sent at most once.
+func (r *ReservationRepository) ClaimReminder(reservationID int64, leadMinutes int) (bool, error) {
+	query := `
+		INSERT INTO reservation_reminders (reservation_id, lead_minutes)
+		VALUES ($1, $2)
+		ON CONFLICT (reservation_id, lead_minutes) DO NOTHING
+	`
+	result, err := r.db.Exec(query, reservationID, leadMinutes)
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return claimed > 0, nil
+}
+
+// ReleaseReminder removes a reminder claim so the reminder is retried, used when sending failed
+func (r *ReservationRepository) ReleaseReminder(reservationID int64, leadMinutes int) error {
+	_, err := r.db.Exec(`DELETE FROM reservation_reminders WHERE reservation_id = $1 AND lead_minutes = $2`, reservationID, leadMinutes)
+	return err
+}
+
+// GetFacilityBookingsWithUserDetails gets reservations for a facility with user information.
+// Guest bookings carry the guest's name and phone instead of an account.
 func (r *ReservationRepository) GetFacilityBookingsWithUserDetails(facilityID int64, startDate, endDate time.Time) ([]dto.ReservationWithFacilityDTO, error) {
 	query := `
 		SELECT 
-			fr.id, fr.user_id, fr.facility_id, fr.start_time, fr.end_time, 
-			fr.status, fr.total_price, fr.created_at,
-			u.name as user_name, u.email as user_email,
+			fr.id, COALESCE(fr.user_id, 0), fr.facility_id, fr.start_time, fr.end_time, 
+			fr.status, fr.total_price, fr.units, fr.created_at, fr.cancellation_reason, fr.reschedule_count,
+			COALESCE(u.name, fr.guest_name) as user_name, COALESCE(u.email, '') as user_email,
+			fr.guest_phone, fr.created_by,
+			(SELECT COUNT(*) FROM facility_reservations ns WHERE ns.user_id = fr.user_id AND ns.status = 'no_show') as user_no_show_count,
 			f.name as facility_name
 		FROM facility_reservations fr
-		JOIN users u ON fr.user_id = u.id
+		LEFT JOIN users u ON fr.user_id = u.id
 		JOIN facilities f ON fr.facility_id = f.id
 		WHERE fr.facility_id = $1 
 		AND fr.start_time >= $2 
@@ -394,9 +1340,10 @@ func (r *ReservationRepository) GetFacilityBookingsWithUserDetails(facilityID in
 		err := rows.Scan(
 			&reservation.ID, &reservation.UserID, &reservation.FacilityID,
 			&reservation.StartTime, &reservation.EndTime, &reservation.Status,
-			&reservation.TotalPrice, &reservation.CreatedAt,
-			&reservation.UserName, &reservation.UserEmail,
-			&reservation.FacilityName,
+			&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &reservation.CancellationReason,
+			&reservation.RescheduleCount,
+			&reservation.UserName, &reservation.UserEmail, &reservation.GuestPhone, &reservation.CreatedBy,
+			&reservation.UserNoShowCount, &reservation.FacilityName,
 		)
 		if err != nil {
 			return nil, err
@@ -406,3 +1353,166 @@ func (r *ReservationRepository) GetFacilityBookingsWithUserDetails(facilityID in
 
 	return reservations, nil
 }
+
+// CreateReservationSeries inserts a series and its bookable occurrences in a single transaction.
+// Occurrences must arrive with status OccurrenceAvailable or a failure status; their status is
+// updated in place. With skipConflicts, occurrences whose slot is taken are reported as conflicts
+// and the rest are created; otherwise any conflict rolls the whole series back. Each occurrence takes units.
+// Occurrences are booked as confirmed reservations paid on site, each with a pending on-site payment
+// the customer may also settle by card beforehand. It returns the number of reservations created.
+func (r *ReservationRepository) CreateReservationSeries(series *model.ReservationSeries, occurrences []model.SeriesOccurrence, units int, buffer time.Duration, skipConflicts bool, currency string) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO reservation_series (user_id, facility_id, frequency, first_start_time, first_end_time, until_date, occurrence_count, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 'active')
+		RETURNING id, status, created_at
+	`
+	err = tx.QueryRow(query, series.UserID, series.FacilityID, series.Frequency, series.FirstStartTime,
+		series.FirstEndTime, series.UntilDate, series.OccurrenceCount).Scan(&series.ID, &series.Status, &series.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create reservation series: %w", err)
+	}
+
+	created := 0
+	for i := range occurrences {
+		occurrence := &occurrences[i]
+		if occurrence.Status != model.OccurrenceAvailable {
+			continue
+		}
+
+		err := lockAndCheckSlot(tx, series.FacilityID, occurrence.StartTime, occurrence.EndTime, buffer, units, 0)
+		if errors.Is(err, ErrSlotTaken) {
+			occurrence.Status = model.OccurrenceConflict
+			occurrence.Reason = ErrSlotTaken.Error()
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if !skipConflicts {
+		for _, occurrence := range occurrences {
+			if occurrence.Status != model.OccurrenceAvailable {
+				return 0, nil
+			}
+		}
+	}
+
+	for i := range occurrences {
+		occurrence := &occurrences[i]
+		if occurrence.Status != model.OccurrenceAvailable {
+			continue
+		}
+
+		// Series occurrences are not held for payment, they stay booked until cancelled
+		reservation, err := insertReservation(tx, series.UserID, series.FacilityID, &series.ID,
+			occurrence.StartTime, occurrence.EndTime, units, 0, occurrence.TotalPrice, occurrence.PriceItems)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err = tx.Exec(`UPDATE facility_reservations SET status = 'confirmed' WHERE id = $1`, reservation.ID); err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO payments (user_id, reservation_id, amount, currency, payment_method, payment_status, created_at)
+			VALUES ($1, $2, $3, $4, 'on_place', 'pending', NOW())
+		`, series.UserID, reservation.ID, occurrence.TotalPrice, currency)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create payment: %w", err)
+		}
+
+		occurrence.Status = model.OccurrenceCreated
+		occurrence.ReservationID = &reservation.ID
+		created++
+	}
+
+	if created == 0 {
+		return 0, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		if isExclusionViolation(err) {
+			return 0, ErrSlotTaken
+		}
+		return 0, err
+	}
+
+	return created, nil
+}
+
+// GetReservationSeries returns a reservation series, or nil if it does not exist
+func (r *ReservationRepository) GetReservationSeries(seriesID int64) (*model.ReservationSeries, error) {
+	query := `
+		SELECT id, user_id, facility_id, frequency, first_start_time, first_end_time,
+		       until_date::text, occurrence_count, status, created_at
+		FROM reservation_series
+		WHERE id = $1
+	`
+	var series model.ReservationSeries
+	err := r.db.QueryRow(query, seriesID).Scan(
+		&series.ID, &series.UserID, &series.FacilityID, &series.Frequency, &series.FirstStartTime,
+		&series.FirstEndTime, &series.UntilDate, &series.OccurrenceCount, &series.Status, &series.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+// GetSeriesReservations returns all reservations belonging to a series ordered by start time
+func (r *ReservationRepository) GetSeriesReservations(seriesID int64) ([]model.FacilityReservation, error) {
+	query := `
+		SELECT id, user_id, facility_id, start_time, end_time, status, total_price, units, created_at, google_calendar_event_id, series_id
+		FROM facility_reservations
+		WHERE series_id = $1
+		ORDER BY start_time
+	`
+	rows, err := r.db.Query(query, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []model.FacilityReservation
+	for rows.Next() {
+		var reservation model.FacilityReservation
+		err := rows.Scan(
+			&reservation.ID, &reservation.UserID, &reservation.FacilityID,
+			&reservation.StartTime, &reservation.EndTime, &reservation.Status,
+			&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &reservation.GoogleCalendarEventID, &reservation.SeriesID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, rows.Err()
+}
+
+// CancelReservationSeries marks a series of the user as cancelled once none of its occurrences is still to come.
+// A series cancelled only from a later date stays active. Its occurrences are cancelled one by one through
+// CancelReservation beforehand, each settling its own payment.
+func (r *ReservationRepository) CancelReservationSeries(seriesID, userID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE reservation_series
+		SET status = 'cancelled'
+		WHERE id = $1 AND user_id = $2
+		AND NOT EXISTS (
+			SELECT 1 FROM facility_reservations
+			WHERE series_id = $1 AND status IN ('pending', 'confirmed') AND end_time > NOW()
+		)
+	`, seriesID, userID)
+	return err
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/testutil"
+)
+
+// concurrentBookings is how many requests race for the same slot
+const concurrentBookings = 12
+
+// raceForSlot runs book concurrently, released at the same moment, and returns how many calls succeeded
+// and how many were rejected with ErrSlotTaken. Any other error fails the test.
+func raceForSlot(t *testing.T, book func() error) (booked, taken int) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, concurrentBookings)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = book()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			booked++
+		case errors.Is(err, ErrSlotTaken):
+			taken++
+		default:
+			t.Fatalf("unexpected booking error: %v", err)
+		}
+	}
+	return booked, taken
+}
+
+// activeReservations counts the reservations still holding a slot of the facility
+func activeReservations(t *testing.T, db *sql.DB, facilityID int64) int {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM facility_reservations WHERE facility_id = $1 AND `+activeReservationFilter, facilityID).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to count reservations: %v", err)
+	}
+	return count
+}
+
+func TestCreateReservationConcurrentRequestsForOneSlot(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID, facilityID := testutil.CreateFacility(t, db)
+	repo := NewReservationRepository(db)
+
+	startTime := time.Now().Add(72 * time.Hour).Truncate(time.Hour)
+	endTime := startTime.Add(time.Hour)
+
+	booked, taken := raceForSlot(t, func() error {
+		_, err := repo.CreateReservation(userID, facilityID, startTime, endTime, 1, 0, 15*time.Minute, 20, nil)
+		return err
+	})
+
+	if booked != 1 || taken != concurrentBookings-1 {
+		t.Fatalf("got %d bookings and %d ErrSlotTaken, want 1 and %d", booked, taken, concurrentBookings-1)
+	}
+	if count := activeReservations(t, db, facilityID); count != 1 {
+		t.Fatalf("got %d active reservations for the slot, want 1", count)
+	}
+}
+
+// Without the facility lock, the exclusion constraint alone still lets only one booking through
+func TestInsertReservationExclusionConstraint(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID, facilityID := testutil.CreateFacility(t, db)
+
+	startTime := time.Now().Add(96 * time.Hour).Truncate(time.Hour)
+
+	booked, taken := raceForSlot(t, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// Overlapping intervals, not only identical ones, must conflict
+		offset := time.Duration(time.Now().UnixNano()%4) * 15 * time.Minute
+		if _, err := insertReservation(tx, userID, facilityID, nil, startTime.Add(offset), startTime.Add(offset+time.Hour), 1, 15*time.Minute, 20, nil); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+
+	if booked != 1 || taken != concurrentBookings-1 {
+		t.Fatalf("got %d bookings and %d ErrSlotTaken, want 1 and %d", booked, taken, concurrentBookings-1)
+	}
+	if count := activeReservations(t, db, facilityID); count != 1 {
+		t.Fatalf("got %d active reservations for the slot, want 1", count)
+	}
+}
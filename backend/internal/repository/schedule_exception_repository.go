@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+type ScheduleExceptionRepository struct {
+	db *sql.DB
+}
+
+func NewScheduleExceptionRepository(db *sql.DB) *ScheduleExceptionRepository {
+	return &ScheduleExceptionRepository{db: db}
+}
+
+const scheduleExceptionColumns = `
+	se.id, se.facility_id, se.sport_complex_id, se.exception_date::text, se.exception_type,
+	se.start_time::text, se.end_time::text, se.price_per_hour, se.reason, se.created_by, se.created_at
+`
+
+// CreateExceptions inserts the given exceptions in a single transaction, filling in their IDs
+func (r *ScheduleExceptionRepository) CreateExceptions(exceptions []model.ScheduleException) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO schedule_exceptions (facility_id, sport_complex_id, exception_date, exception_type, start_time, end_time, price_per_hour, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`
+	for i := range exceptions {
+		e := &exceptions[i]
+		err := tx.QueryRow(query, e.FacilityID, e.SportComplexID, e.Date, e.Type, e.StartTime, e.EndTime, e.PricePerHour, e.Reason, e.CreatedBy).
+			Scan(&e.ID, &e.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create schedule exception for %s: %w", e.Date, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetExceptionByID returns a single exception, or nil if it does not exist
+func (r *ScheduleExceptionRepository) GetExceptionByID(id int64) (*model.ScheduleException, error) {
+	query := `SELECT ` + scheduleExceptionColumns + ` FROM schedule_exceptions se WHERE se.id = $1`
+
+	exception, err := scanScheduleException(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return exception, nil
+}
+
+// GetExceptionsForFacility returns the facility's own exceptions together with those of its sport complex
+func (r *ScheduleExceptionRepository) GetExceptionsForFacility(facilityID int64, startDate, endDate time.Time) ([]model.ScheduleException, error) {
+	return getExceptionsForFacility(r.db, facilityID, startDate, endDate)
+}
+
+// GetExceptionsForComplex returns the exceptions defined for a whole sport complex
+func (r *ScheduleExceptionRepository) GetExceptionsForComplex(complexID int64, startDate, endDate time.Time) ([]model.ScheduleException, error) {
+	query := `
+		SELECT ` + scheduleExceptionColumns + `
+		FROM schedule_exceptions se
+		WHERE se.sport_complex_id = $1 AND se.exception_date BETWEEN $2::date AND $3::date
+		ORDER BY se.exception_date, se.start_time NULLS FIRST
+	`
+	rows, err := r.db.Query(query, complexID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanScheduleExceptions(rows)
+}
+
+// DeleteException removes a schedule exception
+func (r *ScheduleExceptionRepository) DeleteException(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM schedule_exceptions WHERE id = $1`, id)
+	return err
+}
+
+func getExceptionsForFacility(db *sql.DB, facilityID int64, startDate, endDate time.Time) ([]model.ScheduleException, error) {
+	query := `
+		SELECT ` + scheduleExceptionColumns + `
+		FROM schedule_exceptions se
+		JOIN facilities f ON f.id = $1
+		WHERE (se.facility_id = f.id OR se.sport_complex_id = f.sport_complex_id)
+		  AND se.exception_date BETWEEN $2::date AND $3::date
+		ORDER BY se.exception_date, se.start_time NULLS FIRST
+	`
+	rows, err := db.Query(query, facilityID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanScheduleExceptions(rows)
+}
+
+func scanScheduleException(row interface{ Scan(...interface{}) error }) (*model.ScheduleException, error) {
+	var e model.ScheduleException
+	err := row.Scan(&e.ID, &e.FacilityID, &e.SportComplexID, &e.Date, &e.Type,
+		&e.StartTime, &e.EndTime, &e.PricePerHour, &e.Reason, &e.CreatedBy, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func scanScheduleExceptions(rows *sql.Rows) ([]model.ScheduleException, error) {
+	var exceptions []model.ScheduleException
+	for rows.Next() {
+		e, err := scanScheduleException(rows)
+		if err != nil {
+			return nil, err
+		}
+		exceptions = append(exceptions, *e)
+	}
+	return exceptions, rows.Err()
+}
@@ -14,14 +14,14 @@ func NewSportComplexRepository(db *sql.DB) *SportComplexRepository {
 	return &SportComplexRepository{db: db}
 }
 
-func (r *SportComplexRepository) CreateSportComplex(name, address, city, description string, managerID int64) (*model.SportComplex, error) {
+func (r *SportComplexRepository) CreateSportComplex(name, address, city, description, timeZone string, managerID int64) (*model.SportComplex, error) {
 	query := `
-		INSERT INTO sport_complexes (name, address, city, description, manager_id, is_verified, is_active)
-		VALUES ($1, $2, $3, $4, $5, false, false)
+		INSERT INTO sport_complexes (name, address, city, description, time_zone, manager_id, is_verified, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, false, false)
 		RETURNING id
 	`
 	var id int64
-	err := r.db.QueryRow(query, name, address, city, description, managerID).Scan(&id)
+	err := r.db.QueryRow(query, name, address, city, description, timeZone, managerID).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
@@ -32,6 +32,7 @@ func (r *SportComplexRepository) CreateSportComplex(name, address, city, descrip
 		Address:     address,
 		City:        city,
 		Description: description,
+		TimeZone:    timeZone,
 		ManagerID:   &managerID,
 		IsVerified:  false,
 		IsActive:    false,
@@ -40,7 +41,7 @@ func (r *SportComplexRepository) CreateSportComplex(name, address, city, descrip
 
 func (r *SportComplexRepository) GetAllSportComplexes() ([]model.SportComplex, error) {
 	query := `
-		SELECT id, name, address, city, description, manager_id, is_verified, is_active
+		SELECT id, name, address, city, description, time_zone, manager_id, is_verified, is_active
 		FROM sport_complexes
 		WHERE is_verified = true AND is_active = true
 	`
@@ -53,7 +54,7 @@ func (r *SportComplexRepository) GetAllSportComplexes() ([]model.SportComplex, e
 	var complexes []model.SportComplex
 	for rows.Next() {
 		var complex model.SportComplex
-		err := rows.Scan(&complex.ID, &complex.Name, &complex.Address, &complex.City, &complex.Description, &complex.ManagerID, &complex.IsVerified, &complex.IsActive)
+		err := rows.Scan(&complex.ID, &complex.Name, &complex.Address, &complex.City, &complex.Description, &complex.TimeZone, &complex.ManagerID, &complex.IsVerified, &complex.IsActive)
 		if err != nil {
 			return nil, err
 		}
@@ -65,12 +66,12 @@ func (r *SportComplexRepository) GetAllSportComplexes() ([]model.SportComplex, e
 
 func (r *SportComplexRepository) GetSportComplexByID(id int64) (*model.SportComplex, error) {
 	query := `
-		SELECT id, name, address, city, description, manager_id, is_verified, is_active
+		SELECT id, name, address, city, description, time_zone, manager_id, is_verified, is_active
 		FROM sport_complexes
 		WHERE id = $1
 	`
 	var complex model.SportComplex
-	err := r.db.QueryRow(query, id).Scan(&complex.ID, &complex.Name, &complex.Address, &complex.City, &complex.Description, &complex.ManagerID, &complex.IsVerified, &complex.IsActive)
+	err := r.db.QueryRow(query, id).Scan(&complex.ID, &complex.Name, &complex.Address, &complex.City, &complex.Description, &complex.TimeZone, &complex.ManagerID, &complex.IsVerified, &complex.IsActive)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +81,7 @@ func (r *SportComplexRepository) GetSportComplexByID(id int64) (*model.SportComp
 
 func (r *SportComplexRepository) GetComplexesByManagerID(managerID int64) ([]model.SportComplex, error) {
 	query := `
-		SELECT id, name, address, city, description, manager_id, is_verified, is_active
+		SELECT id, name, address, city, description, time_zone, manager_id, is_verified, is_active
 		FROM sport_complexes
 		WHERE manager_id = $1
 		ORDER BY id DESC
@@ -95,7 +96,7 @@ func (r *SportComplexRepository) GetComplexesByManagerID(managerID int64) ([]mod
 	complexes := []model.SportComplex{}
 	for rows.Next() {
 		var complex model.SportComplex
-		err := rows.Scan(&complex.ID, &complex.Name, &complex.Address, &complex.City, &complex.Description, &complex.ManagerID, &complex.IsVerified, &complex.IsActive)
+		err := rows.Scan(&complex.ID, &complex.Name, &complex.Address, &complex.City, &complex.Description, &complex.TimeZone, &complex.ManagerID, &complex.IsVerified, &complex.IsActive)
 		if err != nil {
 			return nil, err
 		}
@@ -107,7 +108,7 @@ func (r *SportComplexRepository) GetComplexesByManagerID(managerID int64) ([]mod
 
 func (r *SportComplexRepository) GetPendingComplexes() ([]model.SportComplex, error) {
 	query := `
-		SELECT id, name, address, city, description, manager_id, is_verified, is_active
+		SELECT id, name, address, city, description, time_zone, manager_id, is_verified, is_active
 		FROM sport_complexes
 		WHERE is_verified = false OR is_active = false
 		ORDER BY id DESC
@@ -121,7 +122,7 @@ func (r *SportComplexRepository) GetPendingComplexes() ([]model.SportComplex, er
 	var complexes []model.SportComplex
 	for rows.Next() {
 		var complex model.SportComplex
-		err := rows.Scan(&complex.ID, &complex.Name, &complex.Address, &complex.City, &complex.Description, &complex.ManagerID, &complex.IsVerified, &complex.IsActive)
+		err := rows.Scan(&complex.ID, &complex.Name, &complex.Address, &complex.City, &complex.Description, &complex.TimeZone, &complex.ManagerID, &complex.IsVerified, &complex.IsActive)
 		if err != nil {
 			return nil, err
 		}
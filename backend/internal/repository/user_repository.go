@@ -419,3 +419,26 @@ func (r *UserRepository) DeactivateUser(userID int64) error {
 	return tx.Commit()
 }
 
+
+// GetCalendarFeedToken returns the user's calendar feed token, or an empty string when none was issued
+func (r *UserRepository) GetCalendarFeedToken(userID int64) (string, error) {
+	var token sql.NullString
+	err := r.db.QueryRow(`SELECT calendar_feed_token FROM users WHERE id = $1`, userID).Scan(&token)
+	if err != nil {
+		return "", err
+	}
+	return token.String, nil
+}
+
+// SetCalendarFeedToken replaces the user's calendar feed token, invalidating feed URLs built with the old one
+func (r *UserRepository) SetCalendarFeedToken(userID int64, token string) error {
+	_, err := r.db.Exec(`UPDATE users SET calendar_feed_token = $1 WHERE id = $2`, token, userID)
+	return err
+}
+
+// GetUserIDByCalendarFeedToken returns the active user owning a calendar feed token
+func (r *UserRepository) GetUserIDByCalendarFeedToken(token string) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(`SELECT id FROM users WHERE calendar_feed_token = $1 AND is_active = TRUE`, token).Scan(&userID)
+	return userID, err
+}
@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/lib/pq"
+)
+
+// ErrAlreadyWaitlisted is returned when a user joins the waitlist for a slot they are already waiting for
+var ErrAlreadyWaitlisted = errors.New("you are already on the waitlist for this time slot")
+
+type WaitlistRepository struct {
+	db *sql.DB
+}
+
+func NewWaitlistRepository(db *sql.DB) *WaitlistRepository {
+	return &WaitlistRepository{db: db}
+}
+
+const waitlistColumns = `id, user_id, facility_id, start_time, end_time, units, status, reservation_id, offered_at, offer_expires_at, created_at`
+
+// CreateEntry adds a user to the waitlist for units of a facility and time range
+func (r *WaitlistRepository) CreateEntry(userID, facilityID int64, startTime, endTime time.Time, units int) (*model.WaitlistEntry, error) {
+	query := `
+		INSERT INTO reservation_waitlist (user_id, facility_id, start_time, end_time, units)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + waitlistColumns
+
+	entry, err := scanWaitlistEntry(r.db.QueryRow(query, userID, facilityID, startTime, endTime, units))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrAlreadyWaitlisted
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// GetEntryByID returns a waitlist entry, or nil if it does not exist
+func (r *WaitlistRepository) GetEntryByID(id int64) (*model.WaitlistEntry, error) {
+	entry, err := scanWaitlistEntry(r.db.QueryRow(`SELECT `+waitlistColumns+` FROM reservation_waitlist WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// GetUserEntries returns the user's waiting and offered entries for slots that have not started yet
+func (r *WaitlistRepository) GetUserEntries(userID int64) ([]model.WaitlistEntry, error) {
+	query := `
+		SELECT ` + waitlistColumns + `
+		FROM reservation_waitlist
+		WHERE user_id = $1 AND status IN ('waiting', 'offered', 'claimed') AND start_time > NOW()
+		ORDER BY start_time
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWaitlistEntries(rows)
+}
+
+// GetOfferCandidates returns waiting entries, oldest first, whose slot may be free: it has no overlapping
+// active reservation, or belongs to a shared facility where OfferSlot checks the free units.
+// A facilityID of 0 returns candidates for every facility.
+func (r *WaitlistRepository) GetOfferCandidates(facilityID int64) ([]model.WaitlistEntry, error) {
+	query := `
+		SELECT ` + waitlistColumns + `
+		FROM reservation_waitlist w
+		WHERE w.status = 'waiting'
+		AND w.start_time > NOW()
+		AND ($1::bigint = 0 OR w.facility_id = $1)
+		AND (EXISTS (
+			SELECT 1
+			FROM facility_booking_policies
+			WHERE facility_id = w.facility_id AND availability_mode = 'shared'
+		) OR NOT EXISTS (
+			SELECT 1
+			FROM facility_reservations
+			WHERE facility_id = w.facility_id
+			AND ` + activeReservationFilter + `
+			AND start_time < w.end_time
+			AND end_time > w.start_time
+		))
+		ORDER BY w.created_at
+	`
+	rows, err := r.db.Query(query, facilityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWaitlistEntries(rows)
+}
+
+// OfferSlot holds the slot of a waiting entry for its user with a pending reservation that expires after claimWindow.
+// Returns ErrSlotTaken if the slot, widened by buffer, is no longer free, and nil if the entry is no longer waiting.
+func (r *WaitlistRepository) OfferSlot(entryID int64, buffer, claimWindow time.Duration, totalPrice float64, priceItems []model.PriceItem) (*model.FacilityReservation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entry, err := scanWaitlistEntry(tx.QueryRow(`SELECT `+waitlistColumns+` FROM reservation_waitlist WHERE id = $1 AND status = 'waiting' FOR UPDATE`, entryID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = lockAndCheckSlot(tx, entry.FacilityID, entry.StartTime, entry.EndTime, buffer, entry.Units, 0); err != nil {
+		return nil, err
+	}
+
+	reservation, err := insertReservation(tx, entry.UserID, entry.FacilityID, nil, entry.StartTime, entry.EndTime, entry.Units, claimWindow, totalPrice, priceItems)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reservation_waitlist
+		SET status = 'offered', reservation_id = $2, offered_at = NOW(), offer_expires_at = $3
+		WHERE id = $1
+	`, entryID, reservation.ID, reservation.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrSlotTaken
+		}
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// ClaimOffer accepts an offered slot. The holding reservation stays pending until paid,
+// with its expiry extended to at least hold from now. Returns nil if the offer is no longer open.
+func (r *WaitlistRepository) ClaimOffer(entryID int64, hold time.Duration) (*model.FacilityReservation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var reservationID sql.NullInt64
+	err = tx.QueryRow(`SELECT reservation_id FROM reservation_waitlist WHERE id = $1 AND status = 'offered' FOR UPDATE`, entryID).Scan(&reservationID)
+	if err == sql.ErrNoRows || (err == nil && !reservationID.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE facility_reservations
+		SET expires_at = GREATEST(expires_at, NOW() + $2::double precision * INTERVAL '1 second')
+		WHERE id = $1 AND status = 'pending' AND expires_at > NOW()
+		RETURNING id, user_id, facility_id, start_time, end_time, status, total_price, units, created_at, expires_at
+	`
+	var reservation model.FacilityReservation
+	err = tx.QueryRow(query, reservationID.Int64, hold.Seconds()).Scan(
+		&reservation.ID, &reservation.UserID, &reservation.FacilityID,
+		&reservation.StartTime, &reservation.EndTime, &reservation.Status,
+		&reservation.TotalPrice, &reservation.Units, &reservation.CreatedAt, &reservation.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec(`UPDATE reservation_waitlist SET status = 'claimed' WHERE id = $1`, entryID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// CancelEntry removes a user from the waitlist. If the slot was already offered to them,
+// the holding reservation is released. Returns false if the entry was not waiting or offered.
+func (r *WaitlistRepository) CancelEntry(entryID int64) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var reservationID sql.NullInt64
+	err = tx.QueryRow(`
+		UPDATE reservation_waitlist
+		SET status = 'cancelled'
+		WHERE id = $1 AND status IN ('waiting', 'offered')
+		RETURNING reservation_id
+	`, entryID).Scan(&reservationID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if reservationID.Valid {
+		_, err = tx.Exec(`UPDATE facility_reservations SET status = 'cancelled' WHERE id = $1 AND status = 'pending'`, reservationID.Int64)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// SettleOffers closes offers whose holding reservation was paid (claimed) or lapsed (expired), expires claimed
+// offers whose hold lapsed before it was paid, and expires waiting entries for slots that have already started.
+// It returns the number of entries updated.
+func (r *WaitlistRepository) SettleOffers() (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE reservation_waitlist w
+		SET status = CASE WHEN fr.status IN ('confirmed', 'completed') THEN 'claimed' ELSE 'expired' END
+		FROM facility_reservations fr
+		WHERE w.reservation_id = fr.id
+		AND ((w.status = 'offered' AND (fr.status <> 'pending' OR fr.expires_at <= NOW()))
+		     OR (w.status = 'claimed' AND (fr.status = 'expired' OR (fr.status = 'pending' AND fr.expires_at <= NOW()))))
+	`)
+	if err != nil {
+		return 0, err
+	}
+	settled, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err = r.db.Exec(`UPDATE reservation_waitlist SET status = 'expired' WHERE status = 'waiting' AND start_time <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	stale, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return settled + stale, nil
+}
+
+func scanWaitlistEntry(row interface{ Scan(...interface{}) error }) (*model.WaitlistEntry, error) {
+	var e model.WaitlistEntry
+	err := row.Scan(&e.ID, &e.UserID, &e.FacilityID, &e.StartTime, &e.EndTime, &e.Units, &e.Status,
+		&e.ReservationID, &e.OfferedAt, &e.OfferExpiresAt, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	e.Offered = e.Status == model.WaitlistOffered && e.OfferExpiresAt != nil && e.OfferExpiresAt.After(time.Now())
+	return &e, nil
+}
+
+func scanWaitlistEntries(rows *sql.Rows) ([]model.WaitlistEntry, error) {
+	entries := []model.WaitlistEntry{}
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	return entries, rows.Err()
+}
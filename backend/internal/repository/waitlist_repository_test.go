@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/testutil"
+)
+
+func TestSettleOffersExpiresLapsedClaims(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID, facilityID := testutil.CreateFacility(t, db)
+	repo := NewWaitlistRepository(db)
+
+	startTime := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	entry, err := repo.CreateEntry(userID, facilityID, startTime, startTime.Add(time.Hour), 1)
+	if err != nil {
+		t.Fatalf("CreateEntry: %v", err)
+	}
+	if _, err := repo.OfferSlot(entry.ID, 0, 30*time.Minute, 20, nil); err != nil {
+		t.Fatalf("OfferSlot: %v", err)
+	}
+	reservation, err := repo.ClaimOffer(entry.ID, 15*time.Minute)
+	if err != nil || reservation == nil {
+		t.Fatalf("ClaimOffer: %v", err)
+	}
+
+	// The claimed hold runs out before it is paid
+	if _, err := db.Exec(`UPDATE facility_reservations SET expires_at = NOW() - INTERVAL '1 second' WHERE id = $1`, reservation.ID); err != nil {
+		t.Fatalf("failed to lapse reservation hold: %v", err)
+	}
+	if _, err := repo.SettleOffers(); err != nil {
+		t.Fatalf("SettleOffers: %v", err)
+	}
+
+	settled, err := repo.GetEntryByID(entry.ID)
+	if err != nil || settled == nil {
+		t.Fatalf("failed to get entry: %v", err)
+	}
+	if settled.Status != model.WaitlistExpired {
+		t.Errorf("entry is %s after its claimed hold lapsed, want %s", settled.Status, model.WaitlistExpired)
+	}
+}
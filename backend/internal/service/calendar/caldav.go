@@ -0,0 +1,248 @@
+package calendar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// ErrHostNotAllowed is returned for calendar URLs that are not https or that lead to a loopback, private
+// or otherwise non-public address, which users must not be able to reach through PlaySpot
+var ErrHostNotAllowed = errors.New("calendar URL must be an https URL of a public server")
+
+// CalDAVProvider implements CalendarProvider for CalDAV servers such as Nextcloud, Radicale or iCloud.
+// Each booking is stored as one iCalendar resource named after its UID in the user's calendar collection,
+// and the resource URL serves as the event ID. Calendar URLs come from users, so requests only go to
+// public addresses over https and redirects are not followed.
+type CalDAVProvider struct {
+	client *http.Client
+}
+
+// NewCalDAVProvider creates a CalDAV provider
+func NewCalDAVProvider() *CalDAVProvider {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		// Checked on every connection, after DNS resolution, so a host cannot resolve to an internal address
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrHostNotAllowed
+			}
+			return nil
+		},
+	}
+
+	return &CalDAVProvider{client: &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// ValidateCalendarURL checks that a calendar URL is an https URL whose host resolves only to public
+// addresses. Connections are checked again when made, as the host may resolve differently later.
+func ValidateCalendarURL(calendarURL string) error {
+	parsed, err := url.Parse(calendarURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" || parsed.User != nil {
+		return ErrHostNotAllowed
+	}
+
+	ips, err := net.LookupIP(parsed.Hostname())
+	if err != nil || len(ips) == 0 {
+		return fmt.Errorf("could not resolve calendar host %s", parsed.Hostname())
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return ErrHostNotAllowed
+		}
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip is a globally reachable unicast address
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, block := range nonPublicBlocks {
+		if block.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// nonPublicBlocks are reserved ranges not covered by the net.IP predicates
+var nonPublicBlocks = func() []*net.IPNet {
+	var blocks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",      // "this" network
+		"100.64.0.0/10",  // carrier-grade NAT
+		"192.0.0.0/24",   // IETF protocol assignments
+		"198.18.0.0/15",  // benchmarking
+		"240.0.0.0/4",    // reserved
+		"64:ff9b:1::/48", // local-use IPv4/IPv6 translation
+		"2001:db8::/32",  // documentation
+	} {
+		_, block, _ := net.ParseCIDR(cidr)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}()
+
+// RefreshCredentials returns the credentials unchanged; CalDAV uses basic authentication
+func (c *CalDAVProvider) RefreshCredentials(creds Credentials) (Credentials, error) {
+	return creds, nil
+}
+
+// CheckCalendar verifies that the calendar collection exists and accepts the credentials
+func (c *CalDAVProvider) CheckCalendar(creds Credentials) error {
+	body := `<?xml version="1.0" encoding="utf-8"?><d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/></d:prop></d:propfind>`
+	resp, err := c.do(creds, "PROPFIND", creds.CalendarURL, strings.NewReader(body), map[string]string{
+		"Depth":        "0",
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus && resp.StatusCode != http.StatusOK {
+		return statusError("calendar not found", resp)
+	}
+	return nil
+}
+
+// CreateEvent stores the entry as a new calendar resource
+func (c *CalDAVProvider) CreateEvent(creds Credentials, entry model.CalendarEntry) (string, error) {
+	eventURL, err := resourceURL(creds.CalendarURL, entry.UID)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.put(creds, eventURL, entry, map[string]string{"If-None-Match": "*"})
+	if err == errPreconditionFailed {
+		// A previous attempt stored the resource but its URL was never recorded; overwrite it
+		err = c.put(creds, eventURL, entry, nil)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return eventURL, nil
+}
+
+// UpdateEvent replaces the calendar resource at eventID
+func (c *CalDAVProvider) UpdateEvent(creds Credentials, eventID string, entry model.CalendarEntry) error {
+	err := c.put(creds, eventID, entry, map[string]string{"If-Match": "*"})
+	if err == errPreconditionFailed {
+		return ErrEventNotFound
+	}
+	return err
+}
+
+// DeleteEvent removes the calendar resource at eventID
+func (c *CalDAVProvider) DeleteEvent(creds Credentials, eventID string) error {
+	resp, err := c.do(creds, http.MethodDelete, eventID, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrEventNotFound
+	case resp.StatusCode >= 300:
+		return statusError("failed to delete calendar event", resp)
+	}
+	return nil
+}
+
+// errPreconditionFailed reports a conditional PUT rejected because the resource did (not) exist
+var errPreconditionFailed = errors.New("precondition failed")
+
+func (c *CalDAVProvider) put(creds Credentials, eventURL string, entry model.CalendarEntry, headers map[string]string) error {
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	headers["Content-Type"] = "text/calendar; charset=utf-8"
+
+	resp, err := c.do(creds, http.MethodPut, eventURL, bytes.NewReader(Encode("", []model.CalendarEntry{entry})), headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return errPreconditionFailed
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrEventNotFound
+	case resp.StatusCode >= 300:
+		return statusError("failed to save calendar event", resp)
+	}
+	return nil
+}
+
+func (c *CalDAVProvider) do(creds Credentials, method, target string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid calendar URL: %w", err)
+	}
+	if req.URL.Scheme != "https" {
+		return nil, ErrHostNotAllowed
+	}
+	req.SetBasicAuth(creds.Username, creds.Password)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if errors.Is(err, ErrHostNotAllowed) {
+		return nil, ErrHostNotAllowed
+	}
+	if err != nil {
+		return nil, errors.New("calendar server request failed")
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: server answered %d", ErrUnauthorized, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// resourceURL returns the URL of the resource holding the event with the given UID
+func resourceURL(calendarURL, uid string) (string, error) {
+	base, err := url.Parse(calendarURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return "", fmt.Errorf("invalid calendar URL: %s", calendarURL)
+	}
+	return strings.TrimSuffix(base.String(), "/") + "/" + url.PathEscape(uid) + ".ics", nil
+}
+
+// statusError describes an unexpected response by its status code alone; the server's reason phrase and
+// body are not passed on, since the errors reach the user who chose the server
+func statusError(message string, resp *http.Response) error {
+	return fmt.Errorf("%s: server answered %d %s", message, resp.StatusCode, http.StatusText(resp.StatusCode))
+}
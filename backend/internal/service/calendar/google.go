@@ -0,0 +1,203 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	gcal "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// GoogleProvider implements CalendarProvider for the user's primary Google Calendar
+type GoogleProvider struct {
+	config *oauth2.Config
+}
+
+// NewGoogleProvider creates a Google Calendar provider for the app's OAuth client
+func NewGoogleProvider(clientID, clientSecret string) *GoogleProvider {
+	return &GoogleProvider{config: GoogleOAuthConfig(clientID, clientSecret)}
+}
+
+// GoogleOAuthConfig returns the OAuth2 config for Google Calendar
+func GoogleOAuthConfig(clientID, clientSecret string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  "postmessage", // For auth-code flow with @react-oauth/google
+		Endpoint:     google.Endpoint,
+		Scopes:       []string{gcal.CalendarEventsScope},
+	}
+}
+
+// ClientID returns the app's OAuth client ID, the audience of the Google ID tokens issued to it
+func (g *GoogleProvider) ClientID() string {
+	return g.config.ClientID
+}
+
+// ExchangeCode exchanges an authorization code from Google sign-in for the user's calendar credentials.
+// It also returns the ID token identifying the user, or "" when Google did not include one.
+func (g *GoogleProvider) ExchangeCode(code string) (Credentials, string, error) {
+	token, err := g.config.Exchange(context.Background(), code)
+	if err != nil {
+		return Credentials{}, "", fmt.Errorf("failed to exchange code for token: %w", err)
+	}
+
+	idToken, _ := token.Extra("id_token").(string)
+	return Credentials{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
+	}, idToken, nil
+}
+
+// RefreshCredentials exchanges the refresh token for a new access token once the current one expired
+func (g *GoogleProvider) RefreshCredentials(creds Credentials) (Credentials, error) {
+	token, err := g.config.TokenSource(context.Background(), oauthToken(creds)).Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			return creds, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+		}
+		return creds, fmt.Errorf("failed to refresh Google token: %w", err)
+	}
+
+	creds.AccessToken = token.AccessToken
+	creds.Expiry = token.Expiry
+	// Google only returns a refresh token when it rotates it
+	if token.RefreshToken != "" {
+		creds.RefreshToken = token.RefreshToken
+	}
+	return creds, nil
+}
+
+// CreateEvent creates a calendar event, in the entry's time zone
+func (g *GoogleProvider) CreateEvent(creds Credentials, entry model.CalendarEntry) (string, error) {
+	service, err := g.calendarService(creds)
+	if err != nil {
+		return "", err
+	}
+
+	event := &gcal.Event{
+		Summary:     entry.Summary,
+		Description: entry.Description,
+		Location:    entry.Location,
+		Start:       eventDateTime(entry.StartTime, entry.TimeZone),
+		End:         eventDateTime(entry.EndTime, entry.TimeZone),
+		ColorId:     "9", // Blue color
+		Reminders: &gcal.EventReminders{
+			UseDefault: true, // Use user's default calendar reminder settings
+		},
+	}
+
+	createdEvent, err := service.Events.Insert("primary", event).Do()
+	if err != nil {
+		return "", googleError("failed to create calendar event", err)
+	}
+
+	return createdEvent.Id, nil
+}
+
+// UpdateEvent updates an existing calendar event, in the entry's time zone
+func (g *GoogleProvider) UpdateEvent(creds Credentials, eventID string, entry model.CalendarEntry) error {
+	service, err := g.calendarService(creds)
+	if err != nil {
+		return err
+	}
+
+	event, err := service.Events.Get("primary", eventID).Do()
+	if err != nil {
+		return googleError("failed to get event", err)
+	}
+
+	// Events deleted in Google Calendar linger with status cancelled
+	if event.Status == "cancelled" {
+		return ErrEventNotFound
+	}
+
+	event.Summary = entry.Summary
+	event.Description = entry.Description
+	event.Location = entry.Location
+	event.Start = eventDateTime(entry.StartTime, entry.TimeZone)
+	event.End = eventDateTime(entry.EndTime, entry.TimeZone)
+
+	_, err = service.Events.Update("primary", eventID, event).Do()
+	if err != nil {
+		return googleError("failed to update calendar event", err)
+	}
+
+	return nil
+}
+
+// DeleteEvent deletes a calendar event
+func (g *GoogleProvider) DeleteEvent(creds Credentials, eventID string) error {
+	service, err := g.calendarService(creds)
+	if err != nil {
+		return err
+	}
+
+	err = service.Events.Delete("primary", eventID).Do()
+	if err != nil {
+		return googleError("failed to delete calendar event", err)
+	}
+
+	return nil
+}
+
+// calendarService creates a Google Calendar service authorized by the user's tokens
+func (g *GoogleProvider) calendarService(creds Credentials) (*gcal.Service, error) {
+	ctx := context.Background()
+	client := g.config.Client(ctx, oauthToken(creds))
+
+	service, err := gcal.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calendar service: %w", err)
+	}
+
+	return service, nil
+}
+
+func oauthToken(creds Credentials) *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  creds.AccessToken,
+		RefreshToken: creds.RefreshToken,
+		Expiry:       creds.Expiry,
+		TokenType:    "Bearer",
+	}
+}
+
+// googleError maps Google API errors onto the provider errors
+func googleError(message string, err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusNotFound, http.StatusGone:
+			return ErrEventNotFound
+		case http.StatusUnauthorized:
+			return fmt.Errorf("%w: %v", ErrUnauthorized, err)
+		}
+	}
+
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// eventDateTime expresses an instant as local time in the facility's time zone,
+// so Google Calendar keeps it at the right wall-clock time across daylight saving changes
+func eventDateTime(t time.Time, timeZone string) *gcal.EventDateTime {
+	loc := loadLocation(timeZone)
+	return &gcal.EventDateTime{
+		DateTime: t.In(loc).Format(time.RFC3339),
+		TimeZone: loc.String(),
+	}
+}
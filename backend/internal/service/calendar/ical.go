@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// icalProductID identifies PlaySpot as the producer of iCalendar data
+const icalProductID = "-//PlaySpot//PlaySpot Bookings//EN"
+
+// icalTimeFormat is the UTC date-time form used for all iCalendar times
+const icalTimeFormat = "20060102T150405Z"
+
+// Encode renders calendar entries as an iCalendar (RFC 5545) document
+func Encode(name string, entries []model.CalendarEntry) []byte {
+	var b strings.Builder
+	stamp := time.Now().UTC().Format(icalTimeFormat)
+
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:"+icalProductID)
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	writeICalLine(&b, "METHOD:PUBLISH")
+	if name != "" {
+		writeICalLine(&b, "X-WR-CALNAME:"+escapeICalText(name))
+	}
+
+	for _, entry := range entries {
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, "UID:"+entry.UID)
+		writeICalLine(&b, "DTSTAMP:"+stamp)
+		writeICalLine(&b, "DTSTART:"+entry.StartTime.UTC().Format(icalTimeFormat))
+		writeICalLine(&b, "DTEND:"+entry.EndTime.UTC().Format(icalTimeFormat))
+		writeICalLine(&b, fmt.Sprintf("SEQUENCE:%d", entry.Sequence))
+		if !entry.UpdatedAt.IsZero() {
+			writeICalLine(&b, "LAST-MODIFIED:"+entry.UpdatedAt.UTC().Format(icalTimeFormat))
+		}
+		writeICalLine(&b, "SUMMARY:"+escapeICalText(entry.Summary))
+		if entry.Description != "" {
+			writeICalLine(&b, "DESCRIPTION:"+escapeICalText(entry.Description))
+		}
+		if entry.Location != "" {
+			writeICalLine(&b, "LOCATION:"+escapeICalText(entry.Location))
+		}
+		if entry.Cancelled {
+			writeICalLine(&b, "STATUS:CANCELLED")
+		} else {
+			writeICalLine(&b, "STATUS:CONFIRMED")
+		}
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// escapeICalText escapes a TEXT property value
+func escapeICalText(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(value)
+}
+
+// writeICalLine writes a content line, folding it at 75 octets without splitting UTF-8 characters
+func writeICalLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,131 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// unfold joins folded iCalendar content lines and splits the document into its logical lines
+func unfold(t *testing.T, data []byte) []string {
+	t.Helper()
+	document := string(data)
+	if !strings.HasSuffix(document, "\r\n") {
+		t.Fatalf("document does not end with CRLF")
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(document, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets is longer than 75: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("folding split a UTF-8 character: %q", line)
+		}
+	}
+	return strings.Split(strings.ReplaceAll(strings.TrimSuffix(document, "\r\n"), "\r\n ", ""), "\r\n")
+}
+
+func TestEscapeICalText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "Court 1", want: "Court 1"},
+		{value: "Sofia, Bulgaria", want: `Sofia\, Bulgaria`},
+		{value: "Tennis; doubles", want: `Tennis\; doubles`},
+		{value: `C:\courts`, want: `C:\\courts`},
+		{value: "Facility: A\nSport: Tennis", want: `Facility: A\nSport: Tennis`},
+		{value: "Facility: A\r\nSport: Tennis", want: `Facility: A\nSport: Tennis`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeICalText(tt.value); got != tt.want {
+			t.Errorf("escapeICalText(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriteICalLineFolding(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantLines int
+	}{
+		{name: "short", line: "SUMMARY:Tennis", wantLines: 1},
+		{name: "exactly 75 octets", line: "SUMMARY:" + strings.Repeat("a", 67), wantLines: 1},
+		{name: "76 octets", line: "SUMMARY:" + strings.Repeat("a", 68), wantLines: 2},
+		{name: "multi-byte characters", line: "SUMMARY:" + strings.Repeat("Тенис корт ", 20), wantLines: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeICalLine(&b, tt.line)
+
+			physical := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+			if len(physical) != tt.wantLines {
+				t.Errorf("folded into %d lines, want %d", len(physical), tt.wantLines)
+			}
+			for i, line := range physical[1:] {
+				if !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space: %q", i+1, line)
+				}
+			}
+			if got := unfold(t, []byte(b.String())); len(got) != 1 || got[0] != tt.line {
+				t.Errorf("unfolded to %q, want %q", got, tt.line)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	start := time.Date(2026, 10, 25, 1, 30, 0, 0, time.FixedZone("EEST", 3*3600))
+	entries := []model.CalendarEntry{
+		{
+			UID:         "reservation-1@playspot",
+			Summary:     "PlaySpot Booking - Court 1, indoor",
+			Description: "Facility: Court 1\nPrice: €20.00",
+			Location:    "Test Street 1, Sofia",
+			StartTime:   start,
+			EndTime:     start.Add(2 * time.Hour),
+			Sequence:    2,
+		},
+		{UID: "reservation-2@playspot", Summary: "Cancelled", StartTime: start, EndTime: start.Add(time.Hour), Cancelled: true},
+	}
+
+	lines := unfold(t, Encode("My bookings; PlaySpot", entries))
+
+	want := []string{
+		"BEGIN:VCALENDAR",
+		`X-WR-CALNAME:My bookings\; PlaySpot`,
+		"BEGIN:VEVENT",
+		"UID:reservation-1@playspot",
+		"DTSTART:20261024T223000Z",
+		"DTEND:20261025T003000Z",
+		"SEQUENCE:2",
+		`SUMMARY:PlaySpot Booking - Court 1\, indoor`,
+		`DESCRIPTION:Facility: Court 1\nPrice: €20.00`,
+		`LOCATION:Test Street 1\, Sofia`,
+		"STATUS:CONFIRMED",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:reservation-2@playspot",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+
+	// The wanted lines appear in order
+	next := 0
+	for _, line := range lines {
+		if next < len(want) && line == want[next] {
+			next++
+		}
+	}
+	if next != len(want) {
+		t.Errorf("missing %q in calendar:\n%s", want[next], strings.Join(lines, "\n"))
+	}
+}
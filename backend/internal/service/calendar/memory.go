@@ -0,0 +1,137 @@
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// MemoryProvider implements CalendarProvider without any external service, for tests and offline
+// development. Events live in memory and, when a file path is given, are saved to that JSON file
+// after every change so they survive restarts and can be inspected.
+type MemoryProvider struct {
+	mu     sync.Mutex
+	path   string
+	state  memoryState
+	tokens int
+}
+
+type memoryState struct {
+	NextID int64                          `json:"next_id"`
+	Events map[string]model.CalendarEntry `json:"events"`
+}
+
+// NewMemoryProvider creates an in-memory calendar, loading events previously saved to path.
+// An empty path keeps events in memory only.
+func NewMemoryProvider(path string) (*MemoryProvider, error) {
+	m := &MemoryProvider{
+		path:  path,
+		state: memoryState{NextID: 1, Events: map[string]model.CalendarEntry{}},
+	}
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calendar file: %w", err)
+	}
+	if err := json.Unmarshal(data, &m.state); err != nil {
+		return nil, fmt.Errorf("failed to parse calendar file: %w", err)
+	}
+	if m.state.Events == nil {
+		m.state.Events = map[string]model.CalendarEntry{}
+	}
+	return m, nil
+}
+
+// RefreshCredentials issues a new fake access token once the current one expired,
+// so token persistence can be exercised offline
+func (m *MemoryProvider) RefreshCredentials(creds Credentials) (Credentials, error) {
+	if creds.Expiry.IsZero() || creds.Expiry.After(time.Now()) {
+		return creds, nil
+	}
+
+	m.mu.Lock()
+	m.tokens++
+	creds.AccessToken = fmt.Sprintf("memory-token-%d", m.tokens)
+	m.mu.Unlock()
+
+	creds.Expiry = time.Now().Add(time.Hour)
+	return creds, nil
+}
+
+// CreateEvent stores the entry and returns its generated ID
+func (m *MemoryProvider) CreateEvent(creds Credentials, entry model.CalendarEntry) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	eventID := fmt.Sprintf("memory-event-%d", m.state.NextID)
+	m.state.NextID++
+	m.state.Events[eventID] = entry
+
+	return eventID, m.save()
+}
+
+// UpdateEvent replaces a stored entry
+func (m *MemoryProvider) UpdateEvent(creds Credentials, eventID string, entry model.CalendarEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.state.Events[eventID]; !ok {
+		return ErrEventNotFound
+	}
+	m.state.Events[eventID] = entry
+
+	return m.save()
+}
+
+// DeleteEvent removes a stored entry
+func (m *MemoryProvider) DeleteEvent(creds Credentials, eventID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.state.Events[eventID]; !ok {
+		return ErrEventNotFound
+	}
+	delete(m.state.Events, eventID)
+
+	return m.save()
+}
+
+// Events returns a copy of the stored entries by event ID
+func (m *MemoryProvider) Events() map[string]model.CalendarEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := make(map[string]model.CalendarEntry, len(m.state.Events))
+	for id, entry := range m.state.Events {
+		events[id] = entry
+	}
+	return events
+}
+
+// save writes the events to the file, replacing it atomically. Callers hold m.mu.
+func (m *MemoryProvider) save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save calendar file: %w", err)
+	}
+	return os.Rename(tmp, m.path)
+}
@@ -0,0 +1,51 @@
+package calendar
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// ErrEventNotFound is returned when the event no longer exists in the user's calendar,
+// typically because the user deleted it there
+var ErrEventNotFound = errors.New("calendar event no longer exists")
+
+// ErrUnauthorized is returned when the provider rejects the user's credentials; retrying will not help
+var ErrUnauthorized = errors.New("calendar access was revoked or the credentials are invalid")
+
+// CalendarProvider defines the interface for calendars that bookings are synced to
+type CalendarProvider interface {
+	// RefreshCredentials returns usable credentials, renewing an expired access token.
+	// Credentials that are still valid are returned unchanged.
+	RefreshCredentials(creds Credentials) (Credentials, error)
+
+	// CreateEvent adds an event and returns the provider's ID for it
+	CreateEvent(creds Credentials, event model.CalendarEntry) (string, error)
+
+	// UpdateEvent replaces an existing event; ErrEventNotFound if it is gone
+	UpdateEvent(creds Credentials, eventID string, event model.CalendarEntry) error
+
+	// DeleteEvent removes an event; ErrEventNotFound if it is already gone
+	DeleteEvent(creds Credentials, eventID string) error
+}
+
+// Credentials authorize calendar access for one user. OAuth providers use the tokens;
+// CalDAV uses the calendar URL with a username and (app) password.
+type Credentials struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time
+
+	CalendarURL string
+	Username    string
+	Password    string
+}
+
+// loadLocation returns the IANA zone, falling back to UTC for unknown names
+func loadLocation(timeZone string) *time.Location {
+	if loc, err := time.LoadLocation(timeZone); err == nil && timeZone != "" {
+		return loc
+	}
+	return time.UTC
+}
@@ -0,0 +1,70 @@
+package calendar
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks a secret sealed by SecretBox; stored values without it predate encryption
+const sealedPrefix = "v1:"
+
+// SecretBox encrypts calendar secrets, such as CalDAV passwords, before they are stored, with AES-GCM
+// under a key derived from a server secret
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox creates a SecretBox from a server secret, which should be long and random
+func NewSecretBox(secret string) (*SecretBox, error) {
+	if secret == "" {
+		return nil, errors.New("an encryption secret is required")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts a secret for storage
+func (b *SecretBox) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a stored secret. The second result is false for a value stored before secrets were
+// encrypted, which is returned as it is and should be sealed again.
+func (b *SecretBox) Open(stored string) (string, bool, error) {
+	if !strings.HasPrefix(stored, sealedPrefix) {
+		return stored, false, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedPrefix))
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", true, errors.New("stored secret is corrupt")
+	}
+
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", true, errors.New("stored secret cannot be decrypted with the current key")
+	}
+	return string(plaintext), true, nil
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+	"github.com/Radi03825/PlaySpot/internal/service/calendar"
+)
+
+// ErrCalendarFeedNotFound is returned for a feed token that does not exist or was rotated
+var ErrCalendarFeedNotFound = errors.New("calendar feed not found")
+
+// calendarFeedHistory is how far back feeds include past bookings and events
+const calendarFeedHistory = 90 * 24 * time.Hour
+
+// calendarFeedHorizon is how far ahead facility feeds include bookings
+const calendarFeedHorizon = 365 * 24 * time.Hour
+
+// CalendarFeedService publishes reservations and events as iCalendar feeds behind secret per-user URLs
+type CalendarFeedService struct {
+	userRepo        *repository.UserRepository
+	reservationRepo *repository.ReservationRepository
+	eventRepo       *repository.EventRepository
+	facilityService *FacilityService
+}
+
+func NewCalendarFeedService(
+	userRepo *repository.UserRepository,
+	reservationRepo *repository.ReservationRepository,
+	eventRepo *repository.EventRepository,
+	facilityService *FacilityService,
+) *CalendarFeedService {
+	return &CalendarFeedService{
+		userRepo:        userRepo,
+		reservationRepo: reservationRepo,
+		eventRepo:       eventRepo,
+		facilityService: facilityService,
+	}
+}
+
+// GetFeedToken returns the user's calendar feed token, issuing one on first use
+func (s *CalendarFeedService) GetFeedToken(userID int64) (string, error) {
+	token, err := s.userRepo.GetCalendarFeedToken(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get calendar feed token: %w", err)
+	}
+	if token != "" {
+		return token, nil
+	}
+	return s.RotateFeedToken(userID)
+}
+
+// RotateFeedToken issues a new calendar feed token; URLs with the previous token stop working
+func (s *CalendarFeedService) RotateFeedToken(userID int64) (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", errors.New("failed to generate calendar feed token")
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	if err := s.userRepo.SetCalendarFeedToken(userID, token); err != nil {
+		return "", fmt.Errorf("failed to save calendar feed token: %w", err)
+	}
+	return token, nil
+}
+
+// UserFeed renders the feed of the token owner's reservations and joined events.
+// Cancelled reservations stay in the feed so subscribed calendars drop them.
+func (s *CalendarFeedService) UserFeed(token string) ([]byte, error) {
+	userID, err := s.feedOwner(token)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-calendarFeedHistory)
+
+	reservations, err := s.reservationRepo.GetUserCalendarReservations(userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reservations: %w", err)
+	}
+
+	events, err := s.eventRepo.GetUserJoinedEvents(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+
+	entries := make([]model.CalendarEntry, 0, len(reservations)+len(events))
+	for _, reservation := range reservations {
+		entries = append(entries, reservationCalendarEntry(reservation))
+	}
+	for _, event := range events {
+		if event.EndTime.Before(since) {
+			continue
+		}
+		entries = append(entries, eventCalendarEntry(event))
+	}
+
+	return calendar.Encode("PlaySpot", entries), nil
+}
+
+// FacilityFeed renders the bookings of a facility managed by the token owner
+func (s *CalendarFeedService) FacilityFeed(token string, facilityID int64) ([]byte, error) {
+	userID, err := s.feedOwner(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkFacilityManager(s.facilityService, facilityID, userID); err != nil {
+		return nil, err
+	}
+
+	facility, err := s.facilityService.GetFacilityByID(facilityID)
+	if err != nil {
+		return nil, fmt.Errorf("facility not found: %w", err)
+	}
+
+	now := time.Now()
+	bookings, err := s.reservationRepo.GetFacilityBookingsWithUserDetails(facilityID, now.Add(-calendarFeedHistory), now.Add(calendarFeedHorizon))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get facility bookings: %w", err)
+	}
+
+	entries := make([]model.CalendarEntry, 0, len(bookings))
+	for _, booking := range bookings {
+		// Unpaid holds come and go; only bookings that were confirmed reach the calendar
+		if booking.Status == "pending" || booking.Status == "expired" {
+			continue
+		}
+		entries = append(entries, facilityBookingEntry(booking, facility))
+	}
+
+	return calendar.Encode(fmt.Sprintf("PlaySpot - %s", facility.Name), entries), nil
+}
+
+func (s *CalendarFeedService) feedOwner(token string) (int64, error) {
+	if token == "" {
+		return 0, ErrCalendarFeedNotFound
+	}
+
+	userID, err := s.userRepo.GetUserIDByCalendarFeedToken(token)
+	if err == sql.ErrNoRows {
+		return 0, ErrCalendarFeedNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up calendar feed: %w", err)
+	}
+	return userID, nil
+}
+
+// facilityBookingEntry describes a booking as the facility's manager sees it
+func facilityBookingEntry(booking dto.ReservationWithFacilityDTO, facility *model.FacilityDetails) model.CalendarEntry {
+	details := []string{fmt.Sprintf("Customer: %s", booking.UserName)}
+	if booking.UserEmail != "" {
+		details = append(details, fmt.Sprintf("Email: %s", booking.UserEmail))
+	}
+	if booking.GuestPhone != nil {
+		details = append(details, fmt.Sprintf("Phone: %s", *booking.GuestPhone))
+	}
+	if booking.Units > 1 {
+		details = append(details, fmt.Sprintf("Units: %d", booking.Units))
+	}
+	details = append(details, fmt.Sprintf("Status: %s", booking.Status), fmt.Sprintf("Price: €%.2f", booking.TotalPrice))
+
+	entry := model.CalendarEntry{
+		UID:         reservationUID(booking.ID),
+		Summary:     fmt.Sprintf("%s - %s", facility.Name, booking.UserName),
+		Description: strings.Join(details, "\n"),
+		Location:    fmt.Sprintf("%s, %s", facility.Address, facility.City),
+		StartTime:   booking.StartTime,
+		EndTime:     booking.EndTime,
+		Sequence:    booking.RescheduleCount,
+	}
+	if booking.Status == "cancelled" {
+		entry.Cancelled = true
+		entry.Sequence++
+	}
+	return entry
+}
+
+// eventCalendarEntry describes an event the user joined
+func eventCalendarEntry(event model.Event) model.CalendarEntry {
+	entry := model.CalendarEntry{
+		UID:       eventUID(event.ID),
+		Summary:   event.Title,
+		StartTime: event.StartTime,
+		EndTime:   event.EndTime,
+		UpdatedAt: event.UpdatedAt,
+	}
+	if event.Status == "CANCELED" {
+		entry.Cancelled = true
+		entry.Sequence = 1
+	}
+	if event.Description != nil {
+		entry.Description = *event.Description
+	}
+	if event.Sport != nil && event.Sport.Name != "" {
+		entry.Description = strings.TrimSpace(fmt.Sprintf("Sport: %s\n%s", event.Sport.Name, entry.Description))
+	}
+	if event.Address != nil {
+		entry.Location = *event.Address
+	}
+	return entry
+}
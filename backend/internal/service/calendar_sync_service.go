@@ -0,0 +1,427 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+	"github.com/Radi03825/PlaySpot/internal/service/calendar"
+)
+
+const (
+	// calendarSyncBatchSize is how many queued calendar changes one run of the sync job applies
+	calendarSyncBatchSize = 20
+	// calendarSyncLease keeps a claimed job from being claimed again while it is being applied
+	calendarSyncLease = 5 * time.Minute
+	// calendarSyncMaxAttempts is how often a change is tried before the job is left failed
+	calendarSyncMaxAttempts = 8
+	// calendarSyncBaseBackoff is the wait after the first failure; it doubles with every further failure
+	calendarSyncBaseBackoff = time.Minute
+	// calendarSyncMaxBackoff caps the wait between attempts
+	calendarSyncMaxBackoff = 6 * time.Hour
+)
+
+// errCalendarNotConnected marks jobs of users who disconnected their calendar
+var errCalendarNotConnected = errors.New("no calendar is connected")
+
+// errCalDAVDisabled is returned for CalDAV calendars when no key to encrypt their passwords is configured
+var errCalDAVDisabled = errors.New("CalDAV calendars are not enabled on this server")
+
+// CalendarSyncService keeps reservations' calendar events in sync through a durable queue.
+// Booking code queues changes; the sync job applies them through the user's calendar provider
+// with retries and stores refreshed OAuth tokens. CalDAV passwords are stored encrypted with secrets.
+type CalendarSyncService struct {
+	repo            *repository.CalendarSyncRepository
+	reservationRepo *repository.ReservationRepository
+	userService     *UserService
+	facilityService *FacilityService
+	providers       map[string]calendar.CalendarProvider // by model.CalendarProvider* name
+	secrets         *calendar.SecretBox                  // nil disables CalDAV calendars
+}
+
+func NewCalendarSyncService(
+	repo *repository.CalendarSyncRepository,
+	reservationRepo *repository.ReservationRepository,
+	userService *UserService,
+	facilityService *FacilityService,
+	providers map[string]calendar.CalendarProvider,
+	secrets *calendar.SecretBox,
+) *CalendarSyncService {
+	return &CalendarSyncService{
+		repo:            repo,
+		reservationRepo: reservationRepo,
+		userService:     userService,
+		facilityService: facilityService,
+		providers:       providers,
+		secrets:         secrets,
+	}
+}
+
+// EnqueueCreate queues adding a confirmed reservation to the user's calendar
+func (s *CalendarSyncService) EnqueueCreate(reservation *model.FacilityReservation) {
+	s.enqueue(reservation, model.CalendarSyncCreate)
+}
+
+// EnqueueUpdate queues moving a reservation's calendar event to the reservation's current time
+func (s *CalendarSyncService) EnqueueUpdate(reservation *model.FacilityReservation) {
+	s.enqueue(reservation, model.CalendarSyncUpdate)
+}
+
+// EnqueueDelete queues removing a cancelled reservation from the user's calendar
+func (s *CalendarSyncService) EnqueueDelete(reservation *model.FacilityReservation) {
+	s.enqueue(reservation, model.CalendarSyncDelete)
+}
+
+// enqueue queues a change for users who connected a calendar, or whose reservation already has an event.
+// Failing to queue never fails the booking.
+func (s *CalendarSyncService) enqueue(reservation *model.FacilityReservation, action string) {
+	// Guests booked by staff have no calendar
+	if reservation.UserID == 0 {
+		return
+	}
+
+	if reservation.GoogleCalendarEventID == nil {
+		provider, _, err := s.userCalendar(reservation.UserID)
+		if err != nil {
+			log.Printf("[CALENDAR] Failed to get calendar connection of user %d: %v", reservation.UserID, err)
+			return
+		}
+		if provider == "" {
+			return
+		}
+	}
+
+	if err := s.repo.EnqueueJob(reservation.ID, reservation.UserID, action); err != nil {
+		log.Printf("[CALENDAR] Failed to queue %s of calendar event for reservation %d: %v", action, reservation.ID, err)
+	}
+}
+
+// ProcessQueue applies due calendar changes and returns how many were completed
+func (s *CalendarSyncService) ProcessQueue() (int64, error) {
+	jobs, err := s.repo.ClaimDueJobs(calendarSyncBatchSize, calendarSyncLease)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim calendar sync jobs: %w", err)
+	}
+
+	var completed int64
+	for i := range jobs {
+		job := &jobs[i]
+		status, message, err := s.applyJob(job)
+		if err != nil {
+			s.retryOrFail(job, err)
+			continue
+		}
+
+		if err := s.repo.FinishJob(job.ID, status, message); err != nil {
+			log.Printf("[CALENDAR] Failed to finish calendar sync job %d: %v", job.ID, err)
+			continue
+		}
+		completed++
+	}
+
+	return completed, nil
+}
+
+// GetSyncStatus returns the Google Calendar sync state of one of the user's reservations
+func (s *CalendarSyncService) GetSyncStatus(reservationID, userID int64) (*model.CalendarSyncStatus, error) {
+	reservation, err := s.reservationRepo.GetReservationByID(reservationID)
+	if err != nil || reservation.UserID != userID {
+		return nil, errors.New("reservation not found")
+	}
+
+	job, err := s.repo.GetLatestJob(reservationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar sync status: %w", err)
+	}
+
+	status := &model.CalendarSyncStatus{
+		ReservationID:         reservationID,
+		Status:                "not_synced",
+		Provider:              reservation.CalendarProvider,
+		GoogleCalendarEventID: reservation.GoogleCalendarEventID,
+		LatestJob:             job,
+	}
+	if job != nil {
+		status.Status = job.Status
+	}
+
+	return status, nil
+}
+
+// applyJob performs a queued change and returns the status to finish the job with.
+// An error means the attempt failed and may be retried.
+func (s *CalendarSyncService) applyJob(job *model.CalendarSyncJob) (string, *string, error) {
+	reservation, err := s.reservationRepo.GetReservationByID(job.ReservationID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get reservation: %w", err)
+	}
+
+	eventID := ""
+	if reservation.GoogleCalendarEventID != nil {
+		eventID = *reservation.GoogleCalendarEventID
+	}
+
+	// An existing event is changed where it lives; new events go to the calendar connected now
+	providerName, creds, err := s.userCalendar(job.UserID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get calendar connection: %w", err)
+	}
+	if eventID != "" {
+		eventProvider := model.CalendarProviderGoogle
+		if reservation.CalendarProvider != nil {
+			eventProvider = *reservation.CalendarProvider
+		}
+		if eventProvider != providerName {
+			providerName, creds, err = s.providerCredentials(job.UserID, eventProvider)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to get calendar connection: %w", err)
+			}
+		}
+	}
+
+	provider := s.providers[providerName]
+	if providerName == "" || provider == nil {
+		return skipJob(errCalendarNotConnected.Error())
+	}
+
+	refreshed, err := provider.RefreshCredentials(*creds)
+	if err != nil {
+		return "", nil, err
+	}
+	if refreshed.AccessToken != creds.AccessToken {
+		s.saveRefreshedTokens(job.UserID, refreshed)
+	}
+
+	switch job.Action {
+	case model.CalendarSyncDelete:
+		if eventID == "" {
+			return skipJob("the reservation has no calendar event")
+		}
+		err := provider.DeleteEvent(refreshed, eventID)
+		if err != nil && !errors.Is(err, calendar.ErrEventNotFound) {
+			return "", nil, err
+		}
+		if err := s.reservationRepo.ClearReservationCalendarEventID(reservation.ID); err != nil {
+			return "", nil, fmt.Errorf("failed to unlink calendar event: %w", err)
+		}
+		return model.CalendarSyncDone, nil, nil
+
+	case model.CalendarSyncCreate, model.CalendarSyncUpdate:
+		if reservation.Status == "cancelled" || reservation.Status == "expired" {
+			return skipJob("the reservation is no longer active")
+		}
+
+		facility, err := s.facilityService.GetFacilityDetailsByID(reservation.FacilityID)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get facility: %w", err)
+		}
+		entry := model.CalendarEntry{
+			UID:     reservationUID(reservation.ID),
+			Summary: fmt.Sprintf("PlaySpot Booking - %s", facility.Name),
+			Description: fmt.Sprintf(
+				"Facility: %s\nCategory: %s\nSport: %s\nPrice: €%.2f",
+				facility.Name,
+				facility.CategoryName,
+				facility.SportName,
+				reservation.TotalPrice,
+			),
+			Location:  fmt.Sprintf("%s, %s", facility.Address, facility.City),
+			StartTime: reservation.StartTime,
+			EndTime:   reservation.EndTime,
+			TimeZone:  facility.TimeZone,
+			Sequence:  reservation.RescheduleCount,
+		}
+
+		// A reservation already linked to an event is updated, so repeated creates never duplicate it
+		if eventID != "" {
+			err := provider.UpdateEvent(refreshed, eventID, entry)
+			if errors.Is(err, calendar.ErrEventNotFound) {
+				// The user removed the event from their calendar; respect that instead of recreating it
+				if err := s.reservationRepo.ClearReservationCalendarEventID(reservation.ID); err != nil {
+					return "", nil, fmt.Errorf("failed to unlink calendar event: %w", err)
+				}
+				return skipJob("the event was removed from the calendar")
+			}
+			if err != nil {
+				return "", nil, err
+			}
+			return model.CalendarSyncDone, nil, nil
+		}
+
+		if job.Action == model.CalendarSyncUpdate {
+			return skipJob("the reservation has no calendar event")
+		}
+
+		createdID, err := provider.CreateEvent(refreshed, entry)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := s.reservationRepo.UpdateReservationCalendarEventID(reservation.ID, providerName, createdID); err != nil {
+			// Without the link a retry would create a second event, so log instead of retrying
+			log.Printf("[CALENDAR] Failed to link calendar event %s to reservation %d: %v", createdID, reservation.ID, err)
+		}
+		return model.CalendarSyncDone, nil, nil
+
+	default:
+		return "", nil, fmt.Errorf("unknown calendar sync action: %s", job.Action)
+	}
+}
+
+// userCalendar returns the calendar the user syncs new bookings to: a connected CalDAV calendar,
+// else Google Calendar. The provider name is empty when the user connected neither.
+func (s *CalendarSyncService) userCalendar(userID int64) (string, *calendar.Credentials, error) {
+	for _, name := range []string{model.CalendarProviderCalDAV, model.CalendarProviderGoogle} {
+		provider, creds, err := s.providerCredentials(userID, name)
+		if err != nil || provider != "" {
+			return provider, creds, err
+		}
+	}
+	return "", nil, nil
+}
+
+// providerCredentials returns the user's credentials for a provider; the provider name is empty when
+// the user is not connected to it
+func (s *CalendarSyncService) providerCredentials(userID int64, provider string) (string, *calendar.Credentials, error) {
+	switch provider {
+	case model.CalendarProviderCalDAV:
+		account, err := s.repo.GetCalDAVAccount(userID)
+		if err != nil || account == nil {
+			return "", nil, err
+		}
+		if s.secrets == nil {
+			return "", nil, errCalDAVDisabled
+		}
+		password, sealed, err := s.secrets.Open(account.Password)
+		if err != nil {
+			return "", nil, err
+		}
+		if !sealed {
+			// Saved before passwords were encrypted
+			if account.Password, err = s.secrets.Seal(password); err == nil {
+				err = s.repo.SaveCalDAVAccount(account)
+			}
+			if err != nil {
+				log.Printf("[CALENDAR] Failed to encrypt CalDAV password of user %d: %v", userID, err)
+			}
+		}
+		return provider, &calendar.Credentials{
+			CalendarURL: account.CalendarURL,
+			Username:    account.Username,
+			Password:    password,
+		}, nil
+
+	case model.CalendarProviderGoogle:
+		accessToken, refreshToken, tokenExpiry, err := s.userService.GetGoogleTokens(userID)
+		if err != nil || refreshToken == "" {
+			return "", nil, err
+		}
+		return provider, &calendar.Credentials{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			Expiry:       tokenExpiry,
+		}, nil
+	}
+
+	return "", nil, nil
+}
+
+// retryOrFail schedules another attempt with exponential backoff, or fails the job once attempts
+// run out or the provider rejected the user's credentials
+func (s *CalendarSyncService) retryOrFail(job *model.CalendarSyncJob, cause error) {
+	message := cause.Error()
+
+	if errors.Is(cause, calendar.ErrUnauthorized) || job.Attempts >= calendarSyncMaxAttempts {
+		log.Printf("[CALENDAR] Giving up on %s of calendar event for reservation %d: %v", job.Action, job.ReservationID, cause)
+		if err := s.repo.FinishJob(job.ID, model.CalendarSyncFailed, &message); err != nil {
+			log.Printf("[CALENDAR] Failed to mark calendar sync job %d failed: %v", job.ID, err)
+		}
+		return
+	}
+
+	backoff := calendarSyncBackoff(job.Attempts)
+	log.Printf("[CALENDAR] %s of calendar event for reservation %d failed (attempt %d), retrying in %s: %v",
+		job.Action, job.ReservationID, job.Attempts, backoff, cause)
+	if err := s.repo.RetryJob(job.ID, time.Now().Add(backoff), message); err != nil {
+		log.Printf("[CALENDAR] Failed to reschedule calendar sync job %d: %v", job.ID, err)
+	}
+}
+
+// calendarSyncBackoff returns how long to wait after a job's attempts-th failed attempt
+func calendarSyncBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := calendarSyncBaseBackoff << (attempts - 1)
+	if backoff > calendarSyncMaxBackoff || backoff <= 0 {
+		return calendarSyncMaxBackoff
+	}
+	return backoff
+}
+
+// saveRefreshedTokens stores the user's Google tokens after the provider issued a new access token
+func (s *CalendarSyncService) saveRefreshedTokens(userID int64, creds calendar.Credentials) {
+	if err := s.userService.UpdateGoogleCalendarTokens(userID, creds.AccessToken, creds.RefreshToken, creds.Expiry); err != nil {
+		log.Printf("[CALENDAR] Failed to save refreshed Google tokens for user %d: %v", userID, err)
+	}
+}
+
+func skipJob(reason string) (string, *string, error) {
+	return model.CalendarSyncSkipped, &reason, nil
+}
+
+// ConnectCalDAV checks and saves the user's CalDAV calendar; new bookings are synced to it instead of Google Calendar.
+// The calendar must be served over https from a public address; its password is stored encrypted.
+func (s *CalendarSyncService) ConnectCalDAV(userID int64, req dto.ConnectCalDAVDTO) (*model.CalDAVAccount, error) {
+	if s.secrets == nil {
+		return nil, errCalDAVDisabled
+	}
+
+	req.CalendarURL = strings.TrimSpace(req.CalendarURL)
+	if req.CalendarURL == "" || req.Username == "" || req.Password == "" {
+		return nil, errors.New("calendar_url, username and password are required")
+	}
+	if err := calendar.ValidateCalendarURL(req.CalendarURL); err != nil {
+		return nil, err
+	}
+
+	creds := calendar.Credentials{CalendarURL: req.CalendarURL, Username: req.Username, Password: req.Password}
+	if checker, ok := s.providers[model.CalendarProviderCalDAV].(interface {
+		CheckCalendar(calendar.Credentials) error
+	}); ok {
+		if err := checker.CheckCalendar(creds); err != nil {
+			return nil, fmt.Errorf("could not access the calendar: %w", err)
+		}
+	}
+
+	password, err := s.secrets.Seal(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt CalDAV password: %w", err)
+	}
+
+	account := &model.CalDAVAccount{
+		UserID:      userID,
+		CalendarURL: req.CalendarURL,
+		Username:    req.Username,
+		Password:    password,
+	}
+	if err := s.repo.SaveCalDAVAccount(account); err != nil {
+		return nil, fmt.Errorf("failed to save CalDAV calendar: %w", err)
+	}
+
+	return account, nil
+}
+
+// GetCalDAVAccount returns the user's connected CalDAV calendar, or nil
+func (s *CalendarSyncService) GetCalDAVAccount(userID int64) (*model.CalDAVAccount, error) {
+	return s.repo.GetCalDAVAccount(userID)
+}
+
+// DisconnectCalDAV stops syncing new bookings to the user's CalDAV calendar
+func (s *CalendarSyncService) DisconnectCalDAV(userID int64) error {
+	return s.repo.DeleteCalDAVAccount(userID)
+}
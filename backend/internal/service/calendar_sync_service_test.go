@@ -0,0 +1,275 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+	"github.com/Radi03825/PlaySpot/internal/service/calendar"
+	"github.com/Radi03825/PlaySpot/internal/testutil"
+)
+
+// flakyProvider fails the next failures event creations with err, then behaves like the memory calendar
+type flakyProvider struct {
+	*calendar.MemoryProvider
+	failures int
+	err      error
+}
+
+func (p *flakyProvider) CreateEvent(creds calendar.Credentials, entry model.CalendarEntry) (string, error) {
+	if p.failures > 0 {
+		p.failures--
+		return "", p.err
+	}
+	return p.MemoryProvider.CreateEvent(creds, entry)
+}
+
+// calendarSyncFixture is a user with Google Calendar connected and a confirmed reservation of theirs
+type calendarSyncFixture struct {
+	db            *sql.DB
+	service       *CalendarSyncService
+	userService   *UserService
+	reservations  *repository.ReservationRepository
+	syncRepo      *repository.CalendarSyncRepository
+	userID        int64
+	reservationID int64
+}
+
+// newCalendarSyncFixture syncs through provider as the user's Google Calendar; tokenExpiry is when
+// the user's stored access token expires
+func newCalendarSyncFixture(t *testing.T, provider calendar.CalendarProvider, tokenExpiry time.Time) *calendarSyncFixture {
+	t.Helper()
+
+	db := testutil.OpenDB(t)
+	userID, facilityID := testutil.CreateFacility(t, db)
+
+	userService := NewUserService(repository.NewUserRepository(db), nil, nil)
+	facilityService := NewFacilityService(repository.NewFacilityRepository(db), userService, nil)
+	f := &calendarSyncFixture{
+		db:            db,
+		userService:   userService,
+		reservations:  repository.NewReservationRepository(db),
+		syncRepo:      repository.NewCalendarSyncRepository(db),
+		userID:        userID,
+		reservationID: testutil.CreateReservation(t, db, userID, facilityID, "confirmed", 40),
+	}
+	f.service = NewCalendarSyncService(f.syncRepo, f.reservations, userService, facilityService,
+		map[string]calendar.CalendarProvider{model.CalendarProviderGoogle: provider}, nil)
+
+	// Stored tokens are unique across users
+	accessToken, refreshToken := fmt.Sprintf("access-%d", userID), fmt.Sprintf("refresh-%d", userID)
+	if err := userService.UpdateGoogleCalendarTokens(userID, accessToken, refreshToken, tokenExpiry); err != nil {
+		t.Fatalf("failed to connect Google Calendar: %v", err)
+	}
+
+	return f
+}
+
+func (f *calendarSyncFixture) reservation(t *testing.T) *model.FacilityReservation {
+	t.Helper()
+	reservation, err := f.reservations.GetReservationByID(f.reservationID)
+	if err != nil {
+		t.Fatalf("failed to get reservation: %v", err)
+	}
+	return reservation
+}
+
+// process runs the sync job and returns the reservation's latest job afterwards
+func (f *calendarSyncFixture) process(t *testing.T) *model.CalendarSyncJob {
+	t.Helper()
+	if _, err := f.service.ProcessQueue(); err != nil {
+		t.Fatalf("failed to process calendar sync queue: %v", err)
+	}
+	job, err := f.syncRepo.GetLatestJob(f.reservationID)
+	if err != nil || job == nil {
+		t.Fatalf("failed to get calendar sync job: %v", err)
+	}
+	return job
+}
+
+// makeDue lets the reservation's pending job run again without waiting for its backoff
+func (f *calendarSyncFixture) makeDue(t *testing.T) {
+	t.Helper()
+	_, err := f.db.Exec(`UPDATE calendar_sync_jobs SET next_attempt_at = NOW() WHERE reservation_id = $1 AND status = 'pending'`, f.reservationID)
+	if err != nil {
+		t.Fatalf("failed to make calendar sync job due: %v", err)
+	}
+}
+
+func TestCalendarSyncBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Minute},
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 5, want: 16 * time.Minute},
+		{attempts: 9, want: 256 * time.Minute},
+		{attempts: 10, want: calendarSyncMaxBackoff},
+		{attempts: 100, want: calendarSyncMaxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := calendarSyncBackoff(tt.attempts); got != tt.want {
+			t.Errorf("calendarSyncBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarSyncCreateUpdateAndDeleteOnCancel(t *testing.T) {
+	provider, _ := calendar.NewMemoryProvider("")
+	f := newCalendarSyncFixture(t, provider, time.Now().Add(time.Hour))
+
+	// Create
+	f.service.EnqueueCreate(f.reservation(t))
+	if job := f.process(t); job.Status != model.CalendarSyncDone {
+		t.Fatalf("create job status = %s, want %s", job.Status, model.CalendarSyncDone)
+	}
+
+	reservation := f.reservation(t)
+	if reservation.GoogleCalendarEventID == nil || reservation.CalendarProvider == nil || *reservation.CalendarProvider != model.CalendarProviderGoogle {
+		t.Fatalf("reservation is not linked to a Google Calendar event")
+	}
+	eventID := *reservation.GoogleCalendarEventID
+	if entry, ok := provider.Events()[eventID]; !ok || !entry.StartTime.Equal(reservation.StartTime) {
+		t.Fatalf("calendar event %s is missing or starts at the wrong time", eventID)
+	}
+
+	// Creating again updates the linked event instead of adding a second one
+	f.service.EnqueueCreate(reservation)
+	f.process(t)
+	if events := provider.Events(); len(events) != 1 {
+		t.Fatalf("calendar has %d events after a repeated create, want 1", len(events))
+	}
+
+	// Update after the reservation moved
+	_, err := f.db.Exec(`
+		UPDATE facility_reservations
+		SET start_time = start_time + INTERVAL '1 day', end_time = end_time + INTERVAL '1 day', reschedule_count = reschedule_count + 1
+		WHERE id = $1
+	`, f.reservationID)
+	if err != nil {
+		t.Fatalf("failed to move reservation: %v", err)
+	}
+	reservation = f.reservation(t)
+	f.service.EnqueueUpdate(reservation)
+	if job := f.process(t); job.Status != model.CalendarSyncDone {
+		t.Fatalf("update job status = %s, want %s", job.Status, model.CalendarSyncDone)
+	}
+	entry := provider.Events()[eventID]
+	if !entry.StartTime.Equal(reservation.StartTime) || !entry.EndTime.Equal(reservation.EndTime) || entry.Sequence != 1 {
+		t.Errorf("calendar event was not moved with the reservation")
+	}
+
+	// Delete once cancelled
+	if _, err := f.db.Exec(`UPDATE facility_reservations SET status = 'cancelled' WHERE id = $1`, f.reservationID); err != nil {
+		t.Fatalf("failed to cancel reservation: %v", err)
+	}
+	f.service.EnqueueDelete(f.reservation(t))
+	if job := f.process(t); job.Status != model.CalendarSyncDone {
+		t.Fatalf("delete job status = %s, want %s", job.Status, model.CalendarSyncDone)
+	}
+	if _, ok := provider.Events()[eventID]; ok {
+		t.Errorf("calendar event of the cancelled reservation still exists")
+	}
+	if f.reservation(t).GoogleCalendarEventID != nil {
+		t.Errorf("cancelled reservation is still linked to its calendar event")
+	}
+}
+
+func TestCalendarSyncRetriesWithBackoff(t *testing.T) {
+	memory, _ := calendar.NewMemoryProvider("")
+	provider := &flakyProvider{MemoryProvider: memory, failures: 2, err: errors.New("calendar is unavailable")}
+	f := newCalendarSyncFixture(t, provider, time.Now().Add(time.Hour))
+
+	f.service.EnqueueCreate(f.reservation(t))
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		before := time.Now()
+		job := f.process(t)
+
+		if job.Status != model.CalendarSyncPending || job.Attempts != attempt {
+			t.Fatalf("after failure %d: status = %s, attempts = %d, want pending after %d attempts", attempt, job.Status, job.Attempts, attempt)
+		}
+		if job.LastError == nil || !strings.Contains(*job.LastError, "calendar is unavailable") {
+			t.Errorf("after failure %d: last error = %v, want the provider's error", attempt, job.LastError)
+		}
+
+		// The database clock may differ slightly from the test's
+		backoff := calendarSyncBackoff(attempt)
+		if wait := job.NextAttemptAt.Sub(before); wait < backoff-5*time.Second || wait > backoff+5*time.Second {
+			t.Errorf("after failure %d: next attempt in %s, want about %s", attempt, wait, backoff)
+		}
+
+		// Not due yet, so running the job again changes nothing
+		if job := f.process(t); job.Attempts != attempt {
+			t.Errorf("job ran again before its backoff passed")
+		}
+		f.makeDue(t)
+	}
+
+	if job := f.process(t); job.Status != model.CalendarSyncDone || job.Attempts != 3 {
+		t.Fatalf("third attempt: status = %s, attempts = %d, want done after 3 attempts", job.Status, job.Attempts)
+	}
+	if events := memory.Events(); len(events) != 1 {
+		t.Errorf("calendar has %d events, want 1", len(events))
+	}
+}
+
+func TestCalendarSyncGivesUp(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		priorAttempt int
+	}{
+		{name: "revoked access", err: calendar.ErrUnauthorized},
+		{name: "attempts run out", err: errors.New("calendar is unavailable"), priorAttempt: calendarSyncMaxAttempts - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory, _ := calendar.NewMemoryProvider("")
+			provider := &flakyProvider{MemoryProvider: memory, failures: 1, err: tt.err}
+			f := newCalendarSyncFixture(t, provider, time.Now().Add(time.Hour))
+
+			f.service.EnqueueCreate(f.reservation(t))
+			if _, err := f.db.Exec(`UPDATE calendar_sync_jobs SET attempts = $2 WHERE reservation_id = $1`, f.reservationID, tt.priorAttempt); err != nil {
+				t.Fatalf("failed to set attempts: %v", err)
+			}
+
+			if job := f.process(t); job.Status != model.CalendarSyncFailed {
+				t.Errorf("job status = %s, want %s", job.Status, model.CalendarSyncFailed)
+			}
+			if len(memory.Events()) != 0 {
+				t.Errorf("a failed job created a calendar event")
+			}
+		})
+	}
+}
+
+func TestCalendarSyncPersistsRefreshedTokens(t *testing.T) {
+	provider, _ := calendar.NewMemoryProvider("")
+	f := newCalendarSyncFixture(t, provider, time.Now().Add(-time.Minute))
+
+	f.service.EnqueueCreate(f.reservation(t))
+	if job := f.process(t); job.Status != model.CalendarSyncDone {
+		t.Fatalf("job status = %s, want %s", job.Status, model.CalendarSyncDone)
+	}
+
+	accessToken, refreshToken, _, err := f.userService.GetGoogleTokens(f.userID)
+	if err != nil {
+		t.Fatalf("failed to get Google tokens: %v", err)
+	}
+	if !strings.HasPrefix(accessToken, "memory-token-") {
+		t.Errorf("access token = %q, want the refreshed token", accessToken)
+	}
+	if want := fmt.Sprintf("refresh-%d", f.userID); refreshToken != want {
+		t.Errorf("refresh token = %q, want %q", refreshToken, want)
+	}
+}
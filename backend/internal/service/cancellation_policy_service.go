@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+)
+
+// maxCancellationRules limits how many refund tiers a single policy can have
+const maxCancellationRules = 10
+
+type CancellationPolicyService struct {
+	repo                *repository.CancellationPolicyRepository
+	facilityService     *FacilityService
+	sportComplexService *SportComplexService
+}
+
+func NewCancellationPolicyService(
+	repo *repository.CancellationPolicyRepository,
+	facilityService *FacilityService,
+	sportComplexService *SportComplexService,
+) *CancellationPolicyService {
+	return &CancellationPolicyService{
+		repo:                repo,
+		facilityService:     facilityService,
+		sportComplexService: sportComplexService,
+	}
+}
+
+// GetFacilityPolicy returns the cancellation policy that applies to a facility, including one inherited from its complex
+func (s *CancellationPolicyService) GetFacilityPolicy(facilityID int64) (*model.CancellationPolicy, error) {
+	return s.repo.GetFacilityPolicy(facilityID)
+}
+
+// GetComplexPolicy returns the cancellation policy defined for a whole sport complex
+func (s *CancellationPolicyService) GetComplexPolicy(complexID int64) (*model.CancellationPolicy, error) {
+	return s.repo.GetComplexPolicy(complexID)
+}
+
+// UpdateFacilityPolicy replaces the cancellation policy of a single facility
+func (s *CancellationPolicyService) UpdateFacilityPolicy(facilityID, userID int64, req dto.CancellationPolicyDTO) (*model.CancellationPolicy, error) {
+	if err := checkFacilityManager(s.facilityService, facilityID, userID); err != nil {
+		return nil, err
+	}
+
+	rules, err := buildCancellationRules(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ReplaceFacilityRules(facilityID, rules); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetFacilityPolicy(facilityID)
+}
+
+// UpdateComplexPolicy replaces the cancellation policy shared by the facilities of a sport complex
+func (s *CancellationPolicyService) UpdateComplexPolicy(complexID, userID int64, req dto.CancellationPolicyDTO) (*model.CancellationPolicy, error) {
+	if err := checkComplexManager(s.sportComplexService, complexID, userID); err != nil {
+		return nil, err
+	}
+
+	rules, err := buildCancellationRules(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ReplaceComplexRules(complexID, rules); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetComplexPolicy(complexID)
+}
+
+// buildCancellationRules validates the requested tiers and orders them by notice, longest first
+func buildCancellationRules(req dto.CancellationPolicyDTO) ([]model.CancellationRule, error) {
+	if len(req.Rules) > maxCancellationRules {
+		return nil, fmt.Errorf("a cancellation policy can have at most %d rules", maxCancellationRules)
+	}
+
+	seen := make(map[int]bool, len(req.Rules))
+	rules := make([]model.CancellationRule, 0, len(req.Rules))
+	for _, r := range req.Rules {
+		if r.HoursBefore < 0 {
+			return nil, errors.New("hours_before cannot be negative")
+		}
+		if r.RefundPercent < 0 || r.RefundPercent > 100 {
+			return nil, errors.New("refund_percent must be between 0 and 100")
+		}
+		if seen[r.HoursBefore] {
+			return nil, fmt.Errorf("duplicate rule for %d hours before", r.HoursBefore)
+		}
+		seen[r.HoursBefore] = true
+
+		rules = append(rules, model.CancellationRule{
+			HoursBefore:   r.HoursBefore,
+			RefundPercent: roundCents(r.RefundPercent),
+		})
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].HoursBefore > rules[j].HoursBefore
+	})
+
+	// A later cancellation must never be refunded more than an earlier one
+	for i := 1; i < len(rules); i++ {
+		if rules[i].RefundPercent > rules[i-1].RefundPercent {
+			return nil, errors.New("refund_percent cannot increase as the booking start gets closer")
+		}
+	}
+
+	return rules, nil
+}
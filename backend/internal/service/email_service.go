@@ -3,13 +3,18 @@ package service
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"html/template"
 	"log"
+	"mime/multipart"
 	"net/smtp"
+	"net/textproto"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
 )
 
 type EmailService struct {
@@ -103,13 +108,39 @@ func (s *EmailService) SendPasswordResetEmailAsync(toEmail, userName, resetToken
 	}()
 }
 
+// emailAttachment is a file sent along with an HTML email
+type emailAttachment struct {
+	filename    string
+	contentType string
+	data        []byte
+}
+
+// calendarAttachment wraps iCalendar data as an .ics attachment
+func calendarAttachment(invite []byte) []emailAttachment {
+	if len(invite) == 0 {
+		return nil
+	}
+	return []emailAttachment{{
+		filename:    "booking.ics",
+		contentType: "text/calendar; charset=UTF-8; method=PUBLISH",
+		data:        invite,
+	}}
+}
+
 func (s *EmailService) sendEmail(to, subject, body string) error {
+	return s.sendEmailWithAttachments(to, subject, body, nil)
+}
+
+func (s *EmailService) sendEmailWithAttachments(to, subject, body string, attachments []emailAttachment) error {
 	// If SMTP is not configured, just log the email (for development)
 	if s.smtpHost == "" || s.smtpPassword == "" {
 		fmt.Println("=== EMAIL (SMTP NOT CONFIGURED) ===")
 		fmt.Printf("To: %s\n", to)
 		fmt.Printf("Subject: %s\n", subject)
 		fmt.Printf("Body:\n%s\n", body)
+		for _, attachment := range attachments {
+			fmt.Printf("Attachment: %s (%d bytes)\n", attachment.filename, len(attachment.data))
+		}
 		fmt.Println("===================================")
 		return nil
 	}
@@ -124,15 +155,24 @@ func (s *EmailService) sendEmail(to, subject, body string) error {
 	}
 
 	// Construct email message
-	message := []byte(
-		"From: " + from + "\r\n" +
-			"To: " + to + "\r\n" +
-			"Subject: " + subject + "\r\n" +
-			"MIME-Version: 1.0\r\n" +
+	headers := "From: " + from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n"
+
+	var message []byte
+	if len(attachments) == 0 {
+		message = []byte(headers +
 			"Content-Type: text/html; charset=UTF-8\r\n" +
 			"\r\n" +
-			body + "\r\n",
-	)
+			body + "\r\n")
+	} else {
+		multipartBody, contentType, err := buildMultipartBody(body, attachments)
+		if err != nil {
+			return fmt.Errorf("failed to build email body: %w", err)
+		}
+		message = append([]byte(headers+"Content-Type: "+contentType+"\r\n\r\n"), multipartBody...)
+	}
 
 	// Try sending with STARTTLS first (port 587)
 	if s.smtpPort == "587" {
@@ -169,6 +209,52 @@ func (s *EmailService) sendEmail(to, subject, body string) error {
 	return fmt.Errorf("all email sending methods failed, last error: %w", err)
 }
 
+// buildMultipartBody builds a multipart/mixed body holding the HTML email and its attachments,
+// returning the body and its Content-Type header value
+func buildMultipartBody(htmlBody string, attachments []emailAttachment) ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	htmlPart, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/html; charset=UTF-8"},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := htmlPart.Write([]byte(htmlBody + "\r\n")); err != nil {
+		return nil, "", err
+	}
+
+	for _, attachment := range attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", attachment.filename)},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		// Base64 lines must not exceed 76 characters
+		encoded := base64.StdEncoding.EncodeToString(attachment.data)
+		for len(encoded) > 76 {
+			if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+				return nil, "", err
+			}
+			encoded = encoded[76:]
+		}
+		if _, err := part.Write([]byte(encoded + "\r\n")); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), "multipart/mixed; boundary=" + writer.Boundary(), nil
+}
+
 func (s *EmailService) sendWithSTARTTLS(to string, message []byte) error {
 	addr := fmt.Sprintf("%s:%s", s.smtpHost, s.smtpPort)
 
@@ -333,12 +419,24 @@ func (s *EmailService) renderTemplate(templateName string, data map[string]inter
 	return buf.String(), nil
 }
 
-// SendPaymentConfirmationEmail sends a confirmation email after successful payment
+// Booking times in emails are shown in the location of startTime, which callers set to the facility's
+// time zone. The zone abbreviation is included so times stay unambiguous on daylight saving changes.
+const (
+	emailDateFormat     = "Monday, January 2, 2006"
+	emailTimeFormat     = "3:04 PM"
+	emailTimeZoneFormat = "3:04 PM MST"
+	emailClaimByFormat  = "January 2 at 3:04 PM MST"
+)
+
+// SendPaymentConfirmationEmail sends a confirmation email after successful payment.
+// calendarInvite, when set, is attached as an .ics file the user can add to any calendar app.
 func (s *EmailService) SendPaymentConfirmationEmail(
 	toEmail, userName, facilityName, address, city, categoryName, sportName string,
 	startTime, endTime time.Time,
 	amount float64,
 	paymentMethod string,
+	priceItems []model.PriceItem,
+	calendarInvite []byte,
 ) error {
 	var subject string
 	if paymentMethod == "on_place" {
@@ -352,6 +450,18 @@ func (s *EmailService) SendPaymentConfirmationEmail(
 		paymentMethodText = "Card"
 	}
 
+	// Itemised price breakdown, one line per price band
+	var priceLines []map[string]string
+	for _, item := range priceItems {
+		priceLines = append(priceLines, map[string]string{
+			"Time": fmt.Sprintf("%s - %s",
+				item.StartTime.In(startTime.Location()).Format(emailTimeFormat),
+				item.EndTime.In(startTime.Location()).Format(emailTimeFormat)),
+			"PricePerHour": fmt.Sprintf("%.2f", item.PricePerHour),
+			"Amount":       fmt.Sprintf("%.2f", item.Amount),
+		})
+	}
+
 	// Load and render template
 	body, err := s.renderTemplate("payment_confirmation.html", map[string]interface{}{
 		"UserName":      userName,
@@ -360,12 +470,184 @@ func (s *EmailService) SendPaymentConfirmationEmail(
 		"City":          city,
 		"CategoryName":  categoryName,
 		"SportName":     sportName,
-		"StartTime":     startTime.Format("Monday, January 2, 2006 at 3:04 PM"),
-		"EndTime":       endTime.Format("3:04 PM"),
-		"Date":          startTime.Format("Monday, January 2, 2006"),
-		"StartTimeOnly": startTime.Format("3:04 PM"),
+		"StartTime":     startTime.Format(emailDateFormat + " at " + emailTimeZoneFormat),
+		"EndTime":       endTime.Format(emailTimeZoneFormat),
+		"Date":          startTime.Format(emailDateFormat),
+		"StartTimeOnly": startTime.Format(emailTimeFormat),
 		"Amount":        fmt.Sprintf("%.2f", amount),
 		"PaymentMethod": paymentMethodText,
+		"PriceItems":    priceLines,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	return s.sendEmailWithAttachments(toEmail, subject, body, calendarAttachment(calendarInvite))
+}
+
+// SendReservationReminderEmail reminds a user about an upcoming booking.
+// leadTime describes how soon the booking starts, e.g. "within 2 hours".
+func (s *EmailService) SendReservationReminderEmail(
+	toEmail, userName, facilityName, complexName, address, city, sportName string,
+	startTime, endTime time.Time,
+	leadTime string,
+) error {
+	subject := fmt.Sprintf("Reminder: your booking at %s starts %s", facilityName, leadTime)
+
+	// Load and render template
+	body, err := s.renderTemplate("reservation_reminder.html", map[string]interface{}{
+		"UserName":      userName,
+		"FacilityName":  facilityName,
+		"ComplexName":   complexName,
+		"Address":       address,
+		"City":          city,
+		"SportName":     sportName,
+		"Date":          startTime.Format(emailDateFormat),
+		"StartTimeOnly": startTime.Format(emailTimeFormat),
+		"EndTime":       endTime.Format(emailTimeZoneFormat),
+		"LeadTime":      leadTime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
+// SendReservationCancellationEmail notifies a user that their booking was cancelled and how much is refunded.
+// reason is set when the facility cancelled the booking; policyNote explains the refund. Both may be empty.
+// calendarInvite, when set, is attached so calendar apps that imported the booking mark it cancelled.
+func (s *EmailService) SendReservationCancellationEmail(
+	toEmail, userName, facilityName, address, city, sportName string,
+	startTime, endTime time.Time,
+	amountPaid, refundAmount float64,
+	reason, policyNote string,
+	calendarInvite []byte,
+) error {
+	subject := fmt.Sprintf("Booking Cancelled - %s", facilityName)
+
+	// Load and render template
+	body, err := s.renderTemplate("reservation_cancellation.html", map[string]interface{}{
+		"UserName":      userName,
+		"FacilityName":  facilityName,
+		"Address":       address,
+		"City":          city,
+		"SportName":     sportName,
+		"Date":          startTime.Format(emailDateFormat),
+		"StartTimeOnly": startTime.Format(emailTimeFormat),
+		"EndTime":       endTime.Format(emailTimeZoneFormat),
+		"Paid":          amountPaid > 0,
+		"AmountPaid":    fmt.Sprintf("%.2f", amountPaid),
+		"RefundAmount":  fmt.Sprintf("%.2f", refundAmount),
+		"Reason":        reason,
+		"PolicyNote":    policyNote,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	return s.sendEmailWithAttachments(toEmail, subject, body, calendarAttachment(calendarInvite))
+}
+
+// SendRefundEmail tells a customer that part or all of a booking's payment was refunded.
+// refundNote explains where the money was returned.
+func (s *EmailService) SendRefundEmail(
+	toEmail, userName, facilityName, sportName string,
+	startTime, endTime time.Time,
+	refundAmount, amountPaid, totalRefunded float64,
+	reason, refundNote string,
+) error {
+	subject := fmt.Sprintf("Refund Issued - %s", facilityName)
+
+	// Load and render template
+	body, err := s.renderTemplate("refund_issued.html", map[string]interface{}{
+		"UserName":      userName,
+		"FacilityName":  facilityName,
+		"SportName":     sportName,
+		"Date":          startTime.Format(emailDateFormat),
+		"StartTimeOnly": startTime.Format(emailTimeFormat),
+		"EndTime":       endTime.Format(emailTimeZoneFormat),
+		"RefundAmount":  fmt.Sprintf("%.2f", refundAmount),
+		"AmountPaid":    fmt.Sprintf("%.2f", amountPaid),
+		"TotalRefunded": fmt.Sprintf("%.2f", totalRefunded),
+		"NetAmount":     fmt.Sprintf("%.2f", amountPaid-totalRefunded),
+		"Reason":        reason,
+		"RefundNote":    refundNote,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
+// SendSharePaymentEmail asks an event participant to pay their share of the booking the event is held at,
+// or reminds them when isReminder is set
+func (s *EmailService) SendSharePaymentEmail(
+	toEmail, userName, organizerName, eventTitle string,
+	eventID int64,
+	facilityName, address, city, sportName string,
+	startTime, endTime time.Time,
+	shareAmount, totalAmount float64,
+	isReminder bool,
+) error {
+	baseURL := os.Getenv("FRONTEND_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:5173"
+	}
+
+	paymentLink := fmt.Sprintf("%s/events/%d?pay_share=1", baseURL, eventID)
+
+	subject := fmt.Sprintf("Pay your share for %s", eventTitle)
+	if isReminder {
+		subject = fmt.Sprintf("Reminder: pay your share for %s", eventTitle)
+	}
+
+	// Load and render template
+	body, err := s.renderTemplate("split_payment_request.html", map[string]interface{}{
+		"UserName":      userName,
+		"OrganizerName": organizerName,
+		"EventTitle":    eventTitle,
+		"FacilityName":  facilityName,
+		"Address":       address,
+		"City":          city,
+		"SportName":     sportName,
+		"Date":          startTime.Format(emailDateFormat),
+		"StartTimeOnly": startTime.Format(emailTimeFormat),
+		"EndTime":       endTime.Format(emailTimeZoneFormat),
+		"ShareAmount":   fmt.Sprintf("%.2f", shareAmount),
+		"TotalAmount":   fmt.Sprintf("%.2f", totalAmount),
+		"PaymentLink":   paymentLink,
+		"IsReminder":    isReminder,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
+// SendWaitlistOfferEmail tells a user on the waitlist that the slot they wanted is held for them until claimBy
+func (s *EmailService) SendWaitlistOfferEmail(
+	toEmail, userName, facilityName, address, city, sportName string,
+	startTime, endTime time.Time,
+	totalPrice float64,
+	claimBy time.Time,
+) error {
+	subject := fmt.Sprintf("A slot at %s is available for you", facilityName)
+
+	// Load and render template
+	body, err := s.renderTemplate("waitlist_offer.html", map[string]interface{}{
+		"UserName":      userName,
+		"FacilityName":  facilityName,
+		"Address":       address,
+		"City":          city,
+		"SportName":     sportName,
+		"Date":          startTime.Format(emailDateFormat),
+		"StartTimeOnly": startTime.Format(emailTimeFormat),
+		"EndTime":       endTime.Format(emailTimeZoneFormat),
+		"Amount":        fmt.Sprintf("%.2f", totalPrice),
+		"ClaimBy":       claimBy.In(startTime.Location()).Format(emailClaimByFormat),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to render email template: %w", err)
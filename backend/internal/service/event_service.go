@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"log"
 	"time"
 
 	"github.com/Radi03825/PlaySpot/internal/dto"
@@ -11,11 +12,13 @@ import (
 
 type EventService struct {
 	eventRepo *repository.EventRepository
+	shareRepo *repository.PaymentShareRepository
 }
 
-func NewEventService(eventRepo *repository.EventRepository) *EventService {
+func NewEventService(eventRepo *repository.EventRepository, shareRepo *repository.PaymentShareRepository) *EventService {
 	return &EventService{
 		eventRepo: eventRepo,
+		shareRepo: shareRepo,
 	}
 }
 
@@ -263,6 +266,11 @@ func (s *EventService) LeaveEvent(eventID int64, userID int64) error {
 		return err
 	}
 
+	// An unpaid share of the booking's cost is no longer owed; the organizer can split it again
+	if err := s.shareRepo.CancelUserShares(eventID, userID); err != nil {
+		log.Printf("[SPLIT] Failed to cancel shares of user %d in event %d: %v", userID, eventID, err)
+	}
+
 	// If event was FULL, update status back to UPCOMING
 	if event.Status == "FULL" {
 		s.eventRepo.UpdateEvent(eventID, map[string]interface{}{"status": "UPCOMING"})
@@ -285,3 +293,8 @@ func (s *EventService) GetUserEvents(userID int64) ([]model.Event, error) {
 func (s *EventService) GetUserJoinedEvents(userID int64) ([]model.Event, error) {
 	return s.eventRepo.GetUserJoinedEvents(userID)
 }
+
+// CompleteFinishedEvents marks events that have ended as completed
+func (s *EventService) CompleteFinishedEvents() (int64, error) {
+	return s.eventRepo.CompleteFinishedEvents()
+}
@@ -29,8 +29,12 @@ func (s *FacilityService) SetSportComplexService(sportComplexService *SportCompl
 	s.sportComplexService = sportComplexService
 }
 
-func (s *FacilityService) CreateFacility(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, managerID int64, imageURLs []string) (*model.Facility, error) {
-	// If facility belongs to a sport complex, get city and address from the complex
+func (s *FacilityService) CreateFacility(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, timeZone string, managerID int64, imageURLs []string) (*model.Facility, error) {
+	if err := validateTimeZone(timeZone); err != nil {
+		return nil, err
+	}
+
+	// If facility belongs to a sport complex, get city and address (and time zone unless given) from the complex
 	if sportComplexID != nil && *sportComplexID > 0 {
 		complex, err := s.sportComplexService.GetSportComplexByID(*sportComplexID)
 		if err != nil {
@@ -38,9 +42,15 @@ func (s *FacilityService) CreateFacility(name string, sportComplexID *int64, cat
 		}
 		city = complex.City
 		address = complex.Address
+		if timeZone == "" {
+			timeZone = complex.TimeZone
+		}
+	}
+	if timeZone == "" {
+		timeZone = model.DefaultTimeZone
 	}
 
-	facility, err := s.repo.CreateFacility(name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, managerID)
+	facility, err := s.repo.CreateFacility(name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, timeZone, managerID)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +134,7 @@ func (s *FacilityService) ToggleFacilityStatus(id int64, isActive bool) error {
 	return s.repo.ToggleFacilityStatus(id, isActive)
 }
 
-func (s *FacilityService) UpdateFacility(id int64, name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, userID int64) error {
+func (s *FacilityService) UpdateFacility(id int64, name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, timeZone string, userID int64, bookingPolicy *dto.BookingPolicyDTO) error {
 	// Check if user owns the facility
 	managerID, err := s.repo.GetFacilityManagerID(id)
 	if err != nil {
@@ -135,6 +145,14 @@ func (s *FacilityService) UpdateFacility(id int64, name string, sportComplexID *
 		return fmt.Errorf("unauthorized: you do not own this facility")
 	}
 
+	if err := validateBookingPolicy(bookingPolicy); err != nil {
+		return err
+	}
+
+	if err := validateTimeZone(timeZone); err != nil {
+		return err
+	}
+
 	// If facility belongs to a sport complex, get city and address from the complex
 	if sportComplexID != nil && *sportComplexID > 0 {
 		complex, err := s.sportComplexService.GetSportComplexByID(*sportComplexID)
@@ -145,26 +163,51 @@ func (s *FacilityService) UpdateFacility(id int64, name string, sportComplexID *
 		address = complex.Address
 	}
 
-	return s.repo.UpdateFacility(id, name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity)
+	err = s.repo.UpdateFacility(id, name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, timeZone)
+	if err != nil {
+		return err
+	}
+
+	if bookingPolicy != nil {
+		return s.saveBookingPolicy(id, bookingPolicy)
+	}
+
+	return nil
 }
 
 // CreateFacilityWithoutImages creates a facility without handling images (for internal use)
-func (s *FacilityService) CreateFacilityWithoutImages(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, managerID int64) (*model.Facility, error) {
-	return s.repo.CreateFacility(name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, managerID)
+func (s *FacilityService) CreateFacilityWithoutImages(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, timeZone string, managerID int64) (*model.Facility, error) {
+	return s.repo.CreateFacility(name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, timeZone, managerID)
 }
 
 func (s *FacilityService) GetFacilityDetailsByID(id int64) (*model.FacilityDetails, error) {
 	return s.repo.GetFacilityByID(id)
 }
 
+// validateTimeZone checks that a time zone, if given, is a known IANA zone name
+func validateTimeZone(timeZone string) error {
+	if timeZone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", timeZone)
+	}
+	return nil
+}
+
 // parseTime parses a time string in HH:MM format
 func parseTime(timeStr string) (time.Time, error) {
 	return time.Parse("15:04", timeStr)
 }
 
-// saveWorkingHours saves working hours for a facility
+// saveWorkingHours saves working hours for a facility, expanding weekday/weekend groups into individual days
 func (s *FacilityService) saveWorkingHours(facilityID int64, workingHours []dto.WorkingHoursDTO) error {
 	for _, wh := range workingHours {
+		days := model.ExpandDayType(model.DayType(wh.DayType))
+		if days == nil {
+			return fmt.Errorf("invalid day type: %s", wh.DayType)
+		}
+
 		// Validate time format
 		_, err := parseTime(wh.OpenTime)
 		if err != nil {
@@ -176,42 +219,124 @@ func (s *FacilityService) saveWorkingHours(facilityID int64, workingHours []dto.
 			return fmt.Errorf("invalid close time format: %v", err)
 		}
 
-		schedule := &model.FacilitySchedule{
-			FacilityID: facilityID,
-			DayType:    model.DayType(wh.DayType),
-			OpenTime:   wh.OpenTime + ":00",  // Convert HH:MM to HH:MM:SS
-			CloseTime:  wh.CloseTime + ":00", // Convert HH:MM to HH:MM:SS
-		}
-
-		if err := s.repo.CreateSchedule(schedule); err != nil {
-			return fmt.Errorf("failed to create schedule: %v", err)
+		for _, day := range days {
+			schedule := &model.FacilitySchedule{
+				FacilityID: facilityID,
+				DayType:    day,
+				OpenTime:   wh.OpenTime + ":00",  // Convert HH:MM to HH:MM:SS
+				CloseTime:  wh.CloseTime + ":00", // Convert HH:MM to HH:MM:SS
+			}
+
+			if err := s.repo.CreateSchedule(schedule); err != nil {
+				return fmt.Errorf("failed to create schedule: %v", err)
+			}
 		}
 	}
 	return nil
 }
 
-// savePricing saves pricing slots for a facility
+// savePricing saves pricing slots for a facility, expanding weekday/weekend groups into individual days
 func (s *FacilityService) savePricing(facilityID int64, pricingSlots []dto.PricingSlotDTO) error {
 	for _, ps := range pricingSlots {
-		pricing := &model.FacilityPricing{
-			FacilityID:   facilityID,
-			DayType:      model.DayType(ps.DayType),
-			StartHour:    ps.StartHour,
-			EndHour:      ps.EndHour,
-			PricePerHour: ps.PricePerHour,
+		days := model.ExpandDayType(model.DayType(ps.DayType))
+		if days == nil {
+			return fmt.Errorf("invalid day type: %s", ps.DayType)
+		}
+
+		for _, day := range days {
+			pricing := &model.FacilityPricing{
+				FacilityID:   facilityID,
+				DayType:      day,
+				StartHour:    ps.StartHour,
+				EndHour:      ps.EndHour,
+				PricePerHour: ps.PricePerHour,
+			}
+
+			if err := s.repo.CreatePricing(pricing); err != nil {
+				return fmt.Errorf("failed to create pricing: %v", err)
+			}
 		}
+	}
+	return nil
+}
+
+// validateBookingPolicy checks that a booking policy is internally consistent (nil means use the default)
+func validateBookingPolicy(policy *dto.BookingPolicyDTO) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.SlotMinutes <= 0 || policy.SlotMinutes > 24*60 {
+		return fmt.Errorf("slot length must be between 1 and 1440 minutes")
+	}
+
+	if policy.MinDurationMinutes < policy.SlotMinutes {
+		return fmt.Errorf("minimum duration cannot be shorter than the slot length")
+	}
+
+	if policy.MinDurationMinutes%policy.SlotMinutes != 0 {
+		return fmt.Errorf("minimum duration must be a multiple of the slot length")
+	}
 
-		if err := s.repo.CreatePricing(pricing); err != nil {
-			return fmt.Errorf("failed to create pricing: %v", err)
+	if policy.MaxDurationMinutes != nil {
+		if *policy.MaxDurationMinutes < policy.MinDurationMinutes {
+			return fmt.Errorf("maximum duration cannot be shorter than the minimum duration")
 		}
+		if *policy.MaxDurationMinutes%policy.SlotMinutes != 0 {
+			return fmt.Errorf("maximum duration must be a multiple of the slot length")
+		}
+	}
+
+	if policy.BufferMinutes < 0 {
+		return fmt.Errorf("buffer time cannot be negative")
+	}
+
+	if policy.RescheduleCutoffMinutes < 0 {
+		return fmt.Errorf("reschedule cutoff cannot be negative")
+	}
+
+	if policy.MaxReschedules != nil && *policy.MaxReschedules < 0 {
+		return fmt.Errorf("maximum number of reschedules cannot be negative")
+	}
+
+	if policy.AvailabilityMode != "" && policy.AvailabilityMode != model.AvailabilityExclusive && policy.AvailabilityMode != model.AvailabilityShared {
+		return fmt.Errorf("availability mode must be '%s' or '%s'", model.AvailabilityExclusive, model.AvailabilityShared)
+	}
+
+	return nil
+}
+
+// saveBookingPolicy saves the booking policy for a facility
+func (s *FacilityService) saveBookingPolicy(facilityID int64, policy *dto.BookingPolicyDTO) error {
+	bookingPolicy := &model.FacilityBookingPolicy{
+		FacilityID:              facilityID,
+		SlotMinutes:             policy.SlotMinutes,
+		MinDurationMinutes:      policy.MinDurationMinutes,
+		MaxDurationMinutes:      policy.MaxDurationMinutes,
+		BufferMinutes:           policy.BufferMinutes,
+		AllowReschedule:         policy.AllowReschedule == nil || *policy.AllowReschedule,
+		RescheduleCutoffMinutes: policy.RescheduleCutoffMinutes,
+		MaxReschedules:          policy.MaxReschedules,
+		AvailabilityMode:        policy.AvailabilityMode,
+	}
+	if bookingPolicy.AvailabilityMode == "" {
+		bookingPolicy.AvailabilityMode = model.AvailabilityExclusive
+	}
+
+	if err := s.repo.UpsertBookingPolicy(bookingPolicy); err != nil {
+		return fmt.Errorf("failed to save booking policy: %v", err)
 	}
 	return nil
 }
 
-// CreateFacilityWithScheduleAndPricing creates a facility with working hours and pricing
-func (s *FacilityService) CreateFacilityWithScheduleAndPricing(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, managerID int64, imageURLs []string, workingHours []dto.WorkingHoursDTO, pricing []dto.PricingSlotDTO) (*model.Facility, error) {
+// CreateFacilityWithScheduleAndPricing creates a facility with working hours, pricing and booking policy
+func (s *FacilityService) CreateFacilityWithScheduleAndPricing(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, timeZone string, managerID int64, imageURLs []string, workingHours []dto.WorkingHoursDTO, pricing []dto.PricingSlotDTO, bookingPolicy *dto.BookingPolicyDTO) (*model.Facility, error) {
+	if err := validateBookingPolicy(bookingPolicy); err != nil {
+		return nil, err
+	}
+
 	// Create the facility first
-	facility, err := s.CreateFacility(name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, managerID, imageURLs)
+	facility, err := s.CreateFacility(name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, timeZone, managerID, imageURLs)
 	if err != nil {
 		return nil, err
 	}
@@ -232,13 +357,24 @@ func (s *FacilityService) CreateFacilityWithScheduleAndPricing(name string, spor
 		}
 	}
 
+	// Save booking policy if provided
+	if bookingPolicy != nil {
+		if err := s.saveBookingPolicy(facility.ID, bookingPolicy); err != nil {
+			return nil, err
+		}
+	}
+
 	return facility, nil
 }
 
 // CreateFacilityWithoutImagesAndSchedule creates a facility without handling images, schedules, or pricing (for internal use)
-func (s *FacilityService) CreateFacilityWithoutImagesAndSchedule(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, managerID int64, workingHours []dto.WorkingHoursDTO, pricing []dto.PricingSlotDTO) (*model.Facility, error) {
+func (s *FacilityService) CreateFacilityWithoutImagesAndSchedule(name string, sportComplexID *int64, categoryID, surfaceID, environmentID int64, city, address, description string, capacity int, timeZone string, managerID int64, workingHours []dto.WorkingHoursDTO, pricing []dto.PricingSlotDTO, bookingPolicy *dto.BookingPolicyDTO) (*model.Facility, error) {
+	if err := validateBookingPolicy(bookingPolicy); err != nil {
+		return nil, err
+	}
+
 	// Create the facility
-	facility, err := s.repo.CreateFacility(name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, managerID)
+	facility, err := s.repo.CreateFacility(name, sportComplexID, categoryID, surfaceID, environmentID, city, address, description, capacity, timeZone, managerID)
 	if err != nil {
 		return nil, err
 	}
@@ -257,6 +393,13 @@ func (s *FacilityService) CreateFacilityWithoutImagesAndSchedule(name string, sp
 		}
 	}
 
+	// Save booking policy if provided
+	if bookingPolicy != nil {
+		if err := s.saveBookingPolicy(facility.ID, bookingPolicy); err != nil {
+			return nil, err
+		}
+	}
+
 	return facility, nil
 }
 
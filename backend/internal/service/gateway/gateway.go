@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"errors"
+	"math"
+	"net/http"
+)
+
+// ErrInvalidSignature is returned when a webhook's signature does not match its payload
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Gateway outcomes of an operation
+const (
+	StatusAuthorized = "authorized" // Funds are reserved and can be captured
+	StatusCaptured   = "captured"   // Funds were taken
+	StatusPending    = "pending"    // The outcome arrives later through a webhook
+	StatusDeclined   = "declined"   // The card or bank refused; FailureReason says why
+	StatusRefunded   = "refunded"   // The refund was accepted
+	StatusVoided     = "voided"     // The authorization was released without taking the funds
+)
+
+// PaymentGateway defines the interface for card processors that take payments
+type PaymentGateway interface {
+	// Name identifies the gateway in payments.gateway and webhook URLs
+	Name() string
+
+	// Authorize reserves the amount on the customer's payment method without taking it.
+	// A declined card is reported through Result.Status, not as an error.
+	Authorize(req AuthorizeRequest) (*Result, error)
+
+	// Capture takes an authorized amount
+	Capture(transactionID string, amount float64, currency string) (*Result, error)
+
+	// Void releases an authorization that was not captured
+	Void(transactionID string) (*Result, error)
+
+	// Refund returns part or all of a captured amount. Retrying with the same idempotencyKey
+	// does not refund twice.
+	Refund(transactionID string, amount float64, currency, idempotencyKey string) (*Result, error)
+
+	// VerifyWebhook checks the signature of a webhook request and parses its event;
+	// ErrInvalidSignature if it was not sent by the gateway
+	VerifyWebhook(payload []byte, header http.Header) (*WebhookEvent, error)
+}
+
+// AuthorizeRequest describes a payment to authorize
+type AuthorizeRequest struct {
+	PaymentID   int64
+	Amount      float64
+	Currency    string
+	Token       string // Payment method collected by the gateway's client library, e.g. a Stripe PaymentMethod ID
+	Description string
+	// IdempotencyKey identifies the payment attempt; retrying it with the same key returns the
+	// original transaction instead of authorizing again
+	IdempotencyKey string
+}
+
+// Result is the gateway's answer to an operation
+type Result struct {
+	TransactionID string
+	Status        string
+	FailureReason string
+}
+
+// WebhookEvent is a verified notification about a transaction
+type WebhookEvent struct {
+	ID            string // The gateway's event ID, unique per delivery of the same event
+	Type          string
+	TransactionID string
+	Status        string // One of the Status constants
+	FailureReason string
+	Amount        float64
+}
+
+// minorUnits converts an amount to the smallest currency unit (cents)
+func minorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signatureTolerance is how old a signed webhook may be, limiting replays of captured requests
+const signatureTolerance = 5 * time.Minute
+
+// signPayload returns a "t=<unix time>,v1=<hex HMAC-SHA256>" signature over "<unix time>.<payload>",
+// the scheme Stripe uses for webhooks
+func signPayload(payload []byte, secret string, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, computeSignature(payload, secret, timestamp))
+}
+
+// verifySignature checks a signature made by signPayload; any of several v1 values may match,
+// as sent while a webhook secret is being rolled
+func verifySignature(payload []byte, signature, secret string) error {
+	var timestamp string
+	var candidates []string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			candidates = append(candidates, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(candidates) == 0 {
+		return ErrInvalidSignature
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > signatureTolerance || age < -signatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := computeSignature(payload, secret, timestamp)
+	for _, candidate := range candidates {
+		if hmac.Equal([]byte(candidate), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func computeSignature(payload []byte, secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
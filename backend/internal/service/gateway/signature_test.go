@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"payment.authorized"}`)
+	secret := "whsec_test"
+	now := time.Now()
+
+	valid := signPayload(payload, secret, now)
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		wantErr   bool
+	}{
+		{name: "valid", payload: payload, signature: valid},
+		{name: "tampered payload", payload: []byte(`{"id":"evt_1","type":"payment.declined"}`), signature: valid, wantErr: true},
+		{name: "other secret", payload: payload, signature: signPayload(payload, "whsec_other", now), wantErr: true},
+		{name: "too old", payload: payload, signature: signPayload(payload, secret, now.Add(-signatureTolerance-time.Minute)), wantErr: true},
+		{name: "too far ahead", payload: payload, signature: signPayload(payload, secret, now.Add(signatureTolerance+time.Minute)), wantErr: true},
+		{name: "within tolerance", payload: payload, signature: signPayload(payload, secret, now.Add(-time.Minute))},
+		{
+			name:      "one of several v1 values while rolling the secret",
+			payload:   payload,
+			signature: fmt.Sprintf("t=%d,v1=%s,v1=%s", now.Unix(), computeSignature(payload, "whsec_old", fmt.Sprint(now.Unix())), computeSignature(payload, secret, fmt.Sprint(now.Unix()))),
+		},
+		{name: "no v1", payload: payload, signature: fmt.Sprintf("t=%d", now.Unix()), wantErr: true},
+		{name: "no timestamp", payload: payload, signature: "v1=" + computeSignature(payload, secret, ""), wantErr: true},
+		{name: "timestamp not signed", payload: payload, signature: fmt.Sprintf("t=%d,v1=%s", now.Unix()+1, computeSignature(payload, secret, fmt.Sprint(now.Unix()))), wantErr: true},
+		{name: "empty", payload: payload, signature: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(tt.payload, tt.signature, secret)
+			switch {
+			case tt.wantErr && !errors.Is(err, ErrInvalidSignature):
+				t.Errorf("verifySignature error = %v, want %v", err, ErrInvalidSignature)
+			case !tt.wantErr && err != nil:
+				t.Errorf("verifySignature: %v", err)
+			}
+		})
+	}
+}
+
+func TestSimulatedWebhookVerification(t *testing.T) {
+	g, err := NewSimulatedGateway(SimulateAsync, "whsec_test")
+	if err != nil {
+		t.Fatalf("NewSimulatedGateway: %v", err)
+	}
+
+	result, err := g.Authorize(AuthorizeRequest{PaymentID: 1, Amount: 40, Currency: "EUR"})
+	if err != nil || result.Status != StatusPending {
+		t.Fatalf("Authorize = %+v, %v, want a pending authorization", result, err)
+	}
+
+	payload, header, err := g.SimulateWebhook(result.TransactionID, true)
+	if err != nil {
+		t.Fatalf("SimulateWebhook: %v", err)
+	}
+
+	event, err := g.VerifyWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+	if event.TransactionID != result.TransactionID || event.Status != StatusAuthorized {
+		t.Errorf("event for %s is %s, want %s authorized", event.TransactionID, event.Status, result.TransactionID)
+	}
+
+	if _, err := g.VerifyWebhook(payload, http.Header{}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("unsigned webhook error = %v, want %v", err, ErrInvalidSignature)
+	}
+
+	other, _ := NewSimulatedGateway(SimulateAsync, "whsec_other")
+	if _, err := other.VerifyWebhook(payload, header); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("webhook signed with another secret error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,264 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Behaviours of the simulated gateway, chosen by its mode or per payment by the matching token
+const (
+	SimulateSucceed = "succeed" // Authorizations are approved
+	SimulateDecline = "decline" // Authorizations are declined
+	SimulateAsync   = "async"   // Authorizations stay pending until SimulateWebhook settles them
+)
+
+// simulatedSignatureHeader carries the webhook signature of the simulated gateway
+const simulatedSignatureHeader = "X-Simulated-Signature"
+
+// SimulatedGateway implements PaymentGateway without any external service, for tests and offline
+// development. The mode sets how every authorization ends; a payment token of "tok_succeed",
+// "tok_decline" or "tok_async" overrides it for that payment. Transactions live in memory, as do
+// the results of requests sent with an idempotency key.
+type SimulatedGateway struct {
+	mu            sync.Mutex
+	mode          string
+	webhookSecret string
+	transactions  map[string]*simulatedTransaction
+	idempotent    map[string]*Result
+	nextID        int64
+}
+
+type simulatedTransaction struct {
+	status   string
+	amount   float64
+	currency string
+	captured float64
+	refunded float64
+}
+
+// simulatedEvent is the webhook body sent by the simulated gateway
+type simulatedEvent struct {
+	ID            string  `json:"id"`
+	Type          string  `json:"type"`
+	TransactionID string  `json:"transaction_id"`
+	Status        string  `json:"status"`
+	FailureReason string  `json:"failure_reason,omitempty"`
+	Amount        float64 `json:"amount"`
+}
+
+// NewSimulatedGateway creates a simulated gateway. An empty mode approves every payment;
+// an empty webhook secret is replaced by a random one.
+func NewSimulatedGateway(mode, webhookSecret string) (*SimulatedGateway, error) {
+	if mode == "" {
+		mode = SimulateSucceed
+	}
+	if mode != SimulateSucceed && mode != SimulateDecline && mode != SimulateAsync {
+		return nil, fmt.Errorf("unknown simulated gateway mode %q", mode)
+	}
+
+	if webhookSecret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		webhookSecret = hex.EncodeToString(secret)
+	}
+
+	return &SimulatedGateway{
+		mode:          mode,
+		webhookSecret: webhookSecret,
+		transactions:  map[string]*simulatedTransaction{},
+		idempotent:    map[string]*Result{},
+	}, nil
+}
+
+// Name identifies the simulated gateway
+func (g *SimulatedGateway) Name() string {
+	return "simulated"
+}
+
+// Authorize approves, declines or leaves the payment pending according to the mode or token
+func (g *SimulatedGateway) Authorize(req AuthorizeRequest) (*Result, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	mode := g.mode
+	switch req.Token {
+	case "tok_succeed":
+		mode = SimulateSucceed
+	case "tok_decline":
+		mode = SimulateDecline
+	case "tok_async":
+		mode = SimulateAsync
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := "authorize_" + req.IdempotencyKey
+	if previous, ok := g.idempotent[key]; ok && req.IdempotencyKey != "" {
+		return previous, nil
+	}
+
+	g.nextID++
+	id := fmt.Sprintf("sim_%d_%d", time.Now().Unix(), g.nextID)
+	transaction := &simulatedTransaction{amount: req.Amount, currency: req.Currency}
+	g.transactions[id] = transaction
+
+	result := &Result{TransactionID: id}
+	switch mode {
+	case SimulateDecline:
+		transaction.status = StatusDeclined
+		result.FailureReason = "Your card was declined."
+	case SimulateAsync:
+		transaction.status = StatusPending
+	default:
+		transaction.status = StatusAuthorized
+	}
+	result.Status = transaction.status
+
+	if req.IdempotencyKey != "" {
+		g.idempotent[key] = result
+	}
+	return result, nil
+}
+
+// Capture takes up to the authorized amount
+func (g *SimulatedGateway) Capture(transactionID string, amount float64, currency string) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	transaction, ok := g.transactions[transactionID]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s not found", transactionID)
+	}
+	if transaction.status == StatusCaptured {
+		return &Result{TransactionID: transactionID, Status: StatusCaptured}, nil
+	}
+	if transaction.status != StatusAuthorized {
+		return nil, fmt.Errorf("transaction %s is %s and cannot be captured", transactionID, transaction.status)
+	}
+	if currency != transaction.currency || minorUnits(amount) > minorUnits(transaction.amount) {
+		return nil, fmt.Errorf("capture of %.2f %s exceeds the authorized %.2f %s", amount, currency, transaction.amount, transaction.currency)
+	}
+
+	transaction.status = StatusCaptured
+	transaction.captured = amount
+	return &Result{TransactionID: transactionID, Status: StatusCaptured}, nil
+}
+
+// Void releases an authorization, or a pending one before SimulateWebhook settles it. A declined
+// transaction has nothing to release and is voided as well.
+func (g *SimulatedGateway) Void(transactionID string) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	transaction, ok := g.transactions[transactionID]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s not found", transactionID)
+	}
+	switch transaction.status {
+	case StatusAuthorized, StatusPending, StatusDeclined, StatusVoided:
+		transaction.status = StatusVoided
+		return &Result{TransactionID: transactionID, Status: StatusVoided}, nil
+	}
+	return nil, fmt.Errorf("transaction %s is %s and cannot be voided", transactionID, transaction.status)
+}
+
+// Refund returns up to the captured amount not refunded yet
+func (g *SimulatedGateway) Refund(transactionID string, amount float64, currency, idempotencyKey string) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := "refund_" + idempotencyKey
+	if previous, ok := g.idempotent[key]; ok && idempotencyKey != "" {
+		return previous, nil
+	}
+
+	transaction, ok := g.transactions[transactionID]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s not found", transactionID)
+	}
+	if transaction.status != StatusCaptured {
+		return nil, fmt.Errorf("transaction %s is %s and cannot be refunded", transactionID, transaction.status)
+	}
+	if amount <= 0 || currency != transaction.currency ||
+		minorUnits(transaction.refunded)+minorUnits(amount) > minorUnits(transaction.captured) {
+		return nil, fmt.Errorf("refund of %.2f %s exceeds the captured amount", amount, currency)
+	}
+
+	transaction.refunded += amount
+	g.nextID++
+	result := &Result{TransactionID: fmt.Sprintf("sim_refund_%d", g.nextID), Status: StatusRefunded}
+	if idempotencyKey != "" {
+		g.idempotent[key] = result
+	}
+	return result, nil
+}
+
+// VerifyWebhook checks the X-Simulated-Signature header and parses the event
+func (g *SimulatedGateway) VerifyWebhook(payload []byte, header http.Header) (*WebhookEvent, error) {
+	if err := verifySignature(payload, header.Get(simulatedSignatureHeader), g.webhookSecret); err != nil {
+		return nil, err
+	}
+
+	var event simulatedEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook: %w", err)
+	}
+
+	return &WebhookEvent{
+		ID:            event.ID,
+		Type:          event.Type,
+		TransactionID: event.TransactionID,
+		Status:        event.Status,
+		FailureReason: event.FailureReason,
+		Amount:        event.Amount,
+	}, nil
+}
+
+// SimulateWebhook settles a pending authorization as approved or declined and returns the signed
+// webhook request announcing it, to be posted to the webhook endpoint
+func (g *SimulatedGateway) SimulateWebhook(transactionID string, approve bool) ([]byte, http.Header, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	transaction, ok := g.transactions[transactionID]
+	if !ok {
+		return nil, nil, fmt.Errorf("transaction %s not found", transactionID)
+	}
+	if transaction.status != StatusPending {
+		return nil, nil, fmt.Errorf("transaction %s is not pending", transactionID)
+	}
+
+	event := simulatedEvent{TransactionID: transactionID, Amount: transaction.amount}
+	if approve {
+		transaction.status = StatusAuthorized
+		event.Type = "payment.authorized"
+	} else {
+		transaction.status = StatusDeclined
+		event.Type = "payment.declined"
+		event.FailureReason = "Your card was declined."
+	}
+	event.Status = transaction.status
+
+	// Stored events are matched by ID, so IDs must not repeat after a restart
+	g.nextID++
+	event.ID = fmt.Sprintf("evt_sim_%d_%d", time.Now().UnixNano(), g.nextID)
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(simulatedSignatureHeader, signPayload(payload, g.webhookSecret, time.Now()))
+	return payload, header, nil
+}
@@ -0,0 +1,263 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stripeAPIURL = "https://api.stripe.com"
+
+// StripeGateway implements PaymentGateway with Stripe PaymentIntents. Payments are authorized with
+// manual capture and captured once the booking is confirmed.
+type StripeGateway struct {
+	client        *http.Client
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+}
+
+// stripePaymentIntent holds the PaymentIntent fields the gateway reads
+type stripePaymentIntent struct {
+	ID               string `json:"id"`
+	Status           string `json:"status"`
+	Amount           int64  `json:"amount"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+type stripeError struct {
+	Error struct {
+		Type          string               `json:"type"`
+		Code          string               `json:"code"`
+		Message       string               `json:"message"`
+		PaymentIntent *stripePaymentIntent `json:"payment_intent"`
+	} `json:"error"`
+}
+
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object struct {
+			stripePaymentIntent
+			PaymentIntent  string `json:"payment_intent"`
+			AmountRefunded int64  `json:"amount_refunded"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+// NewStripeGateway creates a Stripe gateway from a secret API key and a webhook signing secret
+func NewStripeGateway(secretKey, webhookSecret string) *StripeGateway {
+	return &StripeGateway{
+		client:        &http.Client{Timeout: 30 * time.Second},
+		baseURL:       stripeAPIURL,
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+	}
+}
+
+// Name identifies the Stripe gateway
+func (g *StripeGateway) Name() string {
+	return "stripe"
+}
+
+// Authorize creates and confirms a PaymentIntent with manual capture
+func (g *StripeGateway) Authorize(req AuthorizeRequest) (*Result, error) {
+	if req.Token == "" {
+		return nil, fmt.Errorf("payment method token is required")
+	}
+
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(minorUnits(req.Amount), 10))
+	form.Set("currency", strings.ToLower(req.Currency))
+	form.Set("payment_method", req.Token)
+	form.Set("capture_method", "manual")
+	form.Set("confirm", "true")
+	form.Set("automatic_payment_methods[enabled]", "true")
+	form.Set("automatic_payment_methods[allow_redirects]", "never")
+	form.Set("description", req.Description)
+	form.Set("metadata[payment_id]", strconv.FormatInt(req.PaymentID, 10))
+
+	var intent stripePaymentIntent
+	declined, err := g.post("/v1/payment_intents", form, req.IdempotencyKey, &intent)
+	if err != nil {
+		return nil, err
+	}
+	if declined != nil {
+		return declined, nil
+	}
+	return intentResult(&intent), nil
+}
+
+// Capture captures an authorized PaymentIntent
+func (g *StripeGateway) Capture(transactionID string, amount float64, currency string) (*Result, error) {
+	form := url.Values{}
+	form.Set("amount_to_capture", strconv.FormatInt(minorUnits(amount), 10))
+
+	var intent stripePaymentIntent
+	declined, err := g.post("/v1/payment_intents/"+url.PathEscape(transactionID)+"/capture", form, "capture_"+transactionID, &intent)
+	if err != nil {
+		return nil, err
+	}
+	if declined != nil {
+		return declined, nil
+	}
+	return intentResult(&intent), nil
+}
+
+// Void cancels a PaymentIntent that was not captured, releasing its authorization
+func (g *StripeGateway) Void(transactionID string) (*Result, error) {
+	var intent stripePaymentIntent
+	declined, err := g.post("/v1/payment_intents/"+url.PathEscape(transactionID)+"/cancel", url.Values{}, "cancel_"+transactionID, &intent)
+	if err != nil {
+		return nil, err
+	}
+	if declined != nil {
+		return declined, nil
+	}
+	if intent.Status != "canceled" {
+		return nil, fmt.Errorf("payment intent %s is %s and was not canceled", transactionID, intent.Status)
+	}
+	return &Result{TransactionID: intent.ID, Status: StatusVoided}, nil
+}
+
+// Refund refunds part or all of a captured PaymentIntent
+func (g *StripeGateway) Refund(transactionID string, amount float64, currency, idempotencyKey string) (*Result, error) {
+	form := url.Values{}
+	form.Set("payment_intent", transactionID)
+	form.Set("amount", strconv.FormatInt(minorUnits(amount), 10))
+
+	var refund struct {
+		ID            string `json:"id"`
+		Status        string `json:"status"`
+		FailureReason string `json:"failure_reason"`
+	}
+	declined, err := g.post("/v1/refunds", form, idempotencyKey, &refund)
+	if err != nil {
+		return nil, err
+	}
+	if declined != nil {
+		return declined, nil
+	}
+
+	result := &Result{TransactionID: refund.ID}
+	switch refund.Status {
+	case "succeeded":
+		result.Status = StatusRefunded
+	case "pending", "requires_action":
+		result.Status = StatusPending
+	default:
+		result.Status = StatusDeclined
+		result.FailureReason = refund.FailureReason
+	}
+	return result, nil
+}
+
+// VerifyWebhook checks the Stripe-Signature header and parses PaymentIntent and refund events.
+// Events of other types are returned without a status.
+func (g *StripeGateway) VerifyWebhook(payload []byte, header http.Header) (*WebhookEvent, error) {
+	if err := verifySignature(payload, header.Get("Stripe-Signature"), g.webhookSecret); err != nil {
+		return nil, err
+	}
+
+	var event stripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook: %w", err)
+	}
+
+	object := event.Data.Object
+	result := &WebhookEvent{
+		ID:            event.ID,
+		Type:          event.Type,
+		TransactionID: object.ID,
+		Amount:        float64(object.Amount) / 100,
+	}
+	switch event.Type {
+	case "payment_intent.amount_capturable_updated":
+		result.Status = StatusAuthorized
+	case "payment_intent.succeeded":
+		result.Status = StatusCaptured
+	case "payment_intent.payment_failed", "payment_intent.canceled":
+		result.Status = StatusDeclined
+		if object.LastPaymentError != nil {
+			result.FailureReason = object.LastPaymentError.Message
+		}
+	case "charge.refunded":
+		result.Status = StatusRefunded
+		result.TransactionID = object.PaymentIntent
+		result.Amount = float64(object.AmountRefunded) / 100
+	}
+	return result, nil
+}
+
+// post sends a form-encoded API request and decodes the response into out. A card error is
+// returned as a declined result rather than an error. A non-empty idempotencyKey makes Stripe answer
+// a retried request with the original response instead of performing it again.
+func (g *StripeGateway) post(path string, form url.Values, idempotencyKey string, out interface{}) (*Result, error) {
+	req, err := http.NewRequest(http.MethodPost, g.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(g.secretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stripe response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr stripeError
+		if json.Unmarshal(body, &apiErr) != nil {
+			return nil, fmt.Errorf("stripe returned %s", resp.Status)
+		}
+		if apiErr.Error.Type == "card_error" {
+			result := &Result{Status: StatusDeclined, FailureReason: apiErr.Error.Message}
+			if apiErr.Error.PaymentIntent != nil {
+				result.TransactionID = apiErr.Error.PaymentIntent.ID
+			}
+			return result, nil
+		}
+		return nil, fmt.Errorf("stripe returned %s: %s", resp.Status, apiErr.Error.Message)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return nil, fmt.Errorf("failed to parse stripe response: %w", err)
+	}
+	return nil, nil
+}
+
+// intentResult maps a PaymentIntent status to a gateway outcome
+func intentResult(intent *stripePaymentIntent) *Result {
+	result := &Result{TransactionID: intent.ID}
+	switch intent.Status {
+	case "requires_capture":
+		result.Status = StatusAuthorized
+	case "succeeded":
+		result.Status = StatusCaptured
+	case "processing", "requires_action", "requires_confirmation":
+		result.Status = StatusPending
+	default:
+		result.Status = StatusDeclined
+		if intent.LastPaymentError != nil {
+			result.FailureReason = intent.LastPaymentError.Message
+		}
+	}
+	return result
+}
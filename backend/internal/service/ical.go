@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/service/calendar"
+)
+
+// reservationUID is the stable iCalendar UID of a reservation, shared by feeds and email
+// attachments so calendar apps update or cancel the same entry
+func reservationUID(reservationID int64) string {
+	return fmt.Sprintf("reservation-%d@playspot", reservationID)
+}
+
+// eventUID is the stable iCalendar UID of an event
+func eventUID(eventID int64) string {
+	return fmt.Sprintf("event-%d@playspot", eventID)
+}
+
+// reservationCalendarEntry describes a reservation the way it appears in the customer's calendar
+func reservationCalendarEntry(reservation dto.ReservationWithFacilityDTO) model.CalendarEntry {
+	entry := model.CalendarEntry{
+		UID:       reservationUID(reservation.ID),
+		Summary:   fmt.Sprintf("PlaySpot Booking - %s", reservation.FacilityName),
+		Location:  fmt.Sprintf("%s, %s", reservation.FacilityAddress, reservation.FacilityCity),
+		StartTime: reservation.StartTime,
+		EndTime:   reservation.EndTime,
+		Sequence:  reservation.RescheduleCount,
+		Description: fmt.Sprintf(
+			"Facility: %s\nSport: %s\nPrice: €%.2f",
+			reservation.FacilityName, reservation.FacilitySport, reservation.TotalPrice,
+		),
+	}
+	if reservation.Status == "cancelled" {
+		entry.Cancelled = true
+		entry.Sequence++
+	}
+	return entry
+}
+
+// reservationInvite renders a single reservation as an .ics email attachment
+func reservationInvite(reservation *model.FacilityReservation, facility *model.FacilityDetails) []byte {
+	entry := reservationCalendarEntry(dto.ReservationWithFacilityDTO{
+		ID:              reservation.ID,
+		StartTime:       reservation.StartTime,
+		EndTime:         reservation.EndTime,
+		Status:          reservation.Status,
+		TotalPrice:      reservation.TotalPrice,
+		RescheduleCount: reservation.RescheduleCount,
+		FacilityName:    facility.Name,
+		FacilitySport:   facility.SportName,
+		FacilityCity:    facility.City,
+		FacilityAddress: facility.Address,
+	})
+	return calendar.Encode("", []model.CalendarEntry{entry})
+}
@@ -1,46 +1,123 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
 
 	"github.com/Radi03825/PlaySpot/internal/dto"
 	"github.com/Radi03825/PlaySpot/internal/model"
 	"github.com/Radi03825/PlaySpot/internal/repository"
+	"github.com/Radi03825/PlaySpot/internal/service/gateway"
 )
 
+// ErrPaymentDeclined is returned when the payment gateway refused the card
+var ErrPaymentDeclined = errors.New("payment was declined")
+
+// ErrUnknownGateway is returned for webhooks addressed to a payment gateway that is not in use
+var ErrUnknownGateway = errors.New("unknown payment gateway")
+
+// ErrInvalidWebhookSignature is returned for webhooks not signed by the payment gateway
+var ErrInvalidWebhookSignature = gateway.ErrInvalidSignature
+
+// ErrWebhookEventNotFound is returned when a stored webhook event does not exist
+var ErrWebhookEventNotFound = errors.New("webhook event not found")
+
+var (
+	ErrPaymentInProgress = repository.ErrPaymentInProgress
+	ErrPaymentLapsed     = repository.ErrPaymentLapsed
+	ErrNoBalanceDue      = repository.ErrNoBalanceDue
+)
+
+// webhookEventListLimit caps how many stored webhook events are listed
+const webhookEventListLimit = 100
+
+// paymentAttemptHold is how long a card payment attempt keeps the payment claimed and its reservations
+// held while the gateway authorizes it
+const paymentAttemptHold = 2 * time.Minute
+
+// asyncPaymentHold is how long the reservations of a card payment the gateway settles asynchronously
+// stay held waiting for its webhook
+const asyncPaymentHold = 30 * time.Minute
+
 type PaymentService struct {
-	paymentRepo           *repository.PaymentRepository
-	reservationRepo       *repository.ReservationRepository
-	facilityService       *FacilityService
-	emailService          *EmailService
-	googleCalendarService *GoogleCalendarService
-	userService           *UserService
+	paymentRepo         *repository.PaymentRepository
+	webhookRepo         *repository.PaymentWebhookRepository
+	reservationRepo     *repository.ReservationRepository
+	facilityService     *FacilityService
+	emailService        *EmailService
+	calendarSyncService *CalendarSyncService
+	userService         *UserService
+	refundService       *RefundService
+	splitPaymentService *SplitPaymentService
+	gateway             gateway.PaymentGateway
 }
 
 func NewPaymentService(
 	paymentRepo *repository.PaymentRepository,
+	webhookRepo *repository.PaymentWebhookRepository,
 	reservationRepo *repository.ReservationRepository,
 	facilityService *FacilityService,
 	emailService *EmailService,
-	googleCalendarService *GoogleCalendarService,
+	calendarSyncService *CalendarSyncService,
 	userService *UserService,
+	refundService *RefundService,
+	paymentGateway gateway.PaymentGateway,
 ) *PaymentService {
 	return &PaymentService{
-		paymentRepo:           paymentRepo,
-		reservationRepo:       reservationRepo,
-		facilityService:       facilityService,
-		emailService:          emailService,
-		googleCalendarService: googleCalendarService,
-		userService:           userService,
+		paymentRepo:         paymentRepo,
+		webhookRepo:         webhookRepo,
+		reservationRepo:     reservationRepo,
+		facilityService:     facilityService,
+		emailService:        emailService,
+		calendarSyncService: calendarSyncService,
+		userService:         userService,
+		refundService:       refundService,
+		gateway:             paymentGateway,
 	}
 }
 
+// SetSplitPaymentService sets the split payment service (for resolving circular dependencies)
+func (s *PaymentService) SetSplitPaymentService(splitPaymentService *SplitPaymentService) {
+	s.splitPaymentService = splitPaymentService
+}
+
 // GetPaymentByReservationID retrieves a payment by reservation ID
 func (s *PaymentService) GetPaymentByReservationID(reservationID int64) (*model.Payment, error) {
 	return s.paymentRepo.GetPaymentByReservationID(reservationID)
 }
 
-// ProcessPayment processes a payment and creates calendar event & sends email
+// GetPaymentDetails retrieves the payment of a reservation with its refund history and the amount kept after refunds
+func (s *PaymentService) GetPaymentDetails(reservationID int64) (*dto.PaymentDetailsDTO, error) {
+	payment, err := s.paymentRepo.GetPaymentByReservationID(reservationID)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+
+	refunds, err := s.refundService.GetPaymentRefunds(payment.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refunds: %w", err)
+	}
+
+	details := &dto.PaymentDetailsDTO{
+		Payment: payment,
+		Refunds: refunds,
+	}
+	if payment.PaidAt != nil {
+		details.NetAmount = math.Round((payment.Amount-payment.RefundedAmount)*100) / 100
+	}
+
+	return details, nil
+}
+
+// ProcessPayment processes a payment and creates calendar event & sends email.
+// Card payments are authorized and captured through the payment gateway; one the gateway settles
+// asynchronously is returned still pending and leaves the reservations unconfirmed until it completes.
 func (s *PaymentService) ProcessPayment(reservationID, userID int64, req dto.ProcessPaymentDTO) (*model.Payment, error) {
 	// Validate payment method
 	if req.PaymentMethod != "on_place" && req.PaymentMethod != "card" {
@@ -69,9 +146,15 @@ func (s *PaymentService) ProcessPayment(reservationID, userID int64, req dto.Pro
 		return nil, fmt.Errorf("cannot pay for cancelled reservation")
 	}
 
+	// Check if the hold on the slot has lapsed
+	if reservation.Status == "expired" ||
+		(reservation.Status == "pending" && reservation.ExpiresAt != nil && !reservation.ExpiresAt.After(time.Now())) {
+		return nil, fmt.Errorf("reservation has expired, please book the slot again")
+	}
+
 	// If no payment exists, create one
 	if payment == nil {
-		payment, err = s.paymentRepo.CreatePayment(userID, reservationID, reservation.TotalPrice, "EUR")
+		payment, err = s.paymentRepo.CreatePayment(userID, reservationID, reservation.TotalPrice, "EUR", reservation.ExpiresAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create payment: %w", err)
 		}
@@ -82,96 +165,608 @@ func (s *PaymentService) ProcessPayment(reservationID, userID int64, req dto.Pro
 		return payment, nil // Already paid, return existing payment
 	}
 
-	// Process the payment
-	err = s.paymentRepo.ProcessPayment(payment.ID, req.PaymentMethod)
+	// Participants pay the shares of a split booking themselves
+	if payment.PaymentMethod == model.PaymentMethodSplit && payment.PaymentStatus == "pending" {
+		return nil, fmt.Errorf("the cost of this reservation is split among event participants, pay your share from the event")
+	}
+
+	// A basket payment confirms every reservation it still covers
+	reservations, err := s.getPaymentReservations(payment, reservation)
 	if err != nil {
-		return nil, fmt.Errorf("failed to process payment: %w", err)
+		return nil, err
+	}
+
+	// Process the payment; a captured card payment, like one paid on site, confirms its reservations along with it
+	if req.PaymentMethod == "card" {
+		payment, err = s.chargeCard(payment, reservation, req.PaymentToken)
+		if err != nil {
+			return nil, err
+		}
+		if payment.PaymentStatus != "completed" {
+			return payment, nil
+		}
+	} else {
+		payment, err = s.paymentRepo.ProcessPayment(payment.ID, req.PaymentMethod)
+		if errors.Is(err, ErrPaymentInProgress) || errors.Is(err, ErrPaymentLapsed) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to process payment: %w", err)
+		}
 	}
 
-	// Update reservation status to confirmed
-	err = s.reservationRepo.UpdateReservationStatus(reservationID, "confirmed")
+	s.notifyPaid(payment, reservations)
+
+	return payment, nil
+}
+
+// PayBalance pays by card the balance due on a reservation of the user after it moved to a dearer time.
+// It returns the balance charge as recorded afterwards: paid, or pending while the gateway settles it
+// asynchronously. A declined card returns ErrPaymentDeclined and the balance can be paid again.
+func (s *PaymentService) PayBalance(reservationID, userID int64, req dto.PayBalanceDTO) (*model.BalanceCharge, error) {
+	reservation, err := s.reservationRepo.GetReservationByID(reservationID)
+	if err != nil || reservation.UserID != userID {
+		return nil, errors.New("reservation not found")
+	}
+	if reservation.Status != "confirmed" {
+		return nil, fmt.Errorf("cannot pay for a %s reservation", reservation.Status)
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByReservationID(reservationID)
 	if err != nil {
-		fmt.Printf("Warning: Failed to update reservation status: %v\n", err)
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrNoBalanceDue
 	}
 
-	// Get updated payment
-	payment, err = s.paymentRepo.GetPaymentByID(payment.ID)
+	attemptID, err := newPaymentAttemptID()
+	if err != nil {
+		return nil, err
+	}
+	charge, attemptID, err := s.paymentRepo.StartBalanceCharge(payment.ID, s.gateway.Name(), attemptID, paymentAttemptHold)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get updated payment: %w", err)
+		return nil, err
 	}
 
-	// Create Google Calendar event
-	s.createCalendarEventForReservation(reservation)
+	result, err := s.gateway.Authorize(gateway.AuthorizeRequest{
+		PaymentID:      payment.ID,
+		Amount:         charge.Amount,
+		Currency:       payment.Currency,
+		Token:          req.PaymentToken,
+		Description:    fmt.Sprintf("PlaySpot reservation #%d balance", reservation.ID),
+		IdempotencyKey: attemptID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to authorize payment: %w", err)
+	}
 
-	// Send confirmation email
-	s.sendPaymentConfirmationEmail(reservation, payment)
+	switch result.Status {
+	case gateway.StatusDeclined:
+		return nil, s.declineBalanceCharge(charge.ID, result)
+	case gateway.StatusPending:
+		err = s.paymentRepo.SetBalanceChargeTransaction(charge.ID, result.TransactionID)
+	case gateway.StatusCaptured:
+		err = s.completeBalanceCharge(charge, result.TransactionID)
+	default:
+		err = s.captureBalanceCharge(charge, payment.Currency, result.TransactionID)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return payment, nil
+	return s.paymentRepo.GetBalanceCharge(charge.ID)
 }
 
-// createCalendarEventForReservation creates a Google Calendar event for a reservation
-func (s *PaymentService) createCalendarEventForReservation(reservation *model.FacilityReservation) {
-	// Get Google tokens
-	accessToken, refreshToken, tokenExpiry, err := s.userService.GetGoogleTokens(reservation.UserID)
+// captureBalanceCharge captures an authorized balance charge and, once captured, adds it to its payment
+func (s *PaymentService) captureBalanceCharge(charge *model.BalanceCharge, currency, transactionID string) error {
+	result, err := s.gateway.Capture(transactionID, charge.Amount, currency)
 	if err != nil {
-		fmt.Printf("No Google tokens for user %d: %v\n", reservation.UserID, err)
-		return
+		return fmt.Errorf("failed to capture payment: %w", err)
 	}
 
-	if accessToken == "" || refreshToken == "" {
-		fmt.Printf("User %d has no calendar tokens\n", reservation.UserID)
-		return
+	switch result.Status {
+	case gateway.StatusDeclined:
+		return s.declineBalanceCharge(charge.ID, result)
+	case gateway.StatusCaptured:
+		return s.completeBalanceCharge(charge, transactionID)
 	}
 
-	fmt.Printf("Creating calendar event for user %d, reservation %d\n", reservation.UserID, reservation.ID)
+	// Captured asynchronously; the webhook completes the charge
+	return s.paymentRepo.SetBalanceChargeTransaction(charge.ID, transactionID)
+}
 
-	// Get facility details
-	facility, err := s.facilityService.GetFacilityDetailsByID(reservation.FacilityID)
+// completeBalanceCharge records a captured balance charge as paid. What it took beyond the balance still due,
+// e.g. because the reservation was cancelled meanwhile, is refunded, and so is a capture that can no longer
+// be recorded because the charge was paid through another transaction.
+func (s *PaymentService) completeBalanceCharge(charge *model.BalanceCharge, transactionID string) error {
+	payment, err := s.paymentRepo.PayBalanceCharge(charge.ID, transactionID)
+	if errors.Is(err, repository.ErrBalanceChargeNotPending) {
+		current, err := s.paymentRepo.GetBalanceCharge(charge.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get balance charge: %w", err)
+		}
+		if current != nil && current.GatewayTransactionID != nil && *current.GatewayTransactionID == transactionID {
+			return nil
+		}
+
+		payment, err := s.paymentRepo.GetPaymentByID(charge.PaymentID)
+		if err != nil || payment == nil {
+			return fmt.Errorf("failed to get payment %d: %v", charge.PaymentID, err)
+		}
+		if _, failure := s.refundService.refundTransaction(transactionID, charge.Amount, payment.Currency, "charge_refund_"+transactionID); failure != "" {
+			return fmt.Errorf("failed to refund unrecorded capture of balance charge %d: %s", charge.ID, failure)
+		}
+		log.Printf("[PAYMENT] Refunded %.2f captured for balance charge %d that could not be recorded as paid", charge.Amount, charge.ID)
+		return nil
+	}
 	if err != nil {
-		fmt.Printf("Failed to get facility %d: %v\n", reservation.FacilityID, err)
-		return
+		return fmt.Errorf("failed to record balance charge: %w", err)
 	}
-	// Create calendar event
-	description := fmt.Sprintf(
-		"Facility: %s\nCategory: %s\nSport: %s\nAddress: %s, %s\nPrice: €%.2f\n\nBooking confirmed via PlaySpot",
-		facility.Name,
-		facility.CategoryName,
-		facility.SportName,
-		facility.Address,
-		facility.City,
-		reservation.TotalPrice,
-	)
 
-	// Combine address and city for location
-	location := fmt.Sprintf("%s, %s", facility.Address, facility.City)
+	s.refundService.ProcessPaymentRefunds(payment)
+	return nil
+}
+
+// declineBalanceCharge records a declined balance charge and returns the error describing it
+func (s *PaymentService) declineBalanceCharge(chargeID int64, result *gateway.Result) error {
+	reason := result.FailureReason
+	if reason == "" {
+		reason = "declined by the payment gateway"
+	}
+	if err := s.paymentRepo.FailBalanceCharge(chargeID, reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrPaymentDeclined, reason)
+}
+
+// applyBalanceChargeOutcome applies a gateway webhook event to the balance charge paid as its transaction
+func (s *PaymentService) applyBalanceChargeOutcome(event *model.PaymentWebhookEvent, charge *model.BalanceCharge) (string, *string, error) {
+	ignore := func(reason string) (string, *string, error) {
+		return model.WebhookIgnored, &reason, nil
+	}
 
-	eventID, err := s.googleCalendarService.CreateEvent(
-		accessToken,
-		refreshToken,
-		tokenExpiry,
-		facility.Name,
-		description,
-		location,
-		reservation.StartTime,
-		reservation.EndTime,
-	)
+	if charge.Status != model.BalanceChargePending {
+		return ignore("balance charge is already " + charge.Status)
+	}
+
+	switch *event.EventStatus {
+	case gateway.StatusAuthorized:
+		payment, err := s.paymentRepo.GetPaymentByID(charge.PaymentID)
+		if err != nil || payment == nil {
+			return "", nil, fmt.Errorf("failed to get payment %d: %v", charge.PaymentID, err)
+		}
+		err = s.captureBalanceCharge(charge, payment.Currency, *event.TransactionID)
+		if err != nil && !errors.Is(err, ErrPaymentDeclined) {
+			return "", nil, err
+		}
+	case gateway.StatusCaptured:
+		if err := s.completeBalanceCharge(charge, *event.TransactionID); err != nil {
+			return "", nil, err
+		}
+	case gateway.StatusDeclined:
+		reason := "declined by the payment gateway"
+		if event.FailureReason != nil {
+			reason = *event.FailureReason
+		}
+		return model.WebhookProcessed, nil, s.paymentRepo.FailBalanceCharge(charge.ID, reason)
+	default:
+		return ignore(fmt.Sprintf("%s events are not handled", event.EventType))
+	}
 
+	return model.WebhookProcessed, nil, nil
+}
+
+// notifyPaid queues the calendar events and sends the confirmation emails of newly paid reservations
+func (s *PaymentService) notifyPaid(payment *model.Payment, reservations []*model.FacilityReservation) {
+	for _, paid := range reservations {
+		// Queue the Google Calendar event
+		s.calendarSyncService.EnqueueCreate(paid)
+
+		// Send confirmation email, with the reservation's own share of a basket payment
+		amount := payment.Amount
+		if len(reservations) > 1 {
+			amount = paid.TotalPrice
+		}
+		s.sendPaymentConfirmationEmail(paid, payment, amount)
+	}
+}
+
+// chargeCard authorizes the payment through the gateway, then captures it. It returns the payment as
+// recorded afterwards: completed, or pending while the gateway settles the authorization asynchronously.
+// A declined card fails the payment with ErrPaymentDeclined; the customer may retry with another card.
+// An authorized payment whose capture failed is only captured again. The attempt claims the payment, so a
+// concurrent one gets ErrPaymentInProgress instead of authorizing it twice.
+func (s *PaymentService) chargeCard(payment *model.Payment, reservation *model.FacilityReservation, token string) (*model.Payment, error) {
+	if payment.PaymentStatus == "pending" && payment.GatewayTransactionID != nil {
+		// Still waiting for the gateway's answer to an earlier attempt
+		return payment, nil
+	}
+
+	transactionID := ""
+	if payment.GatewayTransactionID != nil {
+		transactionID = *payment.GatewayTransactionID
+	}
+
+	if payment.PaymentStatus != "authorized" {
+		attemptID, err := newPaymentAttemptID()
+		if err != nil {
+			return nil, err
+		}
+		attemptID, err = s.paymentRepo.StartGatewayPayment(payment.ID, "card", s.gateway.Name(), attemptID, paymentAttemptHold)
+		if errors.Is(err, ErrPaymentInProgress) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to process payment: %w", err)
+		}
+
+		result, err := s.gateway.Authorize(gateway.AuthorizeRequest{
+			PaymentID:      payment.ID,
+			Amount:         payment.Amount,
+			Currency:       payment.Currency,
+			Token:          token,
+			Description:    fmt.Sprintf("PlaySpot reservation #%d", reservation.ID),
+			IdempotencyKey: attemptID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to authorize payment: %w", err)
+		}
+
+		switch result.Status {
+		case gateway.StatusDeclined:
+			return nil, s.declinePayment(payment.ID, result)
+		case gateway.StatusPending:
+			if err := s.paymentRepo.SetPaymentTransaction(payment.ID, result.TransactionID, asyncPaymentHold); err != nil {
+				return nil, s.releaseLapsedTransaction(payment.ID, result, err)
+			}
+			return s.paymentRepo.GetPaymentByID(payment.ID)
+		}
+
+		if err := s.paymentRepo.AuthorizePayment(payment.ID, result.TransactionID); err != nil {
+			return nil, s.releaseLapsedTransaction(payment.ID, result, err)
+		}
+		transactionID = result.TransactionID
+
+		if result.Status == gateway.StatusCaptured {
+			return s.confirmPayment(payment.ID)
+		}
+	}
+
+	return s.capturePayment(payment, transactionID)
+}
+
+// capturePayment captures an authorized payment and, once captured, completes it and confirms its reservations
+func (s *PaymentService) capturePayment(payment *model.Payment, transactionID string) (*model.Payment, error) {
+	result, err := s.gateway.Capture(transactionID, payment.Amount, payment.Currency)
 	if err != nil {
-		fmt.Printf("Failed to create calendar event for reservation %d: %v\n", reservation.ID, err)
-		return
+		return nil, fmt.Errorf("failed to capture payment: %w", err)
+	}
+	switch result.Status {
+	case gateway.StatusDeclined:
+		return nil, s.declinePayment(payment.ID, result)
+	case gateway.StatusCaptured:
+		return s.confirmPayment(payment.ID)
+	}
+
+	return s.paymentRepo.GetPaymentByID(payment.ID)
+}
+
+// confirmPayment completes a captured payment and confirms its reservations. A payment that failed
+// meanwhile, because its reservations expired or were cancelled, is refunded and ErrPaymentLapsed returned.
+func (s *PaymentService) confirmPayment(paymentID int64) (*model.Payment, error) {
+	payment, err := s.paymentRepo.ConfirmPayment(paymentID)
+	if !errors.Is(err, ErrPaymentLapsed) {
+		return payment, err
+	}
+
+	if err := s.refundLapsedCapture(paymentID); err != nil {
+		return nil, err
+	}
+	return nil, ErrPaymentLapsed
+}
+
+// refundLapsedCapture refunds in full a failed payment the gateway captured anyway
+func (s *PaymentService) refundLapsedCapture(paymentID int64) error {
+	payment, err := s.paymentRepo.GetPaymentByID(paymentID)
+	if err != nil || payment == nil {
+		return fmt.Errorf("failed to get payment %d: %v", paymentID, err)
+	}
+
+	if _, err := s.refundService.RefundLapsedCapture(payment); err != nil {
+		log.Printf("[PAYMENT] %v", err)
+		return err
+	}
+	return nil
+}
+
+// releaseLapsedTransaction gives back the funds of a transaction the gateway authorized, captured or left
+// pending for a payment that could not record it, having failed meanwhile because its reservation expired or
+// was cancelled. It returns the error describing why the payment did not go through.
+func (s *PaymentService) releaseLapsedTransaction(paymentID int64, result *gateway.Result, cause error) error {
+	if err := s.paymentRepo.SetLapsedTransaction(paymentID, result.TransactionID); err != nil {
+		// The payment did not fail; the transaction could not be recorded for another reason
+		log.Printf("[PAYMENT] Failed to record transaction %s of payment %d: %v", result.TransactionID, paymentID, err)
+		return cause
+	}
+
+	if result.Status == gateway.StatusCaptured {
+		if err := s.refundLapsedCapture(paymentID); err != nil {
+			return err
+		}
+		return ErrPaymentLapsed
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByID(paymentID)
+	if err != nil || payment == nil {
+		log.Printf("[PAYMENT] Failed to get payment %d: %v", paymentID, err)
+		return ErrPaymentLapsed
+	}
+	s.refundService.ReleaseAuthorization(payment)
+	return ErrPaymentLapsed
+}
+
+// newPaymentAttemptID generates the ID of a card payment attempt, sent to the gateway as its idempotency key
+func newPaymentAttemptID() (string, error) {
+	attemptBytes := make([]byte, 16)
+	if _, err := rand.Read(attemptBytes); err != nil {
+		return "", errors.New("failed to generate payment attempt ID")
+	}
+	return hex.EncodeToString(attemptBytes), nil
+}
+
+// declinePayment records a declined gateway operation and returns the error describing it
+func (s *PaymentService) declinePayment(paymentID int64, result *gateway.Result) error {
+	reason := result.FailureReason
+	if reason == "" {
+		reason = "declined by the payment gateway"
+	}
+	if err := s.paymentRepo.FailPayment(paymentID, result.TransactionID, reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrPaymentDeclined, reason)
+}
+
+// HandleWebhook verifies a webhook from the payment gateway named provider, stores it and applies it
+// unless an earlier delivery of the same event already was. Errors other than ErrUnknownGateway and
+// ErrInvalidWebhookSignature mean the event could not be applied and the gateway should send it again.
+func (s *PaymentService) HandleWebhook(provider string, payload []byte, header http.Header) error {
+	if provider != s.gateway.Name() {
+		return ErrUnknownGateway
 	}
 
-	fmt.Printf("Calendar event created with ID: %s for reservation %d\n", eventID, reservation.ID)
+	verified, err := s.gateway.VerifyWebhook(payload, header)
+	if err != nil {
+		return err
+	}
+
+	event := &model.PaymentWebhookEvent{
+		Provider:  provider,
+		EventID:   verified.ID,
+		EventType: verified.Type,
+		Payload:   payload,
+	}
+	if verified.TransactionID != "" {
+		event.TransactionID = &verified.TransactionID
+	}
+	if verified.Status != "" {
+		event.EventStatus = &verified.Status
+		event.Amount = &verified.Amount
+	}
+	if verified.FailureReason != "" {
+		event.FailureReason = &verified.FailureReason
+	}
+
+	event, err = s.webhookRepo.SaveEvent(event)
+	if err != nil {
+		return fmt.Errorf("failed to store webhook event: %w", err)
+	}
+
+	if event.Status == model.WebhookProcessed || event.Status == model.WebhookIgnored {
+		// A repeated delivery of an event that was already applied
+		return nil
+	}
+
+	return s.applyWebhookEvent(event)
+}
+
+// ListWebhookEvents returns the latest stored webhook events, optionally only those with the given status
+func (s *PaymentService) ListWebhookEvents(status string) ([]model.PaymentWebhookEvent, error) {
+	switch status {
+	case "", model.WebhookReceived, model.WebhookProcessed, model.WebhookIgnored, model.WebhookFailed:
+	default:
+		return nil, fmt.Errorf("invalid status. Must be 'received', 'processed', 'ignored' or 'failed'")
+	}
+
+	return s.webhookRepo.ListEvents(status, webhookEventListLimit)
+}
+
+// ReplayWebhookEvent applies a stored webhook event again, e.g. one that failed. Changes the event
+// already made to its payment are not repeated. Returns the event with its new outcome.
+func (s *PaymentService) ReplayWebhookEvent(eventID int64) (*model.PaymentWebhookEvent, error) {
+	event, err := s.webhookRepo.GetEvent(eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook event: %w", err)
+	}
+	if event == nil {
+		return nil, ErrWebhookEventNotFound
+	}
+	if event.Provider != s.gateway.Name() {
+		return nil, ErrUnknownGateway
+	}
 
-	// Update reservation with calendar event ID
-	updateErr := s.reservationRepo.UpdateReservationCalendarEventID(reservation.ID, eventID)
-	if updateErr != nil {
-		fmt.Printf("Failed to update reservation with calendar event ID: %v\n", updateErr)
+	if err := s.applyWebhookEvent(event); err != nil {
+		log.Printf("[PAYMENT] Replaying webhook event %d failed: %v", event.ID, err)
 	}
+
+	return s.webhookRepo.GetEvent(eventID)
+}
+
+// applyWebhookEvent applies a stored event to its payment and records the outcome on the event
+func (s *PaymentService) applyWebhookEvent(event *model.PaymentWebhookEvent) error {
+	status, message, err := s.applyGatewayOutcome(event)
+	if err != nil {
+		failure := err.Error()
+		if finishErr := s.webhookRepo.FinishEvent(event.ID, model.WebhookFailed, &failure); finishErr != nil {
+			log.Printf("[PAYMENT] Failed to record failure of webhook event %d: %v", event.ID, finishErr)
+		}
+		return err
+	}
+
+	return s.webhookRepo.FinishEvent(event.ID, status, message)
+}
+
+// applyGatewayOutcome moves the payment an event concerns to the state the event reports, capturing an
+// authorization and confirming the reservations of a captured payment. Funds authorized or captured for a
+// payment that failed meanwhile are released or refunded. It returns WebhookIgnored with the reason when
+// there is nothing to change, e.g. because an earlier event or request already did.
+func (s *PaymentService) applyGatewayOutcome(event *model.PaymentWebhookEvent) (string, *string, error) {
+	ignore := func(reason string) (string, *string, error) {
+		return model.WebhookIgnored, &reason, nil
+	}
+
+	if event.TransactionID == nil || event.EventStatus == nil {
+		return ignore(fmt.Sprintf("%s events are not handled", event.EventType))
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByTransaction(event.Provider, *event.TransactionID)
+	if err != nil {
+		return "", nil, err
+	}
+	if payment == nil {
+		// The transaction may pay the balance of a booking moved to a dearer time
+		charge, err := s.paymentRepo.GetBalanceChargeByTransaction(event.Provider, *event.TransactionID)
+		if err != nil {
+			return "", nil, err
+		}
+		if charge != nil {
+			return s.applyBalanceChargeOutcome(event, charge)
+		}
+		if s.splitPaymentService != nil {
+			// The transaction may be a participant's share of a split payment
+			return s.splitPaymentService.applyShareOutcome(event)
+		}
+		return ignore("no payment has this transaction")
+	}
+
+	switch *event.EventStatus {
+	case gateway.StatusAuthorized:
+		if payment.PaymentStatus == "failed" {
+			// Authorized after the reservation lapsed; nothing will capture it
+			s.refundService.ReleaseAuthorization(payment)
+			return model.WebhookProcessed, nil, nil
+		}
+		if payment.PaymentStatus != "pending" {
+			return ignore("payment is already " + payment.PaymentStatus)
+		}
+		if err := s.paymentRepo.AuthorizePayment(payment.ID, *event.TransactionID); err != nil {
+			return "", nil, err
+		}
+		payment, err = s.capturePayment(payment, *event.TransactionID)
+		if errors.Is(err, ErrPaymentDeclined) || errors.Is(err, ErrPaymentLapsed) {
+			return model.WebhookProcessed, nil, nil
+		}
+	case gateway.StatusCaptured:
+		if payment.PaymentStatus == "failed" {
+			// Captured after the reservation lapsed; the customer gets the money back
+			return model.WebhookProcessed, nil, s.refundLapsedCapture(payment.ID)
+		}
+		if payment.PaymentStatus != "pending" && payment.PaymentStatus != "authorized" {
+			return ignore("payment is already " + payment.PaymentStatus)
+		}
+		payment, err = s.confirmPayment(payment.ID)
+		if errors.Is(err, ErrPaymentLapsed) {
+			return model.WebhookProcessed, nil, nil
+		}
+	case gateway.StatusDeclined:
+		if payment.PaymentStatus != "pending" && payment.PaymentStatus != "authorized" {
+			return ignore("payment is already " + payment.PaymentStatus)
+		}
+		reason := "declined by the payment gateway"
+		if event.FailureReason != nil {
+			reason = *event.FailureReason
+		}
+		return model.WebhookProcessed, nil, s.paymentRepo.FailPayment(payment.ID, "", reason)
+	default:
+		return ignore(fmt.Sprintf("%s events are not handled", event.EventType))
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if payment.PaymentStatus == "completed" {
+		reservations, err := s.paidReservations(payment)
+		if err != nil {
+			log.Printf("[PAYMENT] Failed to get reservations of payment %d: %v", payment.ID, err)
+		} else {
+			s.notifyPaid(payment, reservations)
+		}
+	}
+
+	return model.WebhookProcessed, nil, nil
+}
+
+// paidReservations returns the confirmed reservations a completed payment covers
+func (s *PaymentService) paidReservations(payment *model.Payment) ([]*model.FacilityReservation, error) {
+	reservationIDs, err := s.paymentRepo.GetPaymentReservationIDs(payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(reservationIDs) == 0 {
+		reservationIDs = []int64{payment.ReservationID}
+	}
+
+	var reservations []*model.FacilityReservation
+	for _, id := range reservationIDs {
+		reservation, err := s.reservationRepo.GetReservationByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if reservation.Status == "confirmed" {
+			reservations = append(reservations, reservation)
+		}
+	}
+
+	return reservations, nil
+}
+
+// getPaymentReservations returns the reservations a payment confirms: just the given one, or for a
+// basket payment every covered reservation that has not been cancelled. It fails if any of them has
+// lapsed, since the basket is paid as a whole.
+func (s *PaymentService) getPaymentReservations(payment *model.Payment, reservation *model.FacilityReservation) ([]*model.FacilityReservation, error) {
+	reservationIDs, err := s.paymentRepo.GetPaymentReservationIDs(payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(reservationIDs) == 0 {
+		return []*model.FacilityReservation{reservation}, nil
+	}
+
+	reservations := make([]*model.FacilityReservation, 0, len(reservationIDs))
+	for _, id := range reservationIDs {
+		covered := reservation
+		if id != reservation.ID {
+			covered, err = s.reservationRepo.GetReservationByID(id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get reservation: %w", err)
+			}
+		}
+
+		if covered.Status == "cancelled" {
+			continue
+		}
+		if covered.Status == "expired" ||
+			(covered.Status == "pending" && covered.ExpiresAt != nil && !covered.ExpiresAt.After(time.Now())) {
+			return nil, fmt.Errorf("reservation has expired, please book the slot again")
+		}
+		reservations = append(reservations, covered)
+	}
+
+	return reservations, nil
 }
 
 // sendPaymentConfirmationEmail sends a confirmation email after successful payment
-func (s *PaymentService) sendPaymentConfirmationEmail(reservation *model.FacilityReservation, payment *model.Payment) {
+func (s *PaymentService) sendPaymentConfirmationEmail(reservation *model.FacilityReservation, payment *model.Payment, amount float64) {
 	// Get user details
 	user, err := s.userService.GetUserByID(reservation.UserID)
 	if err != nil {
@@ -186,7 +781,14 @@ func (s *PaymentService) sendPaymentConfirmationEmail(reservation *model.Facilit
 		return
 	}
 
-	// Send email
+	// Get price breakdown
+	priceItems, err := s.reservationRepo.GetReservationPriceItems(reservation.ID)
+	if err != nil {
+		fmt.Printf("Failed to get price breakdown for reservation %d: %v\n", reservation.ID, err)
+	}
+
+	// Send email, with times in the facility's time zone
+	loc := loadLocation(facility.TimeZone)
 	err = s.emailService.SendPaymentConfirmationEmail(
 		user.Email,
 		user.Name,
@@ -195,10 +797,12 @@ func (s *PaymentService) sendPaymentConfirmationEmail(reservation *model.Facilit
 		facility.City,
 		facility.CategoryName,
 		facility.SportName,
-		reservation.StartTime,
-		reservation.EndTime,
-		payment.Amount,
+		reservation.StartTime.In(loc),
+		reservation.EndTime.In(loc),
+		amount,
 		payment.PaymentMethod,
+		priceItems,
+		reservationInvite(reservation, facility),
 	)
 
 	if err != nil {
@@ -0,0 +1,442 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+	"github.com/Radi03825/PlaySpot/internal/service/calendar"
+	"github.com/Radi03825/PlaySpot/internal/service/gateway"
+	"github.com/Radi03825/PlaySpot/internal/testutil"
+)
+
+// flakyGateway fails the next captureFailures captures, then behaves like the simulated gateway
+type flakyGateway struct {
+	*gateway.SimulatedGateway
+	captureFailures int
+}
+
+func (g *flakyGateway) Capture(transactionID string, amount float64, currency string) (*gateway.Result, error) {
+	if g.captureFailures > 0 {
+		g.captureFailures--
+		return nil, errors.New("gateway timed out")
+	}
+	return g.SimulatedGateway.Capture(transactionID, amount, currency)
+}
+
+// paymentFixture is a pending reservation of 40 EUR, held for 15 minutes, paid through a simulated gateway
+type paymentFixture struct {
+	db            *sql.DB
+	service       *PaymentService
+	gateway       *flakyGateway
+	payments      *repository.PaymentRepository
+	reservations  *repository.ReservationRepository
+	userID        int64
+	reservationID int64
+}
+
+func newPaymentFixture(t *testing.T, mode string) *paymentFixture {
+	t.Helper()
+
+	db := testutil.OpenDB(t)
+	userID, facilityID := testutil.CreateFacility(t, db)
+
+	reservationID := testutil.CreateReservation(t, db, userID, facilityID, "pending", 40)
+	if _, err := db.Exec(`UPDATE facility_reservations SET expires_at = NOW() + INTERVAL '15 minutes' WHERE id = $1`, reservationID); err != nil {
+		t.Fatalf("failed to hold reservation: %v", err)
+	}
+
+	simulated, err := gateway.NewSimulatedGateway(mode, "")
+	if err != nil {
+		t.Fatalf("failed to create simulated gateway: %v", err)
+	}
+	paymentGateway := &flakyGateway{SimulatedGateway: simulated}
+
+	paymentRepo := repository.NewPaymentRepository(db)
+	reservationRepo := repository.NewReservationRepository(db)
+	shareRepo := repository.NewPaymentShareRepository(db)
+	emailService := NewEmailService()
+	userService := NewUserService(repository.NewUserRepository(db), nil, emailService)
+	facilityService := NewFacilityService(repository.NewFacilityRepository(db), userService, nil)
+	calendarSyncService := NewCalendarSyncService(repository.NewCalendarSyncRepository(db), reservationRepo, userService, facilityService,
+		map[string]calendar.CalendarProvider{}, nil)
+	refundService := NewRefundService(repository.NewRefundRepository(db), paymentRepo, shareRepo, reservationRepo, facilityService, userService, emailService, paymentGateway)
+
+	return &paymentFixture{
+		db: db,
+		service: NewPaymentService(paymentRepo, repository.NewPaymentWebhookRepository(db), reservationRepo, facilityService,
+			emailService, calendarSyncService, userService, refundService, paymentGateway),
+		gateway:       paymentGateway,
+		payments:      paymentRepo,
+		reservations:  reservationRepo,
+		userID:        userID,
+		reservationID: reservationID,
+	}
+}
+
+func (f *paymentFixture) pay(token string) (*model.Payment, error) {
+	return f.service.ProcessPayment(f.reservationID, f.userID, dto.ProcessPaymentDTO{PaymentMethod: "card", PaymentToken: token})
+}
+
+// state returns the payment and reservation as stored
+func (f *paymentFixture) state(t *testing.T) (*model.Payment, *model.FacilityReservation) {
+	t.Helper()
+	payment, err := f.payments.GetPaymentByReservationID(f.reservationID)
+	if err != nil || payment == nil {
+		t.Fatalf("failed to get payment: %v", err)
+	}
+	reservation, err := f.reservations.GetReservationByID(f.reservationID)
+	if err != nil {
+		t.Fatalf("failed to get reservation: %v", err)
+	}
+	return payment, reservation
+}
+
+func (f *paymentFixture) expectState(t *testing.T, paymentStatus, reservationStatus string) (*model.Payment, *model.FacilityReservation) {
+	t.Helper()
+	payment, reservation := f.state(t)
+	if payment.PaymentStatus != paymentStatus || reservation.Status != reservationStatus {
+		t.Fatalf("payment %s with reservation %s, want payment %s with reservation %s",
+			payment.PaymentStatus, reservation.Status, paymentStatus, reservationStatus)
+	}
+	return payment, reservation
+}
+
+// webhookStatus returns the outcome recorded for a delivered webhook event
+func (f *paymentFixture) webhookStatus(t *testing.T, transactionID string) string {
+	t.Helper()
+	var status string
+	err := f.db.QueryRow(`SELECT status FROM payment_webhook_events WHERE transaction_id = $1 ORDER BY id DESC LIMIT 1`, transactionID).Scan(&status)
+	if err != nil {
+		t.Fatalf("failed to get webhook event: %v", err)
+	}
+	return status
+}
+
+func TestCardPaymentWithSimulatedGateway(t *testing.T) {
+	tests := []struct {
+		mode              string
+		wantErr           error
+		paymentStatus     string
+		reservationStatus string
+	}{
+		{mode: gateway.SimulateSucceed, paymentStatus: "completed", reservationStatus: "confirmed"},
+		{mode: gateway.SimulateDecline, wantErr: ErrPaymentDeclined, paymentStatus: "failed", reservationStatus: "pending"},
+		{mode: gateway.SimulateAsync, paymentStatus: "pending", reservationStatus: "pending"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f := newPaymentFixture(t, tt.mode)
+
+			_, err := f.pay("")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ProcessPayment error = %v, want %v", err, tt.wantErr)
+			}
+
+			payment, reservation := f.expectState(t, tt.paymentStatus, tt.reservationStatus)
+			if payment.GatewayTransactionID == nil || payment.Gateway == nil || *payment.Gateway != "simulated" {
+				t.Errorf("payment did not record its simulated transaction")
+			}
+
+			switch tt.mode {
+			case gateway.SimulateSucceed:
+				if payment.PaidAt == nil || payment.AuthorizedAt == nil {
+					t.Errorf("completed payment is missing its authorization or payment time")
+				}
+			case gateway.SimulateDecline:
+				if payment.FailureReason == nil {
+					t.Errorf("declined payment has no failure reason")
+				}
+			case gateway.SimulateAsync:
+				// The hold is extended while the gateway settles the payment
+				if reservation.ExpiresAt == nil || reservation.ExpiresAt.Before(time.Now().Add(asyncPaymentHold-time.Minute)) {
+					t.Errorf("reservation hold until %v was not extended for the async payment", reservation.ExpiresAt)
+				}
+				if _, err := f.pay(""); err != nil {
+					t.Errorf("paying again while the gateway settles the payment: %v", err)
+				}
+				if again, _ := f.state(t); *again.GatewayTransactionID != *payment.GatewayTransactionID {
+					t.Errorf("paying again started a second authorization")
+				}
+			}
+		})
+	}
+}
+
+func TestCardPaymentRetriedAfterDecline(t *testing.T) {
+	f := newPaymentFixture(t, gateway.SimulateSucceed)
+
+	if _, err := f.pay("tok_decline"); !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("ProcessPayment error = %v, want %v", err, ErrPaymentDeclined)
+	}
+	f.expectState(t, "failed", "pending")
+
+	if _, err := f.pay("tok_succeed"); err != nil {
+		t.Fatalf("retrying with another card: %v", err)
+	}
+	f.expectState(t, "completed", "confirmed")
+}
+
+func TestOnSitePayment(t *testing.T) {
+	payOnSite := func(f *paymentFixture) error {
+		_, err := f.service.ProcessPayment(f.reservationID, f.userID, dto.ProcessPaymentDTO{PaymentMethod: "on_place"})
+		return err
+	}
+
+	t.Run("while the gateway settles the card", func(t *testing.T) {
+		f := newPaymentFixture(t, gateway.SimulateAsync)
+		if _, err := f.pay(""); err != nil {
+			t.Fatalf("ProcessPayment: %v", err)
+		}
+
+		if err := payOnSite(f); !errors.Is(err, ErrPaymentInProgress) {
+			t.Fatalf("paying on site error = %v, want %v", err, ErrPaymentInProgress)
+		}
+		f.expectState(t, "pending", "pending")
+	})
+
+	t.Run("after a declined card", func(t *testing.T) {
+		f := newPaymentFixture(t, gateway.SimulateSucceed)
+		if _, err := f.pay("tok_decline"); !errors.Is(err, ErrPaymentDeclined) {
+			t.Fatalf("ProcessPayment error = %v, want %v", err, ErrPaymentDeclined)
+		}
+
+		if err := payOnSite(f); err != nil {
+			t.Fatalf("paying on site: %v", err)
+		}
+		payment, _ := f.expectState(t, "completed", "confirmed")
+		if payment.PaymentMethod != "on_place" || payment.Gateway != nil || payment.GatewayTransactionID != nil || payment.FailureReason != nil {
+			t.Errorf("on-site payment kept the declined card attempt: %+v", payment)
+		}
+	})
+
+	t.Run("after the hold lapsed", func(t *testing.T) {
+		f := newPaymentFixture(t, gateway.SimulateSucceed)
+		payment, err := f.payments.CreatePayment(f.userID, f.reservationID, 40, "EUR", nil)
+		if err != nil {
+			t.Fatalf("failed to create payment: %v", err)
+		}
+		if _, err := f.db.Exec(`UPDATE facility_reservations SET expires_at = NOW() - INTERVAL '1 second' WHERE id = $1`, f.reservationID); err != nil {
+			t.Fatalf("failed to lapse reservation hold: %v", err)
+		}
+
+		// Neither the payment nor the reservation is confirmed
+		if _, err := f.payments.ProcessPayment(payment.ID, "on_place"); !errors.Is(err, ErrPaymentLapsed) {
+			t.Fatalf("paying on site error = %v, want %v", err, ErrPaymentLapsed)
+		}
+		f.expectState(t, "pending", "pending")
+	})
+}
+
+func TestSplitRefusedWhileCardPaymentInProgress(t *testing.T) {
+	f := newPaymentFixture(t, gateway.SimulateAsync)
+	if _, err := f.pay(""); err != nil {
+		t.Fatalf("ProcessPayment: %v", err)
+	}
+	pending, _ := f.expectState(t, "pending", "pending")
+
+	shareRepo := repository.NewPaymentShareRepository(f.db)
+	if _, err := shareRepo.SplitPayment(pending.ID, f.reservationID, 0, []int64{f.userID}); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("SplitPayment error = %v, want %v", err, ErrPaymentInProgress)
+	}
+
+	// The card attempt is left for the gateway to settle
+	payment, _ := f.expectState(t, "pending", "pending")
+	if payment.PaymentMethod != "card" || payment.GatewayTransactionID == nil || *payment.GatewayTransactionID != *pending.GatewayTransactionID {
+		t.Errorf("splitting touched the card attempt in progress: %+v", payment)
+	}
+}
+
+func TestCardPaymentCaptureRetried(t *testing.T) {
+	f := newPaymentFixture(t, gateway.SimulateSucceed)
+	f.gateway.captureFailures = 1
+
+	if _, err := f.pay(""); err == nil {
+		t.Fatal("ProcessPayment succeeded although the capture failed")
+	}
+	authorized, _ := f.expectState(t, "authorized", "pending")
+
+	// Paying again only captures the existing authorization
+	if _, err := f.pay(""); err != nil {
+		t.Fatalf("retrying the capture: %v", err)
+	}
+	completed, _ := f.expectState(t, "completed", "confirmed")
+	if *completed.GatewayTransactionID != *authorized.GatewayTransactionID {
+		t.Errorf("retry authorized a new transaction %s instead of capturing %s", *completed.GatewayTransactionID, *authorized.GatewayTransactionID)
+	}
+}
+
+func TestAsyncCardPaymentSettledByWebhook(t *testing.T) {
+	tests := []struct {
+		name              string
+		approve           bool
+		paymentStatus     string
+		reservationStatus string
+	}{
+		{name: "approved", approve: true, paymentStatus: "completed", reservationStatus: "confirmed"},
+		{name: "declined", approve: false, paymentStatus: "failed", reservationStatus: "pending"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPaymentFixture(t, gateway.SimulateAsync)
+
+			if _, err := f.pay(""); err != nil {
+				t.Fatalf("ProcessPayment: %v", err)
+			}
+			pending, _ := f.expectState(t, "pending", "pending")
+			transactionID := *pending.GatewayTransactionID
+
+			payload, header, err := f.gateway.SimulateWebhook(transactionID, tt.approve)
+			if err != nil {
+				t.Fatalf("SimulateWebhook: %v", err)
+			}
+
+			// A webhook that was tampered with is rejected
+			forged := header.Clone()
+			forged.Set("X-Simulated-Signature", "t=1,v1=00")
+			if err := f.service.HandleWebhook("simulated", payload, forged); !errors.Is(err, ErrInvalidWebhookSignature) {
+				t.Errorf("forged webhook error = %v, want %v", err, ErrInvalidWebhookSignature)
+			}
+			f.expectState(t, "pending", "pending")
+
+			if err := f.service.HandleWebhook("simulated", payload, header); err != nil {
+				t.Fatalf("HandleWebhook: %v", err)
+			}
+			settled, _ := f.expectState(t, tt.paymentStatus, tt.reservationStatus)
+			if status := f.webhookStatus(t, transactionID); status != model.WebhookProcessed {
+				t.Errorf("webhook event %s, want %s", status, model.WebhookProcessed)
+			}
+
+			// A repeated delivery changes nothing
+			if err := f.service.HandleWebhook("simulated", payload, header); err != nil {
+				t.Fatalf("repeated HandleWebhook: %v", err)
+			}
+			if again, _ := f.expectState(t, tt.paymentStatus, tt.reservationStatus); again.RefundedAmount != settled.RefundedAmount {
+				t.Errorf("repeated delivery changed the payment")
+			}
+		})
+	}
+}
+
+func TestAsyncCardPaymentApprovedAfterHoldLapsed(t *testing.T) {
+	f := newPaymentFixture(t, gateway.SimulateAsync)
+
+	if _, err := f.pay(""); err != nil {
+		t.Fatalf("ProcessPayment: %v", err)
+	}
+	pending, _ := f.expectState(t, "pending", "pending")
+
+	if _, err := f.db.Exec(`UPDATE facility_reservations SET expires_at = NOW() - INTERVAL '1 second' WHERE id = $1`, f.reservationID); err != nil {
+		t.Fatalf("failed to lapse reservation hold: %v", err)
+	}
+
+	payload, header, err := f.gateway.SimulateWebhook(*pending.GatewayTransactionID, true)
+	if err != nil {
+		t.Fatalf("SimulateWebhook: %v", err)
+	}
+	if err := f.service.HandleWebhook("simulated", payload, header); err != nil {
+		t.Fatalf("HandleWebhook: %v", err)
+	}
+
+	// The captured amount goes back to the customer instead of confirming a lapsed booking
+	payment, reservation := f.state(t)
+	if payment.PaymentStatus != "failed" || reservation.Status == "confirmed" {
+		t.Fatalf("payment %s with reservation %s, want a failed payment and an unconfirmed reservation", payment.PaymentStatus, reservation.Status)
+	}
+	if payment.RefundedAmount != payment.Amount {
+		t.Errorf("refunded %.2f of %.2f", payment.RefundedAmount, payment.Amount)
+	}
+
+	refunds, err := f.service.refundService.GetPaymentRefunds(payment.ID)
+	if err != nil {
+		t.Fatalf("failed to get refunds: %v", err)
+	}
+	if len(refunds) != 1 || refunds[0].Status != model.RefundSucceeded || refunds[0].Initiator != model.RefundInitiatorSystem {
+		t.Errorf("refunds = %+v, want one succeeded system refund", refunds)
+	}
+}
+
+// move reschedules the fixture's reservation by a day at a new price and returns its payment afterwards
+func (f *paymentFixture) move(t *testing.T, totalPrice float64) (*model.Payment, error) {
+	t.Helper()
+	_, reservation := f.state(t)
+	_, payment, err := f.reservations.RescheduleReservation(f.reservationID, reservation.StartTime.Add(24*time.Hour),
+		reservation.EndTime.Add(24*time.Hour), 0, totalPrice, nil)
+	return payment, err
+}
+
+func TestRescheduledBalancePaidAndRefunded(t *testing.T) {
+	f := newPaymentFixture(t, gateway.SimulateSucceed)
+
+	if _, err := f.pay(""); err != nil {
+		t.Fatalf("ProcessPayment: %v", err)
+	}
+	f.expectState(t, "completed", "confirmed")
+
+	// A dearer time leaves the difference to pay
+	payment, err := f.move(t, 60)
+	if err != nil {
+		t.Fatalf("moving to a dearer time: %v", err)
+	}
+	if payment.Amount != 40 || payment.BalanceDue != 20 {
+		t.Fatalf("payment of %.2f with %.2f due, want 40.00 with 20.00 due", payment.Amount, payment.BalanceDue)
+	}
+
+	charge, err := f.service.PayBalance(f.reservationID, f.userID, dto.PayBalanceDTO{})
+	if err != nil {
+		t.Fatalf("PayBalance: %v", err)
+	}
+	if charge.Status != model.BalanceChargePaid || charge.Amount != 20 {
+		t.Errorf("balance charge %s of %.2f, want paid 20.00", charge.Status, charge.Amount)
+	}
+	if payment, _ := f.expectState(t, "completed", "confirmed"); payment.Amount != 60 || payment.BalanceDue != 0 {
+		t.Errorf("payment of %.2f with %.2f due after paying the balance, want 60.00 with nothing due", payment.Amount, payment.BalanceDue)
+	}
+	if _, err := f.service.PayBalance(f.reservationID, f.userID, dto.PayBalanceDTO{}); !errors.Is(err, ErrNoBalanceDue) {
+		t.Errorf("paying again error = %v, want %v", err, ErrNoBalanceDue)
+	}
+
+	// A cheaper time is refunded the difference, through the balance charge first
+	payment, err = f.move(t, 30)
+	if err != nil {
+		t.Fatalf("moving to a cheaper time: %v", err)
+	}
+	f.service.refundService.ProcessPaymentRefunds(payment)
+
+	payment, _ = f.expectState(t, "partially_refunded", "confirmed")
+	if payment.RefundedAmount != 30 || payment.BalanceDue != 0 {
+		t.Errorf("refunded %.2f with %.2f due, want 30.00 refunded with nothing due", payment.RefundedAmount, payment.BalanceDue)
+	}
+	refunds, err := f.service.refundService.GetPaymentRefunds(payment.ID)
+	if err != nil {
+		t.Fatalf("failed to get refunds: %v", err)
+	}
+	if len(refunds) != 1 || refunds[0].Status != model.RefundSucceeded || refunds[0].Initiator != model.RefundInitiatorSystem {
+		t.Errorf("refunds = %+v, want one succeeded system refund", refunds)
+	}
+	if charge, _ := f.payments.GetBalanceCharge(charge.ID); charge.RefundedAmount != 20 {
+		t.Errorf("balance charge refunded %.2f, want 20.00", charge.RefundedAmount)
+	}
+}
+
+func TestRescheduleRejectedWhilePaymentInProgress(t *testing.T) {
+	f := newPaymentFixture(t, gateway.SimulateAsync)
+
+	if _, err := f.pay(""); err != nil {
+		t.Fatalf("ProcessPayment: %v", err)
+	}
+	f.expectState(t, "pending", "pending")
+
+	if _, err := f.move(t, 60); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("moving during the payment error = %v, want %v", err, ErrPaymentInProgress)
+	}
+	if payment, _ := f.state(t); payment.Amount != 40 {
+		t.Errorf("payment amount changed to %.2f during the payment", payment.Amount)
+	}
+}
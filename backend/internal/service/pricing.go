@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// ErrUnpricedInterval is returned when part of a requested booking is not covered by any price band
+var ErrUnpricedInterval = errors.New("no price is defined for part of the selected time")
+
+// priceInterval splits [startTime, endTime) across the facility's price bands, special prices
+// and day boundaries, and returns one item per segment together with the total price.
+// Consecutive segments charged at the same rate are merged into a single item.
+func priceInterval(startTime, endTime time.Time, schedules []model.FacilitySchedule, pricings []model.FacilityPricing, exceptions []model.ScheduleException) ([]model.PriceItem, float64, error) {
+	var items []model.PriceItem
+	plans := make(map[string]dayPlan)
+
+	for cursor := startTime; cursor.Before(endTime); {
+		day := time.Date(cursor.Year(), cursor.Month(), cursor.Day(), 0, 0, 0, 0, cursor.Location())
+		nextDay := day.AddDate(0, 0, 1)
+
+		plan, ok := plans[day.Format("2006-01-02")]
+		if !ok {
+			var err error
+			plan, err = resolveDayPlan(day, schedules, exceptions)
+			if err != nil {
+				return nil, 0, err
+			}
+			plans[day.Format("2006-01-02")] = plan
+		}
+
+		segmentEnd := minTime(endTime, nextDay)
+
+		price, bandEnd, found := specialPriceSegment(cursor, plan)
+		if !found {
+			price, bandEnd, found = bandPriceSegment(cursor, day, pricings)
+		}
+		if !found {
+			return nil, 0, fmt.Errorf("%w (%s %s)", ErrUnpricedInterval, dayTypeFor(day), cursor.Format("15:04"))
+		}
+		segmentEnd = minTime(segmentEnd, bandEnd)
+
+		// A special price starting later in the segment takes over from that point
+		for _, sp := range plan.specialPrices {
+			if sp.start.After(cursor) && sp.start.Before(segmentEnd) {
+				segmentEnd = sp.start
+			}
+		}
+
+		amount := roundCents(price * segmentEnd.Sub(cursor).Hours())
+
+		if n := len(items); n > 0 && items[n-1].PricePerHour == price && items[n-1].EndTime.Equal(cursor) {
+			items[n-1].EndTime = segmentEnd
+			items[n-1].Amount = roundCents(items[n-1].Amount + amount)
+		} else {
+			items = append(items, model.PriceItem{
+				StartTime:    cursor,
+				EndTime:      segmentEnd,
+				PricePerHour: price,
+				Amount:       amount,
+			})
+		}
+
+		cursor = segmentEnd
+	}
+
+	var total float64
+	for _, item := range items {
+		total += item.Amount
+	}
+
+	return items, roundCents(total), nil
+}
+
+// specialPriceSegment returns the special price in effect at t and when it ends
+func specialPriceSegment(t time.Time, plan dayPlan) (float64, time.Time, bool) {
+	for _, sp := range plan.specialPrices {
+		if !t.Before(sp.start) && t.Before(sp.end) {
+			return sp.pricePerHour, sp.end, true
+		}
+	}
+	return 0, time.Time{}, false
+}
+
+// bandPriceSegment returns the regular price band in effect at t and when it ends.
+// A band ending at 00:00 or 24:00 runs until midnight.
+func bandPriceSegment(t, day time.Time, pricings []model.FacilityPricing) (float64, time.Time, bool) {
+	dayType := dayTypeFor(day)
+
+	for _, p := range pricings {
+		if p.DayType != dayType {
+			continue
+		}
+
+		bandStart, err := timeOnDate(day, p.StartHour)
+		if err != nil {
+			continue
+		}
+
+		var bandEnd time.Time
+		if p.EndHour == "24:00:00" || p.EndHour == "24:00" {
+			bandEnd = day.AddDate(0, 0, 1)
+		} else {
+			bandEnd, err = timeOnDate(day, p.EndHour)
+			if err != nil {
+				continue
+			}
+			if !bandEnd.After(bandStart) {
+				bandEnd = day.AddDate(0, 0, 1)
+			}
+		}
+
+		if !t.Before(bandStart) && t.Before(bandEnd) {
+			return p.PricePerHour, bandEnd, true
+		}
+	}
+
+	return 0, time.Time{}, false
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,432 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+	"github.com/Radi03825/PlaySpot/internal/service/gateway"
+)
+
+var (
+	ErrNotRefundable  = repository.ErrNotRefundable
+	ErrRefundTooLarge = repository.ErrRefundTooLarge
+)
+
+// maxRefundReasonLength limits the reason staff give for a refund
+const maxRefundReasonLength = 500
+
+// RefundService returns money from payments. Refunds of payments taken through a payment gateway are sent
+// back through it, and refunds of split payments through the shares participants paid; refunds of other
+// payments, such as those made on site, are returned outside PlaySpot and only recorded. A refund the
+// gateway refuses is marked failed and no longer counts as refunded.
+type RefundService struct {
+	refundRepo      *repository.RefundRepository
+	paymentRepo     *repository.PaymentRepository
+	shareRepo       *repository.PaymentShareRepository
+	reservationRepo *repository.ReservationRepository
+	facilityService *FacilityService
+	userService     *UserService
+	emailService    *EmailService
+	gateway         gateway.PaymentGateway
+}
+
+func NewRefundService(
+	refundRepo *repository.RefundRepository,
+	paymentRepo *repository.PaymentRepository,
+	shareRepo *repository.PaymentShareRepository,
+	reservationRepo *repository.ReservationRepository,
+	facilityService *FacilityService,
+	userService *UserService,
+	emailService *EmailService,
+	paymentGateway gateway.PaymentGateway,
+) *RefundService {
+	return &RefundService{
+		refundRepo:      refundRepo,
+		paymentRepo:     paymentRepo,
+		shareRepo:       shareRepo,
+		reservationRepo: reservationRepo,
+		facilityService: facilityService,
+		userService:     userService,
+		emailService:    emailService,
+		gateway:         paymentGateway,
+	}
+}
+
+// RefundReservation refunds part or all of the payment of a reservation on behalf of staff:
+// the manager of its facility, or an admin when initiator is RefundInitiatorAdmin.
+// The customer is emailed once the refund went through.
+func (s *RefundService) RefundReservation(reservationID, staffID int64, initiator string, req dto.CreateRefundDTO) (*model.Refund, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("a refund reason is required")
+	}
+	if len(reason) > maxRefundReasonLength {
+		return nil, fmt.Errorf("refund reason cannot be longer than %d characters", maxRefundReasonLength)
+	}
+	if req.Amount < 0 {
+		return nil, errors.New("refund amount cannot be negative")
+	}
+
+	reservation, err := s.reservationRepo.GetReservationByID(reservationID)
+	if err != nil {
+		return nil, errors.New("reservation not found")
+	}
+
+	if initiator != model.RefundInitiatorAdmin {
+		if err := checkFacilityManager(s.facilityService, reservation.FacilityID, staffID); err != nil {
+			return nil, err
+		}
+	}
+
+	refund, err := s.refundRepo.CreateRefund(reservationID, math.Round(req.Amount*100)/100, initiator, staffID, &reason)
+	if err != nil {
+		if errors.Is(err, ErrNotRefundable) || errors.Is(err, ErrRefundTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create refund: %w", err)
+	}
+
+	refund = s.processRefund(refund)
+	if refund.Status == model.RefundSucceeded {
+		s.sendRefundEmail(reservation, refund)
+	}
+
+	return refund, nil
+}
+
+// ProcessPaymentRefunds returns the pending refunds of a payment, such as one recorded by a cancellation,
+// or releases the authorization of a card payment the cancellation failed, and returns the payment as it
+// stands afterwards
+func (s *RefundService) ProcessPaymentRefunds(payment *model.Payment) *model.Payment {
+	s.ReleaseAuthorization(payment)
+
+	refunds, err := s.refundRepo.GetPendingRefunds(payment.ID)
+	if err != nil {
+		log.Printf("[REFUND] Failed to get pending refunds of payment %d: %v", payment.ID, err)
+		return payment
+	}
+	if len(refunds) == 0 {
+		return payment
+	}
+
+	for i := range refunds {
+		s.processRefund(&refunds[i])
+	}
+
+	updated, err := s.paymentRepo.GetPaymentByID(payment.ID)
+	if err != nil || updated == nil {
+		return payment
+	}
+	return updated
+}
+
+// ReleaseAuthorization voids at the gateway the authorization of a card payment that failed before it was
+// captured, because its reservation expired or was cancelled. Failures are only logged: should the gateway
+// capture the payment anyway, RefundLapsedCapture returns it when the capture is reported.
+func (s *RefundService) ReleaseAuthorization(payment *model.Payment) {
+	if payment.PaymentStatus != "failed" || payment.PaymentMethod == model.PaymentMethodSplit ||
+		payment.Gateway == nil || payment.GatewayTransactionID == nil {
+		return
+	}
+	if *payment.Gateway != s.gateway.Name() {
+		log.Printf("[REFUND] Cannot release payment %d: payment gateway %s is not in use", payment.ID, *payment.Gateway)
+		return
+	}
+
+	result, err := s.gateway.Void(*payment.GatewayTransactionID)
+	if err != nil {
+		log.Printf("[REFUND] Failed to release authorization of payment %d: %v", payment.ID, err)
+		return
+	}
+	if result.Status != gateway.StatusVoided {
+		log.Printf("[REFUND] Authorization of payment %d was not released: %s", payment.ID, result.FailureReason)
+	}
+}
+
+// RefundLapsedCapture refunds in full a failed payment that the gateway captured after its reservations
+// expired or were cancelled, and records the refund. It returns nil when the payment was already refunded.
+func (s *RefundService) RefundLapsedCapture(payment *model.Payment) (*model.Refund, error) {
+	refund, err := s.refundRepo.CreateLapsedRefund(payment.ID, "The payment completed after the reservation had lapsed")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refund: %w", err)
+	}
+	if refund == nil {
+		return nil, nil
+	}
+
+	refund = s.processRefund(refund)
+	if refund.Status != model.RefundSucceeded {
+		return refund, fmt.Errorf("failed to refund payment %d captured after its reservation lapsed", payment.ID)
+	}
+
+	log.Printf("[REFUND] Refunded %.2f of payment %d captured after its reservation lapsed", refund.Amount, payment.ID)
+	return refund, nil
+}
+
+// GetPaymentRefunds returns the refund history of a payment, oldest first
+func (s *RefundService) GetPaymentRefunds(paymentID int64) ([]model.Refund, error) {
+	return s.refundRepo.GetPaymentRefunds(paymentID)
+}
+
+// processRefund returns a pending refund through the payment's gateway, or records it as returned
+// when the payment did not go through one. It returns the refund with its outcome.
+func (s *RefundService) processRefund(refund *model.Refund) *model.Refund {
+	payment, err := s.paymentRepo.GetPaymentByID(refund.PaymentID)
+	if err != nil || payment == nil {
+		log.Printf("[REFUND] Failed to get payment %d of refund %d: %v", refund.PaymentID, refund.ID, err)
+		return refund
+	}
+
+	var gatewayRefundID *string
+	var returned float64
+	var failure string
+	if payment.PaymentMethod == model.PaymentMethodSplit {
+		gatewayRefundID, returned, failure = s.refundShares(payment, refund)
+	} else {
+		gatewayRefundID, returned, failure = s.refundPayment(payment, refund)
+	}
+
+	switch {
+	case failure != "" && returned > 0:
+		// Keep what did come back as refunded; the rest is recorded as a failed refund of its own
+		log.Printf("[REFUND] Refund %d of payment %d returned only %.2f: %s", refund.ID, payment.ID, returned, failure)
+		err = s.refundRepo.CompletePartialRefund(refund.ID, returned, gatewayRefundID, failure)
+	case failure != "":
+		log.Printf("[REFUND] Refund %d of payment %d failed: %s", refund.ID, payment.ID, failure)
+		err = s.refundRepo.FailRefund(refund.ID, failure)
+	default:
+		err = s.refundRepo.CompleteRefund(refund.ID, gatewayRefundID)
+	}
+	if err != nil {
+		log.Printf("[REFUND] Failed to record outcome of refund %d: %v", refund.ID, err)
+		return refund
+	}
+
+	updated, err := s.refundRepo.GetRefund(refund.ID)
+	if err != nil || updated == nil {
+		return refund
+	}
+	return updated
+}
+
+// refundPayment returns a refund of a payment that was not split: first from the balance charges paid after
+// its booking moved to a dearer time, newest first, then from the payment's own gateway transaction. The rest
+// of a payment settled without a gateway, such as on site, is recorded as returned outside PlaySpot.
+// It returns the comma-separated gateway refund IDs, the amount returned and, when part of the refund could
+// not be returned, the failure describing why.
+func (s *RefundService) refundPayment(payment *model.Payment, refund *model.Refund) (*string, float64, string) {
+	charges, err := s.paymentRepo.GetRefundableBalanceCharges(payment.ID)
+	if err != nil {
+		return nil, 0, fmt.Sprintf("failed to get balance charges: %v", err)
+	}
+
+	total := int64(math.Round(refund.Amount * 100))
+	var refundIDs, failures []string
+	var returned, assigned int64
+	for _, charge := range charges {
+		if assigned == total {
+			break
+		}
+		cents := min(int64(math.Round((charge.Amount-charge.RefundedAmount)*100)), total-assigned)
+		assigned += cents
+
+		if charge.GatewayTransactionID == nil || charge.Gateway != s.gateway.Name() {
+			failures = append(failures, fmt.Sprintf("balance charge %d was not paid through %s", charge.ID, s.gateway.Name()))
+			continue
+		}
+		part := float64(cents) / 100
+		transactionID, failure := s.refundTransaction(*charge.GatewayTransactionID, part, payment.Currency, fmt.Sprintf("refund_%d_charge_%d", refund.ID, charge.ID))
+		if failure != "" {
+			failures = append(failures, fmt.Sprintf("balance charge %d: %s", charge.ID, failure))
+			continue
+		}
+
+		if err := s.paymentRepo.AddBalanceChargeRefund(charge.ID, part); err != nil {
+			log.Printf("[REFUND] Failed to record refund of balance charge %d: %v", charge.ID, err)
+		}
+		refundIDs = append(refundIDs, transactionID)
+		returned += cents
+	}
+
+	if rest := total - assigned; rest > 0 {
+		switch {
+		case payment.Gateway == nil || payment.GatewayTransactionID == nil:
+			// Paid outside PlaySpot, so it is returned there too
+			returned += rest
+		case *payment.Gateway != s.gateway.Name():
+			failures = append(failures, fmt.Sprintf("payment gateway %s is not in use", *payment.Gateway))
+		default:
+			transactionID, failure := s.refundTransaction(*payment.GatewayTransactionID, float64(rest)/100, payment.Currency, fmt.Sprintf("refund_%d", refund.ID))
+			if failure != "" {
+				failures = append(failures, failure)
+				break
+			}
+			refundIDs = append(refundIDs, transactionID)
+			returned += rest
+		}
+	}
+
+	var gatewayRefundID *string
+	if len(refundIDs) > 0 {
+		joined := strings.Join(refundIDs, ",")
+		gatewayRefundID = &joined
+	}
+	switch {
+	case len(failures) > 0 && returned > 0:
+		return gatewayRefundID, float64(returned) / 100, fmt.Sprintf("returned %.2f of %.2f; %s", float64(returned)/100, refund.Amount, strings.Join(failures, "; "))
+	case len(failures) > 0:
+		return nil, 0, strings.Join(failures, "; ")
+	}
+	return gatewayRefundID, float64(returned) / 100, ""
+}
+
+// refundTransaction refunds amount of a gateway transaction. It returns the gateway's refund ID or, when the
+// refund did not go through, why.
+func (s *RefundService) refundTransaction(transactionID string, amount float64, currency, idempotencyKey string) (string, string) {
+	result, err := s.gateway.Refund(transactionID, amount, currency, idempotencyKey)
+	switch {
+	case err != nil:
+		return "", err.Error()
+	case result.Status == gateway.StatusDeclined:
+		if result.FailureReason == "" {
+			return "", "refused by the payment gateway"
+		}
+		return "", result.FailureReason
+	}
+	return result.TransactionID, ""
+}
+
+// refundShares returns a refund of a split payment through the shares that paid it, in proportion to
+// what is left of each. It returns the comma-separated gateway refund IDs, the amount returned and, when
+// any share could not be refunded, the failure describing why the rest was not.
+func (s *RefundService) refundShares(payment *model.Payment, refund *model.Refund) (*string, float64, string) {
+	amount := refund.Amount
+	shares, err := s.shareRepo.GetRefundableShares(payment.ID)
+	if err != nil {
+		return nil, 0, fmt.Sprintf("failed to get paid shares: %v", err)
+	}
+
+	var available int64
+	for _, share := range shares {
+		available += int64(math.Round((share.Amount - share.RefundedAmount) * 100))
+	}
+	total := int64(math.Round(amount * 100))
+	if available < total {
+		return nil, 0, fmt.Sprintf("the paid shares hold only %.2f", float64(available)/100)
+	}
+
+	var refundIDs, failures []string
+	var returned, assigned int64
+	for i, share := range shares {
+		left := int64(math.Round((share.Amount - share.RefundedAmount) * 100))
+		cents := total * left / available
+		if i == len(shares)-1 {
+			cents = total - assigned
+		}
+		assigned += cents
+		if cents <= 0 {
+			continue
+		}
+
+		part := float64(cents) / 100
+		if share.Gateway == nil || share.GatewayTransactionID == nil || *share.Gateway != s.gateway.Name() {
+			failures = append(failures, fmt.Sprintf("share %d was not paid through %s", share.ID, s.gateway.Name()))
+			continue
+		}
+
+		result, err := s.gateway.Refund(*share.GatewayTransactionID, part, payment.Currency, fmt.Sprintf("refund_%d_share_%d", refund.ID, share.ID))
+		switch {
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("share %d: %v", share.ID, err))
+			continue
+		case result.Status == gateway.StatusDeclined:
+			failures = append(failures, fmt.Sprintf("share %d: %s", share.ID, result.FailureReason))
+			continue
+		}
+
+		if err := s.shareRepo.AddShareRefund(share.ID, part); err != nil {
+			log.Printf("[REFUND] Failed to record refund of share %d: %v", share.ID, err)
+		}
+		refundIDs = append(refundIDs, result.TransactionID)
+		returned += cents
+	}
+
+	var gatewayRefundID *string
+	if len(refundIDs) > 0 {
+		joined := strings.Join(refundIDs, ",")
+		gatewayRefundID = &joined
+	}
+	if len(failures) > 0 {
+		return gatewayRefundID, float64(returned) / 100, fmt.Sprintf("returned %.2f of %.2f; %s", float64(returned)/100, amount, strings.Join(failures, "; "))
+	}
+	return gatewayRefundID, float64(returned) / 100, ""
+}
+
+// sendRefundEmail tells the customer about a refund asynchronously
+func (s *RefundService) sendRefundEmail(reservation *model.FacilityReservation, refund *model.Refund) {
+	// Guests booked by staff have no account to email
+	if reservation.UserID == 0 {
+		return
+	}
+
+	go func() {
+		user, err := s.userService.GetUserByID(reservation.UserID)
+		if err != nil {
+			log.Printf("Failed to get user %d: %v", reservation.UserID, err)
+			return
+		}
+
+		facility, err := s.facilityService.GetFacilityDetailsByID(reservation.FacilityID)
+		if err != nil {
+			log.Printf("Failed to get facility %d: %v", reservation.FacilityID, err)
+			return
+		}
+
+		payment, err := s.paymentRepo.GetPaymentByID(refund.PaymentID)
+		if err != nil || payment == nil {
+			log.Printf("Failed to get payment %d: %v", refund.PaymentID, err)
+			return
+		}
+
+		reason := ""
+		if refund.Reason != nil {
+			reason = *refund.Reason
+		}
+
+		loc := loadLocation(facility.TimeZone)
+		err = s.emailService.SendRefundEmail(
+			user.Email,
+			user.Name,
+			facility.Name,
+			facility.SportName,
+			reservation.StartTime.In(loc),
+			reservation.EndTime.In(loc),
+			refund.Amount,
+			payment.Amount,
+			payment.RefundedAmount,
+			reason,
+			refundNote(payment, refund),
+		)
+		if err != nil {
+			log.Printf("Failed to send refund email for reservation %d: %v", reservation.ID, err)
+		}
+	}()
+}
+
+// refundNote tells the customer where a refund was returned
+func refundNote(payment *model.Payment, refund *model.Refund) string {
+	switch {
+	case payment.PaymentMethod == model.PaymentMethodSplit:
+		return "The refund has been returned to the cards the participants paid their shares with. Depending on their banks it can take 5-10 business days to appear."
+	case refund.GatewayRefundID != nil:
+		return "The refund has been sent to the card you paid with. Depending on your bank it can take 5-10 business days to appear."
+	default:
+		return "Please contact the facility to collect your refund."
+	}
+}
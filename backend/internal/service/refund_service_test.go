@@ -0,0 +1,180 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+	"github.com/Radi03825/PlaySpot/internal/service/gateway"
+	"github.com/Radi03825/PlaySpot/internal/testutil"
+)
+
+// createTestFriend inserts a second user to share a booking with
+func createTestFriend(t *testing.T, db *sql.DB) int64 {
+	t.Helper()
+	var friendID int64
+	email := fmt.Sprintf("%s-%d-friend@test.playspot", t.Name(), time.Now().UnixNano())
+	if err := db.QueryRow(`INSERT INTO users (name, email) VALUES ('Test Friend', $1) RETURNING id`, email).Scan(&friendID); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, friendID) })
+	return friendID
+}
+
+// captureTestCharge charges amount through the simulated gateway and returns its transaction ID
+func captureTestCharge(t *testing.T, simulated *gateway.SimulatedGateway, amount float64) string {
+	t.Helper()
+	authorized, err := simulated.Authorize(gateway.AuthorizeRequest{Amount: amount, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if _, err := simulated.Capture(authorized.TransactionID, amount, "EUR"); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	return authorized.TransactionID
+}
+
+func newTestRefundService(db *sql.DB, paymentGateway gateway.PaymentGateway) (*RefundService, *repository.PaymentRepository) {
+	paymentRepo := repository.NewPaymentRepository(db)
+	emailService := NewEmailService()
+	userService := NewUserService(repository.NewUserRepository(db), nil, emailService)
+	facilityService := NewFacilityService(repository.NewFacilityRepository(db), userService, nil)
+	return NewRefundService(repository.NewRefundRepository(db), paymentRepo, repository.NewPaymentShareRepository(db),
+		repository.NewReservationRepository(db), facilityService, userService, emailService, paymentGateway), paymentRepo
+}
+
+func TestSplitPaymentRefundedThroughShares(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID, facilityID := testutil.CreateFacility(t, db)
+	friendID := createTestFriend(t, db)
+	reservationID := testutil.CreateReservation(t, db, userID, facilityID, "confirmed", 40)
+
+	simulated, err := gateway.NewSimulatedGateway(gateway.SimulateSucceed, "")
+	if err != nil {
+		t.Fatalf("failed to create simulated gateway: %v", err)
+	}
+
+	// Two paid shares of 20 EUR, each through the simulated gateway
+	var paymentID int64
+	err = db.QueryRow(`
+		INSERT INTO payments (user_id, reservation_id, amount, currency, payment_method, payment_status, paid_at)
+		VALUES ($1, $2, 40, 'EUR', 'split', 'completed', NOW())
+		RETURNING id
+	`, userID, reservationID).Scan(&paymentID)
+	if err != nil {
+		t.Fatalf("failed to create payment: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO payment_shares (payment_id, user_id, amount, status, gateway, gateway_transaction_id, paid_at)
+		VALUES ($1, $2, 20, 'paid', 'simulated', $4, NOW()), ($1, $3, 20, 'paid', 'simulated', $5, NOW())
+	`, paymentID, userID, friendID, captureTestCharge(t, simulated, 20), captureTestCharge(t, simulated, 20))
+	if err != nil {
+		t.Fatalf("failed to create shares: %v", err)
+	}
+
+	refundService, paymentRepo := newTestRefundService(db, simulated)
+
+	// A partial refund comes out of both shares in proportion
+	refund, err := refundService.RefundReservation(reservationID, userID, model.RefundInitiatorAdmin, dto.CreateRefundDTO{Amount: 10, Reason: "Lights failed"})
+	if err != nil {
+		t.Fatalf("RefundReservation: %v", err)
+	}
+	if refund.Status != model.RefundSucceeded || refund.Amount != 10 {
+		t.Errorf("refund is %s for %.2f, want succeeded for 10.00", refund.Status, refund.Amount)
+	}
+
+	rows, err := db.Query(`SELECT refunded_amount FROM payment_shares WHERE payment_id = $1 ORDER BY id`, paymentID)
+	if err != nil {
+		t.Fatalf("failed to get shares: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var refunded float64
+		if err := rows.Scan(&refunded); err != nil {
+			t.Fatalf("failed to scan share: %v", err)
+		}
+		if refunded != 5 {
+			t.Errorf("share refunded %.2f, want 5.00", refunded)
+		}
+	}
+
+	payment, err := paymentRepo.GetPaymentByID(paymentID)
+	if err != nil || payment == nil {
+		t.Fatalf("failed to get payment: %v", err)
+	}
+	if payment.PaymentStatus != "partially_refunded" || payment.RefundedAmount != 10 {
+		t.Errorf("payment is %s with %.2f refunded, want partially_refunded with 10.00", payment.PaymentStatus, payment.RefundedAmount)
+	}
+
+	// Nothing more than what is left can be refunded
+	if _, err := refundService.RefundReservation(reservationID, userID, model.RefundInitiatorAdmin, dto.CreateRefundDTO{Amount: 31, Reason: "Too much"}); !errors.Is(err, ErrRefundTooLarge) {
+		t.Errorf("refunding more than what is left: got %v, want ErrRefundTooLarge", err)
+	}
+}
+
+func TestSplitPaymentRefundPartlyReturned(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID, facilityID := testutil.CreateFacility(t, db)
+	friendID := createTestFriend(t, db)
+	reservationID := testutil.CreateReservation(t, db, userID, facilityID, "confirmed", 40)
+
+	simulated, err := gateway.NewSimulatedGateway(gateway.SimulateSucceed, "")
+	if err != nil {
+		t.Fatalf("failed to create simulated gateway: %v", err)
+	}
+
+	// Two paid shares: the user's through the simulated gateway, the friend's through a gateway no longer in use
+	var paymentID int64
+	err = db.QueryRow(`
+		INSERT INTO payments (user_id, reservation_id, amount, currency, payment_method, payment_status, paid_at)
+		VALUES ($1, $2, 40, 'EUR', 'split', 'completed', NOW())
+		RETURNING id
+	`, userID, reservationID).Scan(&paymentID)
+	if err != nil {
+		t.Fatalf("failed to create payment: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO payment_shares (payment_id, user_id, amount, status, gateway, gateway_transaction_id, paid_at)
+		VALUES ($1, $2, 20, 'paid', 'simulated', $4, NOW()), ($1, $3, 20, 'paid', 'retired', $5, NOW())
+	`, paymentID, userID, friendID, captureTestCharge(t, simulated, 20), fmt.Sprintf("retired_%d", paymentID))
+	if err != nil {
+		t.Fatalf("failed to create shares: %v", err)
+	}
+
+	refundService, paymentRepo := newTestRefundService(db, simulated)
+
+	refund, err := refundService.RefundReservation(reservationID, userID, model.RefundInitiatorAdmin, dto.CreateRefundDTO{Reason: "Court closed"})
+	if err != nil {
+		t.Fatalf("RefundReservation: %v", err)
+	}
+
+	// The refund keeps what came back; the rest is a failed refund of its own
+	if refund.Status != model.RefundSucceeded || refund.Amount != 20 {
+		t.Errorf("refund is %s for %.2f, want succeeded for 20.00", refund.Status, refund.Amount)
+	}
+	refunds, err := refundService.GetPaymentRefunds(paymentID)
+	if err != nil {
+		t.Fatalf("failed to get refunds: %v", err)
+	}
+	if len(refunds) != 2 || refunds[1].Status != model.RefundFailed || refunds[1].Amount != 20 || refunds[1].FailureReason == nil {
+		t.Fatalf("refunds = %+v, want the succeeded refund and a failed remainder of 20.00", refunds)
+	}
+
+	payment, err := paymentRepo.GetPaymentByID(paymentID)
+	if err != nil || payment == nil {
+		t.Fatalf("failed to get payment: %v", err)
+	}
+	if payment.PaymentStatus != "partially_refunded" || payment.RefundedAmount != 20 {
+		t.Errorf("payment is %s with %.2f refunded, want partially_refunded with 20.00", payment.PaymentStatus, payment.RefundedAmount)
+	}
+
+	// What failed can be refunded again
+	if _, err := refundService.RefundReservation(reservationID, userID, model.RefundInitiatorAdmin, dto.CreateRefundDTO{Amount: 20, Reason: "Retry"}); err != nil {
+		t.Errorf("refunding the failed remainder again: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+)
+
+// defaultReminderLeadTimes are used when REMINDER_LEAD_TIMES is not set
+var defaultReminderLeadTimes = []time.Duration{24 * time.Hour, 2 * time.Hour}
+
+type ReminderService struct {
+	reservationRepo     *repository.ReservationRepository
+	userService         *UserService
+	facilityService     *FacilityService
+	sportComplexService *SportComplexService
+	emailService        *EmailService
+	leadTimes           []time.Duration // sorted from shortest to longest
+}
+
+func NewReminderService(
+	reservationRepo *repository.ReservationRepository,
+	userService *UserService,
+	facilityService *FacilityService,
+	sportComplexService *SportComplexService,
+	emailService *EmailService,
+) *ReminderService {
+	leadTimes, err := parseLeadTimes(os.Getenv("REMINDER_LEAD_TIMES"))
+	if err != nil {
+		log.Printf("[REMINDER] Invalid REMINDER_LEAD_TIMES, using defaults: %v", err)
+		leadTimes = nil
+	}
+	if len(leadTimes) == 0 {
+		leadTimes = append([]time.Duration(nil), defaultReminderLeadTimes...)
+	}
+	sort.Slice(leadTimes, func(i, j int) bool { return leadTimes[i] < leadTimes[j] })
+
+	return &ReminderService{
+		reservationRepo:     reservationRepo,
+		userService:         userService,
+		facilityService:     facilityService,
+		sportComplexService: sportComplexService,
+		emailService:        emailService,
+		leadTimes:           leadTimes,
+	}
+}
+
+// parseLeadTimes parses a comma-separated list of durations such as "24h,2h"
+func parseLeadTimes(value string) ([]time.Duration, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var leadTimes []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		leadTime, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if leadTime < time.Minute {
+			return nil, fmt.Errorf("lead time %s is shorter than a minute", leadTime)
+		}
+		leadTimes = append(leadTimes, leadTime)
+	}
+
+	return leadTimes, nil
+}
+
+// SendDueReminders sends reminders for confirmed reservations that are within a lead time of starting.
+// A reservation only gets the reminder for the window it is currently in, so a booking made
+// an hour before it starts receives the 2h reminder but not the 24h one.
+// It returns the number of reminders sent.
+func (s *ReminderService) SendDueReminders() (int64, error) {
+	// Reservation times are stored in UTC
+	now := time.Now().UTC()
+
+	var sent int64
+	var windowStart time.Duration
+	for _, leadTime := range s.leadTimes {
+		reservations, err := s.reservationRepo.GetUpcomingReservations(now.Add(windowStart), now.Add(leadTime))
+		if err != nil {
+			return sent, fmt.Errorf("failed to get upcoming reservations: %w", err)
+		}
+
+		for i := range reservations {
+			if !reservations[i].StartTime.After(now.Add(windowStart)) {
+				continue
+			}
+			if s.sendReminder(&reservations[i], leadTime) {
+				sent++
+			}
+		}
+
+		windowStart = leadTime
+	}
+
+	return sent, nil
+}
+
+// sendReminder claims and sends a single reminder, releasing the claim if sending fails
+func (s *ReminderService) sendReminder(reservation *model.FacilityReservation, leadTime time.Duration) bool {
+	leadMinutes := int(leadTime / time.Minute)
+
+	claimed, err := s.reservationRepo.ClaimReminder(reservation.ID, leadMinutes)
+	if err != nil {
+		log.Printf("[REMINDER] Failed to claim reminder for reservation %d: %v", reservation.ID, err)
+		return false
+	}
+	if !claimed {
+		return false
+	}
+
+	if err := s.deliverReminder(reservation, leadTime); err != nil {
+		log.Printf("[REMINDER] Failed to send reminder for reservation %d: %v", reservation.ID, err)
+		if err := s.reservationRepo.ReleaseReminder(reservation.ID, leadMinutes); err != nil {
+			log.Printf("[REMINDER] Failed to release reminder for reservation %d: %v", reservation.ID, err)
+		}
+		return false
+	}
+
+	return true
+}
+
+func (s *ReminderService) deliverReminder(reservation *model.FacilityReservation, leadTime time.Duration) error {
+	user, err := s.userService.GetUserByID(reservation.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user %d: %w", reservation.UserID, err)
+	}
+
+	facility, err := s.facilityService.GetFacilityDetailsByID(reservation.FacilityID)
+	if err != nil {
+		return fmt.Errorf("failed to get facility %d: %w", reservation.FacilityID, err)
+	}
+
+	complexName := ""
+	if facility.SportComplexID != nil {
+		complex, err := s.sportComplexService.GetSportComplexByIDForReminder(*facility.SportComplexID)
+		if err == nil && complex != nil {
+			complexName = complex.Name
+		}
+	}
+
+	loc := loadLocation(facility.TimeZone)
+	return s.emailService.SendReservationReminderEmail(
+		user.Email,
+		user.Name,
+		facility.Name,
+		complexName,
+		facility.Address,
+		facility.City,
+		facility.SportName,
+		reservation.StartTime.In(loc),
+		reservation.EndTime.In(loc),
+		formatLeadTime(leadTime),
+	)
+}
+
+// formatLeadTime describes a lead time for the email, e.g. "within 2 hours"
+func formatLeadTime(leadTime time.Duration) string {
+	switch {
+	case leadTime == time.Hour:
+		return "within 1 hour"
+	case leadTime%time.Hour == 0:
+		return fmt.Sprintf("within %d hours", leadTime/time.Hour)
+	default:
+		return fmt.Sprintf("within %d minutes", leadTime/time.Minute)
+	}
+}
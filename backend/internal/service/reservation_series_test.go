@@ -0,0 +1,223 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+	"github.com/Radi03825/PlaySpot/internal/service/calendar"
+	"github.com/Radi03825/PlaySpot/internal/service/gateway"
+	"github.com/Radi03825/PlaySpot/internal/testutil"
+)
+
+// openAllWeek opens the facility every day from 08:00 to 22:00 at 20 EUR an hour
+func openAllWeek(t *testing.T, db *sql.DB, facilityID int64) {
+	t.Helper()
+	for _, day := range []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"} {
+		if _, err := db.Exec(`INSERT INTO facility_schedules (facility_id, open_time, close_time, day_type) VALUES ($1, '08:00', '22:00', $2)`, facilityID, day); err != nil {
+			t.Fatalf("failed to create schedule: %v", err)
+		}
+		if _, err := db.Exec(`INSERT INTO facility_pricings (facility_id, day_type, start_hour, end_hour, price_per_hour) VALUES ($1, $2, '08:00', '22:00', 20)`, facilityID, day); err != nil {
+			t.Fatalf("failed to create pricing: %v", err)
+		}
+	}
+}
+
+// newTestReservationService wires a reservation service whose refunds go through the simulated gateway
+func newTestReservationService(t *testing.T, db *sql.DB) (*ReservationService, *RefundService) {
+	t.Helper()
+	simulated, err := gateway.NewSimulatedGateway(gateway.SimulateSucceed, "")
+	if err != nil {
+		t.Fatalf("failed to create simulated gateway: %v", err)
+	}
+
+	reservationRepo := repository.NewReservationRepository(db)
+	emailService := NewEmailService()
+	userService := NewUserService(repository.NewUserRepository(db), nil, emailService)
+	facilityService := NewFacilityService(repository.NewFacilityRepository(db), userService, nil)
+	calendarSyncService := NewCalendarSyncService(repository.NewCalendarSyncRepository(db), reservationRepo, userService, facilityService,
+		map[string]calendar.CalendarProvider{}, nil)
+	refundService := NewRefundService(repository.NewRefundRepository(db), repository.NewPaymentRepository(db), repository.NewPaymentShareRepository(db),
+		reservationRepo, facilityService, userService, emailService, simulated)
+	return NewReservationService(reservationRepo, userService, facilityService, calendarSyncService, emailService, refundService), refundService
+}
+
+func TestReservationSeriesConflictsAndCancellation(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID, facilityID := testutil.CreateFacility(t, db)
+	openAllWeek(t, db, facilityID)
+
+	reservationRepo := repository.NewReservationRepository(db)
+	s, _ := newTestReservationService(t, db)
+
+	day := time.Now().UTC().AddDate(0, 0, 7)
+	start := time.Date(day.Year(), day.Month(), day.Day(), 10, 0, 0, 0, time.UTC)
+	count := 3
+
+	// The second week is already taken
+	if _, err := reservationRepo.CreateReservation(userID, facilityID, start.AddDate(0, 0, 7), start.AddDate(0, 0, 7).Add(time.Hour), 1, 0, time.Hour, 20, nil); err != nil {
+		t.Fatalf("failed to book the second week: %v", err)
+	}
+
+	req := dto.CreateRecurringReservationDTO{
+		FacilityID: facilityID,
+		StartTime:  start.Format(time.RFC3339),
+		EndTime:    start.Add(2 * time.Hour).Format(time.RFC3339),
+		Frequency:  string(model.RecurrenceWeekly),
+		Count:      &count,
+	}
+
+	// Without skipping conflicts nothing is booked
+	result, err := s.CreateRecurringReservation(userID, req)
+	if err != nil {
+		t.Fatalf("CreateRecurringReservation: %v", err)
+	}
+	if result.Created || result.Series != nil || result.Occurrences[1].Status != model.OccurrenceConflict {
+		t.Fatalf("series with a taken week was created or the conflict was not reported: %+v", result.Occurrences)
+	}
+
+	req.SkipConflicts = true
+	result, err = s.CreateRecurringReservation(userID, req)
+	if err != nil {
+		t.Fatalf("CreateRecurringReservation skipping conflicts: %v", err)
+	}
+	if !result.Created || result.CreatedCount != 2 || result.SkippedCount != 1 || result.TotalPrice != 80 {
+		t.Fatalf("created %d and skipped %d occurrences for %.2f, want 2 and 1 for 80.00", result.CreatedCount, result.SkippedCount, result.TotalPrice)
+	}
+
+	// Cancelling from a later week keeps the series for the first one
+	cancelled, err := s.CancelReservationSeries(result.Series.ID, userID, start.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("CancelReservationSeries from the second week: %v", err)
+	}
+	if cancelled != 1 {
+		t.Fatalf("cancelled %d occurrences from the second week, want 1", cancelled)
+	}
+	series, err := reservationRepo.GetReservationSeries(result.Series.ID)
+	if err != nil || series == nil || series.Status != "active" {
+		t.Fatalf("series with an occurrence left is not active: %+v, %v", series, err)
+	}
+
+	cancelled, err = s.CancelReservationSeries(result.Series.ID, userID, time.Now())
+	if err != nil {
+		t.Fatalf("CancelReservationSeries: %v", err)
+	}
+	if cancelled != 1 {
+		t.Fatalf("cancelled %d occurrences, want 1", cancelled)
+	}
+
+	reservations, err := reservationRepo.GetSeriesReservations(result.Series.ID)
+	if err != nil {
+		t.Fatalf("failed to get series reservations: %v", err)
+	}
+	for _, reservation := range reservations {
+		if reservation.Status != "cancelled" {
+			t.Errorf("occurrence %d is %s, want cancelled", reservation.ID, reservation.Status)
+		}
+	}
+
+	series, err = reservationRepo.GetReservationSeries(result.Series.ID)
+	if err != nil || series == nil || series.Status != "cancelled" {
+		t.Errorf("series was not cancelled: %v", err)
+	}
+}
+
+func TestReservationSeriesPaidOnSiteAndCancelled(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userID, facilityID := testutil.CreateFacility(t, db)
+	openAllWeek(t, db, facilityID)
+
+	reservationRepo := repository.NewReservationRepository(db)
+	paymentRepo := repository.NewPaymentRepository(db)
+	s, refundService := newTestReservationService(t, db)
+
+	loc := loadLocation(model.DefaultTimeZone)
+	day := time.Now().In(loc).AddDate(0, 0, 7)
+	start := time.Date(day.Year(), day.Month(), day.Day(), 10, 0, 0, 0, loc)
+	count := 3
+
+	result, err := s.CreateRecurringReservation(userID, dto.CreateRecurringReservationDTO{
+		FacilityID: facilityID,
+		StartTime:  start.Format(time.RFC3339),
+		EndTime:    start.Add(2 * time.Hour).Format(time.RFC3339),
+		Frequency:  string(model.RecurrenceWeekly),
+		Count:      &count,
+	})
+	if err != nil {
+		t.Fatalf("CreateRecurringReservation: %v", err)
+	}
+	if !result.Created || result.CreatedCount != count || result.TotalPrice != 120 {
+		t.Fatalf("created %d occurrences for %.2f, want %d for 120.00", result.CreatedCount, result.TotalPrice, count)
+	}
+
+	// Occurrences are booked outright and paid on site
+	var reservationIDs []int64
+	for _, occurrence := range result.Occurrences {
+		reservation, err := reservationRepo.GetReservationByID(*occurrence.ReservationID)
+		if err != nil {
+			t.Fatalf("failed to get reservation: %v", err)
+		}
+		if reservation.Status != "confirmed" || reservation.ExpiresAt != nil {
+			t.Errorf("occurrence %d is %s, want confirmed without a hold", reservation.ID, reservation.Status)
+		}
+		payment, err := paymentRepo.GetPaymentByReservationID(reservation.ID)
+		if err != nil || payment == nil {
+			t.Fatalf("occurrence %d has no payment: %v", reservation.ID, err)
+		}
+		if payment.PaymentMethod != "on_place" || payment.PaymentStatus != "pending" || payment.Amount != 40 {
+			t.Errorf("occurrence %d payment is %s %s of %.2f, want a pending on_place payment of 40.00",
+				reservation.ID, payment.PaymentMethod, payment.PaymentStatus, payment.Amount)
+		}
+		reservationIDs = append(reservationIDs, reservation.ID)
+	}
+
+	if _, err := reservationRepo.MarkReservationPaidOnSite(reservationIDs[0]); err != nil {
+		t.Fatalf("failed to mark occurrence paid: %v", err)
+	}
+
+	cancelled, err := s.CancelReservationSeries(result.Series.ID, userID, time.Now())
+	if err != nil {
+		t.Fatalf("CancelReservationSeries: %v", err)
+	}
+	if cancelled != count {
+		t.Fatalf("cancelled %d occurrences, want %d", cancelled, count)
+	}
+
+	// Each occurrence went through the cancellation path: the paid one is refunded and the rest are no longer owed
+	for i, reservationID := range reservationIDs {
+		reservation, err := reservationRepo.GetReservationByID(reservationID)
+		if err != nil {
+			t.Fatalf("failed to get reservation: %v", err)
+		}
+		if reservation.Status != "cancelled" || reservation.CancelledBy == nil || *reservation.CancelledBy != userID {
+			t.Errorf("occurrence %d is %s, want cancelled by its owner", reservationID, reservation.Status)
+		}
+
+		payment, err := paymentRepo.GetPaymentByReservationID(reservationID)
+		if err != nil || payment == nil {
+			t.Fatalf("failed to get payment: %v", err)
+		}
+		refunds, err := refundService.GetPaymentRefunds(payment.ID)
+		if err != nil {
+			t.Fatalf("failed to get refunds: %v", err)
+		}
+
+		if i == 0 {
+			if payment.PaymentStatus != "refunded" || len(refunds) != 1 || refunds[0].Status != model.RefundSucceeded {
+				t.Errorf("paid occurrence payment is %s with refunds %+v, want refunded by one succeeded refund", payment.PaymentStatus, refunds)
+			}
+			continue
+		}
+		if payment.PaymentStatus != "failed" || len(refunds) != 0 {
+			t.Errorf("unpaid occurrence payment is %s with %d refunds, want failed without refunds", payment.PaymentStatus, len(refunds))
+		}
+	}
+
+	series, err := reservationRepo.GetReservationSeries(result.Series.ID)
+	if err != nil || series == nil || series.Status != "cancelled" {
+		t.Errorf("series was not cancelled: %v", err)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Radi03825/PlaySpot/internal/dto"
@@ -11,25 +15,81 @@ import (
 	"github.com/Radi03825/PlaySpot/internal/repository"
 )
 
+// ErrSlotTaken is returned when the requested time slot overlaps an active reservation
+var ErrSlotTaken = repository.ErrSlotTaken
+
+// ErrReservationStarted is returned when a user cancels a reservation that has already started
+var ErrReservationStarted = errors.New("a reservation cannot be cancelled once it has started")
+
+// defaultHoldMinutes is how long an unpaid pending reservation keeps its slot
+const defaultHoldMinutes = 15
+
 type ReservationService struct {
-	repo                  *repository.ReservationRepository
-	userService           *UserService
-	facilityService       *FacilityService
-	googleCalendarService *GoogleCalendarService
+	repo                *repository.ReservationRepository
+	userService         *UserService
+	facilityService     *FacilityService
+	calendarSyncService *CalendarSyncService
+	emailService        *EmailService
+	refundService       *RefundService
+	waitlistService     *WaitlistService
+	holdDuration        time.Duration
 }
 
 func NewReservationService(
 	repo *repository.ReservationRepository,
 	userService *UserService,
 	facilityService *FacilityService,
-	googleCalendarService *GoogleCalendarService,
+	calendarSyncService *CalendarSyncService,
+	emailService *EmailService,
+	refundService *RefundService,
 ) *ReservationService {
+	holdMinutes := defaultHoldMinutes
+	if value, err := strconv.Atoi(os.Getenv("RESERVATION_HOLD_MINUTES")); err == nil && value > 0 {
+		holdMinutes = value
+	}
+
 	return &ReservationService{
-		repo:                  repo,
-		userService:           userService,
-		facilityService:       facilityService,
-		googleCalendarService: googleCalendarService,
+		repo:                repo,
+		userService:         userService,
+		facilityService:     facilityService,
+		calendarSyncService: calendarSyncService,
+		emailService:        emailService,
+		refundService:       refundService,
+		holdDuration:        time.Duration(holdMinutes) * time.Minute,
+	}
+}
+
+// SetWaitlistService sets the waitlist service (for resolving circular dependencies)
+func (s *ReservationService) SetWaitlistService(waitlistService *WaitlistService) {
+	s.waitlistService = waitlistService
+}
+
+// releaseSlot offers a facility's freed slots to users on the waitlist
+func (s *ReservationService) releaseSlot(facilityID int64) {
+	if s.waitlistService != nil {
+		s.waitlistService.NotifySlotReleased(facilityID)
+	}
+}
+
+// ExpirePendingReservations marks unpaid pending reservations with a lapsed hold as expired.
+// Availability already ignores lapsed holds; this records the expiry, fails their payments and
+// releases what the gateway had authorized for them.
+func (s *ReservationService) ExpirePendingReservations() (int64, error) {
+	expired, payments, err := s.repo.ExpirePendingReservations()
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range payments {
+		s.refundService.ReleaseAuthorization(&payments[i])
 	}
+
+	return expired, nil
+}
+
+// CompleteFinishedReservations marks confirmed reservations that have ended as completed
+func (s *ReservationService) CompleteFinishedReservations() (int64, error) {
+	return s.repo.CompleteFinishedReservations()
 }
 
 // GetFacilityAvailability returns availability for a facility for a date range
@@ -54,81 +114,297 @@ func (s *ReservationService) GetFacilityAvailability(facilityID int64, startDate
 		return nil, errors.New("no pricing found for this facility")
 	}
 
+	// Get booking policy
+	policy, err := s.getBookingPolicy(facilityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get booking policy: %w", err)
+	}
+
+	// Days run from midnight to midnight in the facility's time zone
+	loc, err := s.facilityLocation(facilityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get facility time zone: %w", err)
+	}
+	startDate = localDate(startDate, loc)
+	endDate = localDate(endDate, loc)
+
 	// Get existing reservations
-	reservations, err := s.repo.GetReservationsByFacilityAndDateRange(facilityID, startDate, endDate.Add(24*time.Hour))
+	reservations, err := s.repo.GetReservationsByFacilityAndDateRange(facilityID, startDate, endDate.AddDate(0, 0, 1))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get reservations: %w", err)
 	}
 
+	// Get closures, special hours and special prices
+	exceptions, err := s.repo.GetFacilityScheduleExceptions(facilityID, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule exceptions: %w", err)
+	}
+
 	// Build availability map
 	var availability []model.DayAvailability
 
-	// Iterate through each day in the range
-	for date := startDate; !date.After(endDate); date = date.Add(24 * time.Hour) {
-		dayAvailability := s.buildDayAvailability(date, schedules, pricings, reservations)
+	// Iterate through each day in the range (days are 23 or 25 hours long on daylight saving changes)
+	for date := startDate; !date.After(endDate); date = date.AddDate(0, 0, 1) {
+		dayAvailability := s.buildDayAvailability(date, schedules, exceptions, pricings, reservations, policy)
 		availability = append(availability, dayAvailability)
 	}
 
 	return availability, nil
 }
 
-// buildDayAvailability builds availability for a single day
-func (s *ReservationService) buildDayAvailability(date time.Time, schedules []model.FacilitySchedule, pricings []model.FacilityPricing, reservations []model.FacilityReservation) model.DayAvailability {
-	dayType := model.DayTypeWeekday
-	weekday := date.Weekday()
-	if weekday == time.Saturday || weekday == time.Sunday {
-		dayType = model.DayTypeWeekend
+// getBookingPolicy returns the facility's booking policy, falling back to the default hourly policy
+func (s *ReservationService) getBookingPolicy(facilityID int64) (*model.FacilityBookingPolicy, error) {
+	policy, err := s.repo.GetFacilityBookingPolicy(facilityID)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy == nil {
+		return model.DefaultBookingPolicy(facilityID), nil
+	}
+
+	return policy, nil
+}
+
+// facilityLocation returns the time zone a facility's opening hours, prices and closures are defined in
+func (s *ReservationService) facilityLocation(facilityID int64) (*time.Location, error) {
+	timeZone, err := s.repo.GetFacilityTimeZone(facilityID)
+	if err != nil {
+		return nil, err
 	}
+	return loadLocation(timeZone), nil
+}
 
-	// Find schedule for this day type
-	var schedule *model.FacilitySchedule
-	for _, s := range schedules {
-		if s.DayType == dayType {
-			schedule = &s
-			break
+// loadLocation loads an IANA time zone, falling back to the default zone for unknown names
+func loadLocation(timeZone string) *time.Location {
+	if timeZone != "" {
+		if loc, err := time.LoadLocation(timeZone); err == nil {
+			return loc
 		}
+		log.Printf("Unknown time zone %q, using %s", timeZone, model.DefaultTimeZone)
 	}
 
-	dayAvailability := model.DayAvailability{
-		Date:   date.Format("2006-01-02"),
-		IsOpen: schedule != nil,
-		Slots:  []model.AvailableSlot{},
+	loc, err := time.LoadLocation(model.DefaultTimeZone)
+	if err != nil {
+		return time.UTC
 	}
+	return loc
+}
 
-	if schedule == nil {
-		return dayAvailability
+// localDate returns midnight of the given calendar date in loc
+func localDate(date time.Time, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+}
+
+// dayTypeFor returns the day type used for schedules and pricing on the given date
+func dayTypeFor(date time.Time) model.DayType {
+	return model.DayTypeForWeekday(date.Weekday())
+}
+
+// findSchedule finds the schedule that applies to the given date
+func findSchedule(date time.Time, schedules []model.FacilitySchedule) *model.FacilitySchedule {
+	dayType := dayTypeFor(date)
+	for i := range schedules {
+		if schedules[i].DayType == dayType {
+			return &schedules[i]
+		}
+	}
+	return nil
+}
+
+// timeRange is a half-open interval [start, end)
+type timeRange struct {
+	start time.Time
+	end   time.Time
+}
+
+func (r timeRange) overlaps(start, end time.Time) bool {
+	return start.Before(r.end) && end.After(r.start)
+}
+
+// specialPrice is a price override for part of a day
+type specialPrice struct {
+	timeRange
+	pricePerHour float64
+}
+
+// dayPlan is the effective schedule of a single day after schedule exceptions are applied
+type dayPlan struct {
+	isOpen        bool
+	open          time.Time
+	close         time.Time
+	closureReason *string
+	blackouts     []timeRange
+	specialPrices []specialPrice
+}
+
+// resolveDayPlan combines the regular schedule for a date with its closures, special hours and special prices.
+// Facility-level exceptions take precedence over exceptions defined for the whole sport complex.
+func resolveDayPlan(date time.Time, schedules []model.FacilitySchedule, exceptions []model.ScheduleException) (dayPlan, error) {
+	var plan dayPlan
+
+	if schedule := findSchedule(date, schedules); schedule != nil {
+		openAt, err := timeOnDate(date, schedule.OpenTime)
+		if err != nil {
+			return plan, err
+		}
+		closeAt, err := timeOnDate(date, schedule.CloseTime)
+		if err != nil {
+			return plan, err
+		}
+		plan.isOpen = true
+		plan.open = openAt
+		plan.close = closeAt
+	}
+
+	dateStr := date.Format("2006-01-02")
+	specialHoursFromFacility := false
+
+	for _, e := range exceptions {
+		if e.Date != dateStr {
+			continue
+		}
+
+		var window *timeRange
+		if e.StartTime != nil && e.EndTime != nil {
+			start, err := timeOnDate(date, *e.StartTime)
+			if err != nil {
+				return plan, err
+			}
+			end, err := timeOnDate(date, *e.EndTime)
+			if err != nil {
+				return plan, err
+			}
+			window = &timeRange{start: start, end: end}
+		}
+
+		switch e.Type {
+		case model.ScheduleExceptionClosed:
+			if window == nil {
+				return dayPlan{closureReason: e.Reason}, nil
+			}
+			plan.blackouts = append(plan.blackouts, *window)
+		case model.ScheduleExceptionSpecialHours:
+			if window == nil || (specialHoursFromFacility && e.FacilityID == nil) {
+				continue
+			}
+			plan.isOpen = true
+			plan.open = window.start
+			plan.close = window.end
+			specialHoursFromFacility = e.FacilityID != nil
+		case model.ScheduleExceptionSpecialPrice:
+			if e.PricePerHour == nil {
+				continue
+			}
+			sp := specialPrice{pricePerHour: *e.PricePerHour}
+			if window != nil {
+				sp.timeRange = *window
+			} else {
+				midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+				sp.timeRange = timeRange{start: midnight, end: midnight.AddDate(0, 0, 1)}
+			}
+			if e.FacilityID != nil {
+				plan.specialPrices = append([]specialPrice{sp}, plan.specialPrices...)
+			} else {
+				plan.specialPrices = append(plan.specialPrices, sp)
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// isBlackedOut reports whether the interval overlaps a partial-day closure
+func (p dayPlan) isBlackedOut(start, end time.Time) bool {
+	for _, b := range p.blackouts {
+		if b.overlaps(start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// specialPriceAt returns the special price in effect at the given time, if any
+func (p dayPlan) specialPriceAt(t time.Time) (float64, bool) {
+	price, _, found := specialPriceSegment(t, p)
+	return price, found
+}
+
+// timeOnDate places a "HH:MM:SS" time of day on the given date.
+// A time of day repeated when the clocks go back resolves to its first occurrence.
+func timeOnDate(date time.Time, timeOfDay string) (time.Time, error) {
+	t, err := parseTimeOfDay(timeOfDay)
+	if err != nil {
+		return time.Time{}, err
+	}
+	at := time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location())
+	if earlier := at.Add(-time.Hour); earlier.Hour() == at.Hour() && earlier.Minute() == at.Minute() {
+		return earlier, nil
+	}
+	return at, nil
+}
+
+// buildDayAvailability builds availability for a single day
+func (s *ReservationService) buildDayAvailability(date time.Time, schedules []model.FacilitySchedule, exceptions []model.ScheduleException, pricings []model.FacilityPricing, reservations []model.FacilityReservation, policy *model.FacilityBookingPolicy) model.DayAvailability {
+	dayAvailability := model.DayAvailability{
+		Date:     date.Format("2006-01-02"),
+		TimeZone: date.Location().String(),
+		Slots:    []model.AvailableSlot{},
 	}
 
-	// Parse open and close times from "HH:MM:SS" format
-	openTime, err := parseTimeOfDay(schedule.OpenTime)
+	// Apply closures and special hours to the regular schedule
+	plan, err := resolveDayPlan(date, schedules, exceptions)
 	if err != nil {
 		return dayAvailability
 	}
-	closeTime, err := parseTimeOfDay(schedule.CloseTime)
-	if err != nil {
+
+	dayAvailability.IsOpen = plan.isOpen
+	dayAvailability.ClosureReason = plan.closureReason
+
+	if !plan.isOpen {
 		return dayAvailability
 	}
 
-	// Create time slots (hourly)
-	currentSlot := time.Date(date.Year(), date.Month(), date.Day(), openTime.Hour(), openTime.Minute(), 0, 0, date.Location())
-	endOfDay := time.Date(date.Year(), date.Month(), date.Day(), closeTime.Hour(), closeTime.Minute(), 0, 0, date.Location())
+	// Create time slots using the facility's slot length. Slots are measured in elapsed time, so
+	// on daylight saving changes a local hour is skipped or repeated rather than slots being stretched.
+	slotLength := time.Duration(policy.SlotMinutes) * time.Minute
+	buffer := time.Duration(policy.BufferMinutes) * time.Minute
+	currentSlot := plan.open
 
-	for currentSlot.Before(endOfDay) {
-		slotEnd := currentSlot.Add(1 * time.Hour)
+	for !currentSlot.Add(slotLength).After(plan.close) {
+		slotEnd := currentSlot.Add(slotLength)
 
-		// Find pricing for this slot
-		price := s.findPricing(currentSlot, dayType, pricings)
+		// Find pricing for this slot, preferring special prices for the date
+		price, priced := plan.specialPriceAt(currentSlot)
+		if !priced {
+			price, priced = s.findPricing(currentSlot, pricings)
+		}
 
-		// Check if slot is available
-		available := !s.isSlotReserved(currentSlot, slotEnd, reservations)
+		// Check if slot is available (unpriced slots cannot be booked, and the buffer
+		// around existing reservations is kept free)
+		available := priced && !plan.isBlackedOut(currentSlot, slotEnd)
 
 		slot := model.AvailableSlot{
 			StartTime:    currentSlot.Format("15:04"),
 			EndTime:      slotEnd.Format("15:04"),
+			StartsAt:     currentSlot,
+			EndsAt:       slotEnd,
 			PricePerHour: price,
-			Available:    available,
 		}
 
+		// Shared facilities stay available while some units are free
+		if policy.AvailabilityMode == model.AvailabilityShared {
+			remaining := policy.Capacity - model.PeakUnits(reservations, currentSlot.Add(-buffer), slotEnd.Add(buffer))
+			if remaining < 0 {
+				remaining = 0
+			}
+			slot.RemainingUnits = &remaining
+			available = available && remaining > 0
+		} else {
+			available = available && !s.isSlotReserved(currentSlot.Add(-buffer), slotEnd.Add(buffer), reservations)
+		}
+		slot.Available = available
+
 		dayAvailability.Slots = append(dayAvailability.Slots, slot)
 		currentSlot = slotEnd
 	}
@@ -136,18 +412,11 @@ func (s *ReservationService) buildDayAvailability(date time.Time, schedules []mo
 	return dayAvailability
 }
 
-// findPricing finds the price for a given time slot
-func (s *ReservationService) findPricing(slotTime time.Time, dayType model.DayType, pricings []model.FacilityPricing) float64 {
-	slotHour := slotTime.Format("15:04:05")
-
-	for _, p := range pricings {
-		if p.DayType == dayType && slotHour >= p.StartHour && slotHour < p.EndHour {
-			return p.PricePerHour
-		}
-	}
-
-	// Default price if not found
-	return 0.0
+// findPricing finds the price for a given time slot, reporting whether any price band covers it
+func (s *ReservationService) findPricing(slotTime time.Time, pricings []model.FacilityPricing) (float64, bool) {
+	day := time.Date(slotTime.Year(), slotTime.Month(), slotTime.Day(), 0, 0, 0, 0, slotTime.Location())
+	price, _, found := bandPriceSegment(slotTime, day, pricings)
+	return price, found
 }
 
 // isSlotReserved checks if a time slot is already reserved
@@ -183,199 +452,1102 @@ func (s *ReservationService) CreateReservation(userID int64, req dto.CreateReser
 		return nil, errors.New("cannot book in the past")
 	}
 
-	// Check for conflicts
-	hasConflict, err := s.repo.CheckReservationConflict(req.FacilityID, startTime, endTime)
+	// Validate against the facility's schedule and booking policy
+	policy, err := s.validateBookingWindow(req.FacilityID, startTime, endTime)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check for conflicts: %w", err)
+		return nil, err
 	}
 
-	if hasConflict {
-		return nil, errors.New("this time slot is already reserved")
+	units, err := bookingUnits(policy, req.Units)
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate total price
-	totalPrice, err := s.calculatePrice(req.FacilityID, startTime, endTime)
+	// Calculate total price across all price bands the booking spans
+	priceItems, totalPrice, err := s.calculatePrice(req.FacilityID, startTime, endTime, units)
 	if err != nil {
+		if errors.Is(err, ErrUnpricedInterval) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to calculate price: %w", err)
 	}
 
-	// Create reservation
-	reservation, err := s.repo.CreateReservation(userID, req.FacilityID, startTime, endTime, totalPrice)
+	// Create reservation (conflict check and insert happen atomically)
+	buffer := time.Duration(policy.BufferMinutes) * time.Minute
+	reservation, err := s.repo.CreateReservation(userID, req.FacilityID, startTime, endTime, units, buffer, s.holdDuration, totalPrice, priceItems)
 	if err != nil {
+		if errors.Is(err, ErrSlotTaken) {
+			return nil, ErrSlotTaken
+		}
 		return nil, fmt.Errorf("failed to create reservation: %w", err)
 	}
 
 	return reservation, nil
 }
 
-// createCalendarEventForReservation creates a Google Calendar event for a reservation asynchronously
-func (s *ReservationService) createCalendarEventForReservation(reservation *model.FacilityReservation) {
-	// Run calendar event creation in a goroutine to avoid blocking
-	go func() {
-		// Get Google tokens (regardless of whether user has Google auth identity)
-		accessToken, refreshToken, tokenExpiry, err := s.userService.GetGoogleTokens(reservation.UserID)
+// maxBasketItems limits how many reservations a single checkout can create
+const maxBasketItems = 10
+
+// Checkout books several facility intervals at once with one combined payment.
+// Each item is validated like a single reservation; the bookings are then created in one transaction,
+// so either every item is reserved or none is. Returns ErrSlotTaken if any item is unavailable.
+func (s *ReservationService) Checkout(userID int64, req dto.CheckoutDTO) (*dto.CheckoutResultDTO, error) {
+	if len(req.Items) == 0 {
+		return nil, errors.New("basket is empty")
+	}
+	if len(req.Items) > maxBasketItems {
+		return nil, fmt.Errorf("a basket can contain at most %d items", maxBasketItems)
+	}
+
+	items := make([]model.BasketItem, 0, len(req.Items))
+	for i, reqItem := range req.Items {
+		item, err := s.prepareBasketItem(reqItem)
 		if err != nil {
-			// Error getting tokens, skip calendar event
-			log.Printf("[CALENDAR] No Google tokens for user %d: %v", reservation.UserID, err)
-			return
+			return nil, fmt.Errorf("item %d: %w", i+1, err)
 		}
+		items = append(items, *item)
+	}
 
-		if accessToken == "" || refreshToken == "" {
-			// No valid tokens, user hasn't connected calendar
-			log.Printf("[CALENDAR] User %d has no calendar tokens", reservation.UserID)
-			return
+	reservations, payment, err := s.repo.CreateCheckout(userID, items, s.holdDuration, "EUR")
+	if err != nil {
+		if errors.Is(err, ErrSlotTaken) {
+			return nil, err // Names the unavailable item
 		}
+		return nil, fmt.Errorf("failed to create reservations: %w", err)
+	}
 
-		log.Printf("[CALENDAR] Creating calendar event for user %d, reservation %d", reservation.UserID, reservation.ID)
+	return &dto.CheckoutResultDTO{
+		Reservations: reservations,
+		Payment:      payment,
+		TotalPrice:   payment.Amount,
+	}, nil
+}
 
-		// Get facility details
-		facility, err := s.facilityService.GetFacilityDetailsByID(reservation.FacilityID)
-		if err != nil {
-			// Failed to get facility, skip calendar event
-			log.Printf("[CALENDAR] Failed to get facility %d: %v", reservation.FacilityID, err)
-			return
-		}
+// prepareBasketItem validates one basket item against its facility's schedule and booking policy and prices it
+func (s *ReservationService) prepareBasketItem(req dto.CheckoutItemDTO) (*model.BasketItem, error) {
+	startTime, err := time.Parse(time.RFC3339, req.StartTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time format: %w", err)
+	}
 
-		// Create calendar event
-		description := fmt.Sprintf(
-			"Facility: %s\nCategory: %s\nSport: %s\nPrice: €%.2f",
-			facility.Name,
-			facility.CategoryName,
-			facility.SportName,
-			reservation.TotalPrice,
-		)
+	endTime, err := time.Parse(time.RFC3339, req.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end time format: %w", err)
+	}
 
-		// Combine address and city for location
-		location := fmt.Sprintf("%s, %s", facility.Address, facility.City)
+	if !endTime.After(startTime) {
+		return nil, errors.New("end time must be after start time")
+	}
 
-		eventID, err := s.googleCalendarService.CreateEvent(
-			accessToken,
-			refreshToken,
-			tokenExpiry,
-			facility.Name,
-			description,
-			location,
-			reservation.StartTime,
-			reservation.EndTime,
-		)
+	if startTime.Before(time.Now()) {
+		return nil, errors.New("cannot book in the past")
+	}
 
-		if err != nil {
-			// Failed to create calendar event, but don't fail the reservation
-			log.Printf("[CALENDAR] Failed to create calendar event for reservation %d: %v", reservation.ID, err)
-			return
-		}
+	policy, err := s.validateBookingWindow(req.FacilityID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
 
-		log.Printf("[CALENDAR] Calendar event created with ID: %s for reservation %d", eventID, reservation.ID)
+	units, err := bookingUnits(policy, req.Units)
+	if err != nil {
+		return nil, err
+	}
 
-		// Update reservation with calendar event ID
-		updateErr := s.repo.UpdateReservationCalendarEventID(reservation.ID, eventID)
-		if updateErr != nil {
-			log.Printf("[CALENDAR] Failed to update reservation with calendar event ID: %v", updateErr)
+	priceItems, totalPrice, err := s.calculatePrice(req.FacilityID, startTime, endTime, units)
+	if err != nil {
+		if errors.Is(err, ErrUnpricedInterval) {
+			return nil, err
 		}
-	}()
+		return nil, fmt.Errorf("failed to calculate price: %w", err)
+	}
+
+	return &model.BasketItem{
+		FacilityID: req.FacilityID,
+		StartTime:  startTime,
+		EndTime:    endTime,
+		Units:      units,
+		Buffer:     time.Duration(policy.BufferMinutes) * time.Minute,
+		TotalPrice: totalPrice,
+		PriceItems: priceItems,
+	}, nil
 }
 
-// calculatePrice calculates the total price for a reservation
-func (s *ReservationService) calculatePrice(facilityID int64, startTime, endTime time.Time) (float64, error) {
-	pricings, err := s.repo.GetFacilityPricing(facilityID)
+// maxSeriesOccurrences limits how many reservations a single recurring series can create
+const maxSeriesOccurrences = 52
+
+// CreateRecurringReservation books the same facility and time weekly or biweekly.
+// Every occurrence is validated against the schedule, closures, pricing and existing reservations.
+// Unless SkipConflicts is set, the series is only created when every occurrence can be booked.
+func (s *ReservationService) CreateRecurringReservation(userID int64, req dto.CreateRecurringReservationDTO) (*dto.RecurringReservationResultDTO, error) {
+	startTime, err := time.Parse(time.RFC3339, req.StartTime)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("invalid start time format: %w", err)
 	}
 
-	duration := endTime.Sub(startTime)
-	hours := duration.Hours()
-
-	dayType := model.DayTypeWeekday
-	weekday := startTime.Weekday()
-	if weekday == time.Saturday || weekday == time.Sunday {
-		dayType = model.DayTypeWeekend
+	endTime, err := time.Parse(time.RFC3339, req.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end time format: %w", err)
 	}
 
-	// Find the pricing for the start time
-	price := s.findPricing(startTime, dayType, pricings)
-
-	return price * hours, nil
-}
+	if !endTime.After(startTime) {
+		return nil, errors.New("end time must be after start time")
+	}
 
-// GetUserReservations retrieves all reservations for a user
-func (s *ReservationService) GetUserReservations(userID int64) ([]model.FacilityReservation, error) {
-	return s.repo.GetUserReservations(userID)
-}
+	frequency := model.RecurrenceFrequency(req.Frequency)
+	var interval int
+	switch frequency {
+	case model.RecurrenceWeekly:
+		interval = 7
+	case model.RecurrenceBiweekly:
+		interval = 14
+	default:
+		return nil, errors.New("invalid frequency. Must be 'weekly' or 'biweekly'")
+	}
 
-// GetUpcomingConfirmedReservations retrieves upcoming confirmed reservations for a user
-func (s *ReservationService) GetUpcomingConfirmedReservations(userID int64) ([]dto.ReservationWithFacilityDTO, error) {
-	return s.repo.GetUpcomingConfirmedReservationsWithDetails(userID)
-}
+	hasUntil := req.UntilDate != nil && *req.UntilDate != ""
+	if hasUntil == (req.Count != nil) {
+		return nil, errors.New("either until_date or count must be provided")
+	}
 
-// GetPendingReservationsCount retrieves the count of pending reservations for a user
-func (s *ReservationService) GetPendingReservationsCount(userID int64) (int, error) {
-	return s.repo.GetPendingReservationsCount(userID)
-}
+	facilityPolicy, err := s.getBookingPolicy(req.FacilityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get booking policy: %w", err)
+	}
+	units, err := bookingUnits(facilityPolicy, req.Units)
+	if err != nil {
+		return nil, err
+	}
 
-// CancelReservation cancels a reservation
-func (s *ReservationService) CancelReservation(reservationID, userID int64) error {
-	// Cancel reservation and get details
-	reservation, err := s.repo.CancelReservation(reservationID, userID)
+	// Occurrences repeat at the same local time, so across a daylight saving change
+	// they are an hour more or less than a whole number of weeks apart
+	loc, err := s.facilityLocation(req.FacilityID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to get facility time zone: %w", err)
 	}
+	startTime = startTime.In(loc)
+	endTime = endTime.In(loc)
 
-	// Try to delete Google Calendar event if it exists
-	if reservation.GoogleCalendarEventID != nil && *reservation.GoogleCalendarEventID != "" {
-		s.deleteCalendarEventForReservation(reservation)
+	// Build the occurrence dates
+	var occurrences []model.SeriesOccurrence
+	if req.Count != nil {
+		if *req.Count < 1 || *req.Count > maxSeriesOccurrences {
+			return nil, fmt.Errorf("count must be between 1 and %d", maxSeriesOccurrences)
+		}
+		for i := 0; i < *req.Count; i++ {
+			occurrences = append(occurrences, model.SeriesOccurrence{
+				StartTime: startTime.AddDate(0, 0, i*interval),
+				EndTime:   endTime.AddDate(0, 0, i*interval),
+			})
+		}
+	} else {
+		untilDate, err := time.ParseInLocation("2006-01-02", *req.UntilDate, loc)
+		if err != nil {
+			return nil, errors.New("invalid until_date format. Use YYYY-MM-DD")
+		}
+		lastDay := untilDate.AddDate(0, 0, 1)
+		for i := 0; startTime.AddDate(0, 0, i*interval).Before(lastDay); i++ {
+			if i == maxSeriesOccurrences {
+				return nil, fmt.Errorf("a series cannot have more than %d occurrences", maxSeriesOccurrences)
+			}
+			occurrences = append(occurrences, model.SeriesOccurrence{
+				StartTime: startTime.AddDate(0, 0, i*interval),
+				EndTime:   endTime.AddDate(0, 0, i*interval),
+			})
+		}
+		if len(occurrences) == 0 {
+			return nil, errors.New("until_date must not be before the first occurrence")
+		}
 	}
 
-	return nil
-}
+	// Validate every occurrence against the schedule, booking policy and pricing
+	var policy *model.FacilityBookingPolicy
+	for i := range occurrences {
+		occurrence := &occurrences[i]
 
-// deleteCalendarEventForReservation deletes a Google Calendar event for a reservation asynchronously
-func (s *ReservationService) deleteCalendarEventForReservation(reservation *model.FacilityReservation) {
-	// Run calendar event deletion in a goroutine to avoid blocking
-	go func() {
-		// Check if user has Google auth
-		hasGoogle, err := s.userService.HasAuthIdentity(reservation.UserID, "google")
-		if err != nil || !hasGoogle {
-			log.Printf("[CALENDAR] User %d does not have Google auth", reservation.UserID)
-			return
+		if occurrence.StartTime.Before(time.Now()) {
+			occurrence.Status = model.OccurrenceInvalid
+			occurrence.Reason = "cannot book in the past"
+			continue
 		}
 
-		// Get Google tokens
-		accessToken, refreshToken, tokenExpiry, err := s.userService.GetGoogleTokens(reservation.UserID)
-		if err != nil || accessToken == "" {
-			log.Printf("[CALENDAR] Failed to get Google tokens for user %d: %v", reservation.UserID, err)
-			return
+		occurrencePolicy, err := s.validateBookingWindow(req.FacilityID, occurrence.StartTime, occurrence.EndTime)
+		if err != nil {
+			occurrence.Status = model.OccurrenceInvalid
+			occurrence.Reason = err.Error()
+			continue
 		}
+		policy = occurrencePolicy
 
-		// Delete calendar event
-		err = s.googleCalendarService.DeleteEvent(
-			accessToken,
-			refreshToken,
-			tokenExpiry,
-			*reservation.GoogleCalendarEventID,
-		)
+		priceItems, totalPrice, err := s.calculatePrice(req.FacilityID, occurrence.StartTime, occurrence.EndTime, units)
 		if err != nil {
-			log.Printf("[CALENDAR] Failed to delete calendar event %s: %v", *reservation.GoogleCalendarEventID, err)
-		} else {
-			log.Printf("[CALENDAR] Successfully deleted calendar event %s", *reservation.GoogleCalendarEventID)
+			occurrence.Status = model.OccurrenceInvalid
+			occurrence.Reason = err.Error()
+			continue
 		}
-	}()
+
+		occurrence.Status = model.OccurrenceAvailable
+		occurrence.TotalPrice = totalPrice
+		occurrence.PriceItems = priceItems
+	}
+
+	series := &model.ReservationSeries{
+		UserID:          userID,
+		FacilityID:      req.FacilityID,
+		Frequency:       frequency,
+		FirstStartTime:  startTime,
+		FirstEndTime:    endTime,
+		OccurrenceCount: req.Count,
+	}
+	if hasUntil {
+		series.UntilDate = req.UntilDate
+	}
+
+	created := 0
+	if policy != nil {
+		buffer := time.Duration(policy.BufferMinutes) * time.Minute
+		created, err = s.repo.CreateReservationSeries(series, occurrences, units, buffer, req.SkipConflicts, "EUR")
+		if err != nil {
+			if errors.Is(err, ErrSlotTaken) {
+				return nil, ErrSlotTaken
+			}
+			return nil, fmt.Errorf("failed to create reservation series: %w", err)
+		}
+	}
+
+	result := &dto.RecurringReservationResultDTO{
+		Created:      created > 0,
+		CreatedCount: created,
+		SkippedCount: len(occurrences) - created,
+		Occurrences:  occurrences,
+	}
+	if created > 0 {
+		result.Series = series
+		for _, occurrence := range occurrences {
+			if occurrence.Status != model.OccurrenceCreated {
+				continue
+			}
+			result.TotalPrice += occurrence.TotalPrice
+
+			// Occurrences are booked outright, so they go to the user's calendar right away
+			reservation, err := s.repo.GetReservationByID(*occurrence.ReservationID)
+			if err != nil {
+				log.Printf("Failed to get reservation %d of series %d: %v", *occurrence.ReservationID, series.ID, err)
+				continue
+			}
+			s.calendarSyncService.EnqueueCreate(reservation)
+		}
+		result.TotalPrice = roundCents(result.TotalPrice)
+	}
+
+	return result, nil
 }
 
-// parseTimeOfDay parses a time string in "HH:MM:SS" or "HH:MM" format to time.Time
-func parseTimeOfDay(timeStr string) (time.Time, error) {
-	// Try parsing as "HH:MM:SS"
-	t, err := time.Parse("15:04:05", timeStr)
+// GetReservationSeries returns a series and all of its occurrences for its owner
+func (s *ReservationService) GetReservationSeries(seriesID, userID int64) (*dto.ReservationSeriesDetailsDTO, error) {
+	series, err := s.repo.GetReservationSeries(seriesID)
 	if err != nil {
-		// Try parsing as "HH:MM"
-		t, err = time.Parse("15:04", timeStr)
-		if err != nil {
-			return time.Time{}, fmt.Errorf("invalid time format: %s", timeStr)
+		return nil, fmt.Errorf("failed to get reservation series: %w", err)
+	}
+	if series == nil || series.UserID != userID {
+		return nil, errors.New("reservation series not found")
+	}
+
+	reservations, err := s.repo.GetSeriesReservations(seriesID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get series reservations: %w", err)
+	}
+
+	return &dto.ReservationSeriesDetailsDTO{
+		Series:       series,
+		Reservations: reservations,
+	}, nil
+}
+
+// CancelReservationSeries cancels all remaining occurrences of a series starting at or after from.
+// Each occurrence is cancelled like a single reservation: under the facility's cancellation policy, with its
+// payment settled and refunded, its calendar event removed and a cancellation email. The series itself is
+// marked cancelled only once none of its occurrences is left to come.
+func (s *ReservationService) CancelReservationSeries(seriesID, userID int64, from time.Time) (int, error) {
+	if from.Before(time.Now()) {
+		from = time.Now()
+	}
+
+	series, err := s.repo.GetReservationSeries(seriesID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get reservation series: %w", err)
+	}
+	if series == nil || series.UserID != userID {
+		return 0, errors.New("reservation series not found")
+	}
+
+	reservations, err := s.repo.GetSeriesReservations(seriesID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get series reservations: %w", err)
+	}
+
+	cancelled := 0
+	var firstErr error
+	for i := range reservations {
+		reservation := &reservations[i]
+		if reservation.StartTime.Before(from) || (reservation.Status != "pending" && reservation.Status != "confirmed") {
+			continue
+		}
+
+		if _, err := s.cancelOwnReservation(reservation, userID); err != nil {
+			log.Printf("Failed to cancel reservation %d of series %d: %v", reservation.ID, seriesID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
 		}
+		cancelled++
 	}
-	return t, nil
+
+	if cancelled > 0 {
+		s.releaseSlot(series.FacilityID)
+	}
+
+	// The series itself is cancelled once nothing of it is left to come
+	if err := s.repo.CancelReservationSeries(seriesID, userID); err != nil {
+		log.Printf("Failed to cancel reservation series %d: %v", seriesID, err)
+	}
+	if cancelled == 0 && firstErr != nil {
+		return 0, firstErr
+	}
+
+	return cancelled, nil
 }
 
-// GetFacilityBookings retrieves all bookings for a facility within a date range
-func (s *ReservationService) GetFacilityBookings(facilityID int64, startDate, endDate time.Time) ([]dto.ReservationWithFacilityDTO, error) {
-	return s.repo.GetFacilityBookingsWithUserDetails(facilityID, startDate, endDate)
+// RescheduleReservation moves a pending or confirmed reservation to a new interval.
+// The new interval is validated like a new booking and against the facility's reschedule policy,
+// the price is recalculated and the payment and calendar event are updated to match.
+func (s *ReservationService) RescheduleReservation(reservationID, userID int64, req dto.RescheduleReservationDTO) (*dto.RescheduleReservationResultDTO, error) {
+	startTime, err := time.Parse(time.RFC3339, req.StartTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time format: %w", err)
+	}
+
+	endTime, err := time.Parse(time.RFC3339, req.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end time format: %w", err)
+	}
+
+	if !endTime.After(startTime) {
+		return nil, errors.New("end time must be after start time")
+	}
+
+	if startTime.Before(time.Now()) {
+		return nil, errors.New("cannot book in the past")
+	}
+
+	reservation, err := s.repo.GetReservationByID(reservationID)
+	if err != nil || reservation.UserID != userID {
+		return nil, errors.New("reservation not found")
+	}
+
+	switch reservation.Status {
+	case "pending":
+		if reservation.ExpiresAt != nil && !reservation.ExpiresAt.After(time.Now()) {
+			return nil, errors.New("reservation has expired, please book the slot again")
+		}
+	case "confirmed":
+	default:
+		return nil, fmt.Errorf("cannot reschedule a %s reservation", reservation.Status)
+	}
+
+	if startTime.Equal(reservation.StartTime) && endTime.Equal(reservation.EndTime) {
+		return nil, errors.New("reservation is already at the requested time")
+	}
+
+	policy, err := s.validateBookingWindow(reservation.FacilityID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if !policy.AllowReschedule {
+		return nil, errors.New("this facility does not allow rescheduling")
+	}
+
+	cutoff := reservation.StartTime.Add(-time.Duration(policy.RescheduleCutoffMinutes) * time.Minute)
+	if !time.Now().Before(cutoff) {
+		if policy.RescheduleCutoffMinutes > 0 {
+			return nil, fmt.Errorf("reservations can only be rescheduled up to %d minutes before they start", policy.RescheduleCutoffMinutes)
+		}
+		return nil, errors.New("cannot reschedule a reservation that has already started")
+	}
+
+	if policy.MaxReschedules != nil && reservation.RescheduleCount >= *policy.MaxReschedules {
+		return nil, fmt.Errorf("reservation cannot be rescheduled more than %d times", *policy.MaxReschedules)
+	}
+
+	priceItems, totalPrice, err := s.calculatePrice(reservation.FacilityID, startTime, endTime, reservation.Units)
+	if err != nil {
+		if errors.Is(err, ErrUnpricedInterval) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to calculate price: %w", err)
+	}
+
+	buffer := time.Duration(policy.BufferMinutes) * time.Minute
+	updated, payment, err := s.repo.RescheduleReservation(reservationID, startTime, endTime, buffer, totalPrice, priceItems)
+	if err != nil {
+		if errors.Is(err, ErrSlotTaken) || errors.Is(err, ErrPaymentInProgress) || errors.Is(err, repository.ErrSplitPaymentReschedule) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to reschedule reservation: %w", err)
+	}
+
+	// Return the difference when the new time is cheaper than what was paid
+	if payment != nil && payment.PaidAt != nil {
+		payment = s.refundService.ProcessPaymentRefunds(payment)
+	}
+
+	s.calendarSyncService.EnqueueUpdate(updated)
+
+	// The original time may be wanted by someone on the waitlist
+	s.releaseSlot(updated.FacilityID)
+
+	return &dto.RescheduleReservationResultDTO{
+		Reservation:     updated,
+		PreviousPrice:   reservation.TotalPrice,
+		NewPrice:        totalPrice,
+		PriceDifference: roundCents(totalPrice - reservation.TotalPrice),
+		Payment:         payment,
+	}, nil
+}
+
+// validateBookingWindow checks a requested interval against the facility's schedule and booking policy.
+// It returns the policy that was applied so callers can use its buffer for conflict checks.
+func (s *ReservationService) validateBookingWindow(facilityID int64, startTime, endTime time.Time) (*model.FacilityBookingPolicy, error) {
+	policy, err := s.getBookingPolicy(facilityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get booking policy: %w", err)
+	}
+
+	// Schedules are stored as local times of day in the facility's time zone
+	loc, err := s.facilityLocation(facilityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get facility time zone: %w", err)
+	}
+	start := startTime.In(loc)
+	end := endTime.In(loc)
+
+	plan, err := s.getDayPlan(facilityID, start)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkBookingWindow(policy, plan, start, end); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// checkBookingWindow checks an interval in the facility's time zone against the booking policy and the day's plan.
+// Durations and slot boundaries are measured in elapsed time, so they stay correct across daylight saving changes.
+func checkBookingWindow(policy *model.FacilityBookingPolicy, plan dayPlan, start, end time.Time) error {
+	duration := int(end.Sub(start).Minutes())
+	if end.Sub(start)%time.Minute != 0 || duration%policy.SlotMinutes != 0 {
+		return fmt.Errorf("booking duration must be a multiple of %d minutes", policy.SlotMinutes)
+	}
+
+	if duration < policy.MinDurationMinutes {
+		return fmt.Errorf("booking must be at least %d minutes long", policy.MinDurationMinutes)
+	}
+
+	if policy.MaxDurationMinutes != nil && duration > *policy.MaxDurationMinutes {
+		return fmt.Errorf("booking cannot be longer than %d minutes", *policy.MaxDurationMinutes)
+	}
+
+	if !plan.isOpen {
+		if plan.closureReason != nil && *plan.closureReason != "" {
+			return fmt.Errorf("facility is closed on the selected day: %s", *plan.closureReason)
+		}
+		return errors.New("facility is closed on the selected day")
+	}
+
+	if start.Before(plan.open) || end.After(plan.close) {
+		return fmt.Errorf("booking must be within opening hours (%s - %s)", plan.open.Format("15:04"), plan.close.Format("15:04"))
+	}
+
+	for _, b := range plan.blackouts {
+		if b.overlaps(start, end) {
+			return fmt.Errorf("facility is unavailable from %s to %s on the selected day", b.start.Format("15:04"), b.end.Format("15:04"))
+		}
+	}
+
+	if int(start.Sub(plan.open).Minutes())%policy.SlotMinutes != 0 {
+		return fmt.Errorf("booking must start on a %d-minute slot boundary", policy.SlotMinutes)
+	}
+
+	return nil
+}
+
+// getDayPlan loads the schedule and schedule exceptions of a facility and resolves the plan for the given day
+func (s *ReservationService) getDayPlan(facilityID int64, date time.Time) (dayPlan, error) {
+	schedules, err := s.repo.GetFacilitySchedules(facilityID)
+	if err != nil {
+		return dayPlan{}, fmt.Errorf("failed to get facility schedules: %w", err)
+	}
+
+	exceptions, err := s.repo.GetFacilityScheduleExceptions(facilityID, date, date)
+	if err != nil {
+		return dayPlan{}, fmt.Errorf("failed to get schedule exceptions: %w", err)
+	}
+
+	return resolveDayPlan(date, schedules, exceptions)
+}
+
+// calculatePrice splits the reservation across the facility's price bands and returns
+// the itemised breakdown and the total price. Hourly prices are per unit, so amounts are
+// multiplied by the units booked.
+func (s *ReservationService) calculatePrice(facilityID int64, startTime, endTime time.Time, units int) ([]model.PriceItem, float64, error) {
+	pricings, err := s.repo.GetFacilityPricing(facilityID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	schedules, err := s.repo.GetFacilitySchedules(facilityID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Prices are defined as local times of day in the facility's time zone
+	loc, err := s.facilityLocation(facilityID)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := startTime.In(loc)
+	end := endTime.In(loc)
+
+	exceptions, err := s.repo.GetFacilityScheduleExceptions(facilityID, start, end)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	priceItems, totalPrice, err := priceInterval(start, end, schedules, pricings, exceptions)
+	if err != nil || units <= 1 {
+		return priceItems, totalPrice, err
+	}
+
+	totalPrice = 0
+	for i := range priceItems {
+		priceItems[i].Amount = roundCents(priceItems[i].Amount * float64(units))
+		totalPrice += priceItems[i].Amount
+	}
+	return priceItems, roundCents(totalPrice), nil
+}
+
+// bookingUnits validates the units requested for a booking under the facility's policy.
+// Zero means one unit; exclusive-use facilities are always booked as a single unit.
+func bookingUnits(policy *model.FacilityBookingPolicy, units int) (int, error) {
+	if units == 0 {
+		units = 1
+	}
+	if units < 0 {
+		return 0, errors.New("units must be positive")
+	}
+
+	if policy.AvailabilityMode != model.AvailabilityShared {
+		if units != 1 {
+			return 0, errors.New("this facility can only be booked as a whole")
+		}
+		return units, nil
+	}
+
+	if units > policy.Capacity {
+		return 0, fmt.Errorf("this facility has only %d units", policy.Capacity)
+	}
+	return units, nil
+}
+
+// GetUserReservations retrieves all reservations for a user
+func (s *ReservationService) GetUserReservations(userID int64) ([]model.FacilityReservation, error) {
+	return s.repo.GetUserReservations(userID)
+}
+
+// GetUpcomingConfirmedReservations retrieves upcoming confirmed reservations for a user
+func (s *ReservationService) GetUpcomingConfirmedReservations(userID int64) ([]dto.ReservationWithFacilityDTO, error) {
+	return s.repo.GetUpcomingConfirmedReservationsWithDetails(userID)
+}
+
+// GetPendingReservationsCount retrieves the count of pending reservations for a user
+func (s *ReservationService) GetPendingReservationsCount(userID int64) (int, error) {
+	return s.repo.GetPendingReservationsCount(userID)
+}
+
+// CancelReservation cancels a reservation and refunds its payment according to the facility's cancellation policy
+func (s *ReservationService) CancelReservation(reservationID, userID int64) (*model.CancellationOutcome, error) {
+	existing, err := s.repo.GetReservationByID(reservationID)
+	if err != nil || existing.UserID != userID {
+		return nil, errors.New("reservation not found")
+	}
+
+	outcome, err := s.cancelOwnReservation(existing, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.releaseSlot(existing.FacilityID)
+
+	return outcome, nil
+}
+
+// cancelOwnReservation cancels a reservation on behalf of its owner under the facility's cancellation policy,
+// refunds its payment, removes its calendar event and emails the outcome. The freed slot is not offered to the
+// waitlist, so callers cancelling several reservations do that once.
+func (s *ReservationService) cancelOwnReservation(existing *model.FacilityReservation, userID int64) (*model.CancellationOutcome, error) {
+	policy, err := s.repo.GetFacilityCancellationPolicy(existing.FacilityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cancellation policy: %w", err)
+	}
+
+	now := time.Now()
+	if !existing.StartTime.After(now) {
+		return nil, ErrReservationStarted
+	}
+	hoursBefore := existing.StartTime.Sub(now).Hours()
+	rule, refundPercent := applyCancellationPolicy(policy, hoursBefore)
+
+	// Cancel reservation and settle its payment
+	reservation, payment, err := s.repo.CancelReservation(existing.ID, userID, refundPercent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel reservation: %w", err)
+	}
+
+	outcome := &model.CancellationOutcome{
+		ReservationID: reservation.ID,
+		HoursBefore:   math.Round(hoursBefore*10) / 10,
+		AppliedRule:   rule,
+		RefundPercent: refundPercent,
+		CancelledAt:   now,
+	}
+	if payment != nil {
+		// Return the refund the cancellation recorded
+		payment = s.refundService.ProcessPaymentRefunds(payment)
+		outcome.PaymentStatus = &payment.PaymentStatus
+		if payment.PaidAt != nil {
+			outcome.AmountPaid = payment.Amount
+			outcome.RefundAmount = payment.RefundedAmount
+		}
+	}
+
+	s.calendarSyncService.EnqueueDelete(reservation)
+
+	s.sendCancellationEmail(reservation, outcome, cancellationPolicyNote(policy, rule, outcome))
+
+	return outcome, nil
+}
+
+// maxCancellationReasonLength limits the reason a manager gives when cancelling a booking
+const maxCancellationReasonLength = 500
+
+// ManagerCancelReservation cancels an upcoming booking on behalf of the facility's manager.
+// The customer is refunded in full regardless of the cancellation policy and notified by email with the reason.
+func (s *ReservationService) ManagerCancelReservation(reservationID, managerID int64, req dto.ManagerCancelReservationDTO) (*model.CancellationOutcome, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("a cancellation reason is required")
+	}
+	if len(reason) > maxCancellationReasonLength {
+		return nil, fmt.Errorf("cancellation reason cannot be longer than %d characters", maxCancellationReasonLength)
+	}
+
+	existing, err := s.repo.GetReservationByID(reservationID)
+	if err != nil {
+		return nil, errors.New("reservation not found")
+	}
+
+	if err := checkFacilityManager(s.facilityService, existing.FacilityID, managerID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !existing.EndTime.After(now) {
+		return nil, errors.New("cannot cancel a booking that has already ended")
+	}
+
+	reservation, payment, err := s.repo.CancelReservationByManager(reservationID, managerID, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel reservation: %w", err)
+	}
+
+	outcome := &model.CancellationOutcome{
+		ReservationID: reservation.ID,
+		HoursBefore:   math.Round(existing.StartTime.Sub(now).Hours()*10) / 10,
+		RefundPercent: 100,
+		CancelledAt:   now,
+	}
+	if payment != nil {
+		// Return the refund the cancellation recorded
+		payment = s.refundService.ProcessPaymentRefunds(payment)
+		outcome.PaymentStatus = &payment.PaymentStatus
+		if payment.PaidAt != nil {
+			outcome.AmountPaid = payment.Amount
+			outcome.RefundAmount = payment.RefundedAmount
+		}
+	}
+
+	s.calendarSyncService.EnqueueDelete(reservation)
+
+	policyNote := ""
+	if outcome.AmountPaid > 0 {
+		policyNote = "Because the facility cancelled this booking, your payment is refunded in full."
+	}
+	s.sendCancellationEmail(reservation, outcome, policyNote)
+	s.releaseSlot(reservation.FacilityID)
+
+	return outcome, nil
+}
+
+// MarkNoShow records that the customer did not show up for a booking that has ended.
+// It returns the updated reservation with the customer's total number of no-shows.
+func (s *ReservationService) MarkNoShow(reservationID, managerID int64) (*dto.NoShowResultDTO, error) {
+	existing, err := s.repo.GetReservationByID(reservationID)
+	if err != nil {
+		return nil, errors.New("reservation not found")
+	}
+
+	if err := checkFacilityManager(s.facilityService, existing.FacilityID, managerID); err != nil {
+		return nil, err
+	}
+
+	reservation, err := s.repo.MarkReservationNoShow(reservationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark reservation as no-show: %w", err)
+	}
+	if reservation == nil {
+		return nil, errors.New("only confirmed or completed bookings that have ended can be marked as no-show")
+	}
+
+	count, err := s.repo.GetUserNoShowCount(reservation.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count no-shows: %w", err)
+	}
+
+	return &dto.NoShowResultDTO{
+		Reservation:     reservation,
+		UserNoShowCount: count,
+	}, nil
+}
+
+// CreateManagerBooking books a walk-in or phone customer on behalf of the facility's manager.
+// The customer is an existing account, found by email, or a guest identified by name and phone number.
+// The booking is confirmed right away and recorded with the manager who created it; its payment is
+// taken on site, either immediately when req.Paid is set or later through MarkBookingPaid.
+func (s *ReservationService) CreateManagerBooking(managerID int64, req dto.CreateManagerBookingDTO) (*dto.ManagerBookingResultDTO, error) {
+	if err := checkFacilityManager(s.facilityService, req.FacilityID, managerID); err != nil {
+		return nil, err
+	}
+
+	var userID *int64
+	var guestName, guestPhone *string
+	if email := strings.TrimSpace(req.CustomerEmail); email != "" {
+		user, err := s.userService.GetUserByEmail(email)
+		if err != nil || user == nil {
+			return nil, errors.New("no customer account found with this email")
+		}
+		userID = &user.ID
+	} else {
+		name, phone, err := validateGuest(req.GuestName, req.GuestPhone)
+		if err != nil {
+			return nil, err
+		}
+		guestName, guestPhone = &name, &phone
+	}
+
+	startTime, err := time.Parse(time.RFC3339, req.StartTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time format: %w", err)
+	}
+
+	endTime, err := time.Parse(time.RFC3339, req.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end time format: %w", err)
+	}
+
+	if !endTime.After(startTime) {
+		return nil, errors.New("end time must be after start time")
+	}
+
+	// Walk-in customers may take a slot that has already started
+	if !endTime.After(time.Now()) {
+		return nil, errors.New("cannot book in the past")
+	}
+
+	policy, err := s.validateBookingWindow(req.FacilityID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := bookingUnits(policy, req.Units)
+	if err != nil {
+		return nil, err
+	}
+
+	priceItems, totalPrice, err := s.calculatePrice(req.FacilityID, startTime, endTime, units)
+	if err != nil {
+		if errors.Is(err, ErrUnpricedInterval) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to calculate price: %w", err)
+	}
+
+	buffer := time.Duration(policy.BufferMinutes) * time.Minute
+	reservation, payment, err := s.repo.CreateManagerBooking(userID, guestName, guestPhone, managerID, req.FacilityID,
+		startTime, endTime, units, buffer, totalPrice, priceItems, req.Paid, "EUR")
+	if err != nil {
+		if errors.Is(err, ErrSlotTaken) {
+			return nil, ErrSlotTaken
+		}
+		return nil, fmt.Errorf("failed to create booking: %w", err)
+	}
+
+	if payment.PaymentStatus == "completed" {
+		s.calendarSyncService.EnqueueCreate(reservation)
+		s.sendBookingConfirmationEmail(reservation, payment)
+	}
+
+	return &dto.ManagerBookingResultDTO{
+		Reservation: reservation,
+		Payment:     payment,
+	}, nil
+}
+
+// maxGuestNameLength and maxGuestPhoneLength match the guest columns of facility_reservations
+const (
+	maxGuestNameLength  = 255
+	maxGuestPhoneLength = 50
+)
+
+// validateGuest trims and checks the name and phone number of a guest without an account
+func validateGuest(name, phone string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	phone = strings.TrimSpace(phone)
+
+	if name == "" {
+		return "", "", errors.New("guest name is required when no customer email is given")
+	}
+	if len(name) > maxGuestNameLength {
+		return "", "", fmt.Errorf("guest name cannot be longer than %d characters", maxGuestNameLength)
+	}
+
+	if phone == "" {
+		return "", "", errors.New("guest phone number is required when no customer email is given")
+	}
+	if len(phone) > maxGuestPhoneLength {
+		return "", "", fmt.Errorf("guest phone number cannot be longer than %d characters", maxGuestPhoneLength)
+	}
+	digits := 0
+	for _, c := range phone {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '+' || c == ' ' || c == '-' || c == '(' || c == ')':
+		default:
+			return "", "", errors.New("guest phone number may only contain digits, spaces and + - ( )")
+		}
+	}
+	if digits < 5 {
+		return "", "", errors.New("guest phone number is too short")
+	}
+
+	return name, phone, nil
+}
+
+// MarkBookingPaid records that the customer paid a booking on site, confirming it if it was pending
+func (s *ReservationService) MarkBookingPaid(reservationID, managerID int64) (*model.Payment, error) {
+	existing, err := s.repo.GetReservationByID(reservationID)
+	if err != nil {
+		return nil, errors.New("reservation not found")
+	}
+
+	if err := checkFacilityManager(s.facilityService, existing.FacilityID, managerID); err != nil {
+		return nil, err
+	}
+
+	if existing.Status != "pending" && existing.Status != "confirmed" {
+		return nil, fmt.Errorf("cannot mark a %s booking as paid", existing.Status)
+	}
+	if existing.Status == "pending" && existing.ExpiresAt != nil && !existing.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("reservation has expired")
+	}
+
+	payment, err := s.repo.MarkReservationPaidOnSite(reservationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark booking as paid: %w", err)
+	}
+	if payment == nil {
+		return nil, errors.New("booking has no pending payment")
+	}
+
+	if existing.Status == "pending" {
+		if err := s.repo.UpdateReservationStatus(reservationID, "confirmed"); err != nil {
+			return nil, fmt.Errorf("failed to confirm booking: %w", err)
+		}
+		existing.Status = "confirmed"
+	}
+
+	s.calendarSyncService.EnqueueCreate(existing)
+	s.sendBookingConfirmationEmail(existing, payment)
+
+	return payment, nil
+}
+
+// sendBookingConfirmationEmail asynchronously sends the payment confirmation for a booking made by staff.
+// Guests have no account to email and are skipped.
+func (s *ReservationService) sendBookingConfirmationEmail(reservation *model.FacilityReservation, payment *model.Payment) {
+	if reservation.UserID == 0 {
+		return
+	}
+
+	go func() {
+		user, err := s.userService.GetUserByID(reservation.UserID)
+		if err != nil {
+			log.Printf("Failed to get user %d: %v", reservation.UserID, err)
+			return
+		}
+
+		facility, err := s.facilityService.GetFacilityDetailsByID(reservation.FacilityID)
+		if err != nil {
+			log.Printf("Failed to get facility %d: %v", reservation.FacilityID, err)
+			return
+		}
+
+		priceItems, err := s.repo.GetReservationPriceItems(reservation.ID)
+		if err != nil {
+			log.Printf("Failed to get price breakdown for reservation %d: %v", reservation.ID, err)
+		}
+
+		loc := loadLocation(facility.TimeZone)
+		err = s.emailService.SendPaymentConfirmationEmail(
+			user.Email,
+			user.Name,
+			facility.Name,
+			facility.Address,
+			facility.City,
+			facility.CategoryName,
+			facility.SportName,
+			reservation.StartTime.In(loc),
+			reservation.EndTime.In(loc),
+			payment.Amount,
+			payment.PaymentMethod,
+			priceItems,
+			reservationInvite(reservation, facility),
+		)
+		if err != nil {
+			log.Printf("Failed to send booking confirmation email for reservation %d: %v", reservation.ID, err)
+		}
+	}()
+}
+
+// applyCancellationPolicy returns the rule that applies to a cancellation made hoursBefore hours before
+// the booking starts, and the refund percentage. Without rules the booking is refunded in full, unless it
+// has already started.
+func applyCancellationPolicy(policy *model.CancellationPolicy, hoursBefore float64) (*model.CancellationRule, float64) {
+	if hoursBefore <= 0 {
+		return nil, 0
+	}
+	if policy == nil || len(policy.Rules) == 0 {
+		return nil, 100
+	}
+
+	// Rules are ordered by HoursBefore descending, so the first match is the most generous tier reached
+	for i := range policy.Rules {
+		if hoursBefore >= float64(policy.Rules[i].HoursBefore) {
+			return &policy.Rules[i], policy.Rules[i].RefundPercent
+		}
+	}
+
+	return nil, 0
+}
+
+// cancellationPolicyNote describes the policy outcome for the cancellation email
+func cancellationPolicyNote(policy *model.CancellationPolicy, rule *model.CancellationRule, outcome *model.CancellationOutcome) string {
+	if outcome.AmountPaid == 0 {
+		return ""
+	}
+	if policy == nil || len(policy.Rules) == 0 {
+		return "This facility offers free cancellation, so your payment is refunded in full."
+	}
+	if rule == nil {
+		return "The booking was cancelled too close to its start time to qualify for a refund."
+	}
+	return fmt.Sprintf("Cancelling at least %d hours before the start qualifies for a %.0f%% refund.", rule.HoursBefore, rule.RefundPercent)
+}
+
+// sendCancellationEmail notifies the user about a cancelled booking asynchronously
+func (s *ReservationService) sendCancellationEmail(reservation *model.FacilityReservation, outcome *model.CancellationOutcome, policyNote string) {
+	// Guests booked by staff have no account to email
+	if reservation.UserID == 0 {
+		return
+	}
+
+	go func() {
+		user, err := s.userService.GetUserByID(reservation.UserID)
+		if err != nil {
+			log.Printf("Failed to get user %d: %v", reservation.UserID, err)
+			return
+		}
+
+		facility, err := s.facilityService.GetFacilityDetailsByID(reservation.FacilityID)
+		if err != nil {
+			log.Printf("Failed to get facility %d: %v", reservation.FacilityID, err)
+			return
+		}
+
+		reason := ""
+		if reservation.CancellationReason != nil {
+			reason = *reservation.CancellationReason
+		}
+
+		loc := loadLocation(facility.TimeZone)
+		err = s.emailService.SendReservationCancellationEmail(
+			user.Email,
+			user.Name,
+			facility.Name,
+			facility.Address,
+			facility.City,
+			facility.SportName,
+			reservation.StartTime.In(loc),
+			reservation.EndTime.In(loc),
+			outcome.AmountPaid,
+			outcome.RefundAmount,
+			reason,
+			policyNote,
+			reservationInvite(reservation, facility),
+		)
+		if err != nil {
+			log.Printf("Failed to send cancellation email for reservation %d: %v", reservation.ID, err)
+		}
+	}()
+}
+
+// parseTimeOfDay parses a time string in "HH:MM:SS" or "HH:MM" format to time.Time
+func parseTimeOfDay(timeStr string) (time.Time, error) {
+	// Try parsing as "HH:MM:SS"
+	t, err := time.Parse("15:04:05", timeStr)
+	if err != nil {
+		// Try parsing as "HH:MM"
+		t, err = time.Parse("15:04", timeStr)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time format: %s", timeStr)
+		}
+	}
+	return t, nil
+}
+
+// GetFacilityBookings retrieves all bookings for a facility within a date range, for the facility's manager
+func (s *ReservationService) GetFacilityBookings(facilityID, managerID int64, startDate, endDate time.Time) ([]dto.ReservationWithFacilityDTO, error) {
+	if err := checkFacilityManager(s.facilityService, facilityID, managerID); err != nil {
+		return nil, err
+	}
+
+	loc, err := s.facilityLocation(facilityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get facility time zone: %w", err)
+	}
+
+	return s.repo.GetFacilityBookingsWithUserDetails(facilityID, localDate(startDate, loc), localDate(endDate, loc))
 }
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/model"
+)
+
+// Europe/Sofia moves from EET (+02:00) to EEST (+03:00) at 03:00 on 2026-03-29 and
+// back at 04:00 on 2026-10-25; both days are Sundays.
+const sofia = "Europe/Sofia"
+
+var (
+	springForward = time.Date(2026, 3, 29, 0, 0, 0, 0, time.UTC)
+	fallBack      = time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)
+)
+
+func sofiaLocation(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(sofia)
+	if err != nil {
+		t.Fatalf("load %s: %v", sofia, err)
+	}
+	return loc
+}
+
+// mustParse parses an RFC 3339 time, the format booking requests use
+func mustParse(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("parse %q: %v", value, err)
+	}
+	return parsed
+}
+
+func sundaySchedule(open, close string) []model.FacilitySchedule {
+	return []model.FacilitySchedule{{OpenTime: open, CloseTime: close, DayType: model.DayTypeSunday}}
+}
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeZone string
+		want     string
+	}{
+		{name: "known zone", timeZone: "America/New_York", want: "America/New_York"},
+		{name: "empty falls back to default", timeZone: "", want: model.DefaultTimeZone},
+		{name: "unknown falls back to default", timeZone: "Mars/Olympus_Mons", want: model.DefaultTimeZone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loadLocation(tt.timeZone).String(); got != tt.want {
+				t.Errorf("loadLocation(%q) = %s, want %s", tt.timeZone, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildDayAvailabilityAcrossDSTChanges(t *testing.T) {
+	loc := sofiaLocation(t)
+	s := &ReservationService{}
+	pricings := []model.FacilityPricing{{DayType: model.DayTypeSunday, StartHour: "00:00", EndHour: "24:00", PricePerHour: 20}}
+
+	tests := []struct {
+		name       string
+		date       time.Time
+		wantStarts []string
+		wantEnds   []string
+		wantOffset []int // UTC offset of each slot start, in hours
+	}{
+		{
+			name:       "spring forward skips 03:00",
+			date:       springForward,
+			wantStarts: []string{"00:00", "01:00", "02:00", "04:00", "05:00"},
+			wantEnds:   []string{"01:00", "02:00", "04:00", "05:00", "06:00"},
+			wantOffset: []int{2, 2, 2, 3, 3},
+		},
+		{
+			name:       "fall back repeats 03:00",
+			date:       fallBack,
+			wantStarts: []string{"00:00", "01:00", "02:00", "03:00", "03:00", "04:00", "05:00"},
+			wantEnds:   []string{"01:00", "02:00", "03:00", "03:00", "04:00", "05:00", "06:00"},
+			wantOffset: []int{3, 3, 3, 3, 2, 2, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day := s.buildDayAvailability(localDate(tt.date, loc), sundaySchedule("00:00:00", "06:00:00"), nil, pricings, nil, model.DefaultBookingPolicy(1))
+
+			if day.TimeZone != sofia {
+				t.Errorf("time zone = %s, want %s", day.TimeZone, sofia)
+			}
+			if len(day.Slots) != len(tt.wantStarts) {
+				t.Fatalf("got %d slots, want %d", len(day.Slots), len(tt.wantStarts))
+			}
+
+			for i, slot := range day.Slots {
+				if slot.StartTime != tt.wantStarts[i] || slot.EndTime != tt.wantEnds[i] {
+					t.Errorf("slot %d = %s-%s, want %s-%s", i, slot.StartTime, slot.EndTime, tt.wantStarts[i], tt.wantEnds[i])
+				}
+				if got := slot.EndsAt.Sub(slot.StartsAt); got != time.Hour {
+					t.Errorf("slot %d lasts %s, want 1h", i, got)
+				}
+				if _, offset := slot.StartsAt.Zone(); offset != tt.wantOffset[i]*3600 {
+					t.Errorf("slot %d starts at UTC%+d, want UTC%+d", i, offset/3600, tt.wantOffset[i])
+				}
+				if !slot.Available || slot.PricePerHour != 20 {
+					t.Errorf("slot %d available=%v price=%v, want available at 20", i, slot.Available, slot.PricePerHour)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckBookingWindowAcrossDSTChanges(t *testing.T) {
+	loc := sofiaLocation(t)
+	maxDuration := 120
+	policy := model.DefaultBookingPolicy(1)
+	policy.MaxDurationMinutes = &maxDuration
+
+	tests := []struct {
+		name    string
+		date    time.Time
+		start   string
+		end     string
+		wantErr string
+	}{
+		{name: "spring forward hour across the gap", date: springForward, start: "2026-03-29T02:00:00+02:00", end: "2026-03-29T04:00:00+03:00"},
+		{name: "spring forward 00:00-04:00 lasts three hours", date: springForward, start: "2026-03-29T00:00:00+02:00", end: "2026-03-29T04:00:00+03:00", wantErr: "cannot be longer than 120 minutes"},
+		{name: "spring forward 02:00-05:00 lasts two hours", date: springForward, start: "2026-03-29T02:00:00+02:00", end: "2026-03-29T05:00:00+03:00"},
+		{name: "spring forward slot after the gap", date: springForward, start: "2026-03-29T04:00:00+03:00", end: "2026-03-29T05:00:00+03:00"},
+		{name: "fall back first 03:00", date: fallBack, start: "2026-10-25T03:00:00+03:00", end: "2026-10-25T03:00:00+02:00"},
+		{name: "fall back repeated 03:00", date: fallBack, start: "2026-10-25T03:00:00+02:00", end: "2026-10-25T04:00:00+02:00"},
+		{name: "fall back 02:00-04:00 lasts three hours", date: fallBack, start: "2026-10-25T02:00:00+03:00", end: "2026-10-25T04:00:00+02:00", wantErr: "cannot be longer than 120 minutes"},
+		{name: "fall back half hour off the slot grid", date: fallBack, start: "2026-10-25T03:30:00+03:00", end: "2026-10-25T03:30:00+02:00", wantErr: "slot boundary"},
+		{name: "fall back zero length across the change", date: fallBack, start: "2026-10-25T03:00:00+02:00", end: "2026-10-25T04:00:00+03:00", wantErr: "at least 60 minutes"},
+		{name: "fall back after closing", date: fallBack, start: "2026-10-25T05:00:00+02:00", end: "2026-10-25T07:00:00+02:00", wantErr: "within opening hours"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := mustParse(t, tt.start).In(loc)
+			end := mustParse(t, tt.end).In(loc)
+
+			plan, err := resolveDayPlan(localDate(tt.date, loc), sundaySchedule("00:00:00", "06:00:00"), nil)
+			if err != nil {
+				t.Fatalf("resolve day plan: %v", err)
+			}
+
+			err = checkBookingWindow(policy, plan, start, end)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("expected error containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPriceIntervalAcrossDSTChanges(t *testing.T) {
+	loc := sofiaLocation(t)
+	schedules := sundaySchedule("00:00:00", "23:00:00")
+	pricings := []model.FacilityPricing{
+		{DayType: model.DayTypeSunday, StartHour: "00:00", EndHour: "03:00", PricePerHour: 10},
+		{DayType: model.DayTypeSunday, StartHour: "03:00", EndHour: "24:00", PricePerHour: 20},
+	}
+
+	type item struct {
+		start, end string
+		amount     float64
+	}
+
+	tests := []struct {
+		name      string
+		start     string
+		end       string
+		wantItems []item
+		wantTotal float64
+	}{
+		{
+			// 03:00 does not exist, so the night band runs until the clocks jump to 04:00
+			name:      "spring forward",
+			start:     "2026-03-29T01:00:00+02:00",
+			end:       "2026-03-29T05:00:00+03:00",
+			wantItems: []item{{"01:00", "04:00", 20}, {"04:00", "05:00", 20}},
+			wantTotal: 40,
+		},
+		{
+			// The night band ends at the first 03:00, so both runs of the repeated hour are charged at the day band price
+			name:      "fall back",
+			start:     "2026-10-25T01:00:00+03:00",
+			end:       "2026-10-25T05:00:00+02:00",
+			wantItems: []item{{"01:00", "03:00", 20}, {"03:00", "05:00", 60}},
+			wantTotal: 80,
+		},
+		{
+			name:      "fall back repeated hour only",
+			start:     "2026-10-25T03:00:00+02:00",
+			end:       "2026-10-25T04:00:00+02:00",
+			wantItems: []item{{"03:00", "04:00", 20}},
+			wantTotal: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, total, err := priceInterval(mustParse(t, tt.start).In(loc), mustParse(t, tt.end).In(loc), schedules, pricings, nil)
+			if err != nil {
+				t.Fatalf("price interval: %v", err)
+			}
+
+			if total != tt.wantTotal {
+				t.Errorf("total = %v, want %v", total, tt.wantTotal)
+			}
+			if len(items) != len(tt.wantItems) {
+				t.Fatalf("got %d items, want %d", len(items), len(tt.wantItems))
+			}
+
+			var elapsed time.Duration
+			for i, got := range items {
+				want := tt.wantItems[i]
+				if got.StartTime.Format("15:04") != want.start || got.EndTime.Format("15:04") != want.end || got.Amount != want.amount {
+					t.Errorf("item %d = %s-%s %v, want %s-%s %v", i, got.StartTime.Format("15:04"), got.EndTime.Format("15:04"), got.Amount, want.start, want.end, want.amount)
+				}
+				elapsed += got.EndTime.Sub(got.StartTime)
+			}
+
+			if want := mustParse(t, tt.end).Sub(mustParse(t, tt.start)); elapsed != want {
+				t.Errorf("items cover %s, want %s", elapsed, want)
+			}
+		})
+	}
+}
+
+func TestApplyCancellationPolicy(t *testing.T) {
+	tiered := &model.CancellationPolicy{Rules: []model.CancellationRule{
+		{HoursBefore: 24, RefundPercent: 100},
+		{HoursBefore: 6, RefundPercent: 50},
+	}}
+
+	tests := []struct {
+		name        string
+		policy      *model.CancellationPolicy
+		hoursBefore float64
+		wantRule    int // HoursBefore of the applied rule, -1 for none
+		wantPercent float64
+	}{
+		{name: "no policy", policy: nil, hoursBefore: 1, wantRule: -1, wantPercent: 100},
+		{name: "no rules", policy: &model.CancellationPolicy{}, hoursBefore: 1, wantRule: -1, wantPercent: 100},
+		{name: "no rules after the start", policy: &model.CancellationPolicy{}, hoursBefore: -0.5, wantRule: -1, wantPercent: 0},
+		{name: "no rules at the start", policy: nil, hoursBefore: 0, wantRule: -1, wantPercent: 0},
+		{name: "first tier", policy: tiered, hoursBefore: 30, wantRule: 24, wantPercent: 100},
+		{name: "tier boundary", policy: tiered, hoursBefore: 6, wantRule: 6, wantPercent: 50},
+		{name: "too late for a tier", policy: tiered, hoursBefore: 5.9, wantRule: -1, wantPercent: 0},
+		{name: "tiers after the start", policy: tiered, hoursBefore: -2, wantRule: -1, wantPercent: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, percent := applyCancellationPolicy(tt.policy, tt.hoursBefore)
+
+			gotRule := -1
+			if rule != nil {
+				gotRule = rule.HoursBefore
+			}
+			if gotRule != tt.wantRule || percent != tt.wantPercent {
+				t.Errorf("applyCancellationPolicy(%v) = rule %d at %v%%, want rule %d at %v%%", tt.hoursBefore, gotRule, percent, tt.wantRule, tt.wantPercent)
+			}
+		})
+	}
+}
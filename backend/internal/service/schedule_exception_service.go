@@ -0,0 +1,234 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+)
+
+// ErrNotManager is returned when a user tries to change the settings of a facility or complex they do not manage
+var ErrNotManager = errors.New("unauthorized: you do not manage this facility or sport complex")
+
+// maxExceptionDays limits how many days a single request can create exceptions for
+const maxExceptionDays = 366
+
+type ScheduleExceptionService struct {
+	repo                *repository.ScheduleExceptionRepository
+	facilityService     *FacilityService
+	sportComplexService *SportComplexService
+}
+
+func NewScheduleExceptionService(
+	repo *repository.ScheduleExceptionRepository,
+	facilityService *FacilityService,
+	sportComplexService *SportComplexService,
+) *ScheduleExceptionService {
+	return &ScheduleExceptionService{
+		repo:                repo,
+		facilityService:     facilityService,
+		sportComplexService: sportComplexService,
+	}
+}
+
+// CreateFacilityExceptions creates closures, special hours or special prices for a single facility
+func (s *ScheduleExceptionService) CreateFacilityExceptions(facilityID, userID int64, req dto.CreateScheduleExceptionDTO) ([]model.ScheduleException, error) {
+	if err := checkFacilityManager(s.facilityService, facilityID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.createExceptions(&facilityID, nil, userID, req)
+}
+
+// CreateComplexExceptions creates closures, special hours or special prices for every facility of a sport complex
+func (s *ScheduleExceptionService) CreateComplexExceptions(complexID, userID int64, req dto.CreateScheduleExceptionDTO) ([]model.ScheduleException, error) {
+	if err := checkComplexManager(s.sportComplexService, complexID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.createExceptions(nil, &complexID, userID, req)
+}
+
+// GetFacilityExceptions returns the exceptions affecting a facility, including those of its sport complex
+func (s *ScheduleExceptionService) GetFacilityExceptions(facilityID, userID int64, startDate, endDate time.Time) ([]model.ScheduleException, error) {
+	if err := checkFacilityManager(s.facilityService, facilityID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetExceptionsForFacility(facilityID, startDate, endDate)
+}
+
+// GetComplexExceptions returns the exceptions defined for a whole sport complex
+func (s *ScheduleExceptionService) GetComplexExceptions(complexID, userID int64, startDate, endDate time.Time) ([]model.ScheduleException, error) {
+	if err := checkComplexManager(s.sportComplexService, complexID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetExceptionsForComplex(complexID, startDate, endDate)
+}
+
+// DeleteException removes an exception after checking the user manages its facility or complex
+func (s *ScheduleExceptionService) DeleteException(exceptionID, userID int64) error {
+	exception, err := s.repo.GetExceptionByID(exceptionID)
+	if err != nil {
+		return fmt.Errorf("failed to get schedule exception: %w", err)
+	}
+	if exception == nil {
+		return errors.New("schedule exception not found")
+	}
+
+	if exception.FacilityID != nil {
+		err = checkFacilityManager(s.facilityService, *exception.FacilityID, userID)
+	} else {
+		err = checkComplexManager(s.sportComplexService, *exception.SportComplexID, userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.repo.DeleteException(exceptionID)
+}
+
+func (s *ScheduleExceptionService) createExceptions(facilityID, complexID *int64, userID int64, req dto.CreateScheduleExceptionDTO) ([]model.ScheduleException, error) {
+	dates, err := exceptionDates(req.Date, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	exceptionType := model.ScheduleExceptionType(req.Type)
+	startTime, endTime, err := validateExceptionFields(exceptionType, req)
+	if err != nil {
+		return nil, err
+	}
+
+	exceptions := make([]model.ScheduleException, 0, len(dates))
+	for _, date := range dates {
+		exceptions = append(exceptions, model.ScheduleException{
+			FacilityID:     facilityID,
+			SportComplexID: complexID,
+			Date:           date,
+			Type:           exceptionType,
+			StartTime:      startTime,
+			EndTime:        endTime,
+			PricePerHour:   req.PricePerHour,
+			Reason:         req.Reason,
+			CreatedBy:      &userID,
+		})
+	}
+
+	if err := s.repo.CreateExceptions(exceptions); err != nil {
+		return nil, err
+	}
+
+	return exceptions, nil
+}
+
+// exceptionDates expands a date and optional end date into the list of days it covers
+func exceptionDates(dateStr string, endDateStr *string) ([]string, error) {
+	date, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return nil, errors.New("invalid date format. Use YYYY-MM-DD")
+	}
+
+	endDate := date
+	if endDateStr != nil && *endDateStr != "" {
+		endDate, err = time.Parse("2006-01-02", *endDateStr)
+		if err != nil {
+			return nil, errors.New("invalid end_date format. Use YYYY-MM-DD")
+		}
+		if endDate.Before(date) {
+			return nil, errors.New("end_date must not be before date")
+		}
+	}
+
+	var dates []string
+	for d := date; !d.After(endDate); d = d.AddDate(0, 0, 1) {
+		if len(dates) == maxExceptionDays {
+			return nil, fmt.Errorf("an exception cannot span more than %d days", maxExceptionDays)
+		}
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+
+	return dates, nil
+}
+
+// validateExceptionFields checks the fields required by each exception type and normalizes the time window
+func validateExceptionFields(exceptionType model.ScheduleExceptionType, req dto.CreateScheduleExceptionDTO) (*string, *string, error) {
+	hasStart := req.StartTime != nil && *req.StartTime != ""
+	hasEnd := req.EndTime != nil && *req.EndTime != ""
+	if hasStart != hasEnd {
+		return nil, nil, errors.New("start_time and end_time must be provided together")
+	}
+
+	var startTime, endTime *string
+	if hasStart {
+		start, err := parseTimeOfDay(*req.StartTime)
+		if err != nil {
+			return nil, nil, err
+		}
+		end, err := parseTimeOfDay(*req.EndTime)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !start.Before(end) {
+			return nil, nil, errors.New("start_time must be before end_time")
+		}
+		startStr := start.Format("15:04:05")
+		endStr := end.Format("15:04:05")
+		startTime = &startStr
+		endTime = &endStr
+	}
+
+	switch exceptionType {
+	case model.ScheduleExceptionClosed:
+		if req.PricePerHour != nil {
+			return nil, nil, errors.New("price_per_hour is only allowed for special_price exceptions")
+		}
+	case model.ScheduleExceptionSpecialHours:
+		if !hasStart {
+			return nil, nil, errors.New("special_hours exceptions require start_time and end_time")
+		}
+		if req.PricePerHour != nil {
+			return nil, nil, errors.New("price_per_hour is only allowed for special_price exceptions")
+		}
+	case model.ScheduleExceptionSpecialPrice:
+		if req.PricePerHour == nil || *req.PricePerHour < 0 {
+			return nil, nil, errors.New("special_price exceptions require a non-negative price_per_hour")
+		}
+	default:
+		return nil, nil, fmt.Errorf("invalid exception type: %s", req.Type)
+	}
+
+	return startTime, endTime, nil
+}
+
+// checkFacilityManager returns ErrNotManager unless userID manages the facility
+func checkFacilityManager(facilityService *FacilityService, facilityID, userID int64) error {
+	facility, err := facilityService.GetFacilityByID(facilityID)
+	if err != nil {
+		return fmt.Errorf("facility not found: %w", err)
+	}
+
+	if facility.ManagerID == nil || *facility.ManagerID != userID {
+		return ErrNotManager
+	}
+
+	return nil
+}
+
+// checkComplexManager returns ErrNotManager unless userID manages the sport complex
+func checkComplexManager(sportComplexService *SportComplexService, complexID, userID int64) error {
+	complex, err := sportComplexService.GetSportComplexByID(complexID)
+	if err != nil {
+		return fmt.Errorf("sport complex not found: %w", err)
+	}
+
+	if complex.ManagerID == nil || *complex.ManagerID != userID {
+		return ErrNotManager
+	}
+
+	return nil
+}
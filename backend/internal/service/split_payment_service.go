@@ -0,0 +1,526 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+	"github.com/Radi03825/PlaySpot/internal/service/gateway"
+)
+
+var (
+	ErrNotEventOrganizer = errors.New("only the organizer can split the cost of the event")
+	ErrShareNotFound     = errors.New("you have no share to pay in this event")
+	ErrNothingToSplit    = repository.ErrNothingToSplit
+)
+
+// defaultShareReminderInterval is how often unpaid shares are reminded when SPLIT_PAYMENT_REMINDER_INTERVAL is not set
+const defaultShareReminderInterval = 24 * time.Hour
+
+// SplitPaymentService splits the cost of a booking among the participants of an event held at it.
+// The organizer's booking is confirmed when the split is requested and each participant pays their share
+// by card; the booking's payment completes once the paid shares cover it. Whatever the shares have not
+// covered by the start is owed at the facility.
+type SplitPaymentService struct {
+	shareRepo        *repository.PaymentShareRepository
+	eventRepo        *repository.EventRepository
+	paymentRepo      *repository.PaymentRepository
+	reservationRepo  *repository.ReservationRepository
+	paymentService   *PaymentService
+	userService      *UserService
+	facilityService  *FacilityService
+	emailService     *EmailService
+	gateway          gateway.PaymentGateway
+	reminderInterval time.Duration
+}
+
+func NewSplitPaymentService(
+	shareRepo *repository.PaymentShareRepository,
+	eventRepo *repository.EventRepository,
+	paymentRepo *repository.PaymentRepository,
+	reservationRepo *repository.ReservationRepository,
+	paymentService *PaymentService,
+	userService *UserService,
+	facilityService *FacilityService,
+	emailService *EmailService,
+	paymentGateway gateway.PaymentGateway,
+) *SplitPaymentService {
+	reminderInterval := defaultShareReminderInterval
+	if value := os.Getenv("SPLIT_PAYMENT_REMINDER_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < time.Hour {
+			log.Printf("[SPLIT] Invalid SPLIT_PAYMENT_REMINDER_INTERVAL %q, using %s", value, defaultShareReminderInterval)
+		} else {
+			reminderInterval = interval
+		}
+	}
+
+	return &SplitPaymentService{
+		shareRepo:        shareRepo,
+		eventRepo:        eventRepo,
+		paymentRepo:      paymentRepo,
+		reservationRepo:  reservationRepo,
+		paymentService:   paymentService,
+		userService:      userService,
+		facilityService:  facilityService,
+		emailService:     emailService,
+		gateway:          paymentGateway,
+		reminderInterval: reminderInterval,
+	}
+}
+
+// RequestSplit splits what is left to pay of the booking an event is held at among the participants who
+// joined it, and the organizer unless excluded, and emails each participant a link to pay their share.
+// Requesting it again, e.g. after participants joined or left, splits the rest again among those who
+// have not paid yet.
+func (s *SplitPaymentService) RequestSplit(eventID, organizerID int64, req dto.SplitPaymentRequestDTO) (*dto.SplitPaymentDTO, error) {
+	event, err := s.eventRepo.GetEventByID(eventID, nil)
+	if err != nil {
+		return nil, errors.New("event not found")
+	}
+	if event.OrganizerID != organizerID {
+		return nil, ErrNotEventOrganizer
+	}
+	if event.RelatedBookingID == nil {
+		return nil, errors.New("only events held at a booking can split its cost")
+	}
+	if event.Status == "CANCELED" || event.Status == "COMPLETED" {
+		return nil, errors.New("cannot split the cost of an event with status: " + event.Status)
+	}
+
+	reservation, err := s.reservationRepo.GetReservationByID(*event.RelatedBookingID)
+	if err != nil {
+		return nil, errors.New("booking not found")
+	}
+	if reservation.UserID != organizerID {
+		return nil, errors.New("only the organizer's own booking can be split")
+	}
+	if reservation.Status != "pending" && reservation.Status != "confirmed" {
+		return nil, fmt.Errorf("cannot split a %s booking", reservation.Status)
+	}
+	if !reservation.StartTime.After(time.Now()) {
+		return nil, errors.New("cannot split a booking that has already started")
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByReservationID(reservation.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	if payment == nil {
+		payment, err = s.paymentRepo.CreatePayment(organizerID, reservation.ID, reservation.TotalPrice, "EUR", reservation.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create payment: %w", err)
+		}
+	}
+
+	reservationIDs, err := s.paymentRepo.GetPaymentReservationIDs(payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(reservationIDs) > 0 {
+		return nil, errors.New("bookings paid together in a checkout cannot be split")
+	}
+
+	if payment.PaymentStatus != "pending" && payment.PaymentStatus != "failed" {
+		return nil, fmt.Errorf("the booking's payment is already %s", payment.PaymentStatus)
+	}
+
+	participants, err := s.eventRepo.GetEventParticipants(eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get participants: %w", err)
+	}
+
+	var payers []int64
+	for _, participant := range participants {
+		if participant.UserID != organizerID {
+			payers = append(payers, participant.UserID)
+		}
+	}
+	if len(payers) == 0 {
+		return nil, errors.New("no participants have joined the event yet")
+	}
+	if req.IncludeOrganizer == nil || *req.IncludeOrganizer {
+		payers = append([]int64{organizerID}, payers...)
+	}
+
+	payment, err = s.shareRepo.SplitPayment(payment.ID, reservation.ID, eventID, payers)
+	if err != nil {
+		if errors.Is(err, ErrNothingToSplit) || errors.Is(err, ErrPaymentInProgress) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to split payment: %w", err)
+	}
+
+	split, err := s.splitState(eventID, reservation.ID, payment)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, share := range split.Shares {
+		if share.Status == model.ShareStatusPending && share.GatewayTransactionID == nil && share.UserID != organizerID {
+			s.sendShareEmail(share, event, reservation, payment, false)
+		}
+	}
+
+	return split, nil
+}
+
+// GetSplit returns how the cost of an event's booking is split, to its organizer and participants
+func (s *SplitPaymentService) GetSplit(eventID, userID int64) (*dto.SplitPaymentDTO, error) {
+	event, payment, err := s.getEventPayment(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.OrganizerID != userID && !s.eventRepo.IsUserJoined(eventID, userID) {
+		share, err := s.shareRepo.GetUserShare(payment.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if share == nil {
+			return nil, errors.New("only the organizer and participants can view the event's payment")
+		}
+	}
+
+	return s.splitState(eventID, *event.RelatedBookingID, payment)
+}
+
+// PayShare charges a participant's share of an event's booking to their card. A share the gateway
+// settles asynchronously is returned still pending and is paid once its webhook arrives. A declined card
+// returns ErrPaymentDeclined; the participant may retry with another. The attempt claims the share, so a
+// concurrent one gets ErrPaymentInProgress instead of charging it twice.
+func (s *SplitPaymentService) PayShare(eventID, userID int64, req dto.PayShareDTO) (*model.PaymentShare, error) {
+	_, payment, err := s.getEventPayment(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	share, err := s.shareRepo.GetUserShare(payment.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share: %w", err)
+	}
+	if share == nil || share.Status == model.ShareStatusCancelled {
+		return nil, ErrShareNotFound
+	}
+	if share.Status == model.ShareStatusPaid || share.GatewayTransactionID != nil {
+		// Already paid, or waiting for the gateway's answer to an earlier attempt
+		return share, nil
+	}
+	if payment.PaymentStatus != "pending" || payment.PaymentMethod != model.PaymentMethodSplit {
+		return nil, errors.New("the booking's cost is no longer being split")
+	}
+
+	attemptID, err := newPaymentAttemptID()
+	if err != nil {
+		return nil, err
+	}
+	attemptID, err = s.shareRepo.StartSharePayment(share.ID, s.gateway.Name(), attemptID, paymentAttemptHold)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.gateway.Authorize(gateway.AuthorizeRequest{
+		PaymentID:      payment.ID,
+		Amount:         share.Amount,
+		Currency:       payment.Currency,
+		Token:          req.PaymentToken,
+		Description:    fmt.Sprintf("PlaySpot event #%d share", eventID),
+		IdempotencyKey: attemptID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to authorize payment: %w", err)
+	}
+
+	switch result.Status {
+	case gateway.StatusDeclined:
+		return nil, s.declineShare(share.ID, result)
+	case gateway.StatusPending:
+		if err := s.shareRepo.SetShareTransaction(share.ID, result.TransactionID); err != nil {
+			return nil, err
+		}
+	case gateway.StatusCaptured:
+		err = s.completeShare(share, result.TransactionID)
+	default:
+		err = s.captureShare(share, payment.Currency, result.TransactionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.shareRepo.GetShare(share.ID)
+}
+
+// SendShareReminders reminds participants of the shares they have not paid yet, every reminder interval
+// until the booking starts or is paid. It returns the number of reminders sent.
+func (s *SplitPaymentService) SendShareReminders() (int64, error) {
+	shares, err := s.shareRepo.ClaimDueReminders(s.reminderInterval)
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int64
+	for _, share := range shares {
+		event, err := s.eventRepo.GetEventByID(*share.EventID, nil)
+		if err != nil {
+			log.Printf("[SPLIT] Failed to get event %d: %v", *share.EventID, err)
+			continue
+		}
+		payment, err := s.paymentRepo.GetPaymentByID(share.PaymentID)
+		if err != nil || payment == nil {
+			log.Printf("[SPLIT] Failed to get payment %d: %v", share.PaymentID, err)
+			continue
+		}
+		reservation, err := s.reservationRepo.GetReservationByID(payment.ReservationID)
+		if err != nil {
+			log.Printf("[SPLIT] Failed to get reservation %d: %v", payment.ReservationID, err)
+			continue
+		}
+
+		if err := s.emailShare(share, event, reservation, payment, true); err != nil {
+			log.Printf("[SPLIT] Failed to remind user %d of share %d: %v", share.UserID, share.ID, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// applyShareOutcome applies a gateway webhook event to the share paid as its transaction.
+// It is called for events whose transaction belongs to no payment.
+func (s *SplitPaymentService) applyShareOutcome(event *model.PaymentWebhookEvent) (string, *string, error) {
+	ignore := func(reason string) (string, *string, error) {
+		return model.WebhookIgnored, &reason, nil
+	}
+
+	share, err := s.shareRepo.GetShareByTransaction(event.Provider, *event.TransactionID)
+	if err != nil {
+		return "", nil, err
+	}
+	if share == nil {
+		return ignore("no payment has this transaction")
+	}
+	if share.Status != model.ShareStatusPending {
+		return ignore("share is already " + share.Status)
+	}
+
+	switch *event.EventStatus {
+	case gateway.StatusAuthorized:
+		payment, err := s.paymentRepo.GetPaymentByID(share.PaymentID)
+		if err != nil || payment == nil {
+			return "", nil, fmt.Errorf("failed to get payment %d: %v", share.PaymentID, err)
+		}
+		err = s.captureShare(share, payment.Currency, *event.TransactionID)
+		if err != nil && !errors.Is(err, ErrPaymentDeclined) {
+			return "", nil, err
+		}
+	case gateway.StatusCaptured:
+		if err := s.completeShare(share, *event.TransactionID); err != nil {
+			return "", nil, err
+		}
+	case gateway.StatusDeclined:
+		reason := "declined by the payment gateway"
+		if event.FailureReason != nil {
+			reason = *event.FailureReason
+		}
+		return model.WebhookProcessed, nil, s.shareRepo.FailShare(share.ID, reason)
+	default:
+		return ignore(fmt.Sprintf("%s events are not handled", event.EventType))
+	}
+
+	return model.WebhookProcessed, nil, nil
+}
+
+// captureShare captures an authorized share and, once captured, pays it
+func (s *SplitPaymentService) captureShare(share *model.PaymentShare, currency, transactionID string) error {
+	result, err := s.gateway.Capture(transactionID, share.Amount, currency)
+	if err != nil {
+		return fmt.Errorf("failed to capture payment: %w", err)
+	}
+
+	switch result.Status {
+	case gateway.StatusDeclined:
+		return s.declineShare(share.ID, result)
+	case gateway.StatusCaptured:
+		return s.completeShare(share, transactionID)
+	}
+
+	// Captured asynchronously; the webhook pays the share
+	return s.shareRepo.SetShareTransaction(share.ID, transactionID)
+}
+
+// completeShare records a captured share as paid and, when it was the last one needed, confirms the
+// booking's payment to the organizer. A share captured after the booking was cancelled or already paid,
+// or that can no longer be recorded as paid, is refunded.
+func (s *SplitPaymentService) completeShare(share *model.PaymentShare, transactionID string) error {
+	payment, completed, err := s.shareRepo.PayShare(share.ID, transactionID)
+	if errors.Is(err, repository.ErrShareNotPending) {
+		return s.refundUnrecordedShare(share, transactionID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if completed {
+		reservations, err := s.paymentService.paidReservations(payment)
+		if err != nil {
+			log.Printf("[SPLIT] Failed to get reservations of payment %d: %v", payment.ID, err)
+		} else {
+			s.paymentService.notifyPaid(payment, reservations)
+		}
+		return nil
+	}
+
+	if payment.PaymentStatus != "pending" {
+		result, err := s.gateway.Refund(transactionID, share.Amount, payment.Currency, "share_refund_"+transactionID)
+		if err != nil || result.Status == gateway.StatusDeclined {
+			log.Printf("[SPLIT] Failed to refund share %d of a booking no longer owing it: %v", share.ID, err)
+			return nil
+		}
+		if err := s.shareRepo.AddShareRefund(share.ID, share.Amount); err != nil {
+			log.Printf("[SPLIT] Failed to record refund of share %d: %v", share.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// refundUnrecordedShare refunds a capture of a share that could not be recorded as paid because the share
+// was cancelled or paid through another transaction meanwhile. A repeated report of the transaction that
+// paid the share is left alone.
+func (s *SplitPaymentService) refundUnrecordedShare(share *model.PaymentShare, transactionID string) error {
+	current, err := s.shareRepo.GetShare(share.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get share: %w", err)
+	}
+	if current != nil && current.Status == model.ShareStatusPaid &&
+		current.GatewayTransactionID != nil && *current.GatewayTransactionID == transactionID {
+		return nil
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByID(share.PaymentID)
+	if err != nil || payment == nil {
+		return fmt.Errorf("failed to get payment %d: %v", share.PaymentID, err)
+	}
+
+	result, err := s.gateway.Refund(transactionID, share.Amount, payment.Currency, "share_refund_"+transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to refund unrecorded capture of share %d: %w", share.ID, err)
+	}
+	if result.Status == gateway.StatusDeclined {
+		return fmt.Errorf("refund of unrecorded capture of share %d was refused: %s", share.ID, result.FailureReason)
+	}
+
+	log.Printf("[SPLIT] Refunded %.2f captured for share %d that could not be recorded as paid", share.Amount, share.ID)
+	return nil
+}
+
+// declineShare records a declined share payment and returns the error describing it
+func (s *SplitPaymentService) declineShare(shareID int64, result *gateway.Result) error {
+	reason := result.FailureReason
+	if reason == "" {
+		reason = "declined by the payment gateway"
+	}
+	if err := s.shareRepo.FailShare(shareID, reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrPaymentDeclined, reason)
+}
+
+// getEventPayment returns an event held at a booking and the booking's payment
+func (s *SplitPaymentService) getEventPayment(eventID int64) (*model.Event, *model.Payment, error) {
+	event, err := s.eventRepo.GetEventByID(eventID, nil)
+	if err != nil {
+		return nil, nil, errors.New("event not found")
+	}
+	if event.RelatedBookingID == nil {
+		return nil, nil, errors.New("the event is not held at a booking")
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByReservationID(*event.RelatedBookingID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	if payment == nil || payment.PaymentMethod != model.PaymentMethodSplit {
+		return nil, nil, errors.New("the cost of the event's booking is not split")
+	}
+
+	return event, payment, nil
+}
+
+// splitState returns the shares of a split payment with how much they have paid
+func (s *SplitPaymentService) splitState(eventID, reservationID int64, payment *model.Payment) (*dto.SplitPaymentDTO, error) {
+	shares, err := s.shareRepo.GetPaymentShares(payment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	split := &dto.SplitPaymentDTO{
+		EventID:       eventID,
+		ReservationID: reservationID,
+		Payment:       payment,
+		Shares:        shares,
+	}
+	for _, share := range shares {
+		if share.Status == model.ShareStatusPaid {
+			split.Collected += share.Amount
+		}
+	}
+	split.Collected = math.Round(split.Collected*100) / 100
+	if payment.PaidAt == nil {
+		split.Remaining = math.Max(math.Round((payment.Amount-split.Collected)*100)/100, 0)
+	}
+
+	return split, nil
+}
+
+// sendShareEmail asks a participant to pay their share asynchronously
+func (s *SplitPaymentService) sendShareEmail(share model.PaymentShare, event *model.Event, reservation *model.FacilityReservation, payment *model.Payment, isReminder bool) {
+	go func() {
+		if err := s.emailShare(share, event, reservation, payment, isReminder); err != nil {
+			log.Printf("[SPLIT] Failed to email user %d about share %d: %v", share.UserID, share.ID, err)
+		}
+	}()
+}
+
+// emailShare emails a participant the link to pay their share, with times in the facility's time zone
+func (s *SplitPaymentService) emailShare(share model.PaymentShare, event *model.Event, reservation *model.FacilityReservation, payment *model.Payment, isReminder bool) error {
+	user, err := s.userService.GetUserByID(share.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	organizer, err := s.userService.GetUserByID(event.OrganizerID)
+	if err != nil {
+		return fmt.Errorf("failed to get organizer: %w", err)
+	}
+
+	facility, err := s.facilityService.GetFacilityDetailsByID(reservation.FacilityID)
+	if err != nil {
+		return fmt.Errorf("failed to get facility: %w", err)
+	}
+
+	loc := loadLocation(facility.TimeZone)
+	return s.emailService.SendSharePaymentEmail(
+		user.Email,
+		user.Name,
+		organizer.Name,
+		event.Title,
+		event.ID,
+		facility.Name,
+		facility.Address,
+		facility.City,
+		facility.SportName,
+		reservation.StartTime.In(loc),
+		reservation.EndTime.In(loc),
+		share.Amount,
+		payment.Amount,
+		isReminder,
+	)
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"fmt"
+
 	"github.com/Radi03825/PlaySpot/internal/dto"
 	"github.com/Radi03825/PlaySpot/internal/model"
 	"github.com/Radi03825/PlaySpot/internal/repository"
@@ -23,8 +25,23 @@ func NewSportComplexService(repo *repository.SportComplexRepository, facilitySer
 }
 
 func (s *SportComplexService) CreateSportComplex(dto dto.CreateSportComplexDTO, managerID int64) (*model.SportComplex, error) {
+	if err := validateTimeZone(dto.TimeZone); err != nil {
+		return nil, err
+	}
+	timeZone := dto.TimeZone
+	if timeZone == "" {
+		timeZone = model.DefaultTimeZone
+	}
+
+	// Reject an invalid booking policy before anything is created
+	for _, facilityDTO := range dto.Facilities {
+		if err := validateBookingPolicy(facilityDTO.BookingPolicy); err != nil {
+			return nil, fmt.Errorf("facility %q: %w", facilityDTO.Name, err)
+		}
+	}
+
 	// Create the sport complex (not verified by default)
-	complex, err := s.repo.CreateSportComplex(dto.Name, dto.Address, dto.City, dto.Description, managerID)
+	complex, err := s.repo.CreateSportComplex(dto.Name, dto.Address, dto.City, dto.Description, timeZone, managerID)
 	if err != nil {
 		return nil, err
 	}
@@ -51,14 +68,14 @@ func (s *SportComplexService) CreateSportComplex(dto dto.CreateSportComplexDTO,
 				dto.Address, // Use complex's address
 				facilityDTO.Description,
 				facilityDTO.Capacity,
+				timeZone, // Use complex's time zone
 				managerID,
 				facilityDTO.WorkingHours,
 				facilityDTO.Pricing,
+				facilityDTO.BookingPolicy,
 			)
 			if err != nil {
-				// Log error but continue creating other facilities
-				// In production, you might want to handle this differently
-				continue
+				return nil, fmt.Errorf("failed to create facility %q: %w", facilityDTO.Name, err)
 			}
 
 			// Save facility images if provided
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+)
+
+func TestCreateSportComplexRejectsInvalidBookingPolicy(t *testing.T) {
+	// Without a repository the service would fail on any insert, so an error here means nothing was created
+	s := &SportComplexService{}
+
+	_, err := s.CreateSportComplex(dto.CreateSportComplexDTO{
+		Name: "Test Complex",
+		Facilities: []dto.CreateFacilityInComplexDTO{
+			{Name: "Court 1", BookingPolicy: &dto.BookingPolicyDTO{SlotMinutes: 60, MinDurationMinutes: 60}},
+			{Name: "Court 2", BookingPolicy: &dto.BookingPolicyDTO{SlotMinutes: 60, MinDurationMinutes: 90}},
+		},
+	}, 1)
+	if err == nil || !strings.Contains(err.Error(), `"Court 2"`) {
+		t.Fatalf("CreateSportComplex error = %v, want the invalid policy of Court 2", err)
+	}
+}
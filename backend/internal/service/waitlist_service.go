@@ -0,0 +1,245 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Radi03825/PlaySpot/internal/dto"
+	"github.com/Radi03825/PlaySpot/internal/model"
+	"github.com/Radi03825/PlaySpot/internal/repository"
+)
+
+// ErrAlreadyWaitlisted is returned when a user joins the waitlist for a slot they are already waiting for
+var ErrAlreadyWaitlisted = repository.ErrAlreadyWaitlisted
+
+// defaultWaitlistClaimMinutes is how long a freed slot is held for the next user on the waitlist
+const defaultWaitlistClaimMinutes = 30
+
+type WaitlistService struct {
+	repo               *repository.WaitlistRepository
+	reservationRepo    *repository.ReservationRepository
+	reservationService *ReservationService
+	userService        *UserService
+	facilityService    *FacilityService
+	emailService       *EmailService
+	claimWindow        time.Duration
+}
+
+func NewWaitlistService(
+	repo *repository.WaitlistRepository,
+	reservationRepo *repository.ReservationRepository,
+	reservationService *ReservationService,
+	userService *UserService,
+	facilityService *FacilityService,
+	emailService *EmailService,
+) *WaitlistService {
+	claimMinutes := defaultWaitlistClaimMinutes
+	if value, err := strconv.Atoi(os.Getenv("WAITLIST_CLAIM_MINUTES")); err == nil && value > 0 {
+		claimMinutes = value
+	}
+
+	return &WaitlistService{
+		repo:               repo,
+		reservationRepo:    reservationRepo,
+		reservationService: reservationService,
+		userService:        userService,
+		facilityService:    facilityService,
+		emailService:       emailService,
+		claimWindow:        time.Duration(claimMinutes) * time.Minute,
+	}
+}
+
+// JoinWaitlist puts the user in line for a taken slot. Slots that are free must be booked directly.
+func (s *WaitlistService) JoinWaitlist(userID int64, req dto.JoinWaitlistDTO) (*model.WaitlistEntry, error) {
+	startTime, err := time.Parse(time.RFC3339, req.StartTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time format: %w", err)
+	}
+
+	endTime, err := time.Parse(time.RFC3339, req.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end time format: %w", err)
+	}
+
+	if !endTime.After(startTime) {
+		return nil, errors.New("end time must be after start time")
+	}
+
+	if startTime.Before(time.Now()) {
+		return nil, errors.New("cannot join the waitlist for a slot in the past")
+	}
+
+	// Only slots that could be booked once free are worth waiting for
+	policy, err := s.reservationService.validateBookingWindow(req.FacilityID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := bookingUnits(policy, req.Units)
+	if err != nil {
+		return nil, err
+	}
+
+	taken, err := s.reservationRepo.CheckReservationConflict(req.FacilityID, startTime, endTime, units)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check availability: %w", err)
+	}
+	if !taken {
+		return nil, errors.New("this time slot is available, book it directly")
+	}
+
+	return s.repo.CreateEntry(userID, req.FacilityID, startTime, endTime, units)
+}
+
+// GetUserWaitlist returns the user's open waitlist entries; offered entries have Offered set
+func (s *WaitlistService) GetUserWaitlist(userID int64) ([]model.WaitlistEntry, error) {
+	return s.repo.GetUserEntries(userID)
+}
+
+// ClaimOffer accepts a slot offered to the user. The reservation stays pending until it is paid.
+func (s *WaitlistService) ClaimOffer(entryID, userID int64) (*model.FacilityReservation, error) {
+	entry, err := s.repo.GetEntryByID(entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+	if entry == nil || entry.UserID != userID {
+		return nil, errors.New("waitlist entry not found")
+	}
+
+	reservation, err := s.repo.ClaimOffer(entryID, s.reservationService.holdDuration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim offer: %w", err)
+	}
+	if reservation == nil {
+		return nil, errors.New("this offer is no longer available")
+	}
+
+	return reservation, nil
+}
+
+// LeaveWaitlist removes the user from the waitlist, passing an offered slot on to the next user
+func (s *WaitlistService) LeaveWaitlist(entryID, userID int64) error {
+	entry, err := s.repo.GetEntryByID(entryID)
+	if err != nil {
+		return fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+	if entry == nil || entry.UserID != userID {
+		return errors.New("waitlist entry not found")
+	}
+
+	left, err := s.repo.CancelEntry(entryID)
+	if err != nil {
+		return fmt.Errorf("failed to leave waitlist: %w", err)
+	}
+	if !left {
+		return errors.New("waitlist entry is no longer active")
+	}
+
+	if entry.Status == model.WaitlistOffered {
+		s.NotifySlotReleased(entry.FacilityID)
+	}
+
+	return nil
+}
+
+// ProcessWaitlist closes paid and lapsed offers, then offers freed slots to the next users in line.
+// It returns the number of entries settled or offered.
+func (s *WaitlistService) ProcessWaitlist() (int64, error) {
+	settled, err := s.repo.SettleOffers()
+	if err != nil {
+		return 0, fmt.Errorf("failed to settle waitlist offers: %w", err)
+	}
+
+	offered, err := s.OfferAvailableSlots(0)
+	return settled + offered, err
+}
+
+// NotifySlotReleased offers a facility's freed slots to waiting users asynchronously
+func (s *WaitlistService) NotifySlotReleased(facilityID int64) {
+	go func() {
+		if _, err := s.OfferAvailableSlots(facilityID); err != nil {
+			log.Printf("[WAITLIST] Failed to offer freed slots for facility %d: %v", facilityID, err)
+		}
+	}()
+}
+
+// OfferAvailableSlots holds every free slot someone is waiting for for the first user in line,
+// for the claim window. A facilityID of 0 covers all facilities. It returns the number of offers made.
+func (s *WaitlistService) OfferAvailableSlots(facilityID int64) (int64, error) {
+	candidates, err := s.repo.GetOfferCandidates(facilityID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get waitlist candidates: %w", err)
+	}
+
+	var offered int64
+	for i := range candidates {
+		entry := &candidates[i]
+
+		// The schedule may have changed since the user joined; keep waiting until the slot is bookable again
+		policy, err := s.reservationService.validateBookingWindow(entry.FacilityID, entry.StartTime, entry.EndTime)
+		if err != nil {
+			continue
+		}
+
+		priceItems, totalPrice, err := s.reservationService.calculatePrice(entry.FacilityID, entry.StartTime, entry.EndTime, entry.Units)
+		if err != nil {
+			continue
+		}
+
+		buffer := time.Duration(policy.BufferMinutes) * time.Minute
+		reservation, err := s.repo.OfferSlot(entry.ID, buffer, s.claimWindow, totalPrice, priceItems)
+		if errors.Is(err, ErrSlotTaken) {
+			// Offered to someone earlier in line, or booked in the meantime
+			continue
+		}
+		if err != nil {
+			return offered, fmt.Errorf("failed to offer slot to waitlist entry %d: %w", entry.ID, err)
+		}
+		if reservation == nil {
+			continue
+		}
+
+		offered++
+		s.sendOfferEmail(entry, reservation)
+	}
+
+	return offered, nil
+}
+
+// sendOfferEmail tells a waiting user that the slot is held for them, asynchronously
+func (s *WaitlistService) sendOfferEmail(entry *model.WaitlistEntry, reservation *model.FacilityReservation) {
+	go func() {
+		user, err := s.userService.GetUserByID(entry.UserID)
+		if err != nil {
+			log.Printf("[WAITLIST] Failed to get user %d: %v", entry.UserID, err)
+			return
+		}
+
+		facility, err := s.facilityService.GetFacilityDetailsByID(entry.FacilityID)
+		if err != nil {
+			log.Printf("[WAITLIST] Failed to get facility %d: %v", entry.FacilityID, err)
+			return
+		}
+
+		loc := loadLocation(facility.TimeZone)
+		err = s.emailService.SendWaitlistOfferEmail(
+			user.Email,
+			user.Name,
+			facility.Name,
+			facility.Address,
+			facility.City,
+			facility.SportName,
+			reservation.StartTime.In(loc),
+			reservation.EndTime.In(loc),
+			reservation.TotalPrice,
+			*reservation.ExpiresAt,
+		)
+		if err != nil {
+			log.Printf("[WAITLIST] Failed to send offer email for waitlist entry %d: %v", entry.ID, err)
+		}
+	}()
+}
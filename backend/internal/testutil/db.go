@@ -0,0 +1,113 @@
+// Package testutil holds the database fixtures shared by the repository and service tests.
+package testutil
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// OpenDB connects to the PostgreSQL database named by TEST_DATABASE_URL and applies the schema to it.
+// Tests that need a database are skipped when it is not set.
+func OpenDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+
+	// The schema lives in backend/migrations, two levels above this package
+	_, file, _, _ := runtime.Caller(0)
+	schema, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "..", "migrations", "init_database.sql"))
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("failed to apply schema: %v", err)
+	}
+
+	return db
+}
+
+// CreateFacility inserts a user and an exclusive-use facility for them to book. Both are deleted
+// again, with everything referencing them, when the test ends.
+func CreateFacility(t *testing.T, db *sql.DB) (userID, facilityID int64) {
+	t.Helper()
+
+	suffix := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+
+	var sportID, categoryID, surfaceID, environmentID int64
+	steps := []struct {
+		query string
+		dest  *int64
+	}{
+		{`INSERT INTO users (name, email) VALUES ('Test User', $1) RETURNING id`, &userID},
+		{`INSERT INTO sports (name) VALUES ($1) RETURNING id`, &sportID},
+		{`INSERT INTO surfaces (name) VALUES ($1) RETURNING id`, &surfaceID},
+		{`INSERT INTO environments (name) VALUES ($1) RETURNING id`, &environmentID},
+	}
+	for _, step := range steps {
+		if err := db.QueryRow(step.query, suffix+"@test.playspot").Scan(step.dest); err != nil {
+			t.Fatalf("failed to create fixture: %v", err)
+		}
+	}
+
+	err := db.QueryRow(`INSERT INTO categories (name, sport_id) VALUES ($1, $2) RETURNING id`, suffix, sportID).Scan(&categoryID)
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+
+	err = db.QueryRow(`
+		INSERT INTO facilities (name, manager_id, category_id, surface_id, environment_id, city, address, capacity, is_verified)
+		VALUES ('Test Court', $1, $2, $3, $4, 'Sofia', 'Test Street 1', 1, TRUE)
+		RETURNING id
+	`, userID, categoryID, surfaceID, environmentID).Scan(&facilityID)
+	if err != nil {
+		t.Fatalf("failed to create facility: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM users WHERE id = $1`, userID)
+		db.Exec(`DELETE FROM sports WHERE id = $1`, sportID)
+		db.Exec(`DELETE FROM surfaces WHERE id = $1`, surfaceID)
+		db.Exec(`DELETE FROM environments WHERE id = $1`, environmentID)
+	})
+
+	return userID, facilityID
+}
+
+// CreateReservation inserts a two-hour reservation of the facility starting a week from now
+func CreateReservation(t *testing.T, db *sql.DB, userID, facilityID int64, status string, totalPrice float64) int64 {
+	t.Helper()
+
+	start := time.Now().Add(7 * 24 * time.Hour).Truncate(time.Hour)
+
+	var reservationID int64
+	err := db.QueryRow(`
+		INSERT INTO facility_reservations (user_id, facility_id, start_time, end_time, status, total_price)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, userID, facilityID, start, start.Add(2*time.Hour), status, totalPrice).Scan(&reservationID)
+	if err != nil {
+		t.Fatalf("failed to create reservation: %v", err)
+	}
+
+	return reservationID
+}
@@ -127,32 +127,108 @@ CREATE TABLE IF NOT EXISTS facility_schedules (
     facility_id BIGINT NOT NULL REFERENCES facilities(id) ON DELETE CASCADE,
     open_time TIME NOT NULL,
     close_time TIME NOT NULL,
-    day_type VARCHAR(50) NOT NULL CHECK (day_type IN ('weekday', 'weekend'))
+    day_type VARCHAR(50) NOT NULL CHECK (day_type IN ('monday', 'tuesday', 'wednesday', 'thursday', 'friday', 'saturday', 'sunday'))
 );
 
 -- 11. CREATE FACILITY PRICING TABLE
 CREATE TABLE IF NOT EXISTS facility_pricings (
     id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
     facility_id BIGINT NOT NULL REFERENCES facilities(id) ON DELETE CASCADE,
-    day_type VARCHAR(50) NOT NULL CHECK (day_type IN ('weekday', 'weekend')),
+    day_type VARCHAR(50) NOT NULL CHECK (day_type IN ('monday', 'tuesday', 'wednesday', 'thursday', 'friday', 'saturday', 'sunday')),
     start_hour TIME NOT NULL,
     end_hour TIME NOT NULL,
     price_per_hour NUMERIC(10, 2) NOT NULL
 );
 
+-- Migrate legacy 'weekday'/'weekend' schedules and pricing to individual days of the week
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'facility_schedules_day_type_check'
+        AND pg_get_constraintdef(oid) LIKE '%weekend%'
+    ) THEN
+        ALTER TABLE facility_schedules DROP CONSTRAINT facility_schedules_day_type_check;
+        ALTER TABLE facility_pricings DROP CONSTRAINT IF EXISTS facility_pricings_day_type_check;
+
+        INSERT INTO facility_schedules (facility_id, open_time, close_time, day_type)
+        SELECT fs.facility_id, fs.open_time, fs.close_time, l.day_type
+        FROM facility_schedules fs
+        JOIN (VALUES
+            ('weekday', 'monday'), ('weekday', 'tuesday'), ('weekday', 'wednesday'),
+            ('weekday', 'thursday'), ('weekday', 'friday'),
+            ('weekend', 'saturday'), ('weekend', 'sunday')
+        ) AS l(legacy, day_type) ON l.legacy = fs.day_type;
+
+        INSERT INTO facility_pricings (facility_id, day_type, start_hour, end_hour, price_per_hour)
+        SELECT fp.facility_id, l.day_type, fp.start_hour, fp.end_hour, fp.price_per_hour
+        FROM facility_pricings fp
+        JOIN (VALUES
+            ('weekday', 'monday'), ('weekday', 'tuesday'), ('weekday', 'wednesday'),
+            ('weekday', 'thursday'), ('weekday', 'friday'),
+            ('weekend', 'saturday'), ('weekend', 'sunday')
+        ) AS l(legacy, day_type) ON l.legacy = fp.day_type;
+
+        DELETE FROM facility_schedules WHERE day_type IN ('weekday', 'weekend');
+        DELETE FROM facility_pricings WHERE day_type IN ('weekday', 'weekend');
+
+        ALTER TABLE facility_schedules ADD CONSTRAINT facility_schedules_day_type_check
+            CHECK (day_type IN ('monday', 'tuesday', 'wednesday', 'thursday', 'friday', 'saturday', 'sunday'));
+        ALTER TABLE facility_pricings ADD CONSTRAINT facility_pricings_day_type_check
+            CHECK (day_type IN ('monday', 'tuesday', 'wednesday', 'thursday', 'friday', 'saturday', 'sunday'));
+    END IF;
+END $$;
+
 -- 12. CREATE FACILITY RESERVATIONS TABLE
 CREATE TABLE IF NOT EXISTS facility_reservations (
     id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     facility_id BIGINT NOT NULL REFERENCES facilities(id) ON DELETE CASCADE,
-    start_time TIMESTAMP NOT NULL,
-    end_time TIMESTAMP NOT NULL,
-    status VARCHAR(50) NOT NULL CHECK (status IN ('pending', 'confirmed', 'cancelled', 'completed')),
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    status VARCHAR(50) NOT NULL CHECK (status IN ('pending', 'confirmed', 'cancelled', 'completed', 'expired')),
     total_price NUMERIC(10, 2) NOT NULL,
-    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-    google_calendar_event_id VARCHAR(255)
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    google_calendar_event_id VARCHAR(255),
+    expires_at TIMESTAMPTZ
 );
 
+-- Unpaid pending reservations hold their slot until expires_at, then become 'expired'
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'facility_reservations_status_check'
+        AND pg_get_constraintdef(oid) NOT LIKE '%expired%'
+    ) THEN
+        ALTER TABLE facility_reservations DROP CONSTRAINT facility_reservations_status_check;
+        ALTER TABLE facility_reservations ADD CONSTRAINT facility_reservations_status_check
+            CHECK (status IN ('pending', 'confirmed', 'cancelled', 'completed', 'expired'));
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_facility_reservations_pending_expiry ON facility_reservations(expires_at) WHERE status = 'pending';
+
+-- Prevent overlapping active reservations for the same facility at the database level.
+-- The constraint itself is created at the end of this file.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+DO $$
+BEGIN
+    -- Recreate the constraint if it predates the 'expired' status
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'facility_reservations_no_overlap'
+        AND pg_get_constraintdef(oid) NOT LIKE '%expired%'
+    ) THEN
+        ALTER TABLE facility_reservations DROP CONSTRAINT facility_reservations_no_overlap;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_facility_reservations_facility_time ON facility_reservations(facility_id, start_time, end_time);
+
 -- 13. CREATE IMAGES TABLE
 CREATE TABLE IF NOT EXISTS images (
     id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
@@ -179,9 +255,9 @@ CREATE TABLE IF NOT EXISTS payments (
     currency VARCHAR(10) NOT NULL DEFAULT 'EUR',
     payment_method VARCHAR(50) NOT NULL,
     payment_status VARCHAR(50) NOT NULL CHECK (payment_status IN ('pending', 'completed', 'failed', 'refunded')),
-    expired_at TIMESTAMP,
-    paid_at TIMESTAMP,
-    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+    expired_at TIMESTAMPTZ,
+    paid_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 -- 15. CREATE EVENTS TABLE
@@ -235,4 +311,476 @@ CREATE TABLE IF NOT EXISTS reviews (
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
     CONSTRAINT unique_user_facility_review UNIQUE (user_id, facility_id)
-);
\ No newline at end of file
+);
+-- 18. CREATE FACILITY BOOKING POLICIES TABLE
+CREATE TABLE IF NOT EXISTS facility_booking_policies (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    facility_id BIGINT NOT NULL UNIQUE REFERENCES facilities(id) ON DELETE CASCADE,
+    slot_minutes INTEGER NOT NULL DEFAULT 60 CHECK (slot_minutes > 0 AND slot_minutes <= 1440),
+    min_duration_minutes INTEGER NOT NULL DEFAULT 60 CHECK (min_duration_minutes > 0),
+    max_duration_minutes INTEGER CHECK (max_duration_minutes IS NULL OR max_duration_minutes >= min_duration_minutes),
+    buffer_minutes INTEGER NOT NULL DEFAULT 0 CHECK (buffer_minutes >= 0),
+    allow_reschedule BOOLEAN NOT NULL DEFAULT TRUE,
+    reschedule_cutoff_minutes INTEGER NOT NULL DEFAULT 0 CHECK (reschedule_cutoff_minutes >= 0),
+    max_reschedules INTEGER CHECK (max_reschedules IS NULL OR max_reschedules >= 0),
+    availability_mode VARCHAR(20) NOT NULL DEFAULT 'exclusive' CHECK (availability_mode IN ('exclusive', 'shared'))
+);
+
+-- Reschedule policy columns for policies created before rescheduling was supported
+ALTER TABLE facility_booking_policies ADD COLUMN IF NOT EXISTS allow_reschedule BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE facility_booking_policies ADD COLUMN IF NOT EXISTS reschedule_cutoff_minutes INTEGER NOT NULL DEFAULT 0 CHECK (reschedule_cutoff_minutes >= 0);
+ALTER TABLE facility_booking_policies ADD COLUMN IF NOT EXISTS max_reschedules INTEGER CHECK (max_reschedules IS NULL OR max_reschedules >= 0);
+
+-- 19. CREATE SCHEDULE EXCEPTIONS TABLE
+-- Date-specific closures, special opening hours and special prices for a facility or a whole sport complex
+CREATE TABLE IF NOT EXISTS schedule_exceptions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    facility_id BIGINT REFERENCES facilities(id) ON DELETE CASCADE,
+    sport_complex_id BIGINT REFERENCES sport_complexes(id) ON DELETE CASCADE,
+    exception_date DATE NOT NULL,
+    exception_type VARCHAR(50) NOT NULL CHECK (exception_type IN ('closed', 'special_hours', 'special_price')),
+    start_time TIME,
+    end_time TIME,
+    price_per_hour NUMERIC(10, 2),
+    reason TEXT,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT schedule_exceptions_single_target CHECK ((facility_id IS NULL) <> (sport_complex_id IS NULL)),
+    CONSTRAINT schedule_exceptions_time_range CHECK (
+        (start_time IS NULL AND end_time IS NULL) OR (start_time IS NOT NULL AND end_time IS NOT NULL AND start_time < end_time)
+    )
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_exceptions_facility_date ON schedule_exceptions(facility_id, exception_date);
+CREATE INDEX IF NOT EXISTS idx_schedule_exceptions_complex_date ON schedule_exceptions(sport_complex_id, exception_date);
+
+-- 20. CREATE RESERVATION PRICE ITEMS TABLE
+-- Itemised price breakdown of a reservation, one row per price band segment
+CREATE TABLE IF NOT EXISTS reservation_price_items (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    reservation_id BIGINT NOT NULL REFERENCES facility_reservations(id) ON DELETE CASCADE,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    price_per_hour NUMERIC(10, 2) NOT NULL,
+    amount NUMERIC(10, 2) NOT NULL,
+    CHECK (start_time < end_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservation_price_items_reservation ON reservation_price_items(reservation_id);
+
+-- 21. CREATE RESERVATION SERIES TABLE
+-- Recurring weekly or biweekly bookings; each occurrence is a regular reservation linked by series_id
+CREATE TABLE IF NOT EXISTS reservation_series (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    facility_id BIGINT NOT NULL REFERENCES facilities(id) ON DELETE CASCADE,
+    frequency VARCHAR(20) NOT NULL CHECK (frequency IN ('weekly', 'biweekly')),
+    first_start_time TIMESTAMPTZ NOT NULL,
+    first_end_time TIMESTAMPTZ NOT NULL,
+    until_date DATE,
+    occurrence_count INT CHECK (occurrence_count > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'cancelled')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (until_date IS NOT NULL OR occurrence_count IS NOT NULL)
+);
+
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS series_id BIGINT REFERENCES reservation_series(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_facility_reservations_series ON facility_reservations(series_id);
+
+-- 22. CREATE RESERVATION REMINDERS TABLE
+-- One row per reminder sent, so restarts never send the same reminder twice
+CREATE TABLE IF NOT EXISTS reservation_reminders (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    reservation_id BIGINT NOT NULL REFERENCES facility_reservations(id) ON DELETE CASCADE,
+    lead_minutes INT NOT NULL CHECK (lead_minutes > 0),
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (reservation_id, lead_minutes)
+);
+
+-- Track how often a reservation was moved, enforced against max_reschedules
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS reschedule_count INTEGER NOT NULL DEFAULT 0;
+
+-- Amount still owed by (positive) or owed back to (negative) the customer after a booking changed price
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS balance_due NUMERIC(10, 2) NOT NULL DEFAULT 0;
+
+-- 23. CREATE CANCELLATION POLICY RULES TABLE
+-- Refund tiers for cancelling a booking, for a single facility or every facility of a sport complex.
+-- A cancellation refunds refund_percent of the amount paid when made at least hours_before hours
+-- before the booking starts; facility rules replace those of the complex.
+CREATE TABLE IF NOT EXISTS cancellation_policy_rules (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    facility_id BIGINT REFERENCES facilities(id) ON DELETE CASCADE,
+    sport_complex_id BIGINT REFERENCES sport_complexes(id) ON DELETE CASCADE,
+    hours_before INT NOT NULL CHECK (hours_before >= 0),
+    refund_percent NUMERIC(5, 2) NOT NULL CHECK (refund_percent >= 0 AND refund_percent <= 100),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT cancellation_policy_rules_single_target CHECK ((facility_id IS NULL) <> (sport_complex_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cancellation_policy_rules_facility ON cancellation_policy_rules(facility_id, hours_before) WHERE facility_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cancellation_policy_rules_complex ON cancellation_policy_rules(sport_complex_id, hours_before) WHERE sport_complex_id IS NOT NULL;
+
+-- Amount returned to the customer when a paid booking was cancelled
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_amount NUMERIC(10, 2) NOT NULL DEFAULT 0;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'payments_payment_status_check'
+        AND pg_get_constraintdef(oid) NOT LIKE '%partially_refunded%'
+    ) THEN
+        ALTER TABLE payments DROP CONSTRAINT payments_payment_status_check;
+        ALTER TABLE payments ADD CONSTRAINT payments_payment_status_check
+            CHECK (payment_status IN ('pending', 'completed', 'failed', 'refunded', 'partially_refunded'));
+    END IF;
+END $$;
+
+-- Who cancelled a reservation and why; manager cancellations carry a reason for the customer
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS cancelled_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS cancellation_reason TEXT;
+
+-- Completed bookings the customer did not show up for
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'facility_reservations_status_check'
+        AND pg_get_constraintdef(oid) NOT LIKE '%no_show%'
+    ) THEN
+        ALTER TABLE facility_reservations DROP CONSTRAINT facility_reservations_status_check;
+        ALTER TABLE facility_reservations ADD CONSTRAINT facility_reservations_status_check
+            CHECK (status IN ('pending', 'confirmed', 'cancelled', 'completed', 'expired', 'no_show'));
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_facility_reservations_user_no_show ON facility_reservations(user_id) WHERE status = 'no_show';
+
+-- 24. CREATE RESERVATION WAITLIST TABLE
+-- Users waiting for a taken slot. When the slot frees up the first user in line is offered it:
+-- a pending reservation holds the slot for them until offer_expires_at, after which the next user is offered it.
+CREATE TABLE IF NOT EXISTS reservation_waitlist (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    facility_id BIGINT NOT NULL REFERENCES facilities(id) ON DELETE CASCADE,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'offered', 'claimed', 'expired', 'cancelled')),
+    reservation_id BIGINT REFERENCES facility_reservations(id) ON DELETE SET NULL,
+    offered_at TIMESTAMPTZ,
+    offer_expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (start_time < end_time)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservation_waitlist_active_entry
+    ON reservation_waitlist(user_id, facility_id, start_time, end_time) WHERE status IN ('waiting', 'offered');
+CREATE INDEX IF NOT EXISTS idx_reservation_waitlist_waiting ON reservation_waitlist(facility_id, created_at) WHERE status = 'waiting';
+
+-- 25. CREATE PAYMENT RESERVATIONS TABLE
+-- Reservations covered by a combined basket payment, with each reservation's share of the amount.
+-- Single-reservation payments only use payments.reservation_id.
+CREATE TABLE IF NOT EXISTS payment_reservations (
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    reservation_id BIGINT NOT NULL REFERENCES facility_reservations(id) ON DELETE CASCADE,
+    amount NUMERIC(10, 2) NOT NULL CHECK (amount >= 0),
+    PRIMARY KEY (payment_id, reservation_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_reservations_reservation ON payment_reservations(reservation_id);
+
+-- Walk-in and phone bookings made by facility staff on behalf of customers.
+-- created_by is the staff member; guests without a PlaySpot account have no user_id, only a name and phone.
+ALTER TABLE facility_reservations ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS created_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS guest_name VARCHAR(255);
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS guest_phone VARCHAR(50);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'facility_reservations_customer_check') THEN
+        ALTER TABLE facility_reservations ADD CONSTRAINT facility_reservations_customer_check
+            CHECK (user_id IS NOT NULL OR guest_name IS NOT NULL);
+    END IF;
+END $$;
+
+-- Guest bookings paid on site have no paying user
+ALTER TABLE payments ALTER COLUMN user_id DROP NOT NULL;
+
+-- Shared facilities (lanes, gym floors, split pitches) take simultaneous bookings, each using some units,
+-- up to facilities.capacity. Exclusive-use facilities allow one booking at a time as before.
+ALTER TABLE facility_booking_policies ADD COLUMN IF NOT EXISTS availability_mode VARCHAR(20) NOT NULL DEFAULT 'exclusive'
+    CHECK (availability_mode IN ('exclusive', 'shared'));
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS units INTEGER NOT NULL DEFAULT 1 CHECK (units > 0);
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS exclusive_use BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE reservation_waitlist ADD COLUMN IF NOT EXISTS units INTEGER NOT NULL DEFAULT 1 CHECK (units > 0);
+
+-- Only exclusive-use reservations are kept from overlapping; shared capacity is checked when booking.
+-- The constraint is (re)created below once booking times are TIMESTAMPTZ.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'facility_reservations_no_overlap'
+        AND pg_get_constraintdef(oid) NOT LIKE '%exclusive_use%'
+    ) THEN
+        ALTER TABLE facility_reservations DROP CONSTRAINT facility_reservations_no_overlap;
+    END IF;
+END $$;
+
+-- IANA time zone of each sport complex and facility. Opening hours, prices and closures are local times
+-- in the facility's zone; a new facility takes the zone of its sport complex unless one is given.
+ALTER TABLE sport_complexes ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT 'Europe/Sofia';
+ALTER TABLE facilities ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT 'Europe/Sofia';
+
+-- Booking times are absolute instants. Older databases stored them as TIMESTAMP in UTC;
+-- convert them, then (re)create the overlap constraint on the new column type.
+DO $$
+DECLARE
+    col RECORD;
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'facility_reservations'
+        AND column_name = 'start_time' AND data_type = 'timestamp without time zone'
+    ) THEN
+        ALTER TABLE facility_reservations DROP CONSTRAINT IF EXISTS facility_reservations_no_overlap;
+    END IF;
+
+    FOR col IN
+        SELECT table_name, column_name FROM information_schema.columns
+        WHERE table_schema = current_schema()
+        AND data_type = 'timestamp without time zone'
+        AND table_name IN ('facility_reservations', 'payments', 'reservation_price_items', 'reservation_series',
+                           'reservation_reminders', 'reservation_waitlist')
+    LOOP
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE TIMESTAMPTZ USING %I AT TIME ZONE ''UTC''',
+            col.table_name, col.column_name, col.column_name);
+    END LOOP;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'facility_reservations_no_overlap') THEN
+        ALTER TABLE facility_reservations
+            ADD CONSTRAINT facility_reservations_no_overlap
+            EXCLUDE USING gist (facility_id WITH =, tstzrange(start_time, end_time, '[)') WITH &&)
+            WHERE (exclusive_use AND status NOT IN ('cancelled', 'expired'));
+    END IF;
+END $$;
+
+-- Secret token in each user's iCalendar feed URL; rotating it revokes previously shared links
+ALTER TABLE users ADD COLUMN IF NOT EXISTS calendar_feed_token VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_calendar_feed_token ON users(calendar_feed_token);
+
+-- 26. CREATE CALENDAR SYNC JOBS TABLE
+-- Durable queue of Google Calendar changes for reservations. Jobs of a reservation run in order;
+-- failed attempts are retried with backoff until max attempts, then left failed for inspection.
+CREATE TABLE IF NOT EXISTS calendar_sync_jobs (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    reservation_id BIGINT NOT NULL REFERENCES facility_reservations(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'done', 'skipped', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_sync_jobs_due ON calendar_sync_jobs(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_calendar_sync_jobs_reservation ON calendar_sync_jobs(reservation_id);
+-- A reservation has at most one pending job per action; queuing it again is a no-op
+CREATE UNIQUE INDEX IF NOT EXISTS idx_calendar_sync_jobs_pending_action
+    ON calendar_sync_jobs(reservation_id, action) WHERE status = 'pending';
+
+-- Calendar holding a reservation's event: 'google' or 'caldav'. google_calendar_event_id keeps
+-- the event's ID at that provider (a resource URL for CalDAV).
+ALTER TABLE facility_reservations ADD COLUMN IF NOT EXISTS calendar_provider VARCHAR(20);
+UPDATE facility_reservations SET calendar_provider = 'google'
+WHERE google_calendar_event_id IS NOT NULL AND calendar_provider IS NULL;
+
+-- 27. CREATE CALDAV ACCOUNTS TABLE
+-- CalDAV calendar (Nextcloud, Radicale, iCloud...) a user syncs bookings to instead of Google Calendar.
+-- The password should be an app password the user can revoke on their server.
+CREATE TABLE IF NOT EXISTS caldav_accounts (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    calendar_url VARCHAR(1024) NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Card payments go through a payment gateway: pending -> authorized -> completed, or failed when declined.
+-- gateway names the processor ('simulated', 'stripe') and gateway_transaction_id its transaction.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS gateway VARCHAR(20);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS gateway_transaction_id VARCHAR(255);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS authorized_at TIMESTAMPTZ;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_gateway_transaction
+    ON payments(gateway, gateway_transaction_id) WHERE gateway_transaction_id IS NOT NULL;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'payments_payment_status_check'
+        AND pg_get_constraintdef(oid) NOT LIKE '%authorized%'
+    ) THEN
+        ALTER TABLE payments DROP CONSTRAINT payments_payment_status_check;
+        ALTER TABLE payments ADD CONSTRAINT payments_payment_status_check
+            CHECK (payment_status IN ('pending', 'authorized', 'completed', 'failed', 'refunded', 'partially_refunded'));
+    END IF;
+END $$;
+
+-- 28. CREATE PAYMENT WEBHOOK EVENTS TABLE
+-- Every verified webhook received from a payment gateway, kept for audit and replay. An event is applied
+-- once: deliveries repeating a processed or ignored event ID are acknowledged without effect.
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    provider VARCHAR(20) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    transaction_id VARCHAR(255),
+    event_status VARCHAR(20),
+    failure_reason TEXT,
+    amount NUMERIC(10, 2),
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'received' CHECK (status IN ('received', 'processed', 'ignored', 'failed')),
+    error TEXT,
+    deliveries INT NOT NULL DEFAULT 1,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMPTZ,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_webhook_events_status ON payment_webhook_events(status, received_at);
+CREATE INDEX IF NOT EXISTS idx_payment_webhook_events_transaction ON payment_webhook_events(provider, transaction_id);
+
+-- 29. CREATE IDEMPOTENCY KEYS TABLE
+-- First response to each Idempotency-Key a user sent, replayed when the request is retried with the same key.
+-- status_code is NULL while the first request is still being processed. Keys expire after a day.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+-- 30. CREATE REFUNDS TABLE
+-- Money returned from a payment, in full or in part, by a cancellation or by staff. payments.refunded_amount
+-- sums the refunds that did not fail. Refunds of gateway payments go through the gateway; others, such as
+-- payments made on site, are returned outside PlaySpot and only recorded.
+CREATE TABLE IF NOT EXISTS refunds (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    reservation_id BIGINT REFERENCES facility_reservations(id) ON DELETE SET NULL,
+    amount NUMERIC(10, 2) NOT NULL CHECK (amount > 0),
+    reason TEXT,
+    initiator VARCHAR(20) NOT NULL CHECK (initiator IN ('user_cancellation', 'manager', 'admin')),
+    initiated_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    gateway_refund_id VARCHAR(255),
+    failure_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_payment ON refunds(payment_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_reservation ON refunds(reservation_id);
+
+-- Record cancellation refunds settled before refunds were tracked, so each payment's history adds up
+INSERT INTO refunds (payment_id, reservation_id, amount, initiator, initiated_by, reason, status, created_at, processed_at)
+SELECT p.id, p.reservation_id, p.refunded_amount,
+       CASE WHEN fr.cancelled_by IS NOT NULL AND fr.cancelled_by IS DISTINCT FROM fr.user_id THEN 'manager' ELSE 'user_cancellation' END,
+       fr.cancelled_by, fr.cancellation_reason, 'succeeded', p.created_at, p.created_at
+FROM payments p
+LEFT JOIN facility_reservations fr ON fr.id = p.reservation_id
+WHERE p.refunded_amount > 0
+AND NOT EXISTS (SELECT 1 FROM refunds r WHERE r.payment_id = p.id);
+
+-- 31. CREATE PAYMENT SHARES TABLE
+-- Shares of a booking's payment that the organizer of an event on the booking asked participants to pay.
+-- Paid shares count towards the payment, which completes once they cover its amount. A share with a
+-- gateway_transaction_id but still pending waits for the gateway to settle it. refunded_amount is what
+-- refunds of the payment returned from the share.
+CREATE TABLE IF NOT EXISTS payment_shares (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    event_id BIGINT REFERENCES events(id) ON DELETE SET NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount NUMERIC(10, 2) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'cancelled')),
+    gateway VARCHAR(20),
+    gateway_transaction_id VARCHAR(255),
+    failure_reason TEXT,
+    refunded_amount NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    reminders_sent INT NOT NULL DEFAULT 0,
+    last_reminded_at TIMESTAMPTZ,
+    paid_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (payment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_shares_event ON payment_shares(event_id);
+CREATE INDEX IF NOT EXISTS idx_payment_shares_user ON payment_shares(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_shares_gateway_transaction
+    ON payment_shares(gateway, gateway_transaction_id) WHERE gateway_transaction_id IS NOT NULL;
+
+-- A refund of a split payment goes back through several share transactions
+ALTER TABLE refunds ALTER COLUMN gateway_refund_id TYPE TEXT;
+
+-- A card payment attempt claims the payment before the gateway is called, so concurrent requests do not
+-- authorize it twice. gateway_attempt_id doubles as the gateway's idempotency key for the attempt.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS gateway_attempt_id VARCHAR(64);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS gateway_attempt_at TIMESTAMPTZ;
+
+-- PlaySpot itself refunds a payment the gateway captured after its reservation lapsed
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'refunds_initiator_check'
+        AND pg_get_constraintdef(oid) NOT LIKE '%system%'
+    ) THEN
+        ALTER TABLE refunds DROP CONSTRAINT refunds_initiator_check;
+        ALTER TABLE refunds ADD CONSTRAINT refunds_initiator_check
+            CHECK (initiator IN ('user_cancellation', 'manager', 'admin', 'system'));
+    END IF;
+END $$;
+
+-- Attempts to pay a share claim it the same way
+ALTER TABLE payment_shares ADD COLUMN IF NOT EXISTS gateway_attempt_id VARCHAR(64);
+ALTER TABLE payment_shares ADD COLUMN IF NOT EXISTS gateway_attempt_at TIMESTAMPTZ;
+
+-- CalDAV passwords are stored encrypted, which makes them longer
+ALTER TABLE caldav_accounts ALTER COLUMN password TYPE TEXT;
+
+-- The balance left after a paid booking moved to a dearer time is paid by card on a transaction of its own.
+-- Once paid it is added to the payment's amount, and refunds of the payment return it first.
+CREATE TABLE IF NOT EXISTS payment_balance_charges (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    amount NUMERIC(10, 2) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid')),
+    gateway VARCHAR(20) NOT NULL,
+    gateway_transaction_id VARCHAR(255),
+    gateway_attempt_id VARCHAR(64),
+    gateway_attempt_at TIMESTAMPTZ,
+    failure_reason TEXT,
+    refunded_amount NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    paid_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_balance_charges_payment ON payment_balance_charges(payment_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_balance_charges_pending ON payment_balance_charges(payment_id) WHERE status = 'pending';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_balance_charges_gateway_transaction
+    ON payment_balance_charges(gateway, gateway_transaction_id) WHERE gateway_transaction_id IS NOT NULL;
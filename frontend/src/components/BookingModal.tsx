@@ -67,16 +67,9 @@ export default function BookingModal({ facilityId, facilityName, onClose, onSucc
         }
     };
 
-    const isSlotInPast = (slot: AvailableSlot, date: Date): boolean => {
-        const now = new Date();
-        const [year, month, day] = date.toISOString().split('T')[0].split('-').map(Number);
-        const [startHour, startMin] = slot.start_time.split(':').map(Number);
-        
-        // Create a date object for the slot start time
-        const slotDateTime = new Date(year, month - 1, day, startHour, startMin, 0);
-        
-        // Return true if the slot is in the past
-        return slotDateTime < now;
+    const isSlotInPast = (slot: AvailableSlot): boolean => {
+        // starts_at is the exact instant, independent of the browser's time zone
+        return new Date(slot.starts_at) < new Date();
     };
 
     const handleSlotToggle = (slot: AvailableSlot) => {
@@ -148,7 +141,7 @@ export default function BookingModal({ facilityId, facilityName, onClose, onSucc
                 const currentSlot = sortedSlots[i];
 
                 // Check if current slot starts exactly when previous slot ends (consecutive)
-                if (prevSlot.end_time === currentSlot.start_time) {
+                if (prevSlot.ends_at === currentSlot.starts_at) {
                     currentGroup.push(currentSlot);
                 } else {
                     // Non-consecutive, start a new group
@@ -158,25 +151,13 @@ export default function BookingModal({ facilityId, facilityName, onClose, onSucc
             }
             consecutiveGroups.push(currentGroup);
 
-            const dateStr = selectedDate.toISOString().split('T')[0];
-
-            // Create a reservation for each consecutive group
+            // Create a reservation for each consecutive group, using the exact slot instants
+            // so the booking is made in the facility's time zone
             for (const group of consecutiveGroups) {
-                const startTime = group[0].start_time;
-                const endTime = group[group.length - 1].end_time;
-
-                // Parse times and construct local dates (not UTC)
-                const [startHour, startMin] = startTime.split(':').map(Number);
-                const [endHour, endMin] = endTime.split(':').map(Number);
-
-                const [year, month, day] = dateStr.split('-').map(Number);                // Create dates in LOCAL timezone (Sofia time)
-                const startDateTime = new Date(year, month - 1, day, startHour, startMin, 0);
-                const endDateTime = new Date(year, month - 1, day, endHour, endMin, 0);
-
                 await reservationService.create({
                     facility_id: facilityId,
-                    start_time: startDateTime.toISOString(),
-                    end_time: endDateTime.toISOString(),
+                    start_time: group[0].starts_at,
+                    end_time: group[group.length - 1].ends_at,
                 });
             }
 
@@ -228,7 +209,7 @@ export default function BookingModal({ facilityId, facilityName, onClose, onSucc
                                     <h3>Available Time Slots</h3>
                                     <div className="slots-grid">
                                         {dayAvailability.slots
-                                            .filter(slot => !isSlotInPast(slot, selectedDate))
+                                            .filter(slot => !isSlotInPast(slot))
                                             .map((slot, index) => {
                                             const slotKey = `${slot.start_time}-${slot.end_time}`;
                                             const isSelected = selectedSlots.includes(slotKey);
@@ -247,7 +228,7 @@ export default function BookingModal({ facilityId, facilityName, onClose, onSucc
                                         })}
                                     </div>
                                 </div>
-                            )}                            {selectedDate && !loadingSlots && dayAvailability && dayAvailability.is_open && (!dayAvailability.slots || dayAvailability.slots.length === 0 || dayAvailability.slots.filter(slot => !isSlotInPast(slot, selectedDate)).length === 0) && (
+                            )}                            {selectedDate && !loadingSlots && dayAvailability && dayAvailability.is_open && (!dayAvailability.slots || dayAvailability.slots.length === 0 || dayAvailability.slots.filter(slot => !isSlotInPast(slot)).length === 0) && (
                                 <div className="closed-message">
                                     <p>No time slots available for this date.</p>
                                     <p style={{fontSize: '0.9rem', color: '#999', marginTop: '0.5rem'}}>
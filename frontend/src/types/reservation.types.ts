@@ -1,12 +1,16 @@
 export interface AvailableSlot {
     start_time: string;
     end_time: string;
+    starts_at: string;
+    ends_at: string;
     price_per_hour: number;
     available: boolean;
+    remaining_units?: number;
 }
 
 export interface DayAvailability {
     date: string;
+    time_zone: string;
     is_open: boolean;
     slots: AvailableSlot[];
 }
@@ -17,9 +21,12 @@ export interface Reservation {
     facility_id: number;
     start_time: string;
     end_time: string;
-    status: 'pending' | 'confirmed' | 'cancelled' | 'completed';
+    units: number;
+    status: 'pending' | 'confirmed' | 'cancelled' | 'completed' | 'expired' | 'no_show';
     total_price: number;
     created_at: string;
+    expires_at?: string;
+    cancellation_reason?: string;
     facility_name?: string;
     facility_sport?: string;
     facility_sport_id?: number;
@@ -32,9 +39,13 @@ export interface CreateReservationRequest {
     facility_id: number;
     start_time: string;
     end_time: string;
+    units?: number;
 }
 
 export interface ReservationWithFacility extends Reservation {
     user_name?: string;
     user_email?: string;
+    user_no_show_count?: number;
+    guest_phone?: string;
+    created_by?: number;
 }